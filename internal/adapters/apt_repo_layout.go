@@ -0,0 +1,273 @@
+package adapters
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5" //nolint:gosec // MD5sum is a required Packages field, not a security boundary
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/shared"
+)
+
+// aptRepoDeb is one deb copied into the pool together with the control
+// paragraph and checksums that make up its Packages stanza.
+type aptRepoDeb struct {
+	Package      string
+	Version      string
+	Architecture string
+	Filename     string
+	Stanza       string
+}
+
+// aptRepoIndex is a generated index file below dists/<suite>/, keyed by
+// its path relative to that directory.
+type aptRepoIndex struct {
+	Path    string
+	Content []byte
+}
+
+// writeAptRepository copies every deb below debsDir into
+// pool/<component>/ under root and writes dists/<suite>/ with one
+// Packages (and Packages.gz) file per architecture plus a Release file
+// carrying their SHA256 sums. Architecture "all" debs are listed for
+// every concrete architecture; when there is none they get binary-all.
+// It returns the path of the Release file.
+func writeAptRepository(ctx context.Context, root string, debsDir string, suite string, component string, now time.Time) (string, error) {
+	debPaths, err := listDebs(debsDir)
+	if err != nil {
+		return "", err
+	}
+	if len(debPaths) == 0 {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("no deb artifacts found in %s", debsDir))
+	}
+	poolDir := filepath.Join(root, "pool", component)
+	if err := os.MkdirAll(poolDir, 0o750); err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to create pool directory").
+			WithCause(err)
+	}
+
+	var debs []aptRepoDeb
+	for _, path := range debPaths {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		filename := filepath.ToSlash(filepath.Join("pool", component, filepath.Base(path)))
+		if err := copyFile(path, filepath.Join(root, filepath.FromSlash(filename))); err != nil {
+			return "", err
+		}
+		deb, err := readAptRepoDeb(ctx, path, filename)
+		if err != nil {
+			return "", err
+		}
+		debs = append(debs, deb)
+	}
+	sort.Slice(debs, func(i, j int) bool {
+		if debs[i].Package != debs[j].Package {
+			return debs[i].Package < debs[j].Package
+		}
+		if debs[i].Version != debs[j].Version {
+			return debs[i].Version < debs[j].Version
+		}
+		return debs[i].Filename < debs[j].Filename
+	})
+
+	architectures := aptRepoArchitectures(debs)
+	var indexes []aptRepoIndex
+	for _, arch := range architectures {
+		var stanzas []string
+		for _, deb := range debs {
+			if deb.Architecture == arch || deb.Architecture == "all" {
+				stanzas = append(stanzas, deb.Stanza)
+			}
+		}
+		packages := []byte(strings.Join(stanzas, "\n"))
+		compressed, err := gzipBytes(packages)
+		if err != nil {
+			return "", err
+		}
+		dir := component + "/binary-" + arch
+		indexes = append(indexes,
+			aptRepoIndex{Path: dir + "/Packages", Content: packages},
+			aptRepoIndex{Path: dir + "/Packages.gz", Content: compressed},
+		)
+	}
+
+	distDir := filepath.Join(root, "dists", suite)
+	for _, index := range indexes {
+		path := filepath.Join(distDir, filepath.FromSlash(index.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			return "", errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to create dists directory").
+				WithCause(err)
+		}
+		if err := writeFileAtomic(path, index.Content, 0644); err != nil {
+			return "", errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to write Packages index").
+				WithCause(err)
+		}
+	}
+	releasePath := filepath.Join(distDir, "Release")
+	release := buildAptRelease(suite, component, architectures, indexes, now)
+	if err := writeFileAtomic(releasePath, []byte(release), 0644); err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to write Release file").
+			WithCause(err)
+	}
+	return releasePath, nil
+}
+
+// readAptRepoDeb reads the control paragraph of the deb at path with
+// dpkg-deb and extends it into a Packages stanza for filename.
+func readAptRepoDeb(ctx context.Context, path string, filename string) (aptRepoDeb, error) {
+	control, err := readDebControl(ctx, path)
+	if err != nil {
+		return aptRepoDeb{}, err
+	}
+	deb := aptRepoDeb{
+		Package:      controlField(control, "Package"),
+		Version:      controlField(control, "Version"),
+		Architecture: controlField(control, "Architecture"),
+		Filename:     filename,
+	}
+	if deb.Package == "" || deb.Version == "" || deb.Architecture == "" {
+		return aptRepoDeb{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("deb %s is missing Package, Version or Architecture", filepath.Base(path)))
+	}
+	size, md5sum, sha256sum, err := fileDigests(path)
+	if err != nil {
+		return aptRepoDeb{}, err
+	}
+	deb.Stanza = fmt.Sprintf("%s\nFilename: %s\nSize: %d\nMD5sum: %s\nSHA256: %s\n",
+		control, filename, size, md5sum, sha256sum)
+	return deb, nil
+}
+
+// readDebControl returns the control paragraph of the deb at path, as
+// printed by dpkg-deb, without its trailing newline.
+func readDebControl(ctx context.Context, path string) (string, error) {
+	cmd := exec.CommandContext(ctx, "dpkg-deb", "-f", path)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg(fmt.Sprintf("failed to read control fields of %s", filepath.Base(path))).
+			WithCause(shared.CommandError(output, err))
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
+
+// controlField returns the value of a single-line field of a control
+// paragraph, or "" when it is absent.
+func controlField(control string, name string) string {
+	for _, line := range strings.Split(control, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// aptRepoArchitectures returns the sorted concrete architectures of
+// debs, or just "all" when every deb is architecture-independent.
+func aptRepoArchitectures(debs []aptRepoDeb) []string {
+	seen := map[string]struct{}{}
+	var archs []string
+	for _, deb := range debs {
+		if deb.Architecture == "all" {
+			continue
+		}
+		if _, ok := seen[deb.Architecture]; ok {
+			continue
+		}
+		seen[deb.Architecture] = struct{}{}
+		archs = append(archs, deb.Architecture)
+	}
+	if len(archs) == 0 {
+		return []string{"all"}
+	}
+	sort.Strings(archs)
+	return archs
+}
+
+// buildAptRelease renders the Release file of a suite listing the
+// indexes with their MD5 and SHA256 sums.
+func buildAptRelease(suite string, component string, architectures []string, indexes []aptRepoIndex, now time.Time) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Suite: %s\n", suite)
+	fmt.Fprintf(&b, "Codename: %s\n", suite)
+	fmt.Fprintf(&b, "Date: %s\n", now.UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Architectures: %s\n", strings.Join(architectures, " "))
+	fmt.Fprintf(&b, "Components: %s\n", component)
+	b.WriteString("MD5Sum:\n")
+	for _, index := range indexes {
+		sum := md5.Sum(index.Content) //nolint:gosec // see import
+		fmt.Fprintf(&b, " %s %d %s\n", hex.EncodeToString(sum[:]), len(index.Content), index.Path)
+	}
+	b.WriteString("SHA256:\n")
+	for _, index := range indexes {
+		sum := sha256.Sum256(index.Content)
+		fmt.Fprintf(&b, " %s %d %s\n", hex.EncodeToString(sum[:]), len(index.Content), index.Path)
+	}
+	return b.String()
+}
+
+// fileDigests returns the size, MD5 and SHA256 of the file at path.
+func fileDigests(path string) (int64, string, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", "", errbuilder.New().
+			WithCode(errbuilder.CodeNotFound).
+			WithMsg("failed to open deb").
+			WithCause(err)
+	}
+	defer file.Close()
+	md5Hash := md5.New() //nolint:gosec // see import
+	sha256Hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), file)
+	if err != nil {
+		return 0, "", "", errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to hash deb").
+			WithCause(err)
+	}
+	return size, hex.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to compress Packages index").
+			WithCause(err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to compress Packages index").
+			WithCause(err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,92 @@
+package adapters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+// buildTestDeb builds a minimal deb into debsDir; fields are extra
+// control lines such as "Depends: libbar".
+func buildTestDeb(t *testing.T, debsDir string, name string, version string, arch string, fields ...string) {
+	t.Helper()
+	staging := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(staging, "DEBIAN"), 0o755))
+	control := fmt.Sprintf("Package: %s\nVersion: %s\nArchitecture: %s\nMaintainer: Test <test@example.com>\n", name, version, arch)
+	for _, field := range fields {
+		control += field + "\n"
+	}
+	control += fmt.Sprintf("Description: %s test package\n", name)
+	require.NoError(t, os.WriteFile(filepath.Join(staging, "DEBIAN", "control"), []byte(control), 0o644))
+	output := filepath.Join(debsDir, fmt.Sprintf("%s_%s_%s.deb", name, version, arch))
+	out, err := exec.Command("dpkg-deb", "--root-owner-group", "--build", staging, output).CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestRepoSnapshotFileAdapterPublishWritesAptRepository(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb not available")
+	}
+	debsDir := t.TempDir()
+	buildTestDeb(t, debsDir, "libfoo", "1.0.0", "amd64")
+	buildTestDeb(t, debsDir, "python3-bar", "2.0.0", "all")
+
+	dir := t.TempDir()
+	adapter := NewRepoSnapshotFileAdapter(dir).WithAptRepository(debsDir, "stable", "")
+	adapter.Now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+	require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+
+	for _, name := range []string{"libfoo_1.0.0_amd64.deb", "python3-bar_2.0.0_all.deb"} {
+		_, err := os.Stat(filepath.Join(dir, "pool", "main", name))
+		require.NoError(t, err, name)
+	}
+
+	packages, err := os.ReadFile(filepath.Join(dir, "dists", "stable", "main", "binary-amd64", "Packages"))
+	require.NoError(t, err)
+	var got []string
+	for _, line := range strings.Split(string(packages), "\n") {
+		if strings.HasPrefix(line, "Package:") || strings.HasPrefix(line, "Filename:") {
+			got = append(got, line)
+		}
+	}
+	want := []string{
+		"Package: libfoo",
+		"Filename: pool/main/libfoo_1.0.0_amd64.deb",
+		"Package: python3-bar",
+		"Filename: pool/main/python3-bar_2.0.0_all.deb",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected Packages entries (-want +got):\n%s", diff)
+	}
+	_, err = os.Stat(filepath.Join(dir, "dists", "stable", "main", "binary-amd64", "Packages.gz"))
+	require.NoError(t, err)
+
+	release, err := os.ReadFile(filepath.Join(dir, "dists", "stable", "Release"))
+	require.NoError(t, err)
+	sum := sha256.Sum256(packages)
+	require.Contains(t, string(release), "Suite: stable\n")
+	require.Contains(t, string(release), "Date: Fri, 02 Jan 2026 03:04:05 +0000\n")
+	require.Contains(t, string(release), "Architectures: amd64\n")
+	require.Contains(t, string(release), "Components: main\n")
+	require.Contains(t, string(release), fmt.Sprintf(" %s %d main/binary-amd64/Packages\n", hex.EncodeToString(sum[:]), len(packages)))
+}
+
+func TestRepoSnapshotFileAdapterPublishAptRepositoryWithoutDebs(t *testing.T) {
+	dir := t.TempDir()
+	adapter := NewRepoSnapshotFileAdapter(dir).WithAptRepository(t.TempDir(), "stable", "main")
+	err := adapter.Publish(t.Context(), "snap-1")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no deb artifacts found")
+
+	_, statErr := os.Stat(filepath.Join(dir, "snapshots", "snap-1.snapshot"))
+	require.True(t, os.IsNotExist(statErr))
+}
@@ -0,0 +1,40 @@
+package adapters
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFileAtomicLeavesNoPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "apt.lock")
+	require.NoError(t, writeFileAtomic(path, []byte("libfoo=1.0"), 0644))
+
+	err := writeFileAtomicFunc(path, 0644, func(w io.Writer) error {
+		if _, err := w.Write([]byte("libfoo=2.")); err != nil {
+			return err
+		}
+		return errors.New("disk full")
+	})
+	require.ErrorContains(t, err, "disk full")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "libfoo=1.0", string(data))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "temporary file left behind")
+
+	missing := filepath.Join(dir, "resolution.report")
+	err = writeFileAtomicFunc(missing, 0644, func(io.Writer) error { return errors.New("interrupted") })
+	require.Error(t, err)
+	require.NoFileExists(t, missing)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
@@ -42,14 +42,14 @@ func (a CompatibilityOutputAdapter) WriteGetDependencies(resolved []types.Resolv
 			WithCause(err)
 	}
 	aptPath := filepath.Join(a.Dir, "get-dependencies.apt")
-	if err := os.WriteFile(aptPath, []byte(strings.Join(aptLines, "\n")), 0644); err != nil {
+	if err := writeFileAtomic(aptPath, []byte(strings.Join(aptLines, "\n")), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write get-dependencies apt output").
 			WithCause(err)
 	}
 	pipPath := filepath.Join(a.Dir, "get-dependencies.pip")
-	if err := os.WriteFile(pipPath, []byte(strings.Join(pipLines, "\n")), 0644); err != nil {
+	if err := writeFileAtomic(pipPath, []byte(strings.Join(pipLines, "\n")), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write get-dependencies pip output").
@@ -90,7 +90,7 @@ func (a CompatibilityOutputAdapter) WriteRosdepMapping(resolved []types.Resolved
 			WithCause(err)
 	}
 	path := filepath.Join(a.Dir, "rosdep-mapping.yaml")
-	if err := os.WriteFile(path, []byte(builder.String()), 0644); err != nil {
+	if err := writeFileAtomic(path, []byte(builder.String()), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write rosdep mapping output").
@@ -0,0 +1,101 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/shared"
+)
+
+const environmentProbeTimeout = 10 * time.Second
+
+type EnvironmentProbeAdapter struct {
+	httpClient *http.Client
+}
+
+func NewEnvironmentProbeAdapter() EnvironmentProbeAdapter {
+	return EnvironmentProbeAdapter{
+		httpClient: newHTTPClient(environmentProbeTimeout, normalizeHTTPTransportConfig(0, 0, false)),
+	}
+}
+
+func (a EnvironmentProbeAdapter) ToolVersion(ctx context.Context, name string, args ...string) (string, error) {
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeNotFound).
+			WithMsg(fmt.Sprintf("%s not found in PATH", name)).
+			WithCause(err)
+	}
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg(fmt.Sprintf("failed to run %s %s", name, strings.Join(args, " "))).
+			WithCause(shared.CommandError(output, err))
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(line), nil
+}
+
+func (a EnvironmentProbeAdapter) CheckWritable(dir string) error {
+	return checkDirWritable(dir)
+}
+
+// checkDirWritable creates dir when missing and verifies that a file can
+// be created in it.
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodePermissionDenied).
+			WithMsg(fmt.Sprintf("failed to create %s", dir)).
+			WithCause(err)
+	}
+	file, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodePermissionDenied).
+			WithMsg(fmt.Sprintf("%s is not writable", dir)).
+			WithCause(err)
+	}
+	name := file.Name()
+	_ = file.Close()
+	return os.Remove(name)
+}
+
+// CheckEndpoint sends a HEAD request to endpoint. Any response below 500,
+// including authentication failures, proves the endpoint is reachable.
+func (a EnvironmentProbeAdapter) CheckEndpoint(ctx context.Context, endpoint string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, endpoint, nil)
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("invalid endpoint %q", endpoint)).
+			WithCause(err)
+	}
+	client := a.httpClient
+	if client == nil {
+		client = NewEnvironmentProbeAdapter().httpClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeUnavailable).
+			WithMsg(fmt.Sprintf("%s is unreachable", endpoint)).
+			WithCause(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeUnavailable).
+			WithMsg(fmt.Sprintf("%s answered %s", endpoint, resp.Status))
+	}
+	return nil
+}
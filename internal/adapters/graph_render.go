@@ -0,0 +1,130 @@
+package adapters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/types"
+)
+
+const (
+	GraphFormatDOT     = "dot"
+	GraphFormatMermaid = "mermaid"
+)
+
+// RenderDependencyGraph renders graph as Graphviz DOT or a Mermaid
+// flowchart. Selected versions are filled and root candidates outlined
+// so the path to any package in the selection can be traced.
+func RenderDependencyGraph(graph types.DependencyGraph, format string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", GraphFormatDOT:
+		return renderGraphDOT(graph), nil
+	case GraphFormatMermaid:
+		return renderGraphMermaid(graph), nil
+	default:
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("unsupported graph format %q (expected %s or %s)", format, GraphFormatDOT, GraphFormatMermaid))
+	}
+}
+
+func renderGraphDOT(graph types.DependencyGraph) string {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	if graph.Unsatisfiable {
+		fmt.Fprintf(&b, "  label=%s;\n", dotQuote(graphConflictLabel(graph)))
+		b.WriteString("  labelloc=t;\n")
+		b.WriteString("  fontcolor=red;\n")
+	}
+	for _, node := range graph.Nodes {
+		attrs := []string{fmt.Sprintf("label=%s", dotQuote(node.Package+"\n"+node.Version))}
+		if node.Selected {
+			attrs = append(attrs, "style=filled", "fillcolor=palegreen")
+		} else {
+			attrs = append(attrs, "color=gray", "fontcolor=gray")
+		}
+		if node.Root {
+			attrs = append(attrs, "penwidth=2")
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(graphNodeID(node.Package, node.Version)), strings.Join(attrs, ", "))
+	}
+	for _, edge := range graph.Edges {
+		attrs := []string{fmt.Sprintf("label=%s", dotQuote(edge.Relation))}
+		if edge.PreDepends {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n",
+			dotQuote(graphNodeID(edge.FromPackage, edge.FromVersion)),
+			dotQuote(graphNodeID(edge.ToPackage, edge.ToVersion)),
+			strings.Join(attrs, ", "))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func renderGraphMermaid(graph types.DependencyGraph) string {
+	ids := make(map[string]string, len(graph.Nodes))
+	var selected []string
+	var b strings.Builder
+	b.WriteString("graph LR\n")
+	for i, node := range graph.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[graphNodeID(node.Package, node.Version)] = id
+		shape := `["%s"]`
+		if node.Root {
+			shape = `[["%s"]]`
+		}
+		fmt.Fprintf(&b, "  %s"+shape+"\n", id, mermaidEscape(node.Package+" "+node.Version))
+		if node.Selected {
+			selected = append(selected, id)
+		}
+	}
+	for _, edge := range graph.Edges {
+		from := ids[graphNodeID(edge.FromPackage, edge.FromVersion)]
+		to := ids[graphNodeID(edge.ToPackage, edge.ToVersion)]
+		arrow := "-->"
+		if edge.PreDepends {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s|\"%s\"| %s\n", from, arrow, mermaidEscape(edge.Relation), to)
+	}
+	if len(selected) > 0 {
+		b.WriteString("  classDef selected fill:#b7e4c7,stroke:#2d6a4f;\n")
+		fmt.Fprintf(&b, "  class %s selected;\n", strings.Join(selected, ","))
+	}
+	if graph.Unsatisfiable {
+		fmt.Fprintf(&b, "  conflict[\"%s\"]\n", mermaidEscape(graphConflictLabel(graph)))
+		b.WriteString("  classDef conflict fill:#ffccd5,stroke:#c9184a;\n")
+		b.WriteString("  class conflict conflict;\n")
+	}
+	return b.String()
+}
+
+// graphConflictLabel describes an unsatisfiable graph and its unsat core.
+func graphConflictLabel(graph types.DependencyGraph) string {
+	label := "apt solver found no satisfiable solution"
+	if len(graph.Conflict) > 0 {
+		label += "; conflict likely involves: " + strings.Join(graph.Conflict, ", ")
+	}
+	return label
+}
+
+func graphNodeID(pkg string, version string) string {
+	return pkg + "=" + version
+}
+
+// dotQuote returns value as a DOT double-quoted string.
+func dotQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// mermaidEscape replaces the characters that terminate a quoted Mermaid
+// label with their entity codes.
+func mermaidEscape(value string) string {
+	return strings.ReplaceAll(value, `"`, "#quot;")
+}
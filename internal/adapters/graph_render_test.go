@@ -0,0 +1,77 @@
+package adapters
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/types"
+)
+
+func testDependencyGraph() types.DependencyGraph {
+	return types.DependencyGraph{
+		Roots: []string{"app"},
+		Nodes: []types.DependencyGraphNode{
+			{Package: "app", Version: "1.0", Root: true, Selected: true},
+			{Package: "postfix", Version: "3.6"},
+		},
+		Edges: []types.DependencyGraphEdge{
+			{FromPackage: "app", FromVersion: "1.0", ToPackage: "postfix", ToVersion: "3.6", Relation: "mail-transport-agent", PreDepends: true},
+		},
+	}
+}
+
+func TestRenderDependencyGraphDOT(t *testing.T) {
+	got, err := RenderDependencyGraph(testDependencyGraph(), GraphFormatDOT)
+	require.NoError(t, err)
+	want := `digraph dependencies {
+  rankdir=LR;
+  node [shape=box];
+  "app=1.0" [label="app\n1.0", style=filled, fillcolor=palegreen, penwidth=2];
+  "postfix=3.6" [label="postfix\n3.6", color=gray, fontcolor=gray];
+  "app=1.0" -> "postfix=3.6" [label="mail-transport-agent", style=dashed];
+}
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected dot output (-want +got):\n%s", diff)
+	}
+}
+
+func TestRenderDependencyGraphMermaid(t *testing.T) {
+	got, err := RenderDependencyGraph(testDependencyGraph(), GraphFormatMermaid)
+	require.NoError(t, err)
+	want := `graph LR
+  n0[["app 1.0"]]
+  n1["postfix 3.6"]
+  n0 -.->|"mail-transport-agent"| n1
+  classDef selected fill:#b7e4c7,stroke:#2d6a4f;
+  class n0 selected;
+`
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected mermaid output (-want +got):\n%s", diff)
+	}
+}
+
+func TestRenderDependencyGraphShowsConflict(t *testing.T) {
+	graph := testDependencyGraph()
+	graph.Nodes[0].Selected = false
+	graph.Unsatisfiable = true
+	graph.Conflict = []string{"app", "app=1.0 depends on postfix (>= 4)"}
+	label := "apt solver found no satisfiable solution; conflict likely involves: app, app=1.0 depends on postfix (>= 4)"
+
+	dot, err := RenderDependencyGraph(graph, GraphFormatDOT)
+	require.NoError(t, err)
+	require.Contains(t, dot, "  label=\""+label+"\";\n  labelloc=t;\n")
+	require.NotContains(t, dot, "palegreen")
+
+	mermaid, err := RenderDependencyGraph(graph, GraphFormatMermaid)
+	require.NoError(t, err)
+	require.Contains(t, mermaid, "  conflict[\""+label+"\"]\n")
+	require.NotContains(t, mermaid, "class n0 selected")
+}
+
+func TestRenderDependencyGraphRejectsUnknownFormat(t *testing.T) {
+	_, err := RenderDependencyGraph(testDependencyGraph(), "svg")
+	require.Error(t, err)
+}
@@ -0,0 +1,156 @@
+package adapters
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/shared"
+)
+
+const defaultHTTPMaxIdleConnsPerHost = 8
+const defaultHTTPIdleConnTimeout = 90 * time.Second
+
+// httpTransportConfig holds the connection pooling knobs applied to the
+// shared client used by repo-index fetches and publish uploads.
+type httpTransportConfig struct {
+	maxIdleConnsPerHost int
+	idleConnTimeout     time.Duration
+	// disableHTTP2 limits the client to HTTP/1.1. The zero value keeps
+	// HTTP/2, which the cloned transport's custom dialer would otherwise
+	// turn off.
+	disableHTTP2 bool
+	// proxy replaces the HTTP_PROXY/HTTPS_PROXY environment settings.
+	proxy *url.URL
+	// rootCAs replaces the system certificate pool.
+	rootCAs *x509.CertPool
+}
+
+func normalizeHTTPTransportConfig(maxIdleConnsPerHost int, idleConnTimeoutSec int, disableHTTP2 bool) httpTransportConfig {
+	maxIdle := maxIdleConnsPerHost
+	if maxIdle <= 0 {
+		maxIdle = defaultHTTPMaxIdleConnsPerHost
+	}
+	idleTimeout := time.Duration(idleConnTimeoutSec) * time.Second
+	if idleTimeout <= 0 {
+		idleTimeout = defaultHTTPIdleConnTimeout
+	}
+	return httpTransportConfig{
+		maxIdleConnsPerHost: maxIdle,
+		idleConnTimeout:     idleTimeout,
+		disableHTTP2:        disableHTTP2,
+	}
+}
+
+// withProxyAndCA routes cfg through proxyURL and makes it trust the PEM
+// certificates in caBundle on top of the system roots. Empty values keep
+// the defaults: the proxy from the environment and the system roots.
+func (cfg httpTransportConfig) withProxyAndCA(proxyURL string, caBundle string) (httpTransportConfig, error) {
+	if value := strings.TrimSpace(proxyURL); value != "" {
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return cfg, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("http proxy %q must be an absolute URL (e.g., http://proxy.example.com:3128)", value))
+		}
+		cfg.proxy = parsed
+	}
+	if path := strings.TrimSpace(caBundle); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, errbuilder.New().
+				WithCode(errbuilder.CodeNotFound).
+				WithMsg("failed to read CA bundle: " + path).
+				WithCause(err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return cfg, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg("no PEM certificates found in CA bundle: " + path)
+		}
+		cfg.rootCAs = pool
+	}
+	return cfg, nil
+}
+
+// newHTTPClient builds a client whose transport is cloned from the
+// default transport and tuned with the given pooling configuration, so
+// that concurrent workers reuse keep-alive connections to the same host.
+func newHTTPClient(timeout time.Duration, cfg httpTransportConfig) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.maxIdleConnsPerHost
+	if transport.MaxIdleConns < cfg.maxIdleConnsPerHost {
+		transport.MaxIdleConns = cfg.maxIdleConnsPerHost
+	}
+	transport.IdleConnTimeout = cfg.idleConnTimeout
+	transport.ForceAttemptHTTP2 = !cfg.disableHTTP2
+	if cfg.proxy != nil {
+		transport.Proxy = http.ProxyURL(cfg.proxy)
+	}
+	if cfg.rootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{RootCAs: cfg.rootCAs, MinVersion: tls.VersionTLS12}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+// applyUserAgent sets the User-Agent header on an outbound request,
+// falling back to the bare product token when no value is configured.
+func applyUserAgent(req *http.Request, userAgent string) {
+	value := strings.TrimSpace(userAgent)
+	if value == "" {
+		value = shared.UserAgent("")
+	}
+	req.Header.Set("User-Agent", value)
+}
+
+// maxHTTPRedirects matches the redirect limit of the default client.
+const maxHTTPRedirects = 10
+
+// authRedirectPolicy returns a CheckRedirect function that calls
+// applyAuth again when a request is redirected to one of hosts or a
+// subdomain of one. net/http drops the Authorization header on
+// redirects to another host, which breaks mirrors that hand downloads
+// off to a CDN. Auth is never re-applied on an https to http downgrade.
+func authRedirectPolicy(hosts []string, applyAuth func(*http.Request)) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxHTTPRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+		if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+			return nil
+		}
+		if redirectHostAllowed(req.URL.Hostname(), hosts) {
+			applyAuth(req)
+		}
+		return nil
+	}
+}
+
+func redirectHostAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(host)
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package adapters
+
+import (
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClientAppliesTransportConfig(t *testing.T) {
+	cfg := normalizeHTTPTransportConfig(32, 15, false)
+	client := newHTTPClient(5*time.Second, cfg)
+
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Equal(t, 5*time.Second, client.Timeout)
+	require.Equal(t, 32, transport.MaxIdleConnsPerHost)
+	require.GreaterOrEqual(t, transport.MaxIdleConns, 32)
+	require.Equal(t, 15*time.Second, transport.IdleConnTimeout)
+	require.True(t, transport.ForceAttemptHTTP2)
+
+	transport, ok = newHTTPClient(5*time.Second, normalizeHTTPTransportConfig(32, 15, true)).Transport.(*http.Transport)
+	require.True(t, ok)
+	require.False(t, transport.ForceAttemptHTTP2)
+}
+
+func TestNormalizeHTTPTransportConfigDefaults(t *testing.T) {
+	cfg := normalizeHTTPTransportConfig(0, 0, false)
+	require.Equal(t, defaultHTTPMaxIdleConnsPerHost, cfg.maxIdleConnsPerHost)
+	require.Equal(t, defaultHTTPIdleConnTimeout, cfg.idleConnTimeout)
+	require.False(t, cfg.disableHTTP2)
+}
+
+func TestProGetAdapterAppliesTransportConfig(t *testing.T) {
+	adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+		Endpoint:            "https://proget.example.com",
+		Feed:                "avular",
+		TimeoutSec:          7,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeoutSec:  30,
+	})
+
+	client := adapter.httpClient()
+	transport, ok := client.Transport.(*http.Transport)
+	require.True(t, ok)
+	require.Equal(t, 7*time.Second, client.Timeout)
+	require.Equal(t, 16, transport.MaxIdleConnsPerHost)
+	require.Equal(t, 30*time.Second, transport.IdleConnTimeout)
+	require.True(t, transport.ForceAttemptHTTP2)
+}
+
+func TestRedirectHostAllowed(t *testing.T) {
+	allowed := []string{"cdn.example.com", " Mirror.Example.org "}
+	require.True(t, redirectHostAllowed("cdn.example.com", allowed))
+	require.True(t, redirectHostAllowed("eu.cdn.example.com", allowed))
+	require.True(t, redirectHostAllowed("mirror.example.org", allowed))
+	require.False(t, redirectHostAllowed("example.com", allowed))
+	require.False(t, redirectHostAllowed("evilcdn.example.com", allowed))
+}
+
+func TestRepoClientTrustsCustomCABundle(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	// The untrusted fetch below fails the handshake on purpose.
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(bundle, certPEM, 0644))
+
+	fetch := func(cfg httpTransportConfig) error {
+		client := &repoClient{httpCfg: normalizeHTTPConfig(5, 1, 1), httpClient: newHTTPClient(5*time.Second, cfg)}
+		_, _, _, err := client.fetchURL(t.Context(), server.URL+"/Packages")
+		return err
+	}
+	require.Error(t, fetch(normalizeHTTPTransportConfig(0, 0, false)))
+
+	cfg, err := normalizeHTTPTransportConfig(0, 0, false).withProxyAndCA("", bundle)
+	require.NoError(t, err)
+	require.NoError(t, fetch(cfg))
+
+	_, err = normalizeHTTPTransportConfig(0, 0, false).withProxyAndCA("", filepath.Join(t.TempDir(), "missing.pem"))
+	require.ErrorContains(t, err, "failed to read CA bundle")
+}
+
+func TestRepoClientUsesConfiguredProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer proxy.Close()
+
+	cfg, err := normalizeHTTPTransportConfig(0, 0, false).withProxyAndCA(proxy.URL, "")
+	require.NoError(t, err)
+	client := &repoClient{httpCfg: normalizeHTTPConfig(5, 1, 1), httpClient: newHTTPClient(5*time.Second, cfg)}
+	_, body, _, err := client.fetchURL(t.Context(), "http://packages.example.invalid/dists/dev/Release")
+	require.NoError(t, err)
+	require.Equal(t, "ok", string(body))
+	require.Equal(t, "http://packages.example.invalid/dists/dev/Release", proxied)
+
+	_, err = normalizeHTTPTransportConfig(0, 0, false).withProxyAndCA("proxy.example.com", "")
+	require.ErrorContains(t, err, "must be an absolute URL")
+}
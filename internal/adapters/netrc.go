@@ -0,0 +1,133 @@
+package adapters
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// netrcLogin is the login and password of one .netrc machine entry.
+type netrcLogin struct {
+	login    string
+	password string
+}
+
+// netrcFile holds the machine entries of a .netrc file together with
+// its default entry, if any.
+type netrcFile struct {
+	machines map[string]netrcLogin
+	fallback *netrcLogin
+}
+
+// lookup returns the credentials for host: its machine entry, else the
+// default entry.
+func (n *netrcFile) lookup(host string) (netrcLogin, bool) {
+	if n == nil {
+		return netrcLogin{}, false
+	}
+	if login, ok := n.machines[strings.ToLower(host)]; ok {
+		return login, true
+	}
+	if n.fallback != nil {
+		return *n.fallback, true
+	}
+	return netrcLogin{}, false
+}
+
+// netrcPath returns the .netrc file pip and curl would read: $NETRC,
+// else ~/.netrc.
+func netrcPath() string {
+	if path := strings.TrimSpace(os.Getenv("NETRC")); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// loadNetrc reads the .netrc file at path. A missing or unreadable file
+// yields nil, so that credentials only ever come from it when present.
+func loadNetrc(path string) *netrcFile {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn().Err(err).Str("path", path).Msg("failed to read netrc file")
+		}
+		return nil
+	}
+	return parseNetrc(string(data))
+}
+
+// parseNetrc parses the machine, default, login and password tokens of
+// a .netrc file. Account tokens are ignored and macdef bodies, which run
+// until the next blank line, are skipped.
+func parseNetrc(content string) *netrcFile {
+	file := &netrcFile{machines: map[string]netrcLogin{}}
+	var current *netrcLogin
+	var host string
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if host == "" {
+			login := *current
+			file.fallback = &login
+		} else if _, ok := file.machines[host]; !ok {
+			file.machines[host] = *current
+		}
+		current = nil
+	}
+	lines := strings.Split(content, "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			token := fields[j]
+			if strings.HasPrefix(token, "#") {
+				break
+			}
+			next := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+				return ""
+			}
+			switch token {
+			case "machine":
+				flush()
+				host = strings.ToLower(next())
+				current = &netrcLogin{}
+			case "default":
+				flush()
+				host = ""
+				current = &netrcLogin{}
+			case "login":
+				if value := next(); current != nil {
+					current.login = value
+				}
+			case "password":
+				if value := next(); current != nil {
+					current.password = value
+				}
+			case "account":
+				next()
+			case "macdef":
+				flush()
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(fields)
+			}
+		}
+	}
+	flush()
+	return file
+}
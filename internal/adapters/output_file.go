@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 	"strings"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
+	"gopkg.in/yaml.v3"
 
 	"avular-packages/internal/ports"
 	"avular-packages/internal/types"
@@ -33,7 +35,7 @@ func (a OutputFileAdapter) WriteAptLock(entries []types.AptLockEntry) error {
 	for _, entry := range entries {
 		lines = append(lines, fmt.Sprintf("%s=%s", entry.Package, entry.Version))
 	}
-	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")), 0644)
 }
 
 func (a OutputFileAdapter) WriteAptPreferences(entries []types.AptLockEntry) error {
@@ -58,7 +60,7 @@ func (a OutputFileAdapter) WriteAptPreferences(entries []types.AptLockEntry) err
 			builder.WriteString("\n")
 		}
 	}
-	return os.WriteFile(path, []byte(builder.String()), 0644)
+	return writeFileAtomic(path, []byte(builder.String()), 0644)
 }
 
 func (a OutputFileAdapter) WriteAptInstallList(entries []types.AptLockEntry) error {
@@ -75,7 +77,7 @@ func (a OutputFileAdapter) WriteAptInstallList(entries []types.AptLockEntry) err
 		parts = append(parts, fmt.Sprintf("%s=%s", entry.Package, entry.Version))
 	}
 	line := strings.TrimSpace(fmt.Sprintf("apt-get install -y %s", strings.Join(parts, " ")))
-	return os.WriteFile(path, []byte(line), 0644)
+	return writeFileAtomic(path, []byte(line), 0644)
 }
 
 func (a OutputFileAdapter) WriteBundleManifest(entries []types.BundleManifestEntry) error {
@@ -100,7 +102,7 @@ func (a OutputFileAdapter) WriteBundleManifest(entries []types.BundleManifestEnt
 	for _, entry := range ordered {
 		lines = append(lines, fmt.Sprintf("%s,%s,%s,%s", entry.Group, entry.Mode, entry.Package, entry.Version))
 	}
-	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")), 0644)
 }
 
 func (a OutputFileAdapter) WriteSnapshotIntent(intent types.SnapshotIntent) error {
@@ -117,7 +119,7 @@ func (a OutputFileAdapter) WriteSnapshotIntent(intent types.SnapshotIntent) erro
 		intent.CreatedAt,
 		intent.SigningKey,
 	)
-	return os.WriteFile(path, []byte(content), 0644)
+	return writeFileAtomic(path, []byte(content), 0644)
 }
 
 func (a OutputFileAdapter) WriteSnapshotSources(intent types.SnapshotIntent, baseURL string, component string, archs []string) error {
@@ -155,7 +157,7 @@ func (a OutputFileAdapter) WriteSnapshotSources(intent types.SnapshotIntent, bas
 		snapshotID,
 		trimmedComponent,
 	)
-	return os.WriteFile(path, []byte(content), 0644)
+	return writeFileAtomic(path, []byte(content), 0644)
 }
 
 func (a OutputFileAdapter) WriteResolutionReport(report types.ResolutionReport) error {
@@ -191,7 +193,7 @@ func (a OutputFileAdapter) WriteResolutionReport(report types.ResolutionReport)
 			record.ExpiresAt,
 		))
 	}
-	return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+	return writeFileAtomic(path, []byte(strings.Join(lines, "\n")), 0644)
 }
 
 func normalizeArchs(archs []string) []string {
@@ -212,6 +214,52 @@ func normalizeArchs(archs []string) []string {
 	return normalized
 }
 
+// WriteCycloneDXSBOM writes the CycloneDX document to sbom.cdx.json.
+func (a OutputFileAdapter) WriteCycloneDXSBOM(bom types.CycloneDXBOM) error {
+	path, err := a.ensurePath("sbom.cdx.json")
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to marshal cyclonedx sbom").
+			WithCause(err)
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+func (a OutputFileAdapter) WriteResolveSummary(summary types.ResolveSummary) error {
+	path, err := a.ensurePath("resolve.json")
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to marshal resolve summary").
+			WithCause(err)
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
+
+func (a OutputFileAdapter) WriteEffectiveConfig(config types.EffectiveConfig) error {
+	path, err := a.ensurePath("effective-config.yaml")
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to marshal effective config").
+			WithCause(err)
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
 func (a OutputFileAdapter) ensurePath(filename string) (string, error) {
 	if a.Dir == "" {
 		return "", errbuilder.New().
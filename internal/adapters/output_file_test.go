@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"encoding/json"
 	"os"
 	"path/filepath"
 	"strings"
@@ -97,3 +98,51 @@ func TestOutputFileAdapterFormats(t *testing.T) {
 	})
 	require.NoError(t, err)
 }
+
+func TestOutputFileAdapterWritesCycloneDXSBOM(t *testing.T) {
+	dir := t.TempDir()
+	adapter := NewOutputFileAdapter(dir)
+
+	bom := types.CycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:00000000-0000-8000-8000-000000000000",
+		Version:      1,
+		Components: []types.CycloneDXComponent{
+			{Type: "library", Name: "libfoo", Version: "1.0", PURL: "pkg:deb/ubuntu/libfoo@1.0"},
+		},
+	}
+	require.NoError(t, adapter.WriteCycloneDXSBOM(bom))
+
+	data, err := os.ReadFile(filepath.Join(dir, "sbom.cdx.json"))
+	require.NoError(t, err)
+	var got types.CycloneDXBOM
+	require.NoError(t, json.Unmarshal(data, &got))
+	if diff := cmp.Diff(bom, got); diff != "" {
+		t.Fatalf("unexpected sbom content (-want +got):\n%s", diff)
+	}
+}
+
+func TestOutputFileAdapterWritesResolveSummary(t *testing.T) {
+	dir := t.TempDir()
+	adapter := NewOutputFileAdapter(dir)
+
+	summary := types.ResolveSummary{
+		SchemaVersion: types.ResolveSummarySchemaVersion,
+		SnapshotID:    "snap-1",
+		AptLocks:      []types.ResolveSummaryLock{{Package: "libfoo", Version: "1.0"}},
+		Dependencies:  []types.ResolveSummaryDependency{{Type: "apt", Package: "libfoo", Version: "1.0"}},
+		Resolutions:   []types.ResolveSummaryRecord{},
+	}
+	require.NoError(t, adapter.WriteResolveSummary(summary))
+
+	data, err := os.ReadFile(filepath.Join(dir, "resolve.json"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"schema_version": 1,
+		"snapshot_id": "snap-1",
+		"apt_locks": [{"package": "libfoo", "version": "1.0"}],
+		"dependencies": [{"type": "apt", "package": "libfoo", "version": "1.0"}],
+		"resolutions": []
+	}`, string(data))
+}
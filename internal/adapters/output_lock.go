@@ -0,0 +1,46 @@
+package adapters
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+)
+
+// LockOutputDir takes an exclusive advisory lock on dir, creating it if
+// needed, so that concurrent resolve or build runs cannot interleave
+// their writes. It fails immediately when another run holds the lock.
+// The returned function releases it.
+func LockOutputDir(dir string) (func(), error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to create output directory").
+			WithCause(err)
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to open output directory for locking").
+			WithCause(err)
+	}
+	held, err := tryLockFile(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to lock output directory").
+			WithCause(err)
+	}
+	if !held {
+		_ = file.Close()
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("output directory %s is in use by another run", dir))
+	}
+	return func() {
+		_ = unlockFile(file)
+		_ = file.Close()
+	}, nil
+}
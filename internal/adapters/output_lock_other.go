@@ -0,0 +1,15 @@
+//go:build !unix
+
+package adapters
+
+import "os"
+
+// tryLockFile is a no-op where flock is unavailable; concurrent runs
+// are not guarded there.
+func tryLockFile(*os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(*os.File) error {
+	return nil
+}
@@ -1,6 +1,7 @@
 package adapters
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -9,10 +10,18 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
+	debversion "github.com/knqyf263/go-deb-version"
+	"github.com/rs/zerolog/log"
 
 	"avular-packages/internal/ports"
 	"avular-packages/internal/shared"
@@ -21,10 +30,213 @@ import (
 
 type PackageBuildAdapter struct {
 	PipIndexURL string
+	Workers     int
+	Control     types.DebControl
+	// MaintainerScripts maps packaging group names to script directories.
+	MaintainerScripts map[string]string
+	// Compression is the dpkg-deb compressor (xz, gzip, zstd or none).
+	Compression      string
+	CompressionLevel int
+	// LogDir receives one build log per deb and group resolve.
+	LogDir string
+	// FailureSummary is the build-failures.json path.
+	FailureSummary string
+	// TempDir is the base directory for staging (empty = OS temp dir).
+	TempDir string
+	// Transactional builds into a sibling directory and moves the debs
+	// into the output directory only once every build succeeded.
+	Transactional bool
+	// Architecture is the Debian architecture of debs with platform
+	// wheels (empty = host architecture).
+	Architecture string
+	// PythonVersion is the target python3 minor version, e.g. 3.12
+	// (empty = version-independent python3 layout).
+	PythonVersion string
+	// CacheDir and CacheTTLMinutes configure the pip resolve cache
+	// (empty dir or TTL <= 0 = no caching).
+	CacheDir        string
+	CacheTTLMinutes int
+	// Offline installs pip packages only from a local pip index.
+	Offline bool
 }
 
-func NewPackageBuildAdapter(pipIndexURL string) PackageBuildAdapter {
-	return PackageBuildAdapter{PipIndexURL: pipIndexURL}
+// PackageBuildConfig bundles configuration for creating a package build adapter.
+type PackageBuildConfig struct {
+	PipIndexURL string
+	// Workers bounds the number of concurrent deb builds (0 = GOMAXPROCS).
+	Workers int
+	// Control supplies Maintainer/Section/Priority/Homepage for generated debs.
+	Control types.DebControl
+	// MaintainerScripts maps packaging group names to a directory of
+	// preinst/postinst/prerm/postrm scripts.
+	MaintainerScripts map[string]string
+	// Compression selects the dpkg-deb compressor (default xz, falling
+	// back to gzip when dpkg-deb lacks xz support).
+	Compression string
+	// CompressionLevel is passed as -z to dpkg-deb (0 = compressor default).
+	CompressionLevel int
+	// LogDir, when set, receives a <package>_<version>.log file with the
+	// pip and dpkg-deb output of every built deb and a
+	// <group>.resolve.log file per resolved group.
+	LogDir string
+	// FailureSummary, when set, is the path build-failures.json is
+	// written to when a build fails; a stale summary is removed first.
+	FailureSummary string
+	// TempDir is the base directory of the pip and deb staging
+	// directories; it defaults to the OS temp directory and must be
+	// writable.
+	TempDir string
+	// Transactional builds the debs into a temporary sibling of the
+	// output directory and moves them in only when the whole build
+	// succeeds, so a failed build leaves no partial debs behind.
+	Transactional bool
+	// Architecture is the Debian architecture (amd64, arm64, ...) of
+	// debs whose installed wheels are platform-specific; pure-python
+	// debs stay Architecture: all. It defaults to the host architecture;
+	// a foreign architecture selects its wheels with pip --platform and
+	// cannot build sdist-only packages.
+	Architecture string
+	// PythonVersion, when set (e.g. 3.12), installs modules into
+	// usr/lib/python3.X/dist-packages, selects wheels built for CPython
+	// 3.X and makes the debs depend on the python3.X interpreter package
+	// instead of python3.
+	PythonVersion string
+	// CacheDir, when set together with a positive CacheTTLMinutes,
+	// caches the outcome of each group's pip resolve, keyed on its pinned
+	// requirements, the pip index, Architecture and PythonVersion, so
+	// groups with the same python dependencies do not resolve them again.
+	CacheDir        string
+	CacheTTLMinutes int
+	// Offline forbids network access: pip installs with --no-index from
+	// PipIndexURL, which must then be a local wheel directory, and the
+	// pip index check is skipped.
+	Offline bool
+}
+
+const (
+	debCompressionXz   = "xz"
+	debCompressionGzip = "gzip"
+	debCompressionZstd = "zstd"
+	debCompressionNone = "none"
+)
+
+// debCompression selects the compressor dpkg-deb applies to data.tar.
+type debCompression struct {
+	kind  string
+	level int
+}
+
+// debBuildOptions carries the settings shared by every deb generated
+// from one bundle manifest.
+type debBuildOptions struct {
+	debsDir     string
+	pipIndexURL string
+	control     types.DebControl
+	compression debCompression
+	logDir      string
+	failures    *buildFailures
+	tempDir     string
+	arch        string
+	python      string
+	pipCache    cacheConfig
+	offline     bool
+	// written collects the debs this build wrote into debsDir.
+	written *writtenDebs
+}
+
+// writeDeb runs dpkg-deb on staging to write the deb name into debsDir,
+// records it as written by this build and records a failure otherwise.
+func (o debBuildOptions) writeDeb(packageName string, staging string, name string) error {
+	err := runDebBuild(staging, filepath.Join(o.debsDir, name), o.compression)
+	if err == nil {
+		o.written.add(name)
+	}
+	return o.failures.record(packageName, buildStageDpkgDeb, nil, err)
+}
+
+// Toolchain hooks, swapped out in tests so that builds run without pip
+// or dpkg-deb.
+var (
+	runPipInstall = pipInstall
+	runPipList    = pipList
+	runDebBuild   = buildDeb
+	runPipCommand = execPipCommand
+)
+
+func NewPackageBuildAdapter(cfg PackageBuildConfig) PackageBuildAdapter {
+	return PackageBuildAdapter{
+		PipIndexURL:       cfg.PipIndexURL,
+		Workers:           normalizeBuildWorkers(cfg.Workers),
+		Control:           cfg.Control,
+		MaintainerScripts: cfg.MaintainerScripts,
+		Compression:       cfg.Compression,
+		CompressionLevel:  cfg.CompressionLevel,
+		LogDir:            cfg.LogDir,
+		FailureSummary:    cfg.FailureSummary,
+		TempDir:           cfg.TempDir,
+		Transactional:     cfg.Transactional,
+		Architecture:      cfg.Architecture,
+		PythonVersion:     cfg.PythonVersion,
+		CacheDir:          cfg.CacheDir,
+		CacheTTLMinutes:   cfg.CacheTTLMinutes,
+		Offline:           cfg.Offline,
+	}
+}
+
+func normalizeDebCompression(kind string, level int) (debCompression, error) {
+	value := strings.ToLower(strings.TrimSpace(kind))
+	if value == "" {
+		value = debCompressionXz
+	}
+	switch value {
+	case debCompressionXz, debCompressionGzip, debCompressionZstd, debCompressionNone:
+	default:
+		return debCompression{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("unsupported deb compression: %s", kind))
+	}
+	if level < 0 || level > 9 {
+		return debCompression{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("deb compression level must be between 0 and 9: %d", level))
+	}
+	return debCompression{kind: value, level: level}, nil
+}
+
+var pythonVersionPattern = regexp.MustCompile(`^3\.[0-9]+$`)
+
+// normalizePythonVersion validates a 3.X python version; a leading
+// "python" is accepted and empty stays empty.
+func normalizePythonVersion(version string) (string, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(version), "python")
+	if value != "" && !pythonVersionPattern.MatchString(value) {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("unsupported python version: %s (expected 3.X)", version))
+	}
+	return value, nil
+}
+
+// pythonInterpreter returns the interpreter package debs built for
+// version install into and depend on: python3.X, or python3 when no
+// version is set.
+func (o debBuildOptions) pythonInterpreter() string {
+	if o.python == "" {
+		return "python3"
+	}
+	return "python" + o.python
+}
+
+// pipTarget returns the platform pip installs wheels for.
+func (o debBuildOptions) pipTarget() pipTarget {
+	return pipTarget{arch: o.arch, python: o.python}
+}
+
+func normalizeBuildWorkers(value int) int {
+	if value <= 0 {
+		return runtime.GOMAXPROCS(0)
+	}
+	return value
 }
 
 func (a PackageBuildAdapter) BuildDebs(inputDir string, outputDir string) error {
@@ -44,12 +256,38 @@ func (a PackageBuildAdapter) BuildDebs(inputDir string, outputDir string) error
 			WithMsg("failed to create output directory").
 			WithCause(err)
 	}
+	if a.FailureSummary != "" {
+		if err := os.Remove(a.FailureSummary); err != nil && !os.IsNotExist(err) {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to remove stale build failure summary").
+				WithCause(err)
+		}
+	}
+	debsDir := outputDir
+	if a.Transactional {
+		cleanOutput := filepath.Clean(outputDir)
+		staging, err := os.MkdirTemp(filepath.Dir(cleanOutput), filepath.Base(cleanOutput)+".partial-")
+		if err != nil {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to create transactional debs directory").
+				WithCause(err)
+		}
+		defer os.RemoveAll(staging)
+		debsDir = staging
+	}
 
+	written := &writtenDebs{}
 	internalDebs := filepath.Join(inputDir, "internal-debs")
 	if _, err := os.Stat(internalDebs); err == nil {
-		if err := copyDebs(internalDebs, outputDir); err != nil {
+		copied, err := copyDebs(internalDebs, debsDir)
+		if err != nil {
 			return err
 		}
+		for _, name := range copied {
+			written.add(name)
+		}
 	}
 
 	manifest, err := loadBundleManifest(filepath.Join(inputDir, "bundle.manifest"))
@@ -60,7 +298,75 @@ func (a PackageBuildAdapter) BuildDebs(inputDir string, outputDir string) error
 	if err != nil {
 		return err
 	}
-	return buildPythonDebsFromManifest(manifest, pipDeps, outputDir, a.PipIndexURL)
+	compression, err := normalizeDebCompression(a.Compression, a.CompressionLevel)
+	if err != nil {
+		return err
+	}
+	python, err := normalizePythonVersion(a.PythonVersion)
+	if err != nil {
+		return err
+	}
+	if tempDir := strings.TrimSpace(a.TempDir); tempDir != "" {
+		if err := checkDirWritable(tempDir); err != nil {
+			return err
+		}
+	}
+	opts := debBuildOptions{
+		debsDir:     debsDir,
+		pipIndexURL: a.PipIndexURL,
+		control:     a.Control,
+		compression: compression,
+		logDir:      a.LogDir,
+		failures:    &buildFailures{},
+		tempDir:     strings.TrimSpace(a.TempDir),
+		arch:        strings.TrimSpace(a.Architecture),
+		python:      python,
+		pipCache:    normalizeCacheConfig(a.CacheDir, a.CacheTTLMinutes),
+		offline:     a.Offline,
+		written:     written,
+	}
+	if opts.arch == "" {
+		opts.arch = hostDebArchitecture()
+	}
+	err = buildPythonDebsFromManifest(manifest, pipDeps, opts, normalizeBuildWorkers(a.Workers), a.MaintainerScripts)
+	if err != nil && a.FailureSummary != "" {
+		if writeErr := opts.failures.write(a.FailureSummary); writeErr != nil {
+			log.Warn().Err(writeErr).Str("path", a.FailureSummary).Msg("failed to write build failure summary")
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if debsDir != outputDir {
+		if err := moveDebs(debsDir, outputDir); err != nil {
+			return err
+		}
+	}
+	return writeDebChecksums(outputDir, written.names())
+}
+
+// moveDebs renames every deb in srcDir into destDir. srcDir is a sibling
+// of destDir, so each rename stays on one filesystem and is atomic.
+func moveDebs(srcDir string, destDir string) error {
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to read transactional debs directory").
+			WithCause(err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".deb") {
+			continue
+		}
+		if err := os.Rename(filepath.Join(srcDir, entry.Name()), filepath.Join(destDir, entry.Name())); err != nil {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to move deb into output directory").
+				WithCause(err)
+		}
+	}
+	return nil
 }
 
 // groupDeps pairs a packaging group with its resolved pip dependencies.
@@ -69,39 +375,138 @@ type groupDeps struct {
 	deps  []types.ResolvedDependency
 }
 
-func buildPythonDebsFromManifest(manifest []types.BundleManifestEntry, pipDeps []types.ResolvedDependency, debsDir string, pipIndexURL string) error {
+// buildPythonDebsFromManifest builds the python debs for every packaging
+// group. Groups are first resolved concurrently; their pip packages are
+// then claimed serially in group name order, which schedules one deb per
+// distinct pip package plus any bundle debs, and the scheduled builds run
+// on a pool of workers. A package shared by several groups is built by the
+// first of them, with that group's maintainer scripts, so the resulting
+// set of debs does not depend on the worker count or scheduling.
+func buildPythonDebsFromManifest(manifest []types.BundleManifestEntry, pipDeps []types.ResolvedDependency, opts debBuildOptions, workers int, scripts map[string]string) error {
 	grouped, err := groupManifestByPip(manifest, pipDeps)
 	if err != nil {
 		return err
 	}
-	built := map[string]string{}
-	for _, entry := range grouped {
+	resolved := make([]pipResolveResult, len(grouped))
+	plans := make([]func() error, 0, len(grouped))
+	for i, entry := range grouped {
 		sort.Slice(entry.deps, func(i, j int) bool {
 			return entry.deps[i].Package < entry.deps[j].Package
 		})
 		switch entry.group.Mode {
+		case types.PackagingModeIndividual, types.PackagingModeMetaBundle:
+			plans = append(plans, func() error {
+				result, err := resolveGroupPipDeps(entry.group.Name, entry.deps, opts)
+				resolved[i] = result
+				return err
+			})
+		case types.PackagingModeFatBundle:
+		default:
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("unsupported packaging mode: %s", entry.group.Mode))
+		}
+	}
+	if err := runBuildTasks(workers, plans); err != nil {
+		return err
+	}
+
+	built := &builtVersions{versions: map[string]string{}}
+	var builds []func() error
+	for i, entry := range grouped {
+		scriptsDir := scripts[entry.group.Name]
+		switch entry.group.Mode {
 		case types.PackagingModeIndividual:
-			if err := buildResolvedPipDebs(entry.deps, pipIndexURL, debsDir, built); err != nil {
+			if builds, err = schedulePipDebs(builds, resolved[i], opts, scriptsDir, built); err != nil {
 				return err
 			}
 		case types.PackagingModeMetaBundle:
-			if err := buildResolvedPipDebs(entry.deps, pipIndexURL, debsDir, built); err != nil {
-				return err
-			}
-			if err := buildMetaBundleDeb(entry.group.Name, entry.deps, debsDir); err != nil {
+			if builds, err = schedulePipDebs(builds, resolved[i], opts, scriptsDir, built); err != nil {
 				return err
 			}
+			builds = append(builds, func() error {
+				return buildMetaBundleDeb(entry.group.Name, entry.deps, opts)
+			})
 		case types.PackagingModeFatBundle:
-			if err := buildFatBundleDeb(entry.group.Name, entry.deps, debsDir, pipIndexURL); err != nil {
-				return err
-			}
-		default:
-			return errbuilder.New().
+			builds = append(builds, func() error {
+				return buildFatBundleDeb(entry.group.Name, entry.deps, scriptsDir, opts)
+			})
+		}
+	}
+	return runBuildTasks(workers, builds)
+}
+
+// builtVersions records which pip package versions have been scheduled
+// for a deb build so that groups neither build a package twice nor
+// disagree on its version.
+type builtVersions struct {
+	mu       sync.Mutex
+	versions map[string]string
+}
+
+// claim reports whether the caller should build name at version. It
+// fails when another group already scheduled a different version.
+func (b *builtVersions) claim(name string, version string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if existing, ok := b.versions[name]; ok {
+		if existing != version {
+			return false, errbuilder.New().
 				WithCode(errbuilder.CodeInvalidArgument).
-				WithMsg(fmt.Sprintf("unsupported packaging mode: %s", entry.group.Mode))
+				WithMsg(fmt.Sprintf("pip dependency version mismatch for %s: %s vs %s", name, existing, version))
 		}
+		return false, nil
 	}
-	return nil
+	b.versions[name] = version
+	return true, nil
+}
+
+// runBuildTasks runs tasks on at most workers goroutines and returns the
+// first error. Tasks that have not started once a task fails are skipped.
+func runBuildTasks(workers int, tasks []func() error) error {
+	workerCount := workers
+	if len(tasks) < workerCount {
+		workerCount = len(tasks)
+	}
+	if workerCount <= 0 {
+		return nil
+	}
+	jobs := make(chan func() error)
+	results := make(chan error, len(tasks))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i := 0; i < workerCount; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range jobs {
+				if failed.Load() {
+					continue
+				}
+				err := task()
+				if err != nil {
+					failed.Store(true)
+				}
+				results <- err
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	for _, task := range tasks {
+		jobs <- task
+	}
+	close(jobs)
+
+	var firstErr error
+	for err := range results {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
 
 // groupManifestByPip filters and groups manifest entries that match pip
@@ -149,34 +554,41 @@ func groupManifestByPip(manifest []types.BundleManifestEntry, pipDeps []types.Re
 	return result, nil
 }
 
-// buildResolvedPipDebs resolves pip dependencies, builds individual .deb
-// packages, and tracks built versions to detect mismatches.
-func buildResolvedPipDebs(deps []types.ResolvedDependency, pipIndexURL string, debsDir string, built map[string]string) error {
-	resolved, err := resolvePipDependencies(deps, pipIndexURL)
-	if err != nil {
-		return err
+// resolveGroupPipDeps resolves the pip dependencies of a packaging group,
+// recording a failure and a resolve log for the group.
+func resolveGroupPipDeps(groupName string, deps []types.ResolvedDependency, opts debBuildOptions) (pipResolveResult, error) {
+	resolveLog := newBuildLog(opts.logDir, groupName+".resolve")
+	resolved, err := resolvePipDependencies(deps, opts.pipIndexURL, opts.tempDir, opts.pipCache, opts.offline, opts.pipTarget(), resolveLog)
+	if err := resolveLog.close(opts.failures.record(groupName, buildStageResolve, nil, err)); err != nil {
+		return pipResolveResult{}, err
 	}
+	return resolved, nil
+}
+
+// schedulePipDebs appends an individual .deb build to builds for every
+// resolved package not already claimed by another group.
+func schedulePipDebs(builds []func() error, resolved pipResolveResult, opts debBuildOptions, scriptsDir string, built *builtVersions) ([]func() error, error) {
 	for _, dep := range resolved.Packages {
-		if existing, ok := built[dep.Package]; ok {
-			if existing != dep.Version {
-				return errbuilder.New().
-					WithCode(errbuilder.CodeInvalidArgument).
-					WithMsg(fmt.Sprintf("pip dependency version mismatch for %s: %s vs %s", dep.Package, existing, dep.Version))
-			}
+		ok, err := built.claim(dep.Package, dep.Version)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
 			continue
 		}
 		debDepends := pipDebDepends(dep.Package, resolved)
-		if err := buildPythonPackageDeb(dep.Package, dep.Version, debsDir, pipIndexURL, debDepends); err != nil {
-			return err
-		}
-		built[dep.Package] = dep.Version
+		builds = append(builds, func() error {
+			return buildPythonPackageDeb(dep.Package, dep.Version, debDepends, scriptsDir, opts)
+		})
 	}
-	return nil
+	return builds, nil
 }
 
-func buildPythonPackageDeb(name string, version string, debsDir string, pipIndexURL string, debDepends []string) error {
+func buildPythonPackageDeb(name string, version string, debDepends []string, scriptsDir string, opts debBuildOptions) (err error) {
 	packageName := buildDebPackageNameParts("python3", name)
-	staging, err := os.MkdirTemp("", "avular-python-")
+	buildLog := newBuildLog(opts.logDir, fmt.Sprintf("%s_%s", packageName, version))
+	defer func() { err = buildLog.close(err) }()
+	staging, err := os.MkdirTemp(opts.tempDir, "avular-python-")
 	if err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
@@ -192,7 +604,7 @@ func buildPythonPackageDeb(name string, version string, debsDir string, pipIndex
 			WithMsg("failed to create control directory").
 			WithCause(err)
 	}
-	sitePackages := filepath.Join(staging, "usr", "lib", "python3", "dist-packages")
+	sitePackages := filepath.Join(staging, "usr", "lib", opts.pythonInterpreter(), "dist-packages")
 	if err := os.MkdirAll(sitePackages, 0o750); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
@@ -200,25 +612,89 @@ func buildPythonPackageDeb(name string, version string, debsDir string, pipIndex
 			WithCause(err)
 	}
 
-	if err := pipInstall(sitePackages, []types.ResolvedDependency{{Package: name, Version: version}}, pipIndexURL, true); err != nil {
+	output, err := runPipInstall(sitePackages, []types.ResolvedDependency{{Package: name, Version: version}}, opts.pipIndexURL, opts.tempDir, true, opts.offline, opts.pipTarget())
+	buildLog.add("pip install", output)
+	if err != nil {
+		return opts.failures.record(packageName, buildStageInstall, output, err)
+	}
+	arch, err := stagedDebArchitecture(sitePackages, opts.arch)
+	if err != nil {
 		return err
 	}
 
-	depends := formatDebDepends("python3", debDepends)
-	control := buildControl(packageName, version, depends, fmt.Sprintf("Python package %s", name))
-	if err := os.WriteFile(filepath.Join(controlDir, "control"), []byte(control), 0644); err != nil {
+	depends := formatDebDepends(opts.pythonInterpreter(), debDepends)
+	controlFile := buildControl(packageName, version, arch, depends, fmt.Sprintf("Python package %s", name), opts.control)
+	if err := os.WriteFile(filepath.Join(controlDir, "control"), []byte(controlFile), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write control file").
 			WithCause(err)
 	}
-	return buildDeb(staging, filepath.Join(debsDir, fmt.Sprintf("%s_%s_all.deb", packageName, version)))
+	if err := writeMaintainerScripts(controlDir, scriptsDir, name); err != nil {
+		return err
+	}
+	return opts.writeDeb(packageName, staging, fmt.Sprintf("%s_%s_%s.deb", packageName, version, arch))
+}
+
+// maintainerScriptNames lists the dpkg maintainer scripts that may be
+// supplied for a generated deb.
+var maintainerScriptNames = []string{"preinst", "postinst", "prerm", "postrm"}
+
+// writeMaintainerScripts copies the maintainer scripts found in scriptsDir
+// into controlDir with mode 0755. A script in scriptsDir/<pkg>/ takes
+// precedence over the group-level script of the same name. Nothing is
+// written when scriptsDir is empty.
+func writeMaintainerScripts(controlDir string, scriptsDir string, pkg string) error {
+	if strings.TrimSpace(scriptsDir) == "" {
+		return nil
+	}
+	info, err := os.Stat(scriptsDir)
+	if err != nil || !info.IsDir() {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeNotFound).
+			WithMsg(fmt.Sprintf("maintainer scripts directory not found: %s", scriptsDir)).
+			WithCause(err)
+	}
+	for _, script := range maintainerScriptNames {
+		candidates := []string{filepath.Join(scriptsDir, script)}
+		if strings.TrimSpace(pkg) != "" {
+			candidates = append([]string{filepath.Join(scriptsDir, pkg, script)}, candidates...)
+		}
+		for _, candidate := range candidates {
+			content, err := os.ReadFile(candidate)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return errbuilder.New().
+					WithCode(errbuilder.CodeInternal).
+					WithMsg("failed to read maintainer script").
+					WithCause(err)
+			}
+			target := filepath.Join(controlDir, script)
+			if err := os.WriteFile(target, content, 0o755); err != nil {
+				return errbuilder.New().
+					WithCode(errbuilder.CodeInternal).
+					WithMsg("failed to write maintainer script").
+					WithCause(err)
+			}
+			// WriteFile is subject to the umask; dpkg requires 0755.
+			if err := os.Chmod(target, 0o755); err != nil {
+				return errbuilder.New().
+					WithCode(errbuilder.CodeInternal).
+					WithMsg("failed to set maintainer script permissions").
+					WithCause(err)
+			}
+			break
+		}
+	}
+	return nil
 }
 
-func buildMetaBundleDeb(groupName string, deps []types.ResolvedDependency, debsDir string) error {
+func buildMetaBundleDeb(groupName string, deps []types.ResolvedDependency, opts debBuildOptions) error {
 	packageName := buildDebPackageNameParts("python3", groupName, "meta")
 	version := hashVersion(deps)
-	staging, err := os.MkdirTemp("", "avular-meta-")
+	staging, err := os.MkdirTemp(opts.tempDir, "avular-meta-")
 	if err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
@@ -239,20 +715,22 @@ func buildMetaBundleDeb(groupName string, deps []types.ResolvedDependency, debsD
 		pkgName := buildDebPackageNameParts("python3", dep.Package)
 		depends = append(depends, fmt.Sprintf("%s (= %s)", pkgName, dep.Version))
 	}
-	control := buildControl(packageName, version, strings.Join(depends, ", "), fmt.Sprintf("Meta bundle for %s", groupName))
+	control := buildControl(packageName, version, "all", strings.Join(depends, ", "), fmt.Sprintf("Meta bundle for %s", groupName), opts.control)
 	if err := os.WriteFile(filepath.Join(controlDir, "control"), []byte(control), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write control file").
 			WithCause(err)
 	}
-	return buildDeb(staging, filepath.Join(debsDir, fmt.Sprintf("%s_%s_all.deb", packageName, version)))
+	return opts.writeDeb(packageName, staging, fmt.Sprintf("%s_%s_all.deb", packageName, version))
 }
 
-func buildFatBundleDeb(groupName string, deps []types.ResolvedDependency, debsDir string, pipIndexURL string) error {
+func buildFatBundleDeb(groupName string, deps []types.ResolvedDependency, scriptsDir string, opts debBuildOptions) (err error) {
 	packageName := buildDebPackageNameParts("python3", groupName, "fat")
 	version := hashVersion(deps)
-	staging, err := os.MkdirTemp("", "avular-fat-")
+	buildLog := newBuildLog(opts.logDir, fmt.Sprintf("%s_%s", packageName, version))
+	defer func() { err = buildLog.close(err) }()
+	staging, err := os.MkdirTemp(opts.tempDir, "avular-fat-")
 	if err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
@@ -268,48 +746,141 @@ func buildFatBundleDeb(groupName string, deps []types.ResolvedDependency, debsDi
 			WithMsg("failed to create control directory").
 			WithCause(err)
 	}
-	sitePackages := filepath.Join(staging, "usr", "lib", "python3", "dist-packages")
+	sitePackages := filepath.Join(staging, "usr", "lib", opts.pythonInterpreter(), "dist-packages")
 	if err := os.MkdirAll(sitePackages, 0o750); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to create site-packages directory").
 			WithCause(err)
 	}
-	if err := pipInstall(sitePackages, deps, pipIndexURL, false); err != nil {
+	output, err := runPipInstall(sitePackages, deps, opts.pipIndexURL, opts.tempDir, false, opts.offline, opts.pipTarget())
+	buildLog.add("pip install", output)
+	if err != nil {
+		return opts.failures.record(packageName, buildStageInstall, output, err)
+	}
+	arch, err := stagedDebArchitecture(sitePackages, opts.arch)
+	if err != nil {
 		return err
 	}
 
-	control := buildControl(packageName, version, "python3", fmt.Sprintf("Fat bundle for %s", groupName))
+	control := buildControl(packageName, version, arch, opts.pythonInterpreter(), fmt.Sprintf("Fat bundle for %s", groupName), opts.control)
 	if err := os.WriteFile(filepath.Join(controlDir, "control"), []byte(control), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write control file").
 			WithCause(err)
 	}
-	return buildDeb(staging, filepath.Join(debsDir, fmt.Sprintf("%s_%s_all.deb", packageName, version)))
+	if err := writeMaintainerScripts(controlDir, scriptsDir, ""); err != nil {
+		return err
+	}
+	return opts.writeDeb(packageName, staging, fmt.Sprintf("%s_%s_%s.deb", packageName, version, arch))
 }
 
-func pipInstall(targetDir string, deps []types.ResolvedDependency, pipIndexURL string, noDeps bool) error {
-	var args []string
-	args = append(args, "-m", "pip", "install", "--target", targetDir)
+// pipInstall installs deps into targetDir from wheels only and returns
+// pip's combined output, which is also returned when the install fails.
+// When a requirement has no wheel on the index (an sdist-only package),
+// the wheels are built with pip wheel in a directory below tempDir and
+// installed from there, so a missing build toolchain fails the build
+// instead of leaving a half-installed tree. Wheels are selected for
+// target, and wheels built from sdists must also match its python
+// version; sdists cannot be built for a foreign architecture.
+func pipInstall(targetDir string, deps []types.ResolvedDependency, pipIndexURL string, tempDir string, noDeps bool, offline bool, target pipTarget) ([]byte, error) {
+	requirements := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		requirements = append(requirements, fmt.Sprintf("%s==%s", dep.Package, dep.Version))
+	}
+	var indexArgs []string
+	if offline {
+		wheelDir, ok := localPipIndexDir(pipIndexURL)
+		if !ok {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeFailedPrecondition).
+				WithMsg(fmt.Sprintf("offline mode: pip install of %s would require a network request; set a local --pip-index-url directory", strings.Join(requirements, ", ")))
+		}
+		indexArgs = append(indexArgs, "--no-index", "--find-links", wheelDir)
+	} else if strings.TrimSpace(pipIndexURL) != "" {
+		indexArgs = append(indexArgs, "--index-url", pipIndexURL)
+	}
+	var depsArgs []string
 	if noDeps {
-		args = append(args, "--no-deps")
+		depsArgs = append(depsArgs, "--no-deps")
 	}
-	if strings.TrimSpace(pipIndexURL) != "" {
-		args = append(args, "--index-url", pipIndexURL)
+	targetArgs, err := target.args()
+	if err != nil {
+		return nil, err
 	}
-	for _, dep := range deps {
-		args = append(args, fmt.Sprintf("%s==%s", dep.Package, dep.Version))
+
+	args := append([]string{"-m", "pip", "install", "--target", targetDir, "--only-binary=:all:"}, depsArgs...)
+	args = append(append(append(args, targetArgs...), indexArgs...), requirements...)
+	output, err := runPipCommand(args...)
+	if err == nil {
+		return output, nil
+	}
+	if !isMissingWheel(output) {
+		return output, pipCommandError("pip install", output, err, errbuilder.CodeInternal, "pip install failed")
+	}
+	if target.crossArch() {
+		return output, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("pip install found no %s wheel, and sdists cannot be built for a foreign architecture on a %s host", target.arch, hostDebArchitecture())).
+			WithCause(shared.CommandError(output, err))
 	}
-	cmd := exec.Command("python3", args...)
-	output, err := cmd.CombinedOutput()
+
+	wheelDir, err := os.MkdirTemp(tempDir, "avular-wheels-")
 	if err != nil {
-		return errbuilder.New().
+		return output, errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
-			WithMsg("pip install failed").
-			WithCause(shared.CommandError(output, err))
+			WithMsg("failed to create wheel directory").
+			WithCause(err)
 	}
-	return nil
+	defer os.RemoveAll(wheelDir)
+	args = append([]string{"-m", "pip", "wheel", "--wheel-dir", wheelDir}, depsArgs...)
+	args = append(append(args, indexArgs...), requirements...)
+	wheelOutput, err := runPipCommand(args...)
+	output = append(output, wheelOutput...)
+	if err != nil {
+		return output, pipCommandError("pip wheel", wheelOutput, err, errbuilder.CodeFailedPrecondition,
+			"pip wheel failed to build sdist-only packages (is the python build toolchain installed?)")
+	}
+
+	args = append([]string{"-m", "pip", "install", "--target", targetDir, "--only-binary=:all:", "--no-index", "--find-links", wheelDir}, depsArgs...)
+	args = append(append(args, targetArgs...), requirements...)
+	installOutput, err := runPipCommand(args...)
+	output = append(output, installOutput...)
+	if err != nil {
+		return output, pipCommandError("pip install", installOutput, err, errbuilder.CodeInternal, "pip install of built wheels failed")
+	}
+	return output, nil
+}
+
+// localPipIndexDir returns the directory of a pip index given as a
+// local path or file:// URL; remote or unset indexes are not local.
+func localPipIndexDir(pipIndexURL string) (string, bool) {
+	trimmed := strings.TrimSpace(pipIndexURL)
+	if path, ok := strings.CutPrefix(trimmed, "file://"); ok {
+		trimmed = path
+	}
+	if trimmed == "" || strings.Contains(trimmed, "://") {
+		return "", false
+	}
+	return trimmed, true
+}
+
+// isMissingWheel reports whether pip output from an --only-binary
+// install says that no wheel matched a requirement.
+func isMissingWheel(output []byte) bool {
+	message := string(output)
+	return strings.Contains(message, "No matching distribution found") ||
+		strings.Contains(message, "Could not find a version that satisfies the requirement")
+}
+
+// execPipCommand runs python3 with args and returns its combined output.
+func execPipCommand(args ...string) ([]byte, error) {
+	cmd := exec.Command("python3", args...)
+	// SOURCE_DATE_EPOCH makes pip byte-compile hash-based .pyc files,
+	// which keeps the installed tree independent of install time.
+	cmd.Env = sourceDateEpochEnv(sourceDateEpoch())
+	return cmd.CombinedOutput()
 }
 
 type pipResolveResult struct {
@@ -329,16 +900,16 @@ type pipMetadata struct {
 	Requires []string
 }
 
-func resolvePipDependencies(deps []types.ResolvedDependency, pipIndexURL string) (pipResolveResult, error) {
-	result := pipResolveResult{
-		Packages: []types.ResolvedDependency{},
-		Versions: map[string]string{},
-		Requires: map[string][]string{},
-	}
+func resolvePipDependencies(deps []types.ResolvedDependency, pipIndexURL string, tempDir string, cache cacheConfig, offline bool, target pipTarget, buildLog *buildLog) (pipResolveResult, error) {
 	if len(deps) == 0 {
-		return result, nil
+		return newPipResolveResult(map[string]string{}, map[string][]string{}), nil
 	}
-	staging, err := os.MkdirTemp("", "avular-pip-resolve-")
+	cacheKey := pipResolveCacheKey(deps, pipIndexURL, target)
+	if cached, ok := readPipResolveCache(cache, cacheKey); ok {
+		buildLog.add("pip install", []byte("served from pip resolve cache\n"))
+		return cached, nil
+	}
+	staging, err := os.MkdirTemp(tempDir, "avular-pip-resolve-")
 	if err != nil {
 		return pipResolveResult{}, errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
@@ -347,11 +918,13 @@ func resolvePipDependencies(deps []types.ResolvedDependency, pipIndexURL string)
 	}
 	defer os.RemoveAll(staging)
 
-	if err := pipInstall(staging, deps, pipIndexURL, false); err != nil {
+	output, err := runPipInstall(staging, deps, pipIndexURL, tempDir, false, offline, target)
+	buildLog.add("pip install", output)
+	if err != nil {
 		return pipResolveResult{}, err
 	}
 
-	versions, err := pipList(staging)
+	versions, err := runPipList(staging)
 	if err != nil {
 		return pipResolveResult{}, err
 	}
@@ -384,6 +957,14 @@ func resolvePipDependencies(deps []types.ResolvedDependency, pipIndexURL string)
 		requires[normalized] = uniqueSortedStrings(deps)
 	}
 
+	result := newPipResolveResult(versions, requires)
+	writePipResolveCache(cache, cacheKey, result)
+	return result, nil
+}
+
+// newPipResolveResult builds a resolve result from the installed versions
+// and their in-set Requires-Dist edges, listing packages by name.
+func newPipResolveResult(versions map[string]string, requires map[string][]string) pipResolveResult {
 	packages := make([]types.ResolvedDependency, 0, len(versions))
 	for name, version := range versions {
 		packages = append(packages, types.ResolvedDependency{
@@ -396,10 +977,14 @@ func resolvePipDependencies(deps []types.ResolvedDependency, pipIndexURL string)
 		return packages[i].Package < packages[j].Package
 	})
 
-	result.Packages = packages
-	result.Versions = versions
-	result.Requires = requires
-	return result, nil
+	if requires == nil {
+		requires = map[string][]string{}
+	}
+	return pipResolveResult{
+		Packages: packages,
+		Versions: versions,
+		Requires: requires,
+	}
 }
 
 func pipList(targetDir string) (map[string]string, error) {
@@ -565,19 +1150,136 @@ func pipDebDepends(name string, resolved pipResolveResult) []string {
 	return depends
 }
 
-func buildDeb(stagingDir string, outputPath string) error {
-	cmd := exec.Command("dpkg-deb", "--build", stagingDir, outputPath)
-	output, err := cmd.CombinedOutput()
+// defaultSourceDateEpoch is used when SOURCE_DATE_EPOCH is unset so that
+// builds stay reproducible by default (1980-01-01, the earliest time zip
+// archives inside wheels can represent).
+const defaultSourceDateEpoch int64 = 315532800
+
+// sourceDateEpoch returns the timestamp applied to every file in a deb,
+// honouring the SOURCE_DATE_EPOCH convention from reproducible-builds.org.
+func sourceDateEpoch() int64 {
+	value := strings.TrimSpace(os.Getenv("SOURCE_DATE_EPOCH"))
+	if value == "" {
+		return defaultSourceDateEpoch
+	}
+	epoch, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || epoch < 0 {
+		return defaultSourceDateEpoch
+	}
+	return epoch
+}
+
+func sourceDateEpochEnv(epoch int64) []string {
+	return append(os.Environ(), fmt.Sprintf("SOURCE_DATE_EPOCH=%d", epoch))
+}
+
+// normalizeStagingTree sets the mtime of every file and directory under
+// root to ts and opens directories up to 0755, which dpkg-deb requires for
+// DEBIAN and which installed package directories need anyway. Symlinks
+// are skipped since dpkg-deb clamps their timestamps via SOURCE_DATE_EPOCH.
+func normalizeStagingTree(root string, ts time.Time) error {
+	return filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		if entry.IsDir() {
+			if err := os.Chmod(path, 0o755); err != nil {
+				return err
+			}
+		}
+		return os.Chtimes(path, ts, ts)
+	})
+}
+
+// buildDeb packs stagingDir into a deb. Timestamps and directory modes are
+// normalized and ownership is forced to root so that identical inputs
+// yield byte-identical archives. When xz is requested but the installed
+// dpkg-deb does not support it, the build is retried with gzip.
+func buildDeb(stagingDir string, outputPath string, compression debCompression) error {
+	epoch := sourceDateEpoch()
+	if err := normalizeStagingTree(stagingDir, time.Unix(epoch, 0)); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to normalize staging directory").
+			WithCause(err)
+	}
+	output, err := runDpkgDebBuild(stagingDir, outputPath, compression, epoch)
+	if err != nil && compression.kind == debCompressionXz && isUnsupportedCompression(output) {
+		log.Debug().Str("deb", outputPath).Msg("dpkg-deb lacks xz support, falling back to gzip")
+		output, err = runDpkgDebBuild(stagingDir, outputPath, debCompression{kind: debCompressionGzip, level: compression.level}, epoch)
+	}
 	if err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("dpkg-deb build failed").
 			WithCause(shared.CommandError(output, err))
 	}
+	control, err := readDebControl(context.Background(), outputPath)
+	if err != nil {
+		return err
+	}
+	return validateDebControl(filepath.Base(outputPath), control)
+}
+
+// validateDebControl checks the control paragraph of a built deb: the
+// Package, Version and Architecture fields must be set and the version
+// must parse as a Debian version, so that a malformed version fails the
+// build instead of being rejected later by apt.
+func validateDebControl(debName string, control string) error {
+	for _, field := range []string{"Package", "Version", "Architecture"} {
+		if controlField(control, field) == "" {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg(fmt.Sprintf("built deb %s has no %s field", debName, field))
+		}
+	}
+	version := controlField(control, "Version")
+	if _, err := debversion.NewVersion(version); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg(fmt.Sprintf("built deb %s has an invalid Version field %q", debName, version)).
+			WithCause(err)
+	}
 	return nil
 }
 
-func buildControl(packageName string, version string, depends string, description string) string {
+func runDpkgDebBuild(stagingDir string, outputPath string, compression debCompression, epoch int64) ([]byte, error) {
+	args := []string{"--root-owner-group"}
+	if compression.kind != "" {
+		args = append(args, "-Z"+compression.kind)
+	}
+	if compression.level > 0 && compression.kind != debCompressionNone {
+		args = append(args, "-z"+strconv.Itoa(compression.level))
+	}
+	args = append(args, "--build", stagingDir, outputPath)
+	cmd := exec.Command("dpkg-deb", args...)
+	cmd.Env = sourceDateEpochEnv(epoch)
+	return cmd.CombinedOutput()
+}
+
+// isUnsupportedCompression reports whether dpkg-deb rejected the
+// requested compressor, as older or minimal builds do for xz.
+func isUnsupportedCompression(output []byte) bool {
+	message := strings.ToLower(string(output))
+	if !strings.Contains(message, "compress") {
+		return false
+	}
+	return strings.Contains(message, "unknown") ||
+		strings.Contains(message, "unsupported") ||
+		strings.Contains(message, "not supported") ||
+		strings.Contains(message, "invalid")
+}
+
+// buildControl renders a DEBIAN/control file. Optional fields from
+// fields are emitted only when set; Maintainer defaults to "avular".
+func buildControl(packageName string, version string, arch string, depends string, description string, fields types.DebControl) string {
+	maintainer := strings.TrimSpace(fields.Maintainer)
+	if maintainer == "" {
+		maintainer = "avular"
+	}
 	var builder strings.Builder
 	builder.WriteString("Package: ")
 	builder.WriteString(packageName)
@@ -585,8 +1287,15 @@ func buildControl(packageName string, version string, depends string, descriptio
 	builder.WriteString("Version: ")
 	builder.WriteString(version)
 	builder.WriteString("\n")
-	builder.WriteString("Architecture: all\n")
-	builder.WriteString("Maintainer: avular\n")
+	builder.WriteString("Architecture: ")
+	builder.WriteString(arch)
+	builder.WriteString("\n")
+	builder.WriteString("Maintainer: ")
+	builder.WriteString(maintainer)
+	builder.WriteString("\n")
+	writeControlField(&builder, "Section", fields.Section)
+	writeControlField(&builder, "Priority", fields.Priority)
+	writeControlField(&builder, "Homepage", fields.Homepage)
 	if strings.TrimSpace(depends) != "" {
 		builder.WriteString("Depends: ")
 		builder.WriteString(depends)
@@ -598,6 +1307,17 @@ func buildControl(packageName string, version string, depends string, descriptio
 	return builder.String()
 }
 
+func writeControlField(builder *strings.Builder, name string, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	builder.WriteString(name)
+	builder.WriteString(": ")
+	builder.WriteString(value)
+	builder.WriteString("\n")
+}
+
 func normalizeDebPackageName(value string) string {
 	normalized := strings.ToLower(strings.TrimSpace(value))
 	normalized = strings.ReplaceAll(normalized, "_", "-")
@@ -709,14 +1429,17 @@ func loadGetDependenciesPip(path string) ([]types.ResolvedDependency, error) {
 	return deps, nil
 }
 
-func copyDebs(srcDir string, destDir string) error {
+// copyDebs copies the debs in srcDir into destDir and returns their
+// names.
+func copyDebs(srcDir string, destDir string) ([]string, error) {
 	entries, err := os.ReadDir(srcDir)
 	if err != nil {
-		return errbuilder.New().
+		return nil, errbuilder.New().
 			WithCode(errbuilder.CodeNotFound).
 			WithMsg("internal deb dir not found").
 			WithCause(err)
 	}
+	var copied []string
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
@@ -727,10 +1450,11 @@ func copyDebs(srcDir string, destDir string) error {
 		srcPath := filepath.Join(srcDir, entry.Name())
 		destPath := filepath.Join(destDir, entry.Name())
 		if err := copyFile(srcPath, destPath); err != nil {
-			return err
+			return nil, err
 		}
+		copied = append(copied, entry.Name())
 	}
-	return nil
+	return copied, nil
 }
 
 func copyFile(srcPath string, destPath string) error {
@@ -0,0 +1,150 @@
+package adapters
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+)
+
+// goDebArchitectures maps GOARCH values to Debian architecture names
+// where the two differ.
+var goDebArchitectures = map[string]string{
+	"386":     "i386",
+	"arm":     "armhf",
+	"ppc64le": "ppc64el",
+}
+
+// hostDebArchitecture returns the Debian architecture of the host.
+func hostDebArchitecture() string {
+	if arch, ok := goDebArchitectures[runtime.GOARCH]; ok {
+		return arch
+	}
+	return runtime.GOARCH
+}
+
+// debWheelMachines maps Debian architectures to the machine suffix of
+// their Linux wheel platform tags.
+var debWheelMachines = map[string]string{
+	"amd64":   "x86_64",
+	"arm64":   "aarch64",
+	"armhf":   "armv7l",
+	"i386":    "i686",
+	"ppc64el": "ppc64le",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// maxManylinuxGlibcMinor is the newest glibc 2.X whose manylinux_2_X
+// wheels are accepted for a foreign target architecture (glibc 2.39
+// ships with Ubuntu 24.04).
+const maxManylinuxGlibcMinor = 39
+
+// pipTarget describes the platform and interpreter pip installs wheels
+// for. The zero value installs for the host.
+type pipTarget struct {
+	// arch is the Debian architecture of the debs; empty or the host
+	// architecture leaves pip's platform detection alone.
+	arch string
+	// python is the target CPython minor version, e.g. 3.12; empty
+	// leaves pip's interpreter detection alone.
+	python string
+}
+
+// crossArch reports whether the target architecture differs from the
+// host, so wheels must be selected with explicit --platform tags.
+func (t pipTarget) crossArch() bool {
+	return t.arch != "" && t.arch != hostDebArchitecture()
+}
+
+// args returns the pip install arguments selecting wheels for the
+// target; pip only accepts them together with --only-binary=:all:. For a
+// foreign architecture every manylinux tag from manylinux2014 (glibc
+// 2.17) up to maxManylinuxGlibcMinor is accepted, since pip does not
+// widen an explicit --platform on its own.
+func (t pipTarget) args() ([]string, error) {
+	var args []string
+	if t.python != "" {
+		args = append(args, "--python-version", t.python, "--implementation", "cp")
+	}
+	if !t.crossArch() {
+		return args, nil
+	}
+	machine, ok := debWheelMachines[t.arch]
+	if !ok {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("no wheel platform known for target architecture %s", t.arch))
+	}
+	args = append(args, "--platform", "manylinux2014_"+machine)
+	for minor := 17; minor <= maxManylinuxGlibcMinor; minor++ {
+		args = append(args, "--platform", fmt.Sprintf("manylinux_2_%d_%s", minor, machine))
+	}
+	return args, nil
+}
+
+// stagedDebArchitecture returns targetArch when any wheel installed into
+// sitePackages is platform-specific, so compiled extensions never ship
+// in an Architecture: all deb, and "all" otherwise.
+func stagedDebArchitecture(sitePackages string, targetArch string) (string, error) {
+	wheels, err := filepath.Glob(filepath.Join(sitePackages, "*.dist-info", "WHEEL"))
+	if err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to list installed wheels").
+			WithCause(err)
+	}
+	for _, path := range wheels {
+		platform, err := isPlatformWheel(path)
+		if err != nil {
+			return "", err
+		}
+		if platform {
+			return targetArch, nil
+		}
+	}
+	return "all", nil
+}
+
+// isPlatformWheel reports whether the WHEEL metadata file at path lists
+// a tag other than <python>-none-any, or a non-purelib root.
+func isPlatformWheel(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to read wheel metadata").
+			WithCause(err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.ToLower(strings.TrimSpace(value))
+		switch strings.TrimSpace(key) {
+		case "Tag":
+			parts := strings.Split(value, "-")
+			if len(parts) != 3 || parts[1] != "none" || parts[2] != "any" {
+				return true, nil
+			}
+		case "Root-Is-Purelib":
+			if value == "false" {
+				return true, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to read wheel metadata").
+			WithCause(err)
+	}
+	return false, nil
+}
@@ -0,0 +1,94 @@
+package adapters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+)
+
+// debChecksumsFile is the sha256sum-style manifest BuildDebs writes
+// next to the debs it produced.
+const debChecksumsFile = "SHA256SUMS"
+
+// writtenDebs collects the names of the debs a build wrote, from the
+// concurrent build workers.
+type writtenDebs struct {
+	mu   sync.Mutex
+	debs []string
+}
+
+func (w *writtenDebs) add(name string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.debs = append(w.debs, name)
+}
+
+func (w *writtenDebs) names() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string(nil), w.debs...)
+}
+
+// writeDebChecksums writes SHA256SUMS into dir, listing the named debs
+// in it sorted by name, in the format `sha256sum --check` reads. Other
+// debs in dir, e.g. from an earlier build, are left out.
+func writeDebChecksums(dir string, debs []string) error {
+	type debChecksum struct {
+		name string
+		sum  string
+	}
+	checksums := make([]debChecksum, 0, len(debs))
+	for _, name := range uniqueStrings(debs) {
+		sum, err := fileSHA256(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		checksums = append(checksums, debChecksum{name: name, sum: sum})
+	}
+	sort.Slice(checksums, func(i, j int) bool {
+		return checksums[i].name < checksums[j].name
+	})
+	var b strings.Builder
+	for _, checksum := range checksums {
+		fmt.Fprintf(&b, "%s  %s\n", checksum.sum, checksum.name)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, debChecksumsFile), []byte(b.String()), 0644); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to write " + debChecksumsFile).
+			WithCause(err)
+	}
+	return nil
+}
+
+// fileSHA256 streams the file at path through SHA-256 and returns the
+// hex digest.
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeNotFound).
+			WithMsg("failed to open deb").
+			WithCause(err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to hash deb").
+			WithCause(err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,72 @@
+package adapters
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/types"
+)
+
+// Build stages reported in the build failure summary.
+const (
+	buildStageResolve = "resolve"
+	buildStageInstall = "install"
+	buildStageDpkgDeb = "dpkg-deb"
+)
+
+// buildFailures collects the failed package builds of one run. A nil
+// collector ignores failures.
+type buildFailures struct {
+	mu       sync.Mutex
+	failures []types.BuildFailure
+}
+
+// record notes that pkg failed in stage with err and the captured tool
+// output, and returns err.
+func (f *buildFailures) record(pkg string, stage string, output []byte, err error) error {
+	if f == nil || err == nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, types.BuildFailure{
+		Package: pkg,
+		Stage:   stage,
+		Error:   err.Error(),
+		Output:  string(output),
+	})
+	return err
+}
+
+// write stores the collected failures, sorted by package, as JSON at
+// path. Nothing is written when no failure was recorded.
+func (f *buildFailures) write(path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.failures) == 0 {
+		return nil
+	}
+	summary := types.BuildFailureSummary{Failures: append([]types.BuildFailure(nil), f.failures...)}
+	sort.SliceStable(summary.Failures, func(i, j int) bool {
+		return summary.Failures[i].Package < summary.Failures[j].Package
+	})
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to marshal build failure summary").
+			WithCause(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to create build failure summary directory").
+			WithCause(err)
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,68 @@
+package adapters
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+)
+
+// buildLog collects the tool output of one deb build or group resolve
+// and writes it to <dir>/<name>.log, so that failed builds can be
+// inspected after the run. A buildLog without dir discards its output.
+type buildLog struct {
+	dir  string
+	name string
+	mu   sync.Mutex
+	buf  bytes.Buffer
+}
+
+func newBuildLog(dir string, name string) *buildLog {
+	return &buildLog{dir: strings.TrimSpace(dir), name: name}
+}
+
+// add appends the output of one build step.
+func (l *buildLog) add(step string, output []byte) {
+	if l.dir == "" {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(&l.buf, "==> %s\n", step)
+	l.buf.Write(output)
+	if len(output) > 0 && !bytes.HasSuffix(output, []byte("\n")) {
+		l.buf.WriteByte('\n')
+	}
+}
+
+// close writes the log, ending it with buildErr when the build failed,
+// and returns buildErr. A failure to write the log is only returned
+// when the build itself succeeded.
+func (l *buildLog) close(buildErr error) error {
+	if l.dir == "" {
+		return buildErr
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if buildErr != nil {
+		fmt.Fprintf(&l.buf, "==> failed\n%s\n", buildErr.Error())
+	}
+	err := os.MkdirAll(l.dir, 0o750)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(l.dir, l.name+".log"), l.buf.Bytes(), 0644)
+	}
+	if buildErr != nil {
+		return buildErr
+	}
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to write build log").
+			WithCause(err)
+	}
+	return nil
+}
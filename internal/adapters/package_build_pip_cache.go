@@ -0,0 +1,70 @@
+package adapters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"avular-packages/internal/shared"
+	"avular-packages/internal/types"
+)
+
+// pipResolveCacheEntry is the pip list and Requires-Dist outcome of one
+// pip resolve, as stored in the cache directory.
+type pipResolveCacheEntry struct {
+	Versions map[string]string   `json:"versions"`
+	Requires map[string][]string `json:"requires"`
+}
+
+// pipResolveCacheKey keys a pip resolve on its sorted name==version
+// requirements, the pip index they were resolved against and the target
+// architecture and python version, so a different index or target never
+// reuses another one's result (wheel dependencies can differ per
+// platform and interpreter).
+func pipResolveCacheKey(deps []types.ResolvedDependency, pipIndexURL string, target pipTarget) string {
+	pins := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		pins = append(pins, shared.NormalizePipName(dep.Package)+"=="+strings.TrimSpace(dep.Version))
+	}
+	sort.Strings(pins)
+	arch := target.arch
+	if arch == "" {
+		arch = hostDebArchitecture()
+	}
+	sum := sha256.Sum256([]byte("pip-resolve|" + strings.TrimRight(strings.TrimSpace(pipIndexURL), "/") + "|" + arch + "|" + target.python + "|" + strings.Join(pins, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// readPipResolveCache returns the cached resolve stored under key. A
+// missing, expired or unreadable entry is a miss.
+func readPipResolveCache(cfg cacheConfig, key string) (pipResolveResult, bool) {
+	payload, ok, err := readCache(cfg, key)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("failed to read pip resolve cache")
+		return pipResolveResult{}, false
+	}
+	if !ok {
+		return pipResolveResult{}, false
+	}
+	var entry pipResolveCacheEntry
+	if err := json.Unmarshal(payload, &entry); err != nil || entry.Versions == nil {
+		return pipResolveResult{}, false
+	}
+	return newPipResolveResult(entry.Versions, entry.Requires), true
+}
+
+// writePipResolveCache stores result under key. Failing to write only
+// costs a later rebuild the cache hit, so it is logged and ignored.
+func writePipResolveCache(cfg cacheConfig, key string, result pipResolveResult) {
+	payload, err := json.Marshal(pipResolveCacheEntry{Versions: result.Versions, Requires: result.Requires})
+	if err == nil {
+		err = writeCache(cfg, key, payload)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("failed to write pip resolve cache")
+	}
+}
@@ -0,0 +1,54 @@
+package adapters
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/shared"
+)
+
+// pipFailureSignatures match the pip error lines worth surfacing instead
+// of a generic failure. The first capture group names the offending
+// requirement.
+var pipFailureSignatures = []struct {
+	pattern *regexp.Regexp
+	format  string
+}{
+	{regexp.MustCompile(`No matching distribution found for (\S+)`), "no matching distribution found for %s"},
+	{regexp.MustCompile(`Could not find a version that satisfies the requirement (\S+)`), "no matching distribution found for %s"},
+	{regexp.MustCompile(`(?s)DO NOT MATCH THE HASHES.*?\n\s+(\S+) from `), "hash mismatch for %s"},
+	{regexp.MustCompile(`Cannot install (.+?) because these package versions have conflicting dependencies`), "resolution impossible, conflicting dependencies of %s"},
+}
+
+// diagnosePipFailure returns a specific description of a pip failure,
+// e.g. "no matching distribution found for demo==1.0", when its output
+// carries a known signature.
+func diagnosePipFailure(output []byte) (string, bool) {
+	message := string(output)
+	for _, signature := range pipFailureSignatures {
+		match := signature.pattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		return fmt.Sprintf(signature.format, strings.TrimSpace(match[1])), true
+	}
+	return "", false
+}
+
+// pipCommandError wraps a failed pip command. A recognised failure is
+// reported as a FailedPrecondition naming the offending package;
+// anything else falls back to code and msg. The raw output is always
+// attached as the cause.
+func pipCommandError(step string, output []byte, err error, code errbuilder.ErrCode, msg string) error {
+	if diagnosis, ok := diagnosePipFailure(output); ok {
+		code = errbuilder.CodeFailedPrecondition
+		msg = fmt.Sprintf("%s failed: %s", step, diagnosis)
+	}
+	return errbuilder.New().
+		WithCode(code).
+		WithMsg(msg).
+		WithCause(shared.CommandError(output, err))
+}
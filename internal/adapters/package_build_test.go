@@ -0,0 +1,895 @@
+package adapters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/types"
+)
+
+// stubPackageToolchain replaces pip and dpkg-deb with in-process fakes.
+// The fake pip install writes a dist-info directory per requirement and,
+// when dependencies are enabled, adds a shared "common" package that every
+// requirement depends on so that groups contend for the same deb.
+func stubPackageToolchain(t *testing.T) {
+	t.Helper()
+	origInstall, origList, origBuild := runPipInstall, runPipList, runDebBuild
+	t.Cleanup(func() {
+		runPipInstall, runPipList, runDebBuild = origInstall, origList, origBuild
+	})
+	runPipInstall = func(targetDir string, deps []types.ResolvedDependency, _ string, _ string, noDeps bool, _ bool, _ pipTarget) ([]byte, error) {
+		output := fmt.Sprintf("installed into %s\n", filepath.Base(targetDir))
+		for _, dep := range deps {
+			metadata := fmt.Sprintf("Name: %s\nVersion: %s\n", dep.Package, dep.Version)
+			if !noDeps {
+				metadata += "Requires-Dist: common\n"
+			}
+			if err := writeFakeDistInfo(targetDir, dep.Package, dep.Version, metadata); err != nil {
+				return nil, err
+			}
+			output += fmt.Sprintf("Successfully installed %s-%s\n", dep.Package, dep.Version)
+		}
+		if noDeps {
+			return []byte(output), nil
+		}
+		return []byte(output), writeFakeDistInfo(targetDir, "common", "1.0.0", "Name: common\nVersion: 1.0.0\n")
+	}
+	runPipList = func(targetDir string) (map[string]string, error) {
+		metadata, err := readPipMetadata(targetDir)
+		if err != nil {
+			return nil, err
+		}
+		versions := map[string]string{}
+		for name, meta := range metadata {
+			versions[name] = meta.Version
+		}
+		return versions, nil
+	}
+	runDebBuild = func(stagingDir string, outputPath string, _ debCompression) error {
+		control, err := os.ReadFile(filepath.Join(stagingDir, "DEBIAN", "control"))
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(outputPath, control, 0o644)
+	}
+}
+
+func writeFakeDistInfo(targetDir string, name string, version string, metadata string) error {
+	dir := filepath.Join(targetDir, fmt.Sprintf("%s-%s.dist-info", name, version))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "METADATA"), []byte(metadata), 0o644)
+}
+
+func readBuiltDebs(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	debs := map[string]string{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".deb") {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		debs[entry.Name()] = string(content)
+	}
+	return debs
+}
+
+func TestBuildPythonDebsFromManifestParallelMatchesSerial(t *testing.T) {
+	stubPackageToolchain(t)
+
+	modes := []types.PackagingMode{
+		types.PackagingModeIndividual,
+		types.PackagingModeMetaBundle,
+		types.PackagingModeFatBundle,
+	}
+	var manifest []types.BundleManifestEntry
+	var pipDeps []types.ResolvedDependency
+	for g := 0; g < 12; g++ {
+		for p := 0; p < 8; p++ {
+			name := fmt.Sprintf("pkg-%02d-%02d", g, p)
+			manifest = append(manifest, types.BundleManifestEntry{
+				Group:   fmt.Sprintf("group-%02d", g),
+				Mode:    modes[g%len(modes)],
+				Package: name,
+				Version: "1.0.0",
+			})
+			pipDeps = append(pipDeps, types.ResolvedDependency{
+				Type:    types.DependencyTypePip,
+				Package: name,
+				Version: "1.0.0",
+			})
+		}
+	}
+
+	serialDir := t.TempDir()
+	require.NoError(t, buildPythonDebsFromManifest(manifest, pipDeps, debBuildOptions{debsDir: serialDir}, 1, nil))
+	parallelDir := t.TempDir()
+	require.NoError(t, buildPythonDebsFromManifest(manifest, pipDeps, debBuildOptions{debsDir: parallelDir}, 8, nil))
+
+	serial := readBuiltDebs(t, serialDir)
+	require.Contains(t, serial, "python3-common_1.0.0_all.deb")
+	if diff := cmp.Diff(serial, readBuiltDebs(t, parallelDir)); diff != "" {
+		t.Fatalf("unexpected parallel debs (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildPythonDebsFromManifestSharedPackageUsesFirstGroupScripts(t *testing.T) {
+	stubPackageToolchain(t)
+	runDebBuild = func(stagingDir string, outputPath string, _ debCompression) error {
+		postinst, err := os.ReadFile(filepath.Join(stagingDir, "DEBIAN", "postinst"))
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(outputPath, postinst, 0o644)
+	}
+	// Resolve alpha last so that beta would win the shared package if
+	// claims followed scheduling.
+	install := runPipInstall
+	runPipInstall = func(targetDir string, deps []types.ResolvedDependency, indexURL string, tempDir string, noDeps bool, offline bool, target pipTarget) ([]byte, error) {
+		if !noDeps && deps[0].Package == "demo" {
+			time.Sleep(20 * time.Millisecond)
+		}
+		return install(targetDir, deps, indexURL, tempDir, noDeps, offline, target)
+	}
+	scripts := map[string]string{}
+	for _, group := range []string{"alpha", "beta"} {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "postinst"), []byte("#!/bin/sh\necho "+group+"\n"), 0o644))
+		scripts[group] = dir
+	}
+	manifest := []types.BundleManifestEntry{
+		{Group: "beta", Mode: types.PackagingModeIndividual, Package: "extra", Version: "2.0.0"},
+		{Group: "alpha", Mode: types.PackagingModeIndividual, Package: "demo", Version: "1.0.0"},
+	}
+	pipDeps := []types.ResolvedDependency{
+		{Type: types.DependencyTypePip, Package: "demo", Version: "1.0.0"},
+		{Type: types.DependencyTypePip, Package: "extra", Version: "2.0.0"},
+	}
+
+	for i := 0; i < 3; i++ {
+		debsDir := t.TempDir()
+		require.NoError(t, buildPythonDebsFromManifest(manifest, pipDeps, debBuildOptions{debsDir: debsDir}, 8, scripts))
+		common, err := os.ReadFile(filepath.Join(debsDir, "python3-common_1.0.0_all.deb"))
+		require.NoError(t, err)
+		require.Equal(t, "#!/bin/sh\necho alpha\n", string(common))
+	}
+}
+
+func TestBuildPythonDebsFromManifestWritesBuildLogs(t *testing.T) {
+	stubPackageToolchain(t)
+	manifest := []types.BundleManifestEntry{
+		{Group: "tools", Mode: types.PackagingModeIndividual, Package: "demo", Version: "1.0.0"},
+		{Group: "tools", Mode: types.PackagingModeIndividual, Package: "extra", Version: "2.0.0"},
+	}
+	pipDeps := []types.ResolvedDependency{
+		{Type: types.DependencyTypePip, Package: "demo", Version: "1.0.0"},
+		{Type: types.DependencyTypePip, Package: "extra", Version: "2.0.0"},
+	}
+	logDir := filepath.Join(t.TempDir(), "build-logs")
+	require.NoError(t, buildPythonDebsFromManifest(manifest, pipDeps, debBuildOptions{debsDir: t.TempDir(), logDir: logDir}, 2, nil))
+
+	entries, err := os.ReadDir(logDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	want := []string{
+		"python3-common_1.0.0.log",
+		"python3-demo_1.0.0.log",
+		"python3-extra_2.0.0.log",
+		"tools.resolve.log",
+	}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("unexpected build logs (-want +got):\n%s", diff)
+	}
+	content, err := os.ReadFile(filepath.Join(logDir, "python3-demo_1.0.0.log"))
+	require.NoError(t, err)
+	require.Contains(t, string(content), "==> pip install\n")
+	require.Contains(t, string(content), "Successfully installed demo-1.0.0")
+}
+
+func TestBuildDebsWritesFailureSummary(t *testing.T) {
+	stubPackageToolchain(t)
+	stubBuild := runDebBuild
+	runDebBuild = func(stagingDir string, outputPath string, compression debCompression) error {
+		if strings.HasPrefix(filepath.Base(outputPath), "python3-broken_") {
+			return errors.New("dpkg-deb build failed: bad control file")
+		}
+		return stubBuild(stagingDir, outputPath, compression)
+	}
+
+	inputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "bundle.manifest"), []byte("tools,individual,broken,1.0.0\ntools,individual,demo,1.0.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "get-dependencies.pip"), []byte("broken==1.0.0\ndemo==1.0.0\n"), 0o644))
+	summaryPath := filepath.Join(inputDir, "build-failures.json")
+	adapter := NewPackageBuildAdapter(PackageBuildConfig{Workers: 1, FailureSummary: summaryPath})
+
+	require.ErrorContains(t, adapter.BuildDebs(inputDir, t.TempDir()), "bad control file")
+	content, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+	var summary types.BuildFailureSummary
+	require.NoError(t, json.Unmarshal(content, &summary))
+	want := []types.BuildFailure{{
+		Package: "python3-broken",
+		Stage:   "dpkg-deb",
+		Error:   "dpkg-deb build failed: bad control file",
+	}}
+	if diff := cmp.Diff(want, summary.Failures); diff != "" {
+		t.Fatalf("unexpected build failures (-want +got):\n%s", diff)
+	}
+
+	runDebBuild = stubBuild
+	require.NoError(t, adapter.BuildDebs(inputDir, t.TempDir()))
+	_, err = os.Stat(summaryPath)
+	require.True(t, os.IsNotExist(err), "stale build failure summary was not removed")
+}
+
+func TestBuildDebsStagesUnderTempDir(t *testing.T) {
+	stubPackageToolchain(t)
+	stubInstall, stubBuild := runPipInstall, runDebBuild
+	var mu sync.Mutex
+	var staged []string
+	runPipInstall = func(targetDir string, deps []types.ResolvedDependency, pipIndexURL string, tempDir string, noDeps bool, offline bool, target pipTarget) ([]byte, error) {
+		mu.Lock()
+		staged = append(staged, targetDir)
+		mu.Unlock()
+		return stubInstall(targetDir, deps, pipIndexURL, tempDir, noDeps, offline, target)
+	}
+	runDebBuild = func(stagingDir string, outputPath string, compression debCompression) error {
+		mu.Lock()
+		staged = append(staged, stagingDir)
+		mu.Unlock()
+		return stubBuild(stagingDir, outputPath, compression)
+	}
+
+	inputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "bundle.manifest"), []byte("tools,meta-bundle,demo,1.0.0\nfat,fat-bundle,extra,2.0.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "get-dependencies.pip"), []byte("demo==1.0.0\nextra==2.0.0\n"), 0o644))
+	tempDir := filepath.Join(t.TempDir(), "staging")
+	require.NoError(t, NewPackageBuildAdapter(PackageBuildConfig{TempDir: tempDir}).BuildDebs(inputDir, t.TempDir()))
+
+	require.NotEmpty(t, staged)
+	for _, dir := range staged {
+		if !strings.HasPrefix(dir, tempDir+string(filepath.Separator)) {
+			t.Fatalf("staging directory %s is not below %s", dir, tempDir)
+		}
+	}
+
+	notDir := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(notDir, nil, 0o644))
+	err := NewPackageBuildAdapter(PackageBuildConfig{TempDir: notDir}).BuildDebs(inputDir, t.TempDir())
+	require.ErrorContains(t, err, "failed to create "+notDir)
+}
+
+func TestBuildDebsTransactionalLeavesNoPartialDebs(t *testing.T) {
+	stubPackageToolchain(t)
+	stubBuild := runDebBuild
+	failing := true
+	runDebBuild = func(stagingDir string, outputPath string, compression debCompression) error {
+		if failing && strings.HasPrefix(filepath.Base(outputPath), "python3-broken_") {
+			return errors.New("dpkg-deb build failed")
+		}
+		return stubBuild(stagingDir, outputPath, compression)
+	}
+
+	inputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "bundle.manifest"), []byte("tools,individual,demo,1.0.0\ntools,individual,broken,1.0.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "get-dependencies.pip"), []byte("demo==1.0.0\nbroken==1.0.0\n"), 0o644))
+	root := t.TempDir()
+	debsDir := filepath.Join(root, "debs")
+	adapter := NewPackageBuildAdapter(PackageBuildConfig{Workers: 1, Transactional: true})
+
+	require.Error(t, adapter.BuildDebs(inputDir, debsDir))
+	entries, err := os.ReadDir(debsDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+	siblings, err := os.ReadDir(root)
+	require.NoError(t, err)
+	require.Len(t, siblings, 1, "transactional staging directory was not removed")
+
+	failing = false
+	require.NoError(t, adapter.BuildDebs(inputDir, debsDir))
+	entries, err = os.ReadDir(debsDir)
+	require.NoError(t, err)
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if diff := cmp.Diff([]string{"SHA256SUMS", "python3-broken_1.0.0_all.deb", "python3-common_1.0.0_all.deb", "python3-demo_1.0.0_all.deb"}, names); diff != "" {
+		t.Fatalf("unexpected debs (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildDebsUsesTargetArchForPlatformWheels(t *testing.T) {
+	stubPackageToolchain(t)
+	stubInstall := runPipInstall
+	runPipInstall = func(targetDir string, deps []types.ResolvedDependency, pipIndexURL string, tempDir string, noDeps bool, offline bool, target pipTarget) ([]byte, error) {
+		output, err := stubInstall(targetDir, deps, pipIndexURL, tempDir, noDeps, offline, target)
+		if err != nil {
+			return output, err
+		}
+		for _, dep := range deps {
+			tag := "py3-none-any"
+			if dep.Package == "numpy" {
+				tag = "cp312-cp312-manylinux_2_17_aarch64"
+			}
+			wheel := fmt.Sprintf("Wheel-Version: 1.0\nRoot-Is-Purelib: %t\nTag: %s\n", tag == "py3-none-any", tag)
+			path := filepath.Join(targetDir, fmt.Sprintf("%s-%s.dist-info", dep.Package, dep.Version), "WHEEL")
+			if err := os.WriteFile(path, []byte(wheel), 0o644); err != nil {
+				return output, err
+			}
+		}
+		return output, nil
+	}
+
+	inputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "bundle.manifest"), []byte("tools,individual,demo,1.0.0\ntools,individual,numpy,2.1.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "get-dependencies.pip"), []byte("demo==1.0.0\nnumpy==2.1.0\n"), 0o644))
+	debsDir := t.TempDir()
+	adapter := NewPackageBuildAdapter(PackageBuildConfig{Workers: 1, Architecture: "arm64"})
+
+	require.NoError(t, adapter.BuildDebs(inputDir, debsDir))
+	debs := readBuiltDebs(t, debsDir)
+	var names []string
+	for name := range debs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if diff := cmp.Diff([]string{"python3-common_1.0.0_all.deb", "python3-demo_1.0.0_all.deb", "python3-numpy_2.1.0_arm64.deb"}, names); diff != "" {
+		t.Fatalf("unexpected debs (-want +got):\n%s", diff)
+	}
+	require.Contains(t, debs["python3-numpy_2.1.0_arm64.deb"], "Architecture: arm64\n")
+	require.Contains(t, debs["python3-demo_1.0.0_all.deb"], "Architecture: all\n")
+}
+
+func TestBuildDebsStagesIntoPythonVersionSitePackages(t *testing.T) {
+	stubPackageToolchain(t)
+	stubBuild := runDebBuild
+	var mu sync.Mutex
+	var sitePackages []string
+	runDebBuild = func(stagingDir string, outputPath string, compression debCompression) error {
+		matches, err := filepath.Glob(filepath.Join(stagingDir, "usr", "lib", "*", "dist-packages"))
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		for _, match := range matches {
+			rel, err := filepath.Rel(stagingDir, match)
+			if err != nil {
+				return err
+			}
+			sitePackages = append(sitePackages, rel)
+		}
+		mu.Unlock()
+		return stubBuild(stagingDir, outputPath, compression)
+	}
+
+	inputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "bundle.manifest"), []byte("tools,individual,demo,1.0.0\nfat,fat-bundle,extra,2.0.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "get-dependencies.pip"), []byte("demo==1.0.0\nextra==2.0.0\n"), 0o644))
+
+	for _, tc := range []struct {
+		version      string
+		python       string
+		sitePackages string
+	}{
+		{version: "", python: "python3", sitePackages: filepath.Join("usr", "lib", "python3", "dist-packages")},
+		{version: "3.12", python: "python3.12", sitePackages: filepath.Join("usr", "lib", "python3.12", "dist-packages")},
+	} {
+		sitePackages = nil
+		debsDir := t.TempDir()
+		adapter := NewPackageBuildAdapter(PackageBuildConfig{Workers: 1, PythonVersion: tc.version})
+		require.NoError(t, adapter.BuildDebs(inputDir, debsDir))
+
+		if diff := cmp.Diff([]string{tc.sitePackages, tc.sitePackages, tc.sitePackages}, sitePackages); diff != "" {
+			t.Fatalf("unexpected staged site-packages (-want +got):\n%s", diff)
+		}
+		debs := readBuiltDebs(t, debsDir)
+		require.Len(t, debs, 3)
+		for name, control := range debs {
+			require.Regexp(t, "(?m)^Depends: "+regexp.QuoteMeta(tc.python)+"(,|$)", control, name)
+		}
+	}
+
+	err := NewPackageBuildAdapter(PackageBuildConfig{PythonVersion: "3"}).BuildDebs(inputDir, t.TempDir())
+	require.ErrorContains(t, err, "unsupported python version: 3")
+	require.Equal(t, errbuilder.CodeInvalidArgument, errbuilder.CodeOf(err))
+}
+
+func TestBuildDebsWritesSHA256SUMS(t *testing.T) {
+	stubPackageToolchain(t)
+	inputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "bundle.manifest"), []byte("tools,individual,demo,1.0.0\ntools,individual,extra,2.0.0\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(inputDir, "get-dependencies.pip"), []byte("demo==1.0.0\nextra==2.0.0\n"), 0o644))
+	debsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(debsDir, "python3-stale_0.1.0_all.deb"), []byte("earlier run"), 0o644))
+
+	require.NoError(t, NewPackageBuildAdapter(PackageBuildConfig{Workers: 2}).BuildDebs(inputDir, debsDir))
+
+	var want strings.Builder
+	for _, name := range []string{"python3-common_1.0.0_all.deb", "python3-demo_1.0.0_all.deb", "python3-extra_2.0.0_all.deb"} {
+		content, err := os.ReadFile(filepath.Join(debsDir, name))
+		require.NoError(t, err)
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(&want, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+	manifest, err := os.ReadFile(filepath.Join(debsDir, "SHA256SUMS"))
+	require.NoError(t, err)
+	if diff := cmp.Diff(want.String(), string(manifest)); diff != "" {
+		t.Fatalf("unexpected SHA256SUMS (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolvePipDependenciesUsesCache(t *testing.T) {
+	stubPackageToolchain(t)
+	stubInstall := runPipInstall
+	installs := 0
+	runPipInstall = func(targetDir string, deps []types.ResolvedDependency, pipIndexURL string, tempDir string, noDeps bool, offline bool, target pipTarget) ([]byte, error) {
+		installs++
+		return stubInstall(targetDir, deps, pipIndexURL, tempDir, noDeps, offline, target)
+	}
+	cache := normalizeCacheConfig(t.TempDir(), 60)
+	deps := []types.ResolvedDependency{
+		{Type: types.DependencyTypePip, Package: "demo", Version: "1.0.0"},
+		{Type: types.DependencyTypePip, Package: "extra", Version: "2.0.0"},
+	}
+	reordered := []types.ResolvedDependency{deps[1], deps[0]}
+
+	first, err := resolvePipDependencies(deps, "https://pypi.example/simple", t.TempDir(), cache, false, pipTarget{}, newBuildLog("", "group"))
+	require.NoError(t, err)
+	second, err := resolvePipDependencies(reordered, "https://pypi.example/simple/", t.TempDir(), cache, false, pipTarget{}, newBuildLog("", "group"))
+	require.NoError(t, err)
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Fatalf("unexpected cached resolve (-want +got):\n%s", diff)
+	}
+	require.Equal(t, 1, installs)
+	require.Equal(t, []string{"common"}, second.Requires["demo"])
+
+	_, err = resolvePipDependencies(deps, "https://mirror.example/simple", t.TempDir(), cache, false, pipTarget{}, newBuildLog("", "group"))
+	require.NoError(t, err)
+	require.Equal(t, 2, installs)
+
+	_, err = resolvePipDependencies(deps, "https://pypi.example/simple", t.TempDir(), cache, false, pipTarget{arch: hostDebArchitecture()}, newBuildLog("", "group"))
+	require.NoError(t, err)
+	require.Equal(t, 2, installs, "an empty arch is the host architecture")
+	_, err = resolvePipDependencies(deps, "https://pypi.example/simple", t.TempDir(), cache, false, pipTarget{python: "3.12"}, newBuildLog("", "group"))
+	require.NoError(t, err)
+	require.Equal(t, 3, installs)
+	_, err = resolvePipDependencies(deps, "https://pypi.example/simple", t.TempDir(), cache, false, pipTarget{arch: "riscv64"}, newBuildLog("", "group"))
+	require.NoError(t, err)
+	require.Equal(t, 4, installs)
+}
+
+func TestValidateDebControl(t *testing.T) {
+	require.NoError(t, validateDebControl("demo.deb", "Package: demo\nVersion: 0.0.0+cdde7c22\nArchitecture: all"))
+
+	for control, message := range map[string]string{
+		"Package: demo\nArchitecture: all":                   "built deb demo.deb has no Version field",
+		"Package: demo\nVersion: 1.0":                        "built deb demo.deb has no Architecture field",
+		"Package: demo\nVersion: v1.0_rc\nArchitecture: all": `built deb demo.deb has an invalid Version field "v1.0_rc"`,
+	} {
+		err := validateDebControl("demo.deb", control)
+		require.ErrorContains(t, err, message)
+		require.Equal(t, errbuilder.CodeInternal, errbuilder.CodeOf(err))
+	}
+}
+
+func TestBuiltVersionsClaimDetectsMismatch(t *testing.T) {
+	built := &builtVersions{versions: map[string]string{}}
+
+	ok, err := built.claim("requests", "2.31.0")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	ok, err = built.claim("requests", "2.31.0")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	_, err = built.claim("requests", "2.32.0")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pip dependency version mismatch for requests")
+}
+
+func TestRunBuildTasksReturnsFirstError(t *testing.T) {
+	var tasks []func() error
+	for i := 0; i < 20; i++ {
+		tasks = append(tasks, func() error {
+			if i == 5 {
+				return fmt.Errorf("task %d failed", i)
+			}
+			return nil
+		})
+	}
+	err := runBuildTasks(4, tasks)
+	require.EqualError(t, err, "task 5 failed")
+}
+
+func TestBuildControl(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields types.DebControl
+		want   string
+	}{
+		{
+			name: "defaults",
+			want: "Package: python3-demo\nVersion: 1.0.0\nArchitecture: all\nMaintainer: avular\n" +
+				"Depends: python3\nDescription: Python package demo\n",
+		},
+		{
+			name: "configured fields",
+			fields: types.DebControl{
+				Maintainer: "Avular Robotics <packages@avular.com>",
+				Section:    "python",
+				Priority:   "optional",
+				Homepage:   "https://avular.com",
+			},
+			want: "Package: python3-demo\nVersion: 1.0.0\nArchitecture: all\n" +
+				"Maintainer: Avular Robotics <packages@avular.com>\nSection: python\nPriority: optional\n" +
+				"Homepage: https://avular.com\nDepends: python3\nDescription: Python package demo\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildControl("python3-demo", "1.0.0", "all", "python3", "Python package demo", tt.fields)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected control (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPipInstallBuildsWheelsForSdistOnlyPackages(t *testing.T) {
+	origCommand := runPipCommand
+	t.Cleanup(func() { runPipCommand = origCommand })
+	var calls []string
+	wheelFails := false
+	runPipCommand = func(args ...string) ([]byte, error) {
+		calls = append(calls, args[2])
+		joined := strings.Join(args, " ")
+		switch {
+		case strings.Contains(joined, "--only-binary=:all:") && !strings.Contains(joined, "--find-links"):
+			return []byte("ERROR: No matching distribution found for legacy==1.0\n"), errors.New("exit status 1")
+		case args[2] == "wheel" && wheelFails:
+			return []byte("error: command 'gcc' failed: No such file or directory\n"), errors.New("exit status 1")
+		case args[2] == "wheel":
+			require.Contains(t, joined, "--no-deps")
+			require.Contains(t, joined, "--index-url https://pypi.example.com/simple")
+			return []byte("Successfully built legacy\n"), nil
+		default:
+			require.Contains(t, joined, "--no-index --find-links ")
+			require.NotContains(t, joined, "--index-url")
+			return []byte("Successfully installed legacy-1.0\n"), nil
+		}
+	}
+	deps := []types.ResolvedDependency{{Package: "legacy", Version: "1.0"}}
+	tempDir := t.TempDir()
+
+	output, err := pipInstall(t.TempDir(), deps, "https://pypi.example.com/simple", tempDir, true, false, pipTarget{})
+	require.NoError(t, err)
+	require.Equal(t, []string{"install", "wheel", "install"}, calls)
+	require.Contains(t, string(output), "Successfully built legacy")
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	require.Empty(t, entries, "wheel directory was not removed")
+
+	calls = nil
+	wheelFails = true
+	output, err = pipInstall(t.TempDir(), deps, "https://pypi.example.com/simple", tempDir, true, false, pipTarget{})
+	require.ErrorContains(t, err, "pip wheel failed to build sdist-only packages")
+	require.Contains(t, err.Error(), "command 'gcc' failed")
+	require.Contains(t, string(output), "No matching distribution found")
+	require.Equal(t, []string{"install", "wheel"}, calls)
+}
+
+func TestPipInstallDiagnosesFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		wantMsg  string
+		wantCode errbuilder.ErrCode
+	}{
+		{
+			name:     "no matching distribution",
+			output:   "ERROR: Could not find a version that satisfies the requirement demo==9.9 (from versions: 1.0)\nERROR: No matching distribution found for demo==9.9\n",
+			wantMsg:  "pip wheel failed: no matching distribution found for demo==9.9",
+			wantCode: errbuilder.CodeFailedPrecondition,
+		},
+		{
+			name: "hash mismatch",
+			output: "ERROR: THESE PACKAGES DO NOT MATCH THE HASHES FROM THE REQUIREMENTS FILE.\n" +
+				"    demo==1.0 from https://pypi.example.com/demo-1.0-py3-none-any.whl:\n" +
+				"        Expected sha256 aaaa\n             Got        bbbb\n",
+			wantMsg:  "pip install failed: hash mismatch for demo==1.0",
+			wantCode: errbuilder.CodeFailedPrecondition,
+		},
+		{
+			name: "resolution impossible",
+			output: "ERROR: Cannot install demo==1.0 and other==2.0 because these package versions have conflicting dependencies.\n" +
+				"ERROR: ResolutionImpossible: for help visit https://pip.pypa.io\n",
+			wantMsg:  "pip install failed: resolution impossible, conflicting dependencies of demo==1.0 and other==2.0",
+			wantCode: errbuilder.CodeFailedPrecondition,
+		},
+		{
+			name:     "unrecognised",
+			output:   "ERROR: Could not install packages due to an OSError: [Errno 28] No space left on device\n",
+			wantMsg:  "pip install failed",
+			wantCode: errbuilder.CodeInternal,
+		},
+	}
+	origCommand := runPipCommand
+	t.Cleanup(func() { runPipCommand = origCommand })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A missing distribution makes the --only-binary install fall
+			// back to pip wheel, which then fails the same way.
+			runPipCommand = func(args ...string) ([]byte, error) {
+				return []byte(tt.output), errors.New("exit status 1")
+			}
+			deps := []types.ResolvedDependency{{Package: "demo", Version: "1.0"}}
+			_, err := pipInstall(t.TempDir(), deps, "", t.TempDir(), false, false, pipTarget{})
+			require.ErrorContains(t, err, tt.wantMsg)
+			require.ErrorContains(t, err, strings.TrimSpace(tt.output))
+			require.Equal(t, tt.wantCode, errbuilder.CodeOf(err))
+		})
+	}
+}
+
+func TestPipInstallOfflineUsesLocalIndexOnly(t *testing.T) {
+	origCommand := runPipCommand
+	t.Cleanup(func() { runPipCommand = origCommand })
+	var calls []string
+	runPipCommand = func(args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		return []byte("Successfully installed demo-1.0\n"), nil
+	}
+	deps := []types.ResolvedDependency{{Package: "demo", Version: "1.0"}}
+
+	for _, index := range []string{"", "https://pypi.example.com/simple"} {
+		_, err := pipInstall(t.TempDir(), deps, index, t.TempDir(), true, true, pipTarget{})
+		require.ErrorContains(t, err, "offline mode: pip install of demo==1.0 would require a network request")
+		require.Equal(t, errbuilder.CodeFailedPrecondition, errbuilder.CodeOf(err))
+	}
+	require.Empty(t, calls)
+
+	wheelDir := t.TempDir()
+	_, err := pipInstall(t.TempDir(), deps, "file://"+wheelDir, t.TempDir(), true, true, pipTarget{})
+	require.NoError(t, err)
+	require.Len(t, calls, 1)
+	require.Contains(t, calls[0], "--no-index --find-links "+wheelDir+" demo==1.0")
+	require.NotContains(t, calls[0], "--index-url")
+}
+
+func TestPipInstallSelectsWheelsForTargetArchitecture(t *testing.T) {
+	origCommand := runPipCommand
+	t.Cleanup(func() { runPipCommand = origCommand })
+	var calls []string
+	missing := false
+	runPipCommand = func(args ...string) ([]byte, error) {
+		calls = append(calls, strings.Join(args, " "))
+		if missing {
+			return []byte("ERROR: No matching distribution found for demo==1.0\n"), errors.New("exit status 1")
+		}
+		return []byte("Successfully installed demo-1.0\n"), nil
+	}
+	deps := []types.ResolvedDependency{{Package: "demo", Version: "1.0"}}
+	foreign, machine := "arm64", "aarch64"
+	if hostDebArchitecture() == "arm64" {
+		foreign, machine = "amd64", "x86_64"
+	}
+
+	_, err := pipInstall(t.TempDir(), deps, "", t.TempDir(), true, false, pipTarget{arch: hostDebArchitecture()})
+	require.NoError(t, err)
+	require.NotContains(t, calls[0], "--platform")
+
+	calls = nil
+	_, err = pipInstall(t.TempDir(), deps, "", t.TempDir(), true, false, pipTarget{arch: foreign})
+	require.NoError(t, err)
+	require.Contains(t, calls[0], "--only-binary=:all:")
+	require.Contains(t, calls[0], "--platform manylinux2014_"+machine)
+	require.Contains(t, calls[0], "--platform manylinux_2_28_"+machine)
+
+	calls = nil
+	missing = true
+	_, err = pipInstall(t.TempDir(), deps, "", t.TempDir(), true, false, pipTarget{arch: foreign})
+	require.ErrorContains(t, err, "sdists cannot be built for a foreign architecture")
+	require.Equal(t, errbuilder.CodeFailedPrecondition, errbuilder.CodeOf(err))
+	require.Len(t, calls, 1, "pip wheel must not build host wheels for a foreign target")
+
+	_, err = pipInstall(t.TempDir(), deps, "", t.TempDir(), true, false, pipTarget{arch: "m68k"})
+	require.ErrorContains(t, err, "no wheel platform known for target architecture m68k")
+}
+
+func TestPipInstallTargetsPythonVersion(t *testing.T) {
+	origCommand := runPipCommand
+	t.Cleanup(func() { runPipCommand = origCommand })
+	var calls []string
+	runPipCommand = func(args ...string) ([]byte, error) {
+		joined := strings.Join(args, " ")
+		calls = append(calls, joined)
+		if args[2] == "install" && !strings.Contains(joined, "--find-links") {
+			return []byte("ERROR: No matching distribution found for legacy==1.0\n"), errors.New("exit status 1")
+		}
+		return []byte("ok\n"), nil
+	}
+	deps := []types.ResolvedDependency{{Package: "legacy", Version: "1.0"}}
+
+	_, err := pipInstall(t.TempDir(), deps, "", t.TempDir(), true, false, pipTarget{python: "3.12"})
+	require.NoError(t, err)
+	require.Len(t, calls, 3)
+	for _, call := range []string{calls[0], calls[2]} {
+		require.Contains(t, call, "--only-binary=:all:")
+		require.Contains(t, call, "--python-version 3.12 --implementation cp")
+	}
+	require.NotContains(t, calls[1], "--python-version", "pip wheel builds with the host interpreter")
+}
+
+func TestBuildPythonPackageDebIsReproducible(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb not available")
+	}
+	origInstall := runPipInstall
+	t.Cleanup(func() { runPipInstall = origInstall })
+	install := 0
+	runPipInstall = func(targetDir string, deps []types.ResolvedDependency, _ string, _ string, _ bool, _ bool, _ pipTarget) ([]byte, error) {
+		install++
+		moduleDir := filepath.Join(targetDir, "demo")
+		if err := os.MkdirAll(moduleDir, 0o755); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(moduleDir, "__init__.py")
+		if err := os.WriteFile(path, []byte("VERSION = '1.0.0'\n"), 0o644); err != nil {
+			return nil, err
+		}
+		// Give each install a distinct mtime, as a real pip run would.
+		stamp := time.Now().Add(time.Duration(install) * time.Hour)
+		return nil, os.Chtimes(path, stamp, stamp)
+	}
+
+	var sums []string
+	for i := 0; i < 2; i++ {
+		dir := t.TempDir()
+		require.NoError(t, buildPythonPackageDeb("demo", "1.0.0", nil, "", debBuildOptions{debsDir: dir, compression: debCompression{kind: debCompressionXz}}))
+		content, err := os.ReadFile(filepath.Join(dir, "python3-demo_1.0.0_all.deb"))
+		require.NoError(t, err)
+		sum := sha256.Sum256(content)
+		sums = append(sums, hex.EncodeToString(sum[:]))
+	}
+	if diff := cmp.Diff(sums[0], sums[1]); diff != "" {
+		t.Fatalf("unexpected deb checksum (-want +got):\n%s", diff)
+	}
+}
+
+func TestSourceDateEpoch(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	require.Equal(t, defaultSourceDateEpoch, sourceDateEpoch())
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	require.Equal(t, int64(1700000000), sourceDateEpoch())
+	t.Setenv("SOURCE_DATE_EPOCH", "not-a-number")
+	require.Equal(t, defaultSourceDateEpoch, sourceDateEpoch())
+}
+
+func TestWriteMaintainerScripts(t *testing.T) {
+	scriptsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "postinst"), []byte("#!/bin/sh\necho group\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "prerm"), []byte("#!/bin/sh\necho prerm\n"), 0o600))
+	require.NoError(t, os.MkdirAll(filepath.Join(scriptsDir, "requests"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "requests", "postinst"), []byte("#!/bin/sh\necho requests\n"), 0o600))
+
+	controlDir := t.TempDir()
+	require.NoError(t, writeMaintainerScripts(controlDir, scriptsDir, "requests"))
+
+	got := map[string]string{}
+	entries, err := os.ReadDir(controlDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		require.NoError(t, err)
+		require.Equal(t, os.FileMode(0o755), info.Mode().Perm(), entry.Name())
+		content, err := os.ReadFile(filepath.Join(controlDir, entry.Name()))
+		require.NoError(t, err)
+		got[entry.Name()] = string(content)
+	}
+	want := map[string]string{
+		"postinst": "#!/bin/sh\necho requests\n",
+		"prerm":    "#!/bin/sh\necho prerm\n",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected maintainer scripts (-want +got):\n%s", diff)
+	}
+}
+
+func TestWriteMaintainerScriptsSkipsUnsetDirectory(t *testing.T) {
+	controlDir := t.TempDir()
+	require.NoError(t, writeMaintainerScripts(controlDir, "", "requests"))
+	entries, err := os.ReadDir(controlDir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+
+	err = writeMaintainerScripts(controlDir, filepath.Join(t.TempDir(), "missing"), "requests")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "maintainer scripts directory not found")
+}
+
+func TestBuildPythonPackageDebIncludesMaintainerScripts(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb not available")
+	}
+	stubPackageToolchain(t)
+	runDebBuild = buildDeb
+	scriptsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(scriptsDir, "postinst"), []byte("#!/bin/sh\nexit 0\n"), 0o644))
+
+	debsDir := t.TempDir()
+	require.NoError(t, buildPythonPackageDeb("demo", "1.0.0", nil, scriptsDir, debBuildOptions{debsDir: debsDir}))
+	output, err := exec.Command("dpkg-deb", "--info", filepath.Join(debsDir, "python3-demo_1.0.0_all.deb")).CombinedOutput()
+	require.NoError(t, err, string(output))
+	require.Contains(t, string(output), "postinst")
+}
+
+func TestNormalizeDebCompression(t *testing.T) {
+	got, err := normalizeDebCompression("", 0)
+	require.NoError(t, err)
+	require.Equal(t, debCompression{kind: debCompressionXz}, got)
+
+	got, err = normalizeDebCompression(" GZIP ", 9)
+	require.NoError(t, err)
+	require.Equal(t, debCompression{kind: debCompressionGzip, level: 9}, got)
+
+	_, err = normalizeDebCompression("bzip2", 0)
+	require.Error(t, err)
+	_, err = normalizeDebCompression("xz", 12)
+	require.Error(t, err)
+}
+
+func TestIsUnsupportedCompression(t *testing.T) {
+	require.True(t, isUnsupportedCompression([]byte("dpkg-deb: error: unknown compression type 'xz'!")))
+	require.True(t, isUnsupportedCompression([]byte("dpkg-deb: error: obsolete compression type 'xz'; compressor not supported")))
+	require.False(t, isUnsupportedCompression([]byte("dpkg-deb: error: control directory has bad permissions")))
+}
+
+func TestBuildDebCompressionUnpacks(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb not available")
+	}
+	stubPackageToolchain(t)
+	runDebBuild = buildDeb
+
+	for _, kind := range []string{debCompressionXz, debCompressionGzip} {
+		t.Run(kind, func(t *testing.T) {
+			debsDir := t.TempDir()
+			opts := debBuildOptions{debsDir: debsDir, compression: debCompression{kind: kind, level: 6}}
+			require.NoError(t, buildPythonPackageDeb("demo", "1.0.0", nil, "", opts))
+
+			debPath := filepath.Join(debsDir, "python3-demo_1.0.0_all.deb")
+			content, err := os.ReadFile(debPath)
+			require.NoError(t, err)
+			member := map[string]string{debCompressionXz: "data.tar.xz", debCompressionGzip: "data.tar.gz"}[kind]
+			require.Contains(t, string(content), member)
+
+			output, err := exec.Command("dpkg-deb", "--contents", debPath).CombinedOutput()
+			require.NoError(t, err, string(output))
+			require.Contains(t, string(output), "./usr/lib/python3/dist-packages/demo-1.0.0.dist-info/METADATA")
+		})
+	}
+}
@@ -2,7 +2,9 @@ package adapters
 
 import (
 	"encoding/xml"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -23,8 +25,9 @@ func NewPackageXMLAdapter() *PackageXMLAdapter {
 }
 
 type packageXML struct {
-	Name   string        `xml:"name"`
-	Export exportSection `xml:"export"`
+	Name    string        `xml:"name"`
+	Version string        `xml:"version"`
+	Export  exportSection `xml:"export"`
 
 	// Standard ROS dependency tags (REP-149 / REP-140)
 	Depend         []simpleDepend `xml:"depend"`
@@ -41,11 +44,62 @@ type exportSection struct {
 }
 
 type simpleDepend struct {
-	Value string `xml:",chardata"`
+	Value      string `xml:",chardata"`
+	VersionLt  string `xml:"version_lt,attr"`
+	VersionLte string `xml:"version_lte,attr"`
+	VersionEq  string `xml:"version_eq,attr"`
+	VersionGte string `xml:"version_gte,attr"`
+	VersionGt  string `xml:"version_gt,attr"`
 }
 
+// constraints converts the REP-149 version_* attributes of a dependency
+// tag into constraints sourced from that tag.
+func (d simpleDepend) constraints(key string, tag string) []types.Constraint {
+	var out []types.Constraint
+	for _, attr := range []struct {
+		op      types.ConstraintOp
+		version string
+	}{
+		{types.ConstraintOpLt, d.VersionLt},
+		{types.ConstraintOpLte, d.VersionLte},
+		{types.ConstraintOpEq, d.VersionEq},
+		{types.ConstraintOpGte, d.VersionGte},
+		{types.ConstraintOpGt, d.VersionGt},
+	} {
+		version := strings.TrimSpace(attr.version)
+		if version == "" {
+			continue
+		}
+		out = append(out, types.Constraint{
+			Name:    key,
+			Op:      attr.op,
+			Version: version,
+			Source:  "package_xml:" + tag,
+		})
+	}
+	return out
+}
+
+// entries renders the version_* attributes of the dependency as
+// "name<op>version" entries, one per attribute, using eqOp for
+// version_eq.
+func (d simpleDepend) entries(name string, eqOp types.ConstraintOp) []string {
+	constraints := d.constraints(name, "")
+	out := make([]string, 0, len(constraints))
+	for _, constraint := range constraints {
+		op := constraint.Op
+		if op == types.ConstraintOpEq {
+			op = eqOp
+		}
+		out = append(out, name+string(op)+constraint.Version)
+	}
+	return out
+}
+
+// pipDepend is a pip_depend export tag. Its version attribute pins an
+// exact version; the version_* attributes express ranges.
 type pipDepend struct {
-	Value   string `xml:",chardata"`
+	simpleDepend
 	Version string `xml:"version,attr"`
 }
 
@@ -55,6 +109,7 @@ type packageXMLCacheEntry struct {
 	pipDeps    []string
 	rosTagDeps []types.ROSTagDependency
 	name       string
+	version    string
 }
 
 func (a *PackageXMLAdapter) ParseDependencies(paths []string, tags []string) ([]string, []string, error) {
@@ -97,16 +152,35 @@ func (a *PackageXMLAdapter) ParseROSTags(paths []string) ([]types.ROSTagDependen
 	return result, nil
 }
 
+// ParsePackageNames returns the package names declared by the
+// package.xml files at paths. Two files declaring the same name are a
+// workspace mistake that would confuse workspace filtering and internal
+// deb builds, so they fail with both paths.
 func (a *PackageXMLAdapter) ParsePackageNames(paths []string) ([]string, error) {
 	var names []string
+	seen := map[string]string{}
+	versions := map[string]string{}
 	for _, path := range paths {
 		entry, err := a.loadPackageXML(path)
 		if err != nil {
 			return nil, err
 		}
-		if strings.TrimSpace(entry.name) != "" {
-			names = append(names, strings.TrimSpace(entry.name))
+		if entry.name == "" {
+			continue
+		}
+		cleaned := filepath.Clean(path)
+		if previous, ok := seen[entry.name]; ok {
+			if previous == cleaned {
+				continue
+			}
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("duplicate workspace package %s: %s (version %s) and %s (version %s)",
+					entry.name, previous, versions[entry.name], cleaned, entry.version))
 		}
+		seen[entry.name] = cleaned
+		versions[entry.name] = entry.version
+		names = append(names, entry.name)
 	}
 	return names, nil
 }
@@ -143,23 +217,32 @@ func (a *PackageXMLAdapter) loadPackageXML(path string) (packageXMLCacheEntry, e
 	entry := packageXMLCacheEntry{
 		modTime: info.ModTime(),
 		name:    strings.TrimSpace(pkg.Name),
+		version: strings.TrimSpace(pkg.Version),
 	}
 	for _, dep := range pkg.Export.DebianDepends {
 		value := strings.TrimSpace(dep.Value)
-		if value != "" {
-			entry.debianDeps = append(entry.debianDeps, value)
+		if value == "" {
+			continue
+		}
+		entries := dep.entries(value, types.ConstraintOpEq)
+		if len(entries) == 0 {
+			entries = []string{value}
 		}
+		entry.debianDeps = append(entry.debianDeps, entries...)
 	}
 	for _, dep := range pkg.Export.PipDepends {
 		value := strings.TrimSpace(dep.Value)
 		if value == "" {
 			continue
 		}
-		if dep.Version != "" {
-			entry.pipDeps = append(entry.pipDeps, value+"=="+dep.Version)
-			continue
+		entries := dep.entries(value, types.ConstraintOpEq2)
+		if version := strings.TrimSpace(dep.Version); version != "" {
+			entries = append([]string{value + "==" + version}, entries...)
 		}
-		entry.pipDeps = append(entry.pipDeps, value)
+		if len(entries) == 0 {
+			entries = []string{value}
+		}
+		entry.pipDeps = append(entry.pipDeps, entries...)
 	}
 
 	// Extract standard ROS dependency tags as abstract keys
@@ -175,38 +258,29 @@ func (a *PackageXMLAdapter) loadPackageXML(path string) (packageXMLCacheEntry, e
 // parsed package.xml and returns them as ROSTagDependency entries.
 func collectROSTags(pkg *packageXML) []types.ROSTagDependency {
 	var deps []types.ROSTagDependency
+	deps = appendROSTags(deps, pkg.Depend, "depend", types.ROSDepScopeAll)
+	deps = appendROSTags(deps, pkg.ExecDepend, "exec_depend", types.ROSDepScopeExec)
+	deps = appendROSTags(deps, pkg.BuildDepend, "build_depend", types.ROSDepScopeBuild)
+	deps = appendROSTags(deps, pkg.BuildExportDep, "build_export_depend", types.ROSDepScopeBuildExec)
+	// Format 1 packages declare runtime dependencies as run_depend,
+	// which formats 2 and 3 replaced with exec_depend.
+	deps = appendROSTags(deps, pkg.RunDepend, "run_depend", types.ROSDepScopeExec)
+	deps = appendROSTags(deps, pkg.TestDepend, "test_depend", types.ROSDepScopeTest)
+	return deps
+}
 
-	for _, dep := range pkg.Depend {
-		if key := strings.TrimSpace(dep.Value); key != "" {
-			deps = append(deps, types.ROSTagDependency{Key: key, Scope: types.ROSDepScopeAll})
-		}
-	}
-	for _, dep := range pkg.ExecDepend {
-		if key := strings.TrimSpace(dep.Value); key != "" {
-			deps = append(deps, types.ROSTagDependency{Key: key, Scope: types.ROSDepScopeExec})
-		}
-	}
-	for _, dep := range pkg.BuildDepend {
-		if key := strings.TrimSpace(dep.Value); key != "" {
-			deps = append(deps, types.ROSTagDependency{Key: key, Scope: types.ROSDepScopeBuild})
-		}
-	}
-	for _, dep := range pkg.BuildExportDep {
-		if key := strings.TrimSpace(dep.Value); key != "" {
-			deps = append(deps, types.ROSTagDependency{Key: key, Scope: types.ROSDepScopeBuildExec})
-		}
-	}
-	for _, dep := range pkg.RunDepend {
-		if key := strings.TrimSpace(dep.Value); key != "" {
-			deps = append(deps, types.ROSTagDependency{Key: key, Scope: types.ROSDepScopeExec})
-		}
-	}
-	for _, dep := range pkg.TestDepend {
-		if key := strings.TrimSpace(dep.Value); key != "" {
-			deps = append(deps, types.ROSTagDependency{Key: key, Scope: types.ROSDepScopeTest})
+func appendROSTags(deps []types.ROSTagDependency, tags []simpleDepend, tag string, scope types.ROSDepScope) []types.ROSTagDependency {
+	for _, dep := range tags {
+		key := strings.TrimSpace(dep.Value)
+		if key == "" {
+			continue
 		}
+		deps = append(deps, types.ROSTagDependency{
+			Key:         key,
+			Scope:       scope,
+			Constraints: dep.constraints(key, tag),
+		})
 	}
-
 	return deps
 }
 
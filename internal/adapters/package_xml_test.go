@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/ZanzyTHEbar/errbuilder-go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -69,6 +70,31 @@ func TestParseROSTags(t *testing.T) {
 	}
 }
 
+func TestParseROSTagsDependCoversBuildAndExec(t *testing.T) {
+	xmlPath := filepath.Join(t.TempDir(), "package.xml")
+	require.NoError(t, os.WriteFile(xmlPath, []byte(`<?xml version="1.0"?>
+<package format="3">
+  <name>demo</name>
+  <depend>rclcpp</depend>
+  <exec_depend>fmt</exec_depend>
+</package>`), 0644))
+
+	tags, err := NewPackageXMLAdapter().ParseROSTags([]string{xmlPath})
+	require.NoError(t, err)
+	require.Len(t, tags, 2)
+
+	depend, exec := tags[0], tags[1]
+	require.Equal(t, "rclcpp", depend.Key)
+	assert.Equal(t, types.ROSDepScopeAll, depend.Scope)
+	assert.True(t, depend.Scope.Includes(types.ROSDepScopeBuild))
+	assert.True(t, depend.Scope.Includes(types.ROSDepScopeExec))
+	assert.False(t, depend.Scope.Includes(types.ROSDepScopeTest))
+
+	require.Equal(t, "fmt", exec.Key)
+	assert.True(t, exec.Scope.Includes(types.ROSDepScopeExec))
+	assert.False(t, exec.Scope.Includes(types.ROSDepScopeBuild))
+}
+
 func TestParseROSTagsCoexistsWithExportTags(t *testing.T) {
 	dir := t.TempDir()
 	xmlPath := filepath.Join(dir, "package.xml")
@@ -102,3 +128,124 @@ func TestParseROSTagsEmptyXML(t *testing.T) {
 	require.NoError(t, err)
 	assert.Empty(t, tags)
 }
+
+func TestParseROSTagsVersionAttributes(t *testing.T) {
+	dir := t.TempDir()
+	xmlPath := filepath.Join(dir, "package.xml")
+	require.NoError(t, os.WriteFile(xmlPath, []byte(`<?xml version="1.0"?>
+<package format="3">
+  <name>versioned_pkg</name>
+  <version>0.0.1</version>
+  <depend version_gte="1.2">fmt</depend>
+  <exec_depend version_gt="2.0" version_lt="3.0">opencv</exec_depend>
+  <build_depend>ament_cmake</build_depend>
+</package>`), 0644))
+
+	adapter := NewPackageXMLAdapter()
+	tags, err := adapter.ParseROSTags([]string{xmlPath})
+	require.NoError(t, err)
+
+	want := []types.ROSTagDependency{
+		{Key: "fmt", Scope: types.ROSDepScopeAll, Constraints: []types.Constraint{
+			{Name: "fmt", Op: types.ConstraintOpGte, Version: "1.2", Source: "package_xml:depend"},
+		}},
+		{Key: "opencv", Scope: types.ROSDepScopeExec, Constraints: []types.Constraint{
+			{Name: "opencv", Op: types.ConstraintOpLt, Version: "3.0", Source: "package_xml:exec_depend"},
+			{Name: "opencv", Op: types.ConstraintOpGt, Version: "2.0", Source: "package_xml:exec_depend"},
+		}},
+		{Key: "ament_cmake", Scope: types.ROSDepScopeBuild},
+	}
+	assert.Equal(t, want, tags)
+}
+
+func TestParseROSTagsFormat1RunDepend(t *testing.T) {
+	xmlPath := filepath.Join(t.TempDir(), "package.xml")
+	require.NoError(t, os.WriteFile(xmlPath, []byte(`<?xml version="1.0"?>
+<package>
+  <name>legacy_pkg</name>
+  <version>0.0.1</version>
+  <buildtool_depend>catkin</buildtool_depend>
+  <build_depend>roscpp</build_depend>
+  <run_depend>roscpp</run_depend>
+  <run_depend version_gte="1.5">yaml-cpp</run_depend>
+</package>`), 0644))
+
+	tags, err := NewPackageXMLAdapter().ParseROSTags([]string{xmlPath})
+	require.NoError(t, err)
+
+	want := []types.ROSTagDependency{
+		{Key: "roscpp", Scope: types.ROSDepScopeBuild},
+		{Key: "roscpp", Scope: types.ROSDepScopeExec},
+		{Key: "yaml-cpp", Scope: types.ROSDepScopeExec, Constraints: []types.Constraint{
+			{Name: "yaml-cpp", Op: types.ConstraintOpGte, Version: "1.5", Source: "package_xml:run_depend"},
+		}},
+	}
+	assert.ElementsMatch(t, want, tags)
+}
+
+func TestParseDependenciesVersionAttributes(t *testing.T) {
+	xmlPath := filepath.Join(t.TempDir(), "package.xml")
+	require.NoError(t, os.WriteFile(xmlPath, []byte(`<?xml version="1.0"?>
+<package format="3">
+  <name>ranged_pkg</name>
+  <version>0.0.1</version>
+  <export>
+    <debian_depend version_gte="8.0">libfmt-dev</debian_depend>
+    <debian_depend version_lte="0.7">libyaml-dev</debian_depend>
+    <debian_depend version_eq="1.2.3">libfoo</debian_depend>
+    <debian_depend version_gt="2.0">libbar</debian_depend>
+    <debian_depend version_lt="3.0">libbaz</debian_depend>
+    <debian_depend version_gte="1.0" version_lte="2.0">libqux</debian_depend>
+    <debian_depend>libplain</debian_depend>
+    <pip_depend version="0.1.0">pinned</pip_depend>
+    <pip_depend version_eq="1.0">exact</pip_depend>
+    <pip_depend version_gte="1.20" version_lte="1.26">numpy</pip_depend>
+    <pip_depend>requests</pip_depend>
+  </export>
+</package>`), 0644))
+
+	debs, pips, err := NewPackageXMLAdapter().ParseDependencies([]string{xmlPath}, []string{"debian_depend", "pip_depend"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"libfmt-dev>=8.0",
+		"libyaml-dev<=0.7",
+		"libfoo=1.2.3",
+		"libbar>2.0",
+		"libbaz<3.0",
+		"libqux<=2.0",
+		"libqux>=1.0",
+		"libplain",
+	}, debs)
+	assert.Equal(t, []string{
+		"pinned==0.1.0",
+		"exact==1.0",
+		"numpy<=1.26",
+		"numpy>=1.20",
+		"requests",
+	}, pips)
+}
+
+func TestParsePackageNamesRejectsDuplicateNames(t *testing.T) {
+	root := t.TempDir()
+	writePackage := func(dir string, version string) string {
+		path := filepath.Join(root, dir, "package.xml")
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(`<?xml version="1.0"?>
+<package format="3">
+  <name>shared_pkg</name>
+  <version>`+version+`</version>
+</package>`), 0644))
+		return path
+	}
+	first := writePackage("a", "1.0.0")
+	second := writePackage("b", "2.0.0")
+	adapter := NewPackageXMLAdapter()
+
+	names, err := adapter.ParsePackageNames([]string{first, first})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shared_pkg"}, names)
+
+	_, err = adapter.ParsePackageNames([]string{first, second})
+	require.ErrorContains(t, err, "duplicate workspace package shared_pkg: "+first+" (version 1.0.0) and "+second+" (version 2.0.0)")
+	assert.Equal(t, errbuilder.CodeInvalidArgument, errbuilder.CodeOf(err))
+}
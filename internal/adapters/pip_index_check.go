@@ -0,0 +1,80 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	pep440 "github.com/aquasecurity/go-pep440-version"
+
+	"avular-packages/internal/shared"
+	"avular-packages/internal/types"
+)
+
+// CheckPipIndex verifies that every pip version pinned in the resolved
+// get-dependencies.pip below inputDir is published on the configured pip
+// index, so a missing version fails the build before any pip install
+// runs. All missing pins are reported together. Without a configured
+// index, or offline, there is nothing to check.
+func (a PackageBuildAdapter) CheckPipIndex(ctx context.Context, inputDir string) error {
+	if strings.TrimSpace(a.PipIndexURL) == "" || a.Offline {
+		return nil
+	}
+	deps, err := loadGetDependenciesPip(filepath.Join(inputDir, "get-dependencies.pip"))
+	if err != nil {
+		return err
+	}
+	client := &repoClient{
+		httpCfg:    normalizeHTTPConfig(0, 0, 0),
+		httpClient: newHTTPClient(defaultHTTPTimeout, normalizeHTTPTransportConfig(0, 0, false)),
+		netrc:      loadNetrc(netrcPath()),
+	}
+	return checkPipIndexVersions(ctx, a.PipIndexURL, deps, client)
+}
+
+func checkPipIndexVersions(ctx context.Context, pipIndexURL string, deps []types.ResolvedDependency, client *repoClient) error {
+	simpleBase := normalizePipSimpleIndex(pipIndexURL)
+	published := map[string][]string{}
+	var missing []string
+	for _, dep := range deps {
+		name := shared.NormalizePipName(dep.Package)
+		versions, ok := published[name]
+		if !ok {
+			fetched, err := fetchPipPackageVersions(ctx, simpleBase, name, client)
+			if err != nil {
+				return err
+			}
+			versions = fetched
+			published[name] = versions
+		}
+		if !containsPipVersion(versions, dep.Version) {
+			missing = append(missing, fmt.Sprintf("%s==%s", dep.Package, dep.Version))
+		}
+	}
+	if len(missing) > 0 {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("resolved pip versions not found in %s: %s", simpleBase, strings.Join(missing, ", ")))
+	}
+	return nil
+}
+
+// containsPipVersion reports whether versions holds version, comparing
+// PEP 440 normalized forms so that e.g. 1.0 matches 1.0.0.
+func containsPipVersion(versions []string, version string) bool {
+	want, err := pep440.Parse(version)
+	for _, candidate := range versions {
+		if candidate == version {
+			return true
+		}
+		if err != nil {
+			continue
+		}
+		if parsed, parseErr := pep440.Parse(candidate); parseErr == nil && parsed.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package adapters
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPipIndexReportsMissingVersions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/simple/demo/":
+			_, _ = w.Write([]byte(`<a href="demo-1.0.0-py3-none-any.whl">demo-1.0.0-py3-none-any.whl</a>`))
+		case "/simple/other-pkg/":
+			_, _ = w.Write([]byte(`<a href="other_pkg-2.0.tar.gz">other_pkg-2.0.tar.gz</a>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	inputDir := t.TempDir()
+	writePins := func(content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(inputDir, "get-dependencies.pip"), []byte(content), 0644))
+	}
+	adapter := NewPackageBuildAdapter(PackageBuildConfig{PipIndexURL: server.URL + "/simple"})
+
+	writePins("demo==1.0.0\nOther_Pkg==2.0.0\n")
+	require.NoError(t, adapter.CheckPipIndex(t.Context(), inputDir))
+
+	writePins("demo==1.0.0\ndemo-extra==0.1\nother-pkg==3.0\n")
+	err := adapter.CheckPipIndex(t.Context(), inputDir)
+	require.ErrorContains(t, err, "resolved pip versions not found in "+server.URL+"/simple/: demo-extra==0.1, other-pkg==3.0")
+	require.Equal(t, errbuilder.CodeFailedPrecondition, errbuilder.CodeOf(err))
+
+	require.NoError(t, NewPackageBuildAdapter(PackageBuildConfig{}).CheckPipIndex(t.Context(), inputDir))
+}
@@ -0,0 +1,91 @@
+package adapters
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/shared"
+	"avular-packages/internal/types"
+)
+
+// fetchPipReleaseMetadata returns the Requires-Dist metadata of every
+// version, read from the PEP 658 metadata file of one of its
+// distributions (a wheel when there is one). Versions whose files have
+// no separately served metadata are recorded without requirements.
+func fetchPipReleaseMetadata(ctx context.Context, client *repoClient, files []pipSimpleFile, versions []string) ([]types.PipPackageVersion, error) {
+	byVersion := map[string][]pipSimpleFile{}
+	for _, file := range files {
+		if !file.Metadata {
+			continue
+		}
+		version := parsePipVersionFromFilename(file.Filename)
+		byVersion[version] = append(byVersion[version], file)
+	}
+	releases := make([]types.PipPackageVersion, 0, len(versions))
+	for _, version := range versions {
+		release := types.PipPackageVersion{Version: version}
+		if candidates := byVersion[version]; len(candidates) > 0 {
+			sort.SliceStable(candidates, func(i, j int) bool {
+				iWheel := strings.HasSuffix(candidates[i].Filename, ".whl")
+				jWheel := strings.HasSuffix(candidates[j].Filename, ".whl")
+				if iWheel != jWheel {
+					return iWheel
+				}
+				return candidates[i].Filename < candidates[j].Filename
+			})
+			requires, err := fetchPipRequiresDist(ctx, client, candidates[0].URL+".metadata")
+			if err != nil {
+				return nil, err
+			}
+			release.RequiresDist = requires
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+// fetchPipRequiresDist fetches a core metadata file and returns its
+// Requires-Dist values; a missing file has none.
+func fetchPipRequiresDist(ctx context.Context, client *repoClient, url string) ([]string, error) {
+	status, body, _, err := client.fetchURL(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, nil
+	}
+	if status < 200 || status >= 300 {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to fetch pip package metadata").
+			WithCause(shared.HTTPStatusError(status, url))
+	}
+	return parsePipRequiresDist(url, body)
+}
+
+// parsePipRequiresDist reads the Requires-Dist headers of a core
+// metadata file (RFC 822 style headers, followed by the description).
+func parsePipRequiresDist(url string, body []byte) ([]string, error) {
+	header, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(body))).ReadMIMEHeader()
+	if err != nil && len(header) == 0 {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("invalid pip package metadata").
+			WithCause(fmt.Errorf("%s: %w", url, err))
+	}
+	var requires []string
+	for _, value := range header.Values("Requires-Dist") {
+		if value = strings.TrimSpace(value); value != "" {
+			requires = append(requires, value)
+		}
+	}
+	return requires, nil
+}
@@ -0,0 +1,217 @@
+package adapters
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	pep440 "github.com/aquasecurity/go-pep440-version"
+
+	"avular-packages/internal/shared"
+)
+
+type pipSimpleFormat int
+
+const (
+	pipSimpleHTML pipSimpleFormat = iota
+	pipSimpleJSON
+)
+
+// pipSimpleJSONIndex is the PEP 691 JSON form of the Simple API root.
+type pipSimpleJSONIndex struct {
+	Meta     pipSimpleJSONMeta `json:"meta"`
+	Projects []struct {
+		Name string `json:"name"`
+	} `json:"projects"`
+}
+
+// pipSimpleJSONProject is the PEP 691 JSON form of a Simple API project page.
+type pipSimpleJSONProject struct {
+	Meta  pipSimpleJSONMeta `json:"meta"`
+	Files []struct {
+		Filename string `json:"filename"`
+		URL      string `json:"url"`
+		// CoreMetadata (PEP 714) and its older DistInfoMetadata spelling
+		// are false, true or a hash mapping when the index serves the
+		// file's metadata at <url>.metadata (PEP 658).
+		CoreMetadata     json.RawMessage `json:"core-metadata"`
+		DistInfoMetadata json.RawMessage `json:"dist-info-metadata"`
+	} `json:"files"`
+}
+
+// pipSimpleFile is one distribution file listed on a Simple API project
+// page. Metadata is set when the index serves the file's core metadata
+// at URL + ".metadata".
+type pipSimpleFile struct {
+	Filename string
+	URL      string
+	Metadata bool
+}
+
+type pipSimpleJSONMeta struct {
+	APIVersion string `json:"api-version"`
+}
+
+// detectPipSimpleFormat decides how a Simple API response must be parsed
+// based on its Content-Type. Responses served from the local cache carry
+// no headers, so the body is sniffed instead. Any other content type is
+// rejected so that error pages never get parsed into package names.
+func detectPipSimpleFormat(url string, header http.Header, body []byte) (pipSimpleFormat, error) {
+	contentType := ""
+	if header != nil {
+		contentType = strings.TrimSpace(header.Get("Content-Type"))
+	}
+	if contentType == "" {
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+			return pipSimpleJSON, nil
+		}
+		return pipSimpleHTML, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(contentType)
+	}
+	switch mediaType {
+	case "text/html", "application/vnd.pypi.simple.v1+html":
+		return pipSimpleHTML, nil
+	case "application/json", "application/vnd.pypi.simple.v1+json":
+		return pipSimpleJSON, nil
+	default:
+		return 0, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("pip index returned unexpected content type").
+			WithCause(fmt.Errorf("%s returned %q", url, contentType))
+	}
+}
+
+func parsePipSimpleNamesJSON(url string, body []byte) ([]string, error) {
+	var index pipSimpleJSONIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return nil, invalidPipSimpleJSON(url, err)
+	}
+	if strings.TrimSpace(index.Meta.APIVersion) == "" {
+		return nil, invalidPipSimpleJSON(url, fmt.Errorf("missing meta.api-version"))
+	}
+	var names []string
+	for _, project := range index.Projects {
+		name := strings.TrimSpace(project.Name)
+		if name == "" {
+			continue
+		}
+		names = append(names, shared.NormalizePipName(name))
+	}
+	sort.Strings(names)
+	return uniqueStrings(names), nil
+}
+
+func parsePipVersionsFromSimpleJSON(url string, body []byte) ([]string, error) {
+	files, err := parsePipSimpleFilesJSON(url, body)
+	if err != nil {
+		return nil, err
+	}
+	return pipFileVersions(files), nil
+}
+
+// parsePipSimpleFilesJSON lists the files of a PEP 691 project page with
+// their URLs resolved against pageURL.
+func parsePipSimpleFilesJSON(pageURL string, body []byte) ([]pipSimpleFile, error) {
+	var project pipSimpleJSONProject
+	if err := json.Unmarshal(body, &project); err != nil {
+		return nil, invalidPipSimpleJSON(pageURL, err)
+	}
+	if strings.TrimSpace(project.Meta.APIVersion) == "" {
+		return nil, invalidPipSimpleJSON(pageURL, fmt.Errorf("missing meta.api-version"))
+	}
+	files := make([]pipSimpleFile, 0, len(project.Files))
+	for _, file := range project.Files {
+		files = append(files, pipSimpleFile{
+			Filename: file.Filename,
+			URL:      resolvePipFileURL(pageURL, file.URL),
+			Metadata: pipMetadataAdvertised(file.CoreMetadata) || pipMetadataAdvertised(file.DistInfoMetadata),
+		})
+	}
+	return files, nil
+}
+
+var pipSimpleMetadataAttrPattern = regexp.MustCompile(`(?i)\bdata-(?:core|dist-info)-metadata\b(?:\s*=\s*["']?([^"'\s>]*))?`)
+
+// parsePipSimpleFiles lists the files of an HTML project page with their
+// URLs resolved against pageURL. A data-core-metadata or
+// data-dist-info-metadata attribute other than "false" marks a file whose
+// metadata is served separately (PEP 658 / PEP 714).
+func parsePipSimpleFiles(pageURL string, content string) []pipSimpleFile {
+	var files []pipSimpleFile
+	for _, match := range pipSimpleAnchorPattern.FindAllStringSubmatch(content, -1) {
+		href := pipSimpleHrefPattern.FindStringSubmatch(match[1])
+		if href == nil {
+			continue
+		}
+		link := html.UnescapeString(strings.TrimSpace(href[1]))
+		raw := strings.Split(strings.Split(link, "#")[0], "?")[0]
+		file := pipSimpleFile{Filename: path.Base(raw), URL: resolvePipFileURL(pageURL, link)}
+		for _, attr := range pipSimpleMetadataAttrPattern.FindAllStringSubmatch(match[1], -1) {
+			if !strings.EqualFold(attr[1], "false") {
+				file.Metadata = true
+			}
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// pipMetadataAdvertised reports whether a PEP 691 core-metadata value
+// is present and not false.
+func pipMetadataAdvertised(raw json.RawMessage) bool {
+	value := strings.TrimSpace(string(raw))
+	return value != "" && value != "false" && value != "null"
+}
+
+// resolvePipFileURL resolves a file link against the project page URL
+// and drops its fragment (the hash pip verifies). Unresolvable links
+// are returned unchanged.
+func resolvePipFileURL(pageURL string, link string) string {
+	resolved, err := resolvePipPageURL(pageURL, link)
+	if err != nil {
+		return link
+	}
+	return strings.Split(resolved, "#")[0]
+}
+
+// pipFileVersions returns the versions encoded in the files' names.
+// Pre-releases are kept; the resolver only selects them when a
+// constraint asks for one.
+func pipFileVersions(files []pipSimpleFile) []string {
+	versions := map[string]struct{}{}
+	for _, file := range files {
+		addPipFileVersion(versions, file.Filename)
+	}
+	return mapKeys(versions)
+}
+
+// addPipFileVersion records the version encoded in a wheel or sdist
+// filename, skipping names without a valid PEP 440 version.
+func addPipFileVersion(versions map[string]struct{}, filename string) {
+	version := parsePipVersionFromFilename(filename)
+	if version == "" {
+		return
+	}
+	if _, err := pep440.Parse(version); err != nil {
+		return
+	}
+	versions[version] = struct{}{}
+}
+
+func invalidPipSimpleJSON(url string, cause error) error {
+	return errbuilder.New().
+		WithCode(errbuilder.CodeInvalidArgument).
+		WithMsg("pip index returned JSON that is not a PEP 691 simple response").
+		WithCause(fmt.Errorf("%s: %w", url, cause))
+}
@@ -1,12 +1,17 @@
 package adapters
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
 
@@ -15,12 +20,26 @@ import (
 	"avular-packages/internal/types"
 )
 
+// defaultComposeCacheTTL bounds how long fetched http and git compose
+// sources are reused before they are fetched again.
+const defaultComposeCacheTTL = time.Hour
+
 type ProfileSourceAdapter struct {
 	Spec SpecFileAdapter
+	// CacheDir holds fetched http and git compose sources, keyed by
+	// source and ref. Empty uses <user cache dir>/avular-packages/compose.
+	CacheDir   string
+	CacheTTL   time.Duration
+	HTTPClient *http.Client
+	UserAgent  string
 }
 
 func NewProfileSourceAdapter(spec SpecFileAdapter) ProfileSourceAdapter {
-	return ProfileSourceAdapter{Spec: spec}
+	return ProfileSourceAdapter{
+		Spec:       spec,
+		CacheTTL:   defaultComposeCacheTTL,
+		HTTPClient: newHTTPClient(60*time.Second, normalizeHTTPTransportConfig(0, 0, true)),
+	}
 }
 
 func (a ProfileSourceAdapter) LoadProfiles(product types.Spec, explicit []string) ([]types.Spec, error) {
@@ -67,6 +86,8 @@ func (a ProfileSourceAdapter) loadComposeProfile(compose types.ComposeRef) (type
 		return spec, nil
 	case "git":
 		return a.loadGitProfile(compose)
+	case "http", "https":
+		return a.loadHTTPProfile(compose)
 	case "inline":
 		return a.loadInlineProfile(compose)
 	default:
@@ -106,49 +127,159 @@ func (a ProfileSourceAdapter) loadInlineProfile(compose types.ComposeRef) (types
 	}, nil
 }
 
+// loadGitProfile clones the repository (URL, falling back to Name) at
+// ref Version into the compose cache and loads Path from the clone, so
+// schemas/ next to the profile are discovered as for local sources.
 func (a ProfileSourceAdapter) loadGitProfile(compose types.ComposeRef) (types.Spec, error) {
-	repo := strings.TrimSpace(compose.Name)
+	repo := strings.TrimSpace(compose.URL)
+	if repo == "" {
+		repo = strings.TrimSpace(compose.Name)
+	}
 	if repo == "" {
 		return types.Spec{}, errbuilder.New().
 			WithCode(errbuilder.CodeInvalidArgument).
-			WithMsg("compose name must be git repository URL for git sources")
+			WithMsg("compose url (or name) must be git repository URL for git sources")
 	}
 	if strings.TrimSpace(compose.Path) == "" {
 		return types.Spec{}, errbuilder.New().
 			WithCode(errbuilder.CodeInvalidArgument).
 			WithMsg("compose path is required for git sources")
 	}
-	tempDir, err := os.MkdirTemp("", "avular-packages-compose-")
+	cache := a.composeCache()
+	cloneDir := filepath.Join(cache.dir, composeCacheKey("git", repo, compose.Version))
+	if !cacheFresh(cloneDir, cache.ttl) {
+		if err := os.RemoveAll(cloneDir); err != nil {
+			return types.Spec{}, errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to clear cached git compose source").
+				WithCause(err)
+		}
+		if err := os.MkdirAll(cache.dir, 0o750); err != nil {
+			return types.Spec{}, errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to create compose cache directory").
+				WithCause(err)
+		}
+		args := []string{"clone", "--depth", "1"}
+		if strings.TrimSpace(compose.Version) != "" {
+			args = append(args, "--branch", compose.Version)
+		}
+		args = append(args, repo, cloneDir)
+
+		cmd := exec.Command("git", args...)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			_ = os.RemoveAll(cloneDir)
+			return types.Spec{}, errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to clone git compose source").
+				WithCause(shared.CommandError(output, err))
+		}
+	}
+
+	specPath := filepath.Join(cloneDir, compose.Path)
+	spec, err := a.Spec.LoadProfile(specPath)
 	if err != nil {
-		return types.Spec{}, errbuilder.New().
-			WithCode(errbuilder.CodeInternal).
-			WithMsg("failed to create temp directory for compose").
-			WithCause(err)
+		return types.Spec{}, err
 	}
-	defer os.RemoveAll(tempDir)
+	enrichProfileSchemas(&spec, specPath)
+	return spec, nil
+}
 
-	args := []string{"clone", "--depth", "1"}
-	if strings.TrimSpace(compose.Version) != "" {
-		args = append(args, "--branch", compose.Version)
+// loadHTTPProfile downloads the profile YAML at URL into the compose
+// cache, reusing a cached copy younger than the cache TTL.
+func (a ProfileSourceAdapter) loadHTTPProfile(compose types.ComposeRef) (types.Spec, error) {
+	url := strings.TrimSpace(compose.URL)
+	if url == "" {
+		return types.Spec{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("compose url is required for http sources")
+	}
+	cache := a.composeCache()
+	key := composeCacheKey("http", url, "")
+	_, cached, err := readCache(cache, key)
+	if err != nil {
+		return types.Spec{}, err
+	}
+	if !cached {
+		data, err := a.fetchHTTPProfile(url)
+		if err != nil {
+			return types.Spec{}, err
+		}
+		if err := writeCache(cache, key, data); err != nil {
+			return types.Spec{}, err
+		}
 	}
-	args = append(args, repo, tempDir)
+	return a.Spec.LoadProfile(filepath.Join(cache.dir, key+".cache"))
+}
 
-	cmd := exec.Command("git", args...)
-	output, err := cmd.CombinedOutput()
+func (a ProfileSourceAdapter) fetchHTTPProfile(url string) ([]byte, error) {
+	client := a.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return types.Spec{}, errbuilder.New().
-			WithCode(errbuilder.CodeInternal).
-			WithMsg("failed to clone git compose source").
-			WithCause(shared.CommandError(output, err))
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("invalid compose url: %s", url)).
+			WithCause(err)
+	}
+	applyUserAgent(req, a.UserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeUnavailable).
+			WithMsg("failed to fetch http compose source").
+			WithCause(err)
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeUnavailable).
+			WithMsg("failed to fetch http compose source").
+			WithCause(shared.HTTPStatusError(resp.StatusCode, url))
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeUnavailable).
+			WithMsg("failed to read http compose source").
+			WithCause(err)
+	}
+	return data, nil
+}
 
-	specPath := filepath.Join(tempDir, compose.Path)
-	spec, err := a.Spec.LoadProfile(specPath)
+// composeCache returns the cache configuration for fetched compose
+// sources, resolving the default directory when none is set.
+func (a ProfileSourceAdapter) composeCache() cacheConfig {
+	dir := strings.TrimSpace(a.CacheDir)
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			base = os.TempDir()
+		}
+		dir = filepath.Join(base, "avular-packages", "compose")
+	}
+	ttl := a.CacheTTL
+	if ttl <= 0 {
+		ttl = defaultComposeCacheTTL
+	}
+	return cacheConfig{dir: dir, ttl: ttl}
+}
+
+func composeCacheKey(source string, location string, ref string) string {
+	sum := sha256.Sum256([]byte(source + "|" + location + "|" + ref))
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheFresh reports whether path exists and was modified within ttl.
+func cacheFresh(path string, ttl time.Duration) bool {
+	info, err := os.Stat(path)
 	if err != nil {
-		return types.Spec{}, err
+		return false
 	}
-	enrichProfileSchemas(&spec, specPath)
-	return spec, nil
+	return time.Since(info.ModTime()) <= ttl
 }
 
 // enrichProfileSchemas discovers schema files in a schemas/ directory
@@ -1,9 +1,12 @@
 package adapters
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -35,6 +38,7 @@ func TestLoadGitComposeProfile(t *testing.T) {
 	}
 	specAdapter := NewSpecFileAdapter()
 	source := NewProfileSourceAdapter(specAdapter)
+	source.CacheDir = t.TempDir()
 	profiles, err := source.LoadProfiles(product, nil)
 	require.NoError(t, err)
 	if diff := cmp.Diff(1, len(profiles)); diff != "" {
@@ -43,6 +47,49 @@ func TestLoadGitComposeProfile(t *testing.T) {
 	if diff := cmp.Diff(types.SpecKindProfile, profiles[0].Kind); diff != "" {
 		t.Fatalf("unexpected spec kind (-want +got):\n%s", diff)
 	}
+
+	// A second load within the TTL reuses the cached clone.
+	require.NoError(t, os.RemoveAll(repoDir))
+	profiles, err = source.LoadProfiles(product, nil)
+	require.NoError(t, err)
+	require.Len(t, profiles, 1)
+}
+
+func TestLoadHTTPComposeProfile(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if r.URL.Path != "/profiles/base.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(sampleProfileSpec))
+	}))
+	defer server.Close()
+
+	product := types.Spec{
+		Kind: types.SpecKindProduct,
+		Compose: []types.ComposeRef{
+			{Name: "base-profile", Source: "http", URL: server.URL + "/profiles/base.yaml"},
+		},
+	}
+	source := NewProfileSourceAdapter(NewSpecFileAdapter())
+	source.CacheDir = t.TempDir()
+
+	for i := 0; i < 2; i++ {
+		profiles, err := source.LoadProfiles(product, nil)
+		require.NoError(t, err)
+		require.Len(t, profiles, 1)
+		if diff := cmp.Diff("base-profile", profiles[0].Metadata.Name); diff != "" {
+			t.Fatalf("unexpected profile name (-want +got):\n%s", diff)
+		}
+	}
+	require.Equal(t, int32(1), hits.Load(), "second load should be served from the cache")
+
+	product.Compose[0].URL = server.URL + "/profiles/missing.yaml"
+	_, err := source.LoadProfiles(product, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failed to fetch http compose source")
 }
 
 func runGit(t *testing.T, dir string, args ...string) {
@@ -0,0 +1,108 @@
+package adapters
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// maxRateLimitChunk bounds how many bytes a single Read may pass through
+// the limiter so that throughput is smoothed rather than bursty.
+const maxRateLimitChunk = 32 * 1024
+
+// rateLimiter caps aggregate throughput in bytes per second. A single
+// limiter is shared by all workers of an operation so that concurrency
+// does not multiply the configured budget.
+type rateLimiter struct {
+	mu          sync.Mutex
+	bytesPerSec int64
+	next        time.Time
+	now         func() time.Time
+	sleep       func(ctx context.Context, d time.Duration) error
+}
+
+// newRateLimiter returns nil when bytesPerSec is not positive, which
+// disables limiting for callers that wrap readers unconditionally.
+func newRateLimiter(bytesPerSec int) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{
+		bytesPerSec: int64(bytesPerSec),
+		now:         time.Now,
+		sleep:       sleepContext,
+	}
+}
+
+// wait reserves n bytes of budget and blocks until the reservation that
+// preceded it has been paid off.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := l.now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSec))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	return l.sleep(ctx, delay)
+}
+
+func (l *rateLimiter) chunkSize() int {
+	chunk := l.bytesPerSec / 10
+	if chunk < 1 {
+		chunk = 1
+	}
+	if chunk > maxRateLimitChunk {
+		chunk = maxRateLimitChunk
+	}
+	return int(chunk)
+}
+
+// rateLimitedReader throttles reads from the wrapped reader through a
+// shared rateLimiter.
+type rateLimitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *rateLimiter
+}
+
+// limitReader wraps reader with the limiter, returning reader unchanged
+// when limiting is disabled.
+func limitReader(ctx context.Context, reader io.Reader, limiter *rateLimiter) io.Reader {
+	if limiter == nil {
+		return reader
+	}
+	return &rateLimitedReader{ctx: ctx, reader: reader, limiter: limiter}
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if chunk := r.limiter.chunkSize(); len(p) > chunk {
+		p = p[:chunk]
+	}
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.wait(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
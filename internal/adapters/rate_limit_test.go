@@ -0,0 +1,98 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterAccumulatesDelay(t *testing.T) {
+	now := time.Unix(0, 0)
+	var slept time.Duration
+	limiter := newRateLimiter(1000)
+	limiter.now = func() time.Time { return now }
+	limiter.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+
+	reader := limitReader(t.Context(), bytes.NewReader(make([]byte, 1000)), limiter)
+	buf := make([]byte, 100)
+	total := 0
+	for {
+		n, err := reader.Read(buf)
+		total += n
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1000, total)
+	// Each 100-byte chunk waits for the previous one to be paid off, so
+	// ten chunks at 1000 B/s sleep for 0.9s in total.
+	require.Equal(t, 900*time.Millisecond, slept)
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	require.Nil(t, newRateLimiter(0))
+	source := bytes.NewReader(nil)
+	require.Same(t, io.Reader(source), limitReader(t.Context(), source, nil))
+}
+
+func TestRateLimiterHonorsContext(t *testing.T) {
+	limiter := newRateLimiter(10)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := io.ReadAll(limitReader(ctx, bytes.NewReader(make([]byte, 100)), limiter))
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFetchURLRateLimitSlowsDownload(t *testing.T) {
+	payload := bytes.Repeat([]byte("a"), 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer server.Close()
+
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 0), limiter: newRateLimiter(20000)}
+	start := time.Now()
+	_, body, _, err := client.fetchURL(t.Context(), server.URL)
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+	require.Len(t, body, len(payload))
+	// 10000 bytes at 20000 B/s in 2000-byte chunks: roughly 400ms.
+	require.GreaterOrEqual(t, elapsed, 350*time.Millisecond)
+}
+
+func TestProGetUploadRateLimitSlowsTransfer(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received = len(data)
+	}))
+	defer server.Close()
+
+	debsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), bytes.Repeat([]byte("d"), 10000), 0644))
+	adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+		Endpoint:             server.URL,
+		Feed:                 "avular",
+		DebsDir:              debsDir,
+		APIKey:               "secret",
+		Retries:              1,
+		RateLimitBytesPerSec: 20000,
+	})
+	start := time.Now()
+	require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+	require.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+	require.Equal(t, 10000, received)
+}
@@ -0,0 +1,71 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/shared"
+)
+
+// defaultGPGBinary is used when no gpg path is configured.
+const defaultGPGBinary = "gpg"
+
+// ReleaseSigner signs apt Release files with a key from the local gpg
+// keyring, producing the detached Release.gpg and the inline-signed
+// InRelease next to it. GNUPGHOME is honoured through the environment.
+type ReleaseSigner struct {
+	Binary string
+	KeyID  string
+}
+
+func NewReleaseSigner(binary string, keyID string) ReleaseSigner {
+	return ReleaseSigner{Binary: binary, KeyID: keyID}
+}
+
+// Sign writes Release.gpg and InRelease for the Release file at
+// releasePath. It fails before signing when the key has no secret part
+// in the keyring.
+func (s ReleaseSigner) Sign(ctx context.Context, releasePath string) error {
+	keyID := strings.TrimSpace(s.KeyID)
+	if keyID == "" {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("gpg key is required to sign Release files")
+	}
+	if output, err := s.run(ctx, "--list-secret-keys", keyID); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("gpg secret key %s not found in keyring", keyID)).
+			WithCause(shared.CommandError(output, err))
+	}
+	dir := filepath.Dir(releasePath)
+	if output, err := s.run(ctx, "--local-user", keyID, "--armor", "--detach-sign",
+		"--output", filepath.Join(dir, "Release.gpg"), releasePath); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to write Release.gpg").
+			WithCause(shared.CommandError(output, err))
+	}
+	if output, err := s.run(ctx, "--local-user", keyID, "--clearsign",
+		"--output", filepath.Join(dir, "InRelease"), releasePath); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to write InRelease").
+			WithCause(shared.CommandError(output, err))
+	}
+	return nil
+}
+
+func (s ReleaseSigner) run(ctx context.Context, args ...string) ([]byte, error) {
+	binary := strings.TrimSpace(s.Binary)
+	if binary == "" {
+		binary = defaultGPGBinary
+	}
+	cmd := exec.CommandContext(ctx, binary, append([]string{"--batch", "--yes"}, args...)...)
+	return cmd.CombinedOutput()
+}
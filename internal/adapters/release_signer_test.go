@@ -0,0 +1,55 @@
+package adapters
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseSignerSign(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available")
+	}
+	home := t.TempDir()
+	t.Setenv("GNUPGHOME", home)
+	out, err := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "repo@example.com", "default", "default", "never").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	dir := t.TempDir()
+	releasePath := filepath.Join(dir, "Release")
+	require.NoError(t, os.WriteFile(releasePath, []byte("Suite: stable\n"), 0o644))
+
+	require.NoError(t, NewReleaseSigner("", "repo@example.com").Sign(t.Context(), releasePath))
+
+	for _, name := range []string{"Release.gpg", "InRelease"} {
+		path := filepath.Join(dir, name)
+		_, err := os.Stat(path)
+		require.NoError(t, err, name)
+	}
+	out, err = exec.Command("gpg", "--batch", "--verify", filepath.Join(dir, "Release.gpg"), releasePath).CombinedOutput()
+	require.NoError(t, err, string(out))
+	out, err = exec.Command("gpg", "--batch", "--verify", filepath.Join(dir, "InRelease")).CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestReleaseSignerMissingKey(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available")
+	}
+	t.Setenv("GNUPGHOME", t.TempDir())
+	releasePath := filepath.Join(t.TempDir(), "Release")
+	require.NoError(t, os.WriteFile(releasePath, []byte("Suite: stable\n"), 0o644))
+
+	err := NewReleaseSigner("", "missing@example.com").Sign(t.Context(), releasePath)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "gpg secret key missing@example.com not found in keyring")
+}
+
+func TestReleaseSignerRequiresKey(t *testing.T) {
+	err := NewReleaseSigner("", " ").Sign(t.Context(), "Release")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "gpg key is required")
+}
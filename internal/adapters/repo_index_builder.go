@@ -7,13 +7,17 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,6 +25,7 @@ import (
 	"github.com/ZanzyTHEbar/errbuilder-go"
 	pep440 "github.com/aquasecurity/go-pep440-version"
 	debversion "github.com/knqyf263/go-deb-version"
+	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
 
 	"avular-packages/internal/ports"
@@ -32,11 +37,20 @@ type RepoIndexBuilderAdapter struct{}
 
 type RepoIndexWriterAdapter struct{}
 
+// aptSource is either a remote apt repository, read through its
+// Packages index, a Packages file on disk (PackagesFile), or a local
+// directory of debs (DebDir) whose control files are read directly.
 type aptSource struct {
 	Endpoint     string
 	Distribution string
 	Component    string
 	Arch         string
+	PackagesFile string
+	DebDir       string
+	// Flat marks a flat repository whose Packages index lives directly
+	// below the endpoint (or the Distribution subdirectory) instead of
+	// under dists/.
+	Flat bool
 }
 
 const defaultAptFetchWorkers = 4
@@ -59,10 +73,25 @@ type cacheConfig struct {
 // repoClient bundles the credentials and transport configuration shared
 // across all HTTP-based repository fetch operations.
 type repoClient struct {
-	user     string
-	apiKey   string
-	httpCfg  httpRetryConfig
-	cacheCfg cacheConfig
+	user       string
+	apiKey     string
+	authMode   string
+	userAgent  string
+	httpCfg    httpRetryConfig
+	cacheCfg   cacheConfig
+	httpClient *http.Client
+	limiter    *rateLimiter
+	// redirectHosts are the hosts that keep credentials when a request is
+	// redirected to them; see authRedirectPolicy.
+	redirectHosts []string
+	// hostCredentials override user and apiKey for requests to a host.
+	hostCredentials map[string]ports.HostCredential
+	// netrc supplies basic-auth credentials per host when no API key
+	// is configured.
+	netrc *netrcFile
+	// offline serves every fetch from the cache, whatever its age, and
+	// fails instead of making a request on a miss.
+	offline bool
 }
 
 func normalizeHTTPConfig(timeoutSec int, retries int, delayMs int) httpRetryConfig {
@@ -118,28 +147,56 @@ func (a RepoIndexBuilderAdapter) Build(ctx context.Context, request ports.RepoIn
 		request.AptComponent,
 		request.AptArch,
 	)
+	aptSources = append(aptSources, localAptSources(request.AptDebDirs)...)
 	httpCfg := normalizeHTTPConfig(request.HTTPTimeoutSec, request.HTTPRetries, request.HTTPRetryDelayMs)
 	cacheCfg := normalizeCacheConfig(request.CacheDir, request.CacheTTLMinutes)
-	aptClient := &repoClient{user: request.AptUser, apiKey: request.AptAPIKey, httpCfg: httpCfg, cacheCfg: cacheCfg}
+	if request.Offline {
+		if strings.TrimSpace(request.CacheDir) == "" {
+			return types.RepoIndexFile{}, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg("offline mode requires a cache directory (--cache-dir)")
+		}
+		cacheCfg = cacheConfig{dir: strings.TrimSpace(request.CacheDir)}
+	}
+	transportCfg, err := normalizeHTTPTransportConfig(request.HTTPMaxIdleConnsPerHost, request.HTTPIdleConnTimeoutSec, request.HTTPDisableHTTP2).
+		withProxyAndCA(request.HTTPProxy, request.HTTPCABundle)
+	if err != nil {
+		return types.RepoIndexFile{}, err
+	}
+	aptAuthMode, err := normalizeAuthMode(request.AptAuthMode)
+	if err != nil {
+		return types.RepoIndexFile{}, err
+	}
+	pipAuthMode, err := normalizeAuthMode(request.PipAuthMode)
+	if err != nil {
+		return types.RepoIndexFile{}, err
+	}
+	httpClient := newHTTPClient(httpCfg.timeout, transportCfg)
+	limiter := newRateLimiter(request.RateLimitBytesPerSec)
+	netrc := loadNetrc(netrcPath())
+	aptClient := &repoClient{user: request.AptUser, apiKey: request.AptAPIKey, authMode: aptAuthMode, userAgent: request.UserAgent, httpCfg: httpCfg, cacheCfg: cacheCfg, httpClient: httpClient, limiter: limiter, redirectHosts: request.HTTPAuthRedirectHosts, hostCredentials: request.HostCredentials, netrc: netrc, offline: request.Offline}
 	aptVersions, aptPackages, err := buildAptIndex(ctx, aptSources, request.AptWorkers, aptClient)
 	if err != nil {
 		return types.RepoIndexFile{}, err
 	}
-	pipClient := &repoClient{user: request.PipUser, apiKey: request.PipAPIKey, httpCfg: httpCfg, cacheCfg: cacheCfg}
-	pipIndexMap, err := buildPipIndex(ctx, pipIndexRequest{
+	pipClient := &repoClient{user: request.PipUser, apiKey: request.PipAPIKey, authMode: pipAuthMode, userAgent: request.UserAgent, httpCfg: httpCfg, cacheCfg: cacheCfg, httpClient: httpClient, limiter: limiter, redirectHosts: request.HTTPAuthRedirectHosts, hostCredentials: request.HostCredentials, netrc: netrc, offline: request.Offline}
+	pipIndexMap, pipPackages, err := buildPipIndex(ctx, pipIndexRequest{
 		base:        pipIndex,
 		client:      pipClient,
 		packages:    request.PipPackages,
 		maxPackages: request.PipMax,
 		workerCount: request.PipWorkers,
+		metadata:    request.PipMetadata,
 	})
 	if err != nil {
 		return types.RepoIndexFile{}, err
 	}
 	return types.RepoIndexFile{
-		Apt:         aptVersions,
-		AptPackages: aptPackages,
-		Pip:         pipIndexMap,
+		Apt:                aptVersions,
+		AptPackages:        aptPackages,
+		Pip:                pipIndexMap,
+		PipPackages:        pipPackages,
+		PipAllowPrerelease: request.PipAllowPrerelease,
 	}, nil
 }
 
@@ -162,7 +219,7 @@ func (a RepoIndexWriterAdapter) Write(path string, index types.RepoIndexFile) er
 			WithMsg("failed to create repo index directory").
 			WithCause(err)
 	}
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := writeFileAtomic(path, data, 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write repo index").
@@ -235,7 +292,16 @@ func buildAptIndex(ctx context.Context, sources []aptSource, workerCount int, cl
 }
 
 func buildAptIndexSingle(ctx context.Context, source aptSource, client *repoClient) (map[string]map[string]types.AptPackageVersion, error) {
-	base := strings.TrimRight(strings.TrimSpace(source.Endpoint), "/")
+	if source.DebDir != "" {
+		return scanAptDebDir(ctx, source.DebDir)
+	}
+	if source.PackagesFile != "" {
+		return readAptPackagesFile(source.PackagesFile)
+	}
+	base, err := normalizeAptEndpoint(source.Endpoint)
+	if err != nil {
+		return nil, err
+	}
 	component := strings.TrimSpace(source.Component)
 	if component == "" {
 		component = "main"
@@ -250,21 +316,58 @@ func buildAptIndexSingle(ctx context.Context, source aptSource, client *repoClie
 			WithCode(errbuilder.CodeInvalidArgument).
 			WithMsg("apt distribution is required")
 	}
-	gzURL := fmt.Sprintf("%s/dists/%s/%s/binary-%s/Packages.gz", base, distribution, component, arch)
-	index, notFound, err := fetchAptPackages(ctx, gzURL, client)
+	if source.Flat || isFlatAptDistribution(distribution) {
+		indexURL := base
+		if dir := strings.Trim(strings.TrimPrefix(distribution, "./"), "/"); dir != "" {
+			indexURL += "/" + dir
+		}
+		index, _, err := fetchAptPackagesDir(ctx, indexURL, client)
+		return index, err
+	}
+	componentURL := fmt.Sprintf("%s/dists/%s/%s", base, distribution, component)
+	index, _, err := fetchAptPackagesDir(ctx, componentURL+"/binary-"+arch, client)
+	if err != nil || arch == "all" {
+		return index, err
+	}
+	// Architecture-independent packages are often only listed in
+	// binary-all, which not every repository has.
+	allIndex, notFound, err := fetchAptPackagesDir(ctx, componentURL+"/binary-all", client)
 	if err != nil {
 		return nil, err
 	}
 	if notFound {
-		plainURL := fmt.Sprintf("%s/dists/%s/%s/binary-%s/Packages", base, distribution, component, arch)
-		index, _, err = fetchAptPackages(ctx, plainURL, client)
-		if err != nil {
-			return nil, err
+		return index, nil
+	}
+	if index == nil {
+		index = map[string]map[string]types.AptPackageVersion{}
+	}
+	for name, versions := range allIndex {
+		if index[name] == nil {
+			index[name] = map[string]types.AptPackageVersion{}
+		}
+		for version, metadata := range versions {
+			if _, ok := index[name][version]; !ok {
+				index[name][version] = metadata
+			}
 		}
 	}
 	return index, nil
 }
 
+// fetchAptPackagesDir fetches Packages.gz below indexURL, falling back to
+// the uncompressed Packages file. It reports whether neither exists.
+func fetchAptPackagesDir(ctx context.Context, indexURL string, client *repoClient) (map[string]map[string]types.AptPackageVersion, bool, error) {
+	if client.offline && !client.cached(indexURL+"/Packages.gz") {
+		// An earlier online run may only have found the uncompressed file.
+		return fetchAptPackages(ctx, indexURL+"/Packages", client)
+	}
+	index, notFound, err := fetchAptPackages(ctx, indexURL+"/Packages.gz", client)
+	if err != nil || !notFound {
+		return index, notFound, err
+	}
+	return fetchAptPackages(ctx, indexURL+"/Packages", client)
+}
+
 func fetchAptPackages(ctx context.Context, url string, client *repoClient) (map[string]map[string]types.AptPackageVersion, bool, error) {
 	status, body, header, err := client.fetchURL(ctx, url)
 	if err != nil {
@@ -279,11 +382,34 @@ func fetchAptPackages(ctx context.Context, url string, client *repoClient) (map[
 			WithMsg("failed to fetch apt packages").
 			WithCause(shared.HTTPStatusError(status, url))
 	}
+	index, err := decodeAptPackages(url, body, header)
+	if err != nil {
+		return nil, false, err
+	}
+	return index, false, nil
+}
+
+// readAptPackagesFile parses a Packages (or Packages.gz) file on disk,
+// e.g. one exported from apt, without going through HTTP.
+func readAptPackagesFile(path string) (map[string]map[string]types.AptPackageVersion, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeNotFound).
+			WithMsg(fmt.Sprintf("failed to read apt packages file %s", path)).
+			WithCause(err)
+	}
+	return decodeAptPackages(path, body, nil)
+}
+
+// decodeAptPackages parses the Packages index in body, decompressing it
+// first when name, header or the content itself mark it as gzip.
+func decodeAptPackages(name string, body []byte, header http.Header) (map[string]map[string]types.AptPackageVersion, error) {
 	var reader io.Reader = bytes.NewReader(body)
-	if isGzipContent(url, body, header) {
+	if isGzipContent(name, body, header) {
 		gz, err := gzip.NewReader(bytes.NewReader(body))
 		if err != nil {
-			return nil, false, errbuilder.New().
+			return nil, errbuilder.New().
 				WithCode(errbuilder.CodeInternal).
 				WithMsg("failed to read gzipped apt packages").
 				WithCause(err)
@@ -291,11 +417,7 @@ func fetchAptPackages(ctx context.Context, url string, client *repoClient) (map[
 		defer gz.Close()
 		reader = gz
 	}
-	index, err := parseAptPackages(reader)
-	if err != nil {
-		return nil, false, err
-	}
-	return index, false, nil
+	return parseAptPackages(reader)
 }
 
 // aptStanza accumulates fields for a single APT package stanza while
@@ -306,6 +428,10 @@ type aptStanza struct {
 	dependsRaw    string
 	preDependsRaw string
 	providesRaw   string
+	breaksRaw     string
+	conflictsRaw  string
+	essential     bool
+	priority      string
 	lastField     string
 }
 
@@ -317,6 +443,10 @@ func (s *aptStanza) reset() {
 	s.dependsRaw = ""
 	s.preDependsRaw = ""
 	s.providesRaw = ""
+	s.breaksRaw = ""
+	s.conflictsRaw = ""
+	s.essential = false
+	s.priority = ""
 	s.lastField = ""
 }
 
@@ -334,6 +464,10 @@ func (s *aptStanza) flush(packages map[string]map[string]types.AptPackageVersion
 		Depends:    parseAptDependencyField(s.dependsRaw),
 		PreDepends: parseAptDependencyField(s.preDependsRaw),
 		Provides:   parseAptDependencyField(s.providesRaw),
+		Breaks:     parseAptDependencyField(s.breaksRaw),
+		Conflicts:  parseAptDependencyField(s.conflictsRaw),
+		Essential:  s.essential,
+		Priority:   s.priority,
 	}
 }
 
@@ -360,6 +494,10 @@ func (s *aptStanza) appendContinuation(value string) {
 		s.preDependsRaw = joinField(s.preDependsRaw, value)
 	case "Provides":
 		s.providesRaw = joinField(s.providesRaw, value)
+	case "Breaks":
+		s.breaksRaw = joinField(s.breaksRaw, value)
+	case "Conflicts":
+		s.conflictsRaw = joinField(s.conflictsRaw, value)
 	}
 }
 
@@ -378,11 +516,19 @@ func (s *aptStanza) setField(field string, value string) {
 		s.preDependsRaw = value
 	case "Provides":
 		s.providesRaw = value
+	case "Breaks":
+		s.breaksRaw = value
+	case "Conflicts":
+		s.conflictsRaw = value
+	case "Essential":
+		s.essential = strings.EqualFold(value, "yes")
+	case "Priority":
+		s.priority = strings.ToLower(value)
 	}
 }
 
 // stanzaFields lists the APT Packages file fields we care about.
-var stanzaFields = []string{"Package:", "Version:", "Depends:", "Pre-Depends:", "Provides:"}
+var stanzaFields = []string{"Package:", "Version:", "Depends:", "Pre-Depends:", "Provides:", "Breaks:", "Conflicts:", "Essential:", "Priority:"}
 
 // parseStanzaField checks whether line starts with a known field prefix
 // and returns the field name and trimmed value.
@@ -439,15 +585,17 @@ type pipIndexRequest struct {
 	packages    []string
 	maxPackages int
 	workerCount int
+	// metadata also fetches the Requires-Dist of every indexed release.
+	metadata bool
 }
 
-func buildPipIndex(ctx context.Context, req pipIndexRequest) (map[string][]string, error) {
+func buildPipIndex(ctx context.Context, req pipIndexRequest) (map[string][]string, map[string][]types.PipPackageVersion, error) {
 	simpleBase := normalizePipSimpleIndex(req.base)
 	names := uniqueStrings(normalizePipNames(req.packages))
 	if len(names) == 0 {
 		list, err := fetchPipPackageNames(ctx, simpleBase, req.client)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		names = list
 	}
@@ -455,8 +603,12 @@ func buildPipIndex(ctx context.Context, req pipIndexRequest) (map[string][]strin
 		names = names[:req.maxPackages]
 	}
 	index := map[string][]string{}
+	var packages map[string][]types.PipPackageVersion
+	if req.metadata {
+		packages = map[string][]types.PipPackageVersion{}
+	}
 	if len(names) == 0 {
-		return index, nil
+		return index, packages, nil
 	}
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
@@ -470,6 +622,7 @@ func buildPipIndex(ctx context.Context, req pipIndexRequest) (map[string][]strin
 	type pipResult struct {
 		name     string
 		versions []string
+		releases []types.PipPackageVersion
 		err      error
 	}
 	tasks := make(chan string)
@@ -484,8 +637,17 @@ func buildPipIndex(ctx context.Context, req pipIndexRequest) (map[string][]strin
 					results <- pipResult{name: name, versions: nil, err: ctx.Err()}
 					continue
 				}
-				versions, err := fetchPipPackageVersions(ctx, simpleBase, name, req.client)
-				results <- pipResult{name: name, versions: versions, err: err}
+				files, err := fetchPipProjectFiles(ctx, simpleBase, name, req.client)
+				if err != nil {
+					results <- pipResult{name: name, err: err}
+					continue
+				}
+				versions := sortPep440Versions(pipFileVersions(files))
+				var releases []types.PipPackageVersion
+				if req.metadata {
+					releases, err = fetchPipReleaseMetadata(ctx, req.client, files, versions)
+				}
+				results <- pipResult{name: name, versions: versions, releases: releases, err: err}
 			}
 		}()
 	}
@@ -509,37 +671,101 @@ func buildPipIndex(ctx context.Context, req pipIndexRequest) (map[string][]strin
 		}
 		if result.err == nil && len(result.versions) > 0 {
 			index[result.name] = result.versions
+			if packages != nil {
+				packages[result.name] = result.releases
+			}
 		}
 	}
 	if firstErr != nil {
-		return nil, firstErr
+		return nil, nil, firstErr
 	}
-	return index, nil
+	return index, packages, nil
 }
 
+// maxPipSimplePages bounds how many "next page" links are followed when
+// listing a paginated Simple API root.
+const maxPipSimplePages = 1000
+
+// fetchPipPackageNames lists every project on the Simple API root. Some
+// proxy front-ends paginate the HTML listing; their rel="next" links are
+// followed until the last page.
 func fetchPipPackageNames(ctx context.Context, simpleBase string, client *repoClient) ([]string, error) {
-	status, body, _, err := client.fetchURL(ctx, simpleBase)
+	var names []string
+	visited := map[string]struct{}{}
+	pageURL := simpleBase
+	for pageURL != "" {
+		if _, ok := visited[pageURL]; ok {
+			break
+		}
+		if len(visited) >= maxPipSimplePages {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg(fmt.Sprintf("pip index pagination exceeded %d pages", maxPipSimplePages))
+		}
+		visited[pageURL] = struct{}{}
+		pageNames, next, err := fetchPipPackageNamesPage(ctx, pageURL, client)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, pageNames...)
+		pageURL = next
+	}
+	sort.Strings(names)
+	names = uniqueStrings(names)
+	if len(names) == 0 {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("pip index returned no packages")
+	}
+	return names, nil
+}
+
+// fetchPipPackageNamesPage fetches a single listing page and returns its
+// project names together with the absolute URL of the next page, if any.
+func fetchPipPackageNamesPage(ctx context.Context, pageURL string, client *repoClient) ([]string, string, error) {
+	status, body, header, err := client.fetchURL(ctx, pageURL)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if status < 200 || status >= 300 {
-		return nil, errbuilder.New().
+		return nil, "", errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to fetch pip index").
-			WithCause(shared.HTTPStatusError(status, simpleBase))
+			WithCause(shared.HTTPStatusError(status, pageURL))
+	}
+	format, err := detectPipSimpleFormat(pageURL, header, body)
+	if err != nil {
+		return nil, "", err
+	}
+	if format == pipSimpleJSON {
+		names, err := parsePipSimpleNamesJSON(pageURL, body)
+		return names, "", err
 	}
 	names := parsePipSimpleNames(string(body))
-	if len(names) == 0 {
-		return nil, errbuilder.New().
-			WithCode(errbuilder.CodeInvalidArgument).
-			WithMsg("pip index returned no packages")
+	next := parsePipSimpleNextPage(string(body))
+	if next == "" {
+		return names, "", nil
 	}
-	return names, nil
+	nextURL, err := resolvePipPageURL(pageURL, next)
+	if err != nil {
+		return nil, "", err
+	}
+	return names, nextURL, nil
 }
 
 func fetchPipPackageVersions(ctx context.Context, simpleBase string, name string, client *repoClient) ([]string, error) {
+	files, err := fetchPipProjectFiles(ctx, simpleBase, name, client)
+	if err != nil {
+		return nil, err
+	}
+	return sortPep440Versions(pipFileVersions(files)), nil
+}
+
+// fetchPipProjectFiles lists the distribution files of a project's
+// Simple API page; an unknown project has none.
+func fetchPipProjectFiles(ctx context.Context, simpleBase string, name string, client *repoClient) ([]pipSimpleFile, error) {
 	url := strings.TrimRight(simpleBase, "/") + "/" + name + "/"
-	status, body, _, err := client.fetchURL(ctx, url)
+	status, body, header, err := client.fetchURL(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -552,38 +778,166 @@ func fetchPipPackageVersions(ctx context.Context, simpleBase string, name string
 			WithMsg("failed to fetch pip package").
 			WithCause(shared.HTTPStatusError(status, url))
 	}
-	versions := parsePipVersionsFromSimple(string(body))
-	return sortPep440Versions(versions), nil
+	format, err := detectPipSimpleFormat(url, header, body)
+	if err != nil {
+		return nil, err
+	}
+	if format == pipSimpleJSON {
+		return parsePipSimpleFilesJSON(url, body)
+	}
+	return parsePipSimpleFiles(url, string(body)), nil
 }
 
+// fetchURL GETs url through the on-disk cache. Entries younger than the
+// TTL are served without a request. Older entries that were stored with
+// an ETag or Last-Modified header are revalidated with a conditional
+// request; a 304 serves the cached payload and restarts its TTL.
 func (c *repoClient) fetchURL(ctx context.Context, url string) (int, []byte, http.Header, error) {
-	if c.cacheCfg.dir != "" && c.cacheCfg.ttl > 0 {
-		key := c.cacheKey(url)
+	if c.offline {
+		return c.fetchOffline(url)
+	}
+	cacheEnabled := c.cacheCfg.dir != "" && c.cacheCfg.ttl > 0
+	var key string
+	var stale []byte
+	var validators cacheValidators
+	if cacheEnabled {
+		key = c.cacheKey(url)
 		if payload, ok, err := readCache(c.cacheCfg, key); err != nil {
 			return 0, nil, nil, err
 		} else if ok {
 			return http.StatusOK, payload, http.Header{}, nil
 		}
+		stale, validators = readStaleCache(c.cacheCfg, key)
 	}
-	resp, err := c.doRequest(ctx, url)
+	resp, err := c.doRequest(ctx, url, validators)
 	if err != nil {
 		return 0, nil, nil, err
 	}
 	defer resp.Body.Close()
-	payload, err := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotModified && stale != nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		touchCache(c.cacheCfg, key)
+		return http.StatusOK, stale, resp.Header, nil
+	}
+	payload, err := c.readResumable(ctx, url, resp)
 	if err != nil {
 		return 0, nil, nil, errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to read response body").
 			WithCause(err)
 	}
-	if c.cacheCfg.dir != "" && c.cacheCfg.ttl > 0 && resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		key := c.cacheKey(url)
-		_ = writeCache(c.cacheCfg, key, payload)
+	if cacheEnabled && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if writeCache(c.cacheCfg, key, payload) == nil {
+			_ = writeCacheValidators(c.cacheCfg, key, responseValidators(resp.Header))
+		}
 	}
 	return resp.StatusCode, payload, resp.Header, nil
 }
 
+// fetchOffline serves url from the cache regardless of the entry's age.
+// A miss fails with FailedPrecondition rather than touching the network.
+func (c *repoClient) fetchOffline(url string) (int, []byte, http.Header, error) {
+	if c.cacheCfg.dir != "" {
+		payload, err := os.ReadFile(c.cachePath(url))
+		if err == nil {
+			return http.StatusOK, payload, http.Header{}, nil
+		}
+	}
+	return 0, nil, nil, errbuilder.New().
+		WithCode(errbuilder.CodeFailedPrecondition).
+		WithMsg(fmt.Sprintf("offline mode: %s is not cached and would require a network request", url))
+}
+
+// cached reports whether the cache holds an entry for url.
+func (c *repoClient) cached(url string) bool {
+	if c.cacheCfg.dir == "" {
+		return false
+	}
+	_, err := os.Stat(c.cachePath(url))
+	return err == nil
+}
+
+func (c *repoClient) cachePath(url string) string {
+	return filepath.Join(c.cacheCfg.dir, c.cacheKey(url)+".cache")
+}
+
+// readResumable reads a response body and, when the connection drops
+// mid-transfer, re-requests the remainder with an HTTP Range header
+// starting at the last received byte. The range is conditional on the
+// ETag or Last-Modified of the first response (If-Range), so a resource
+// that changed in between is sent whole. The transfer restarts from
+// zero when the server answers 200, when its Content-Range does not
+// start at the received offset, or when the first response carried no
+// validator to resume against. Bodies transparently decompressed by the
+// transport cannot be resumed by byte offset.
+func (c *repoClient) readResumable(ctx context.Context, url string, resp *http.Response) ([]byte, error) {
+	var buf bytes.Buffer
+	body := resp.Body
+	validators := responseValidators(resp.Header)
+	for attempt := 0; ; attempt++ {
+		_, err := io.Copy(&buf, limitReader(ctx, body, c.limiter))
+		body.Close()
+		if err == nil {
+			return buf.Bytes(), nil
+		}
+		if ctx.Err() != nil || resp.Uncompressed || attempt >= c.httpCfg.retries-1 {
+			return nil, err
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return nil, err
+		}
+		offset := int64(buf.Len())
+		if validators.ifRange() == "" {
+			offset = 0
+		}
+		log.Ctx(ctx).Debug().Str("url", url).Int64("offset", offset).Err(err).Msg("resuming interrupted download")
+		next, reqErr := c.doRangeRequest(ctx, url, offset, validators)
+		if reqErr != nil {
+			return nil, reqErr
+		}
+		if next.StatusCode == http.StatusPartialContent {
+			if start, ok := contentRangeStart(next.Header); !ok || start != offset {
+				_, _ = io.Copy(io.Discard, next.Body)
+				next.Body.Close()
+				log.Ctx(ctx).Debug().Str("url", url).Int64("offset", offset).Msg("server resumed at another offset, restarting download")
+				next, reqErr = c.doRequest(ctx, url, cacheValidators{})
+				if reqErr != nil {
+					return nil, reqErr
+				}
+			}
+		}
+		switch next.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusOK:
+			buf.Reset()
+			validators = responseValidators(next.Header)
+		default:
+			_, _ = io.Copy(io.Discard, next.Body)
+			next.Body.Close()
+			return nil, err
+		}
+		body = next.Body
+	}
+}
+
+// contentRangeStart returns the first byte position of a 206 response's
+// Content-Range header, e.g. 100 for "bytes 100-199/200".
+func contentRangeStart(header http.Header) (int64, bool) {
+	value, ok := strings.CutPrefix(strings.TrimSpace(header.Get("Content-Range")), "bytes ")
+	if !ok {
+		return 0, false
+	}
+	first, _, ok := strings.Cut(value, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return start, true
+}
+
 func isGzipContent(url string, data []byte, header http.Header) bool {
 	if strings.HasSuffix(url, ".gz") {
 		return true
@@ -638,7 +992,7 @@ func writeCache(cfg cacheConfig, key string, data []byte) error {
 			WithCause(err)
 	}
 	path := filepath.Join(cfg.dir, key+".cache")
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := writeFileAtomic(path, data, 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write cache file").
@@ -647,6 +1001,99 @@ func writeCache(cfg cacheConfig, key string, data []byte) error {
 	return nil
 }
 
+// cacheValidators are the ETag and Last-Modified headers of a cached
+// response, stored next to its payload so an expired entry can be
+// revalidated instead of downloaded again.
+type cacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+func responseValidators(header http.Header) cacheValidators {
+	return cacheValidators{
+		ETag:         strings.TrimSpace(header.Get("ETag")),
+		LastModified: strings.TrimSpace(header.Get("Last-Modified")),
+	}
+}
+
+func (v cacheValidators) empty() bool {
+	return v.ETag == "" && v.LastModified == ""
+}
+
+// ifRange returns the validator for an If-Range header: a strong ETag,
+// or else the Last-Modified date. Weak ETags cannot guard a range.
+func (v cacheValidators) ifRange() string {
+	if v.ETag != "" && !strings.HasPrefix(v.ETag, "W/") {
+		return v.ETag
+	}
+	return v.LastModified
+}
+
+// apply turns the validators into If-None-Match and If-Modified-Since
+// request headers.
+func (v cacheValidators) apply(req *http.Request) {
+	if v.ETag != "" {
+		req.Header.Set("If-None-Match", v.ETag)
+	}
+	if v.LastModified != "" {
+		req.Header.Set("If-Modified-Since", v.LastModified)
+	}
+}
+
+// readStaleCache returns the payload and validators of a cache entry
+// regardless of its age. Entries without validators, or that cannot be
+// read, yield a nil payload so the caller falls back to a plain GET.
+func readStaleCache(cfg cacheConfig, key string) ([]byte, cacheValidators) {
+	meta, err := os.ReadFile(filepath.Join(cfg.dir, key+".meta"))
+	if err != nil {
+		return nil, cacheValidators{}
+	}
+	var validators cacheValidators
+	if err := json.Unmarshal(meta, &validators); err != nil || validators.empty() {
+		return nil, cacheValidators{}
+	}
+	payload, err := os.ReadFile(filepath.Join(cfg.dir, key+".cache"))
+	if err != nil {
+		return nil, cacheValidators{}
+	}
+	return payload, validators
+}
+
+// writeCacheValidators stores the validators of a cache entry, removing
+// stale ones when the response carried none.
+func writeCacheValidators(cfg cacheConfig, key string, validators cacheValidators) error {
+	path := filepath.Join(cfg.dir, key+".meta")
+	if validators.empty() {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to remove cache validators").
+				WithCause(err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(validators)
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to marshal cache validators").
+			WithCause(err)
+	}
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to write cache validators").
+			WithCause(err)
+	}
+	return nil
+}
+
+// touchCache restarts the TTL of a revalidated cache entry.
+func touchCache(cfg cacheConfig, key string) {
+	now := time.Now()
+	_ = os.Chtimes(filepath.Join(cfg.dir, key+".cache"), now, now)
+}
+
 func normalizePipSimpleIndex(base string) string {
 	trimmed := strings.TrimRight(strings.TrimSpace(base), "/")
 	if strings.HasSuffix(trimmed, "/simple") {
@@ -655,12 +1102,21 @@ func normalizePipSimpleIndex(base string) string {
 	return trimmed + "/simple/"
 }
 
+var (
+	pipSimpleAnchorPattern = regexp.MustCompile(`(?is)<a([^>]*)>([^<]+)</a>`)
+	pipSimpleLinkPattern   = regexp.MustCompile(`(?is)<(?:a|link)\b([^>]*)>`)
+	pipSimpleRelPattern    = regexp.MustCompile(`(?i)\brel\s*=\s*["']?([^"'\s>]+)`)
+	pipSimpleHrefPattern   = regexp.MustCompile(`(?i)\bhref\s*=\s*["']([^"']+)["']`)
+)
+
 func parsePipSimpleNames(content string) []string {
-	re := regexp.MustCompile(`(?is)<a[^>]*>([^<]+)</a>`)
-	matches := re.FindAllStringSubmatch(content, -1)
+	matches := pipSimpleAnchorPattern.FindAllStringSubmatch(content, -1)
 	var names []string
 	for _, match := range matches {
-		name := strings.TrimSpace(match[1])
+		if isPipSimplePagingRel(match[1]) {
+			continue
+		}
+		name := strings.TrimSpace(match[2])
 		if name == "" {
 			continue
 		}
@@ -670,24 +1126,55 @@ func parsePipSimpleNames(content string) []string {
 	return uniqueStrings(names)
 }
 
-func parsePipVersionsFromSimple(content string) []string {
-	re := regexp.MustCompile(`href=["']([^"']+)["']`)
-	matches := re.FindAllStringSubmatch(content, -1)
-	versions := map[string]struct{}{}
-	for _, match := range matches {
-		raw := strings.Split(match[1], "#")[0]
-		raw = strings.Split(raw, "?")[0]
-		filename := filepath.Base(raw)
-		version := parsePipVersionFromFilename(filename)
-		if version == "" {
+// parsePipSimpleNextPage returns the href of the rel="next" anchor or
+// link element of a paginated listing, or "" on the last page.
+func parsePipSimpleNextPage(content string) string {
+	for _, match := range pipSimpleLinkPattern.FindAllStringSubmatch(content, -1) {
+		rel := pipSimpleRelPattern.FindStringSubmatch(match[1])
+		if rel == nil || !strings.EqualFold(rel[1], "next") {
 			continue
 		}
-		if _, err := pep440.Parse(version); err != nil {
-			continue
+		if href := pipSimpleHrefPattern.FindStringSubmatch(match[1]); href != nil {
+			return html.UnescapeString(strings.TrimSpace(href[1]))
 		}
-		versions[version] = struct{}{}
 	}
-	return mapKeys(versions)
+	return ""
+}
+
+func isPipSimplePagingRel(attrs string) bool {
+	rel := pipSimpleRelPattern.FindStringSubmatch(attrs)
+	if rel == nil {
+		return false
+	}
+	switch strings.ToLower(rel[1]) {
+	case "next", "prev", "previous", "first", "last":
+		return true
+	}
+	return false
+}
+
+func resolvePipPageURL(pageURL string, href string) (string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("invalid pip index url").
+			WithCause(err)
+	}
+	ref, err := url.Parse(href)
+	if err != nil {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("invalid pip index next page link").
+			WithCause(err)
+	}
+	return base.ResolveReference(ref).String(), nil
+}
+
+// parsePipVersionsFromSimple extracts PEP 440 versions from a Simple API
+// project page, pre-releases and dev releases included.
+func parsePipVersionsFromSimple(content string) []string {
+	return pipFileVersions(parsePipSimpleFiles("", content))
 }
 
 func parsePipVersionFromFilename(filename string) string {
@@ -849,8 +1336,98 @@ func resolveAptSources(values []string, endpoint string, distribution string, co
 	return sources
 }
 
+// isFlatAptDistribution reports whether distribution names a flat
+// repository, which apt spells as a path ending in a slash, e.g. "./".
+func isFlatAptDistribution(distribution string) bool {
+	return strings.HasSuffix(strings.TrimSpace(distribution), "/")
+}
+
+// normalizeAptEndpoint checks that endpoint is an absolute http or https
+// URL and returns it without trailing slashes.
+func normalizeAptEndpoint(endpoint string) (string, error) {
+	base := strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	parsed, err := url.Parse(base)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		builder := errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("apt endpoint %q must be an absolute http or https URL (e.g., https://packages.avular.dev/debian/avular)", endpoint))
+		if err != nil {
+			builder = builder.WithCause(err)
+		}
+		return "", builder
+	}
+	return base, nil
+}
+
+// localAptSources turns each non-empty deb directory into an apt source.
+func localAptSources(dirs []string) []aptSource {
+	var sources []aptSource
+	for _, dir := range dirs {
+		if dir = strings.TrimSpace(dir); dir != "" {
+			sources = append(sources, aptSource{DebDir: dir})
+		}
+	}
+	return sources
+}
+
+// scanAptDebDir indexes every deb below dir from its control file, for
+// air-gapped builds with a local pool instead of a Packages URL. Debs of
+// every architecture are included.
+func scanAptDebDir(ctx context.Context, dir string) (map[string]map[string]types.AptPackageVersion, error) {
+	debPaths, err := listDebs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(debPaths) == 0 {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("no deb artifacts found in %s", dir))
+	}
+	packages := map[string]map[string]types.AptPackageVersion{}
+	for _, path := range debPaths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		control, err := readDebControl(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		index, err := parseAptPackages(strings.NewReader(control + "\n"))
+		if err != nil {
+			return nil, err
+		}
+		if len(index) == 0 {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("deb %s is missing Package or Version", filepath.Base(path)))
+		}
+		for name, versions := range index {
+			if packages[name] == nil {
+				packages[name] = map[string]types.AptPackageVersion{}
+			}
+			for version, metadata := range versions {
+				packages[name][version] = metadata
+			}
+		}
+	}
+	return packages, nil
+}
+
+// parseAptSource parses an --apt-source entry. Besides the
+// endpoint|distribution|component|arch form, where a distribution
+// ending in a slash such as "./" selects a flat repository without
+// component and arch, it accepts the path of a
+// local Packages file, either as a file:// URL or, without any "|"
+// fields, as a plain path.
 func parseAptSource(value string) (aptSource, error) {
 	parts := strings.Split(value, "|")
+	first := strings.TrimSpace(parts[0])
+	if path, ok := strings.CutPrefix(first, "file://"); ok && path != "" {
+		return aptSource{PackagesFile: path}, nil
+	}
+	if len(parts) == 1 && first != "" && !strings.Contains(first, "://") {
+		return aptSource{PackagesFile: first}, nil
+	}
 	if len(parts) < 2 {
 		return aptSource{}, errbuilder.New().
 			WithCode(errbuilder.CodeInvalidArgument).
@@ -860,6 +1437,10 @@ func parseAptSource(value string) (aptSource, error) {
 		Endpoint:     strings.TrimSpace(parts[0]),
 		Distribution: strings.TrimSpace(parts[1]),
 	}
+	if isFlatAptDistribution(source.Distribution) {
+		source.Flat = true
+		return source, nil
+	}
 	if len(parts) > 2 {
 		source.Component = strings.TrimSpace(parts[2])
 	}
@@ -869,8 +1450,69 @@ func parseAptSource(value string) (aptSource, error) {
 	return source, nil
 }
 
-func (c *repoClient) doRequest(ctx context.Context, url string) (*http.Response, error) {
-	client := &http.Client{Timeout: c.httpCfg.timeout}
+const (
+	authModeBasic  = "basic"
+	authModeBearer = "bearer"
+)
+
+// normalizeAuthMode validates an auth mode, defaulting to basic.
+func normalizeAuthMode(mode string) (string, error) {
+	switch normalized := strings.ToLower(strings.TrimSpace(mode)); normalized {
+	case "", authModeBasic:
+		return authModeBasic, nil
+	case authModeBearer:
+		return authModeBearer, nil
+	default:
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("unsupported auth mode %q (expected basic or bearer)", mode))
+	}
+}
+
+func (c *repoClient) doRequest(ctx context.Context, url string, validators cacheValidators) (*http.Response, error) {
+	return c.doRangeRequest(ctx, url, 0, validators)
+}
+
+// applyAuth sets the configured credentials on req, if any: basic auth
+// by default, or the API key as a bearer token in bearer mode. Without
+// an API key the .netrc entry for the request host is used, as pip does.
+// Credentials configured for the request host take precedence over both.
+func (c *repoClient) applyAuth(req *http.Request) {
+	user, apiKey := c.user, c.apiKey
+	if credential, ok := c.hostCredentials[strings.ToLower(req.URL.Hostname())]; ok {
+		user, apiKey = credential.User, credential.APIKey
+	}
+	if strings.TrimSpace(apiKey) == "" {
+		if login, ok := c.netrc.lookup(req.URL.Hostname()); ok {
+			req.SetBasicAuth(login.login, login.password)
+		}
+		return
+	}
+	if c.authMode == authModeBearer {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		return
+	}
+	authUser := strings.TrimSpace(user)
+	if authUser == "" {
+		authUser = "api"
+	}
+	req.SetBasicAuth(authUser, apiKey)
+}
+
+// doRangeRequest performs a GET with retries; a positive offset requests
+// the remainder of the resource from that byte onwards, and validators
+// then guard the range through If-Range. Otherwise non-empty validators
+// make the request conditional.
+func (c *repoClient) doRangeRequest(ctx context.Context, url string, offset int64, validators cacheValidators) (*http.Response, error) {
+	client := c.httpClient
+	if client == nil {
+		client = &http.Client{Timeout: c.httpCfg.timeout}
+	}
+	if len(c.redirectHosts) > 0 {
+		redirecting := *client
+		redirecting.CheckRedirect = authRedirectPolicy(c.redirectHosts, c.applyAuth)
+		client = &redirecting
+	}
 	var lastErr error
 	for attempt := 0; attempt < c.httpCfg.retries; attempt++ {
 		if ctx.Err() != nil {
@@ -886,13 +1528,16 @@ func (c *repoClient) doRequest(ctx context.Context, url string) (*http.Response,
 				WithMsg("failed to create request").
 				WithCause(err)
 		}
-		if strings.TrimSpace(c.apiKey) != "" {
-			authUser := strings.TrimSpace(c.user)
-			if authUser == "" {
-				authUser = "api"
+		applyUserAgent(req, c.userAgent)
+		if offset > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+			if ifRange := validators.ifRange(); ifRange != "" {
+				req.Header.Set("If-Range", ifRange)
 			}
-			req.SetBasicAuth(authUser, c.apiKey)
+		} else {
+			validators.apply(req)
 		}
+		c.applyAuth(req)
 		resp, err := client.Do(req)
 		if err != nil {
 			if ctx.Err() != nil {
@@ -933,8 +1578,7 @@ func httpRetryDelay(attempt int, cfg httpRetryConfig) time.Duration {
 	if delay > maxHTTPRetryDelay {
 		delay = maxHTTPRetryDelay
 	}
-	jitter := time.Duration(time.Now().UnixNano() % int64(delay/2+1))
-	return delay + jitter
+	return delay + shared.Jitter(delay/2)
 }
 
 var _ ports.RepoIndexBuilderPort = RepoIndexBuilderAdapter{}
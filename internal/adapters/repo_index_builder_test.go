@@ -1,12 +1,30 @@
 package adapters
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/ZanzyTHEbar/errbuilder-go"
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/ports"
+	"avular-packages/internal/shared"
+	"avular-packages/internal/types"
 )
 
 func TestParseAptPackages(t *testing.T) {
@@ -16,6 +34,9 @@ func TestParseAptPackages(t *testing.T) {
 		"Depends: libc6 (>= 2.31), libbar | libbaz",
 		"Pre-Depends: dpkg (>= 1.19)",
 		"Provides: foo-virtual",
+		"Breaks: libold (<< 2.0)",
+		"Conflicts: libfoo-legacy,",
+		" libfoo-ng",
 		"",
 		"Package: libfoo",
 		"Version: 1.1.0",
@@ -49,6 +70,35 @@ func TestParseAptPackages(t *testing.T) {
 	if diff := cmp.Diff([]string{"foo-virtual"}, provides); diff != "" {
 		t.Fatalf("unexpected provides (-want +got):\n%s", diff)
 	}
+	if diff := cmp.Diff([]string{"libold (<< 2.0)"}, fooVersions["1.0.0"].Breaks); diff != "" {
+		t.Fatalf("unexpected breaks (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"libfoo-legacy", "libfoo-ng"}, fooVersions["1.0.0"].Conflicts); diff != "" {
+		t.Fatalf("unexpected conflicts (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseAptPackagesEssentialAndPriority(t *testing.T) {
+	content := strings.Join([]string{
+		"Package: base-files",
+		"Essential: yes",
+		"Priority: required",
+		"Version: 12ubuntu4",
+		"",
+		"Package: libfoo",
+		"Priority: Optional",
+		"Version: 1.0.0",
+		"",
+	}, "\n")
+	index, err := parseAptPackages(strings.NewReader(content))
+	require.NoError(t, err)
+	want := map[string]map[string]types.AptPackageVersion{
+		"base-files": {"12ubuntu4": {Version: "12ubuntu4", Essential: true, Priority: "required"}},
+		"libfoo":     {"1.0.0": {Version: "1.0.0", Priority: "optional"}},
+	}
+	if diff := cmp.Diff(want, index); diff != "" {
+		t.Fatalf("unexpected apt packages (-want +got):\n%s", diff)
+	}
 }
 
 func TestParsePipSimpleNames(t *testing.T) {
@@ -97,6 +147,13 @@ func TestParsePipVersionsFromSimple(t *testing.T) {
 			html: `<a href="demo.whl">bad</a><a href="demo-1.0.0.tar.gz">ok</a>`,
 			want: []string{"1.0.0"},
 		},
+		{
+			name: "keeps pre-releases",
+			html: `<a href="demo-1.0.0.tar.gz">a</a>` +
+				`<a href="demo-2.0.0rc1.tar.gz">b</a>` +
+				`<a href="demo-2.0.0.dev1.tar.gz">c</a>`,
+			want: []string{"1.0.0", "2.0.0.dev1", "2.0.0rc1"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -111,6 +168,14 @@ func TestParsePipVersionsFromSimple(t *testing.T) {
 	}
 }
 
+func TestSortPep440VersionsOrdersPrereleases(t *testing.T) {
+	versions := sortPep440Versions([]string{"2.0.0rc1", "1.0.0", "2.0.0.dev1"})
+	expected := []string{"1.0.0", "2.0.0.dev1", "2.0.0rc1"}
+	if diff := cmp.Diff(expected, versions); diff != "" {
+		t.Fatalf("unexpected version order (-want +got):\n%s", diff)
+	}
+}
+
 func TestParsePipVersionFromFilename(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -143,3 +208,731 @@ func TestParsePipVersionFromFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestRepoIndexBuilderSendsUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents = append(agents, r.Header.Get("User-Agent"))
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/apt/dists/dev/main/binary-amd64/Packages":
+			_, _ = w.Write([]byte("Package: libfoo\nVersion: 1.0.0\n\n"))
+		case "/simple/":
+			_, _ = w.Write([]byte(`<a href="/simple/demo/">demo</a>`))
+		case "/simple/demo/":
+			_, _ = w.Write([]byte(`<a href="demo-1.0.0-py3-none-any.whl">demo-1.0.0-py3-none-any.whl</a>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	_, err := NewRepoIndexBuilderAdapter().Build(t.Context(), ports.RepoIndexBuildRequest{
+		AptSources:  []string{server.URL + "/apt|dev|main|amd64"},
+		PipIndex:    server.URL,
+		HTTPRetries: 1,
+		UserAgent:   "avular-packages/1.2.3",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, agents)
+	for _, agent := range agents {
+		if diff := cmp.Diff("avular-packages/1.2.3", agent); diff != "" {
+			t.Fatalf("unexpected user agent (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestBuildAptIndexScansLocalDebDir(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb not available")
+	}
+	debsDir := t.TempDir()
+	buildTestDeb(t, debsDir, "libfoo", "1.0.0", "amd64", "Depends: libbar (>= 2.0), libc6 | libc6-compat", "Provides: foo-virtual")
+	buildTestDeb(t, debsDir, "libfoo", "1.1.0", "amd64")
+	buildTestDeb(t, debsDir, "libbar", "2.0.0", "all", "Breaks: libold (<< 1.0)")
+
+	versions, packages, err := buildAptIndex(t.Context(), localAptSources([]string{debsDir}), 0, &repoClient{})
+	require.NoError(t, err)
+	if diff := cmp.Diff(map[string][]string{
+		"libbar": {"2.0.0"},
+		"libfoo": {"1.0.0", "1.1.0"},
+	}, versions); diff != "" {
+		t.Fatalf("unexpected versions (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(map[string][]types.AptPackageVersion{
+		"libbar": {{Version: "2.0.0", Breaks: []string{"libold (<< 1.0)"}}},
+		"libfoo": {
+			{Version: "1.0.0", Depends: []string{"libbar (>= 2.0)", "libc6 | libc6-compat"}, Provides: []string{"foo-virtual"}},
+			{Version: "1.1.0"},
+		},
+	}, packages); diff != "" {
+		t.Fatalf("unexpected packages (-want +got):\n%s", diff)
+	}
+
+	_, _, err = buildAptIndex(t.Context(), localAptSources([]string{t.TempDir()}), 0, &repoClient{})
+	require.ErrorContains(t, err, "no deb artifacts found")
+}
+
+func TestBuildAptIndexReadsLocalPackagesFile(t *testing.T) {
+	dir := t.TempDir()
+	content := "Package: libfoo\nVersion: 1.0.0\nDepends: libbar (>= 2.0)\n\nPackage: libbar\nVersion: 2.0.0\n"
+	plain := filepath.Join(dir, "Packages")
+	require.NoError(t, os.WriteFile(plain, []byte(content), 0644))
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("Package: libfoo\nVersion: 1.1.0\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	gzipped := filepath.Join(dir, "Packages.gz")
+	require.NoError(t, os.WriteFile(gzipped, compressed.Bytes(), 0644))
+
+	sources := resolveAptSources([]string{plain, "file://" + gzipped}, "", "", "", "")
+	if diff := cmp.Diff([]aptSource{{PackagesFile: plain}, {PackagesFile: gzipped}}, sources); diff != "" {
+		t.Fatalf("unexpected sources (-want +got):\n%s", diff)
+	}
+	versions, packages, err := buildAptIndex(t.Context(), sources, 0, &repoClient{})
+	require.NoError(t, err)
+	if diff := cmp.Diff(map[string][]string{
+		"libbar": {"2.0.0"},
+		"libfoo": {"1.0.0", "1.1.0"},
+	}, versions); diff != "" {
+		t.Fatalf("unexpected versions (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"libbar (>= 2.0)"}, packages["libfoo"][0].Depends); diff != "" {
+		t.Fatalf("unexpected depends (-want +got):\n%s", diff)
+	}
+
+	_, _, err = buildAptIndex(t.Context(), []aptSource{{PackagesFile: filepath.Join(dir, "missing")}}, 0, &repoClient{})
+	require.ErrorContains(t, err, "failed to read apt packages file")
+}
+
+func TestBuildAptIndexFlatAndStandardLayouts(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("Package: libflat\nVersion: 1.0.0\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flat/Packages.gz":
+			_, _ = w.Write(compressed.Bytes())
+		case "/mirror/pool/Packages":
+			_, _ = w.Write([]byte("Package: libpool\nVersion: 3.0.0\n"))
+		case "/apt/dists/dev/main/binary-amd64/Packages":
+			_, _ = w.Write([]byte("Package: libfoo\nVersion: 2.0.0\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sources := resolveAptSources([]string{
+		server.URL + "/flat|./",
+		server.URL + "/mirror|pool/",
+		server.URL + "/apt|dev|main|amd64",
+	}, "", "", "", "")
+	if diff := cmp.Diff([]aptSource{
+		{Endpoint: server.URL + "/flat", Distribution: "./", Flat: true},
+		{Endpoint: server.URL + "/mirror", Distribution: "pool/", Flat: true},
+		{Endpoint: server.URL + "/apt", Distribution: "dev", Component: "main", Arch: "amd64"},
+	}, sources); diff != "" {
+		t.Fatalf("unexpected sources (-want +got):\n%s", diff)
+	}
+	versions, _, err := buildAptIndex(t.Context(), sources, 0, &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 1)})
+	require.NoError(t, err)
+	if diff := cmp.Diff(map[string][]string{
+		"libflat": {"1.0.0"},
+		"libfoo":  {"2.0.0"},
+		"libpool": {"3.0.0"},
+	}, versions); diff != "" {
+		t.Fatalf("unexpected versions (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildAptIndexMergesBinaryAll(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("Package: python3-demo\nVersion: 1.0.0\n\nPackage: libfoo\nVersion: 2.0.0\nDepends: libold\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apt/dists/dev/main/binary-amd64/Packages", "/apt/dists/stable/main/binary-amd64/Packages":
+			_, _ = w.Write([]byte("Package: libfoo\nVersion: 2.0.0\n"))
+		case "/apt/dists/dev/main/binary-all/Packages.gz":
+			_, _ = w.Write(compressed.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 1)}
+
+	index, err := buildAptIndexSingle(t.Context(), aptSource{Endpoint: server.URL + "/apt", Distribution: "dev", Arch: "amd64"}, client)
+	require.NoError(t, err)
+	if diff := cmp.Diff(map[string]map[string]types.AptPackageVersion{
+		"libfoo":       {"2.0.0": {Version: "2.0.0"}},
+		"python3-demo": {"1.0.0": {Version: "1.0.0"}},
+	}, index); diff != "" {
+		t.Fatalf("unexpected merged index (-want +got):\n%s", diff)
+	}
+
+	index, err = buildAptIndexSingle(t.Context(), aptSource{Endpoint: server.URL + "/apt", Distribution: "stable", Arch: "amd64"}, client)
+	require.NoError(t, err)
+	if diff := cmp.Diff(map[string]map[string]types.AptPackageVersion{
+		"libfoo": {"2.0.0": {Version: "2.0.0"}},
+	}, index); diff != "" {
+		t.Fatalf("unexpected index without binary-all (-want +got):\n%s", diff)
+	}
+}
+
+func TestNormalizeAptEndpoint(t *testing.T) {
+	for _, endpoint := range []string{"https://packages.avular.dev/debian/avular/", " http://localhost:8080 "} {
+		_, err := normalizeAptEndpoint(endpoint)
+		require.NoError(t, err, endpoint)
+	}
+	base, err := normalizeAptEndpoint("https://packages.avular.dev/debian/avular//")
+	require.NoError(t, err)
+	if diff := cmp.Diff("https://packages.avular.dev/debian/avular", base); diff != "" {
+		t.Fatalf("unexpected endpoint (-want +got):\n%s", diff)
+	}
+	for _, endpoint := range []string{"packages.avular.dev/debian/avular", "ftp://packages.avular.dev", "https://", "::"} {
+		_, err := normalizeAptEndpoint(endpoint)
+		require.ErrorContains(t, err, "must be an absolute http or https URL", endpoint)
+	}
+
+	_, err = NewRepoIndexBuilderAdapter().Build(t.Context(), ports.RepoIndexBuildRequest{
+		AptEndpoint:     "packages.avular.dev",
+		AptDistribution: "dev",
+		PipIndex:        "https://pypi.org",
+	})
+	require.ErrorContains(t, err, `apt endpoint "packages.avular.dev" must be an absolute http or https URL`)
+}
+
+func TestRepoClientDefaultUserAgent(t *testing.T) {
+	var agent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 0)}
+	status, _, _, err := client.fetchURL(t.Context(), server.URL)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	if diff := cmp.Diff("avular-packages", agent); diff != "" {
+		t.Fatalf("unexpected user agent (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchURLResumesInterruptedDownload(t *testing.T) {
+	payload := []byte(strings.Repeat("Package: libfoo\nVersion: 1.0.0\n\n", 200))
+	half := len(payload) / 2
+	var mu sync.Mutex
+	var ranges []string
+	var ifRanges []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rangeHeader := r.Header.Get("Range")
+		mu.Lock()
+		ranges = append(ranges, rangeHeader)
+		ifRanges = append(ifRanges, r.Header.Get("If-Range"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		if rangeHeader == "" {
+			// Promise the full body, send half, then drop the connection.
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(payload[:half])
+			w.(http.Flusher).Flush()
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				_ = conn.Close()
+			}
+			return
+		}
+		var offset int
+		_, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &offset)
+		if err != nil || offset > len(payload) {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, len(payload)-1, len(payload)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(payload[offset:])
+	}))
+	defer server.Close()
+
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 3, 1)}
+	status, body, _, err := client.fetchURL(t.Context(), server.URL+"/Packages")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	if diff := cmp.Diff(string(payload), string(body)); diff != "" {
+		t.Fatalf("unexpected payload (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"", fmt.Sprintf("bytes=%d-", half)}, ranges); diff != "" {
+		t.Fatalf("unexpected range requests (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"", `"v1"`}, ifRanges); diff != "" {
+		t.Fatalf("unexpected If-Range headers (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchURLRestartsDownloadWhenResumeDoesNotMatch(t *testing.T) {
+	payload := []byte(strings.Repeat("Package: libfoo\nVersion: 2.0.0\n\n", 200))
+	stale := []byte(strings.Repeat("Package: libfoo\nVersion: 1.0.0\n\n", 200))
+	half := len(payload) / 2
+	tests := []struct {
+		name string
+		// resume answers the range request of the interrupted transfer.
+		resume func(w http.ResponseWriter, offset int)
+	}{
+		{
+			name: "changed resource",
+			resume: func(w http.ResponseWriter, _ int) {
+				w.Header().Set("ETag", `"v2"`)
+				_, _ = w.Write(payload)
+			},
+		},
+		{
+			name: "other offset",
+			resume: func(w http.ResponseWriter, offset int) {
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset-1, len(payload)-1, len(payload)))
+				w.WriteHeader(http.StatusPartialContent)
+				_, _ = w.Write(payload[offset-1:])
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var ranges []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rangeHeader := r.Header.Get("Range")
+				mu.Lock()
+				ranges = append(ranges, rangeHeader)
+				first := len(ranges) == 1
+				mu.Unlock()
+				if first {
+					w.Header().Set("ETag", `"v1"`)
+					w.Header().Set("Content-Length", strconv.Itoa(len(stale)))
+					w.WriteHeader(http.StatusOK)
+					_, _ = w.Write(stale[:half])
+					w.(http.Flusher).Flush()
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err == nil {
+						_ = conn.Close()
+					}
+					return
+				}
+				if rangeHeader == "" {
+					_, _ = w.Write(payload)
+					return
+				}
+				var offset int
+				_, _ = fmt.Sscanf(rangeHeader, "bytes=%d-", &offset)
+				tt.resume(w, offset)
+			}))
+			defer server.Close()
+
+			client := &repoClient{httpCfg: normalizeHTTPConfig(0, 3, 1)}
+			status, body, _, err := client.fetchURL(t.Context(), server.URL+"/Packages")
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, status)
+			if diff := cmp.Diff(string(payload), string(body)); diff != "" {
+				t.Fatalf("unexpected payload (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFetchURLReappliesAuthOnAllowedRedirect(t *testing.T) {
+	var mu sync.Mutex
+	var cdnAuth []string
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		cdnAuth = append(cdnAuth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		_, _ = w.Write([]byte("Package: libfoo\nVersion: 1.0.0\n"))
+	}))
+	defer cdn.Close()
+	cdnURL := strings.Replace(cdn.URL, "127.0.0.1", "localhost", 1)
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, cdnURL+r.URL.Path, http.StatusFound)
+	}))
+	defer mirror.Close()
+
+	for _, redirectHosts := range [][]string{{"localhost"}, nil} {
+		client := &repoClient{user: "ci", apiKey: "secret", httpCfg: normalizeHTTPConfig(0, 1, 1), redirectHosts: redirectHosts}
+		status, _, _, err := client.fetchURL(t.Context(), mirror.URL+"/Packages")
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+	}
+
+	want := []string{"Basic " + base64.StdEncoding.EncodeToString([]byte("ci:secret")), ""}
+	if diff := cmp.Diff(want, cdnAuth); diff != "" {
+		t.Fatalf("unexpected CDN authorization (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchURLUsesNetrcCredentials(t *testing.T) {
+	var mu sync.Mutex
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		_, _ = w.Write([]byte("<a href=\"demo-1.0.0.tar.gz\">demo-1.0.0.tar.gz</a>"))
+	}))
+	defer server.Close()
+	netrc := filepath.Join(t.TempDir(), "netrc")
+	require.NoError(t, os.WriteFile(netrc, []byte("machine pypi.example login other password nope\n\nmachine 127.0.0.1\n  login pip\n  password s3cret\n"), 0o600))
+	t.Setenv("NETRC", netrc)
+
+	clients := []*repoClient{
+		{httpCfg: normalizeHTTPConfig(0, 1, 1), netrc: loadNetrc(netrcPath())},
+		{user: "ci", apiKey: "explicit", httpCfg: normalizeHTTPConfig(0, 1, 1), netrc: loadNetrc(netrcPath())},
+		{httpCfg: normalizeHTTPConfig(0, 1, 1)},
+	}
+	for _, client := range clients {
+		_, _, _, err := client.fetchURL(t.Context(), server.URL+"/simple/demo/")
+		require.NoError(t, err)
+	}
+	localhost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	_, _, _, err := clients[0].fetchURL(t.Context(), localhost+"/simple/demo/")
+	require.NoError(t, err)
+
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+	want := []string{basic("pip", "s3cret"), basic("ci", "explicit"), "", ""}
+	if diff := cmp.Diff(want, auth); diff != "" {
+		t.Fatalf("unexpected authorization (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchURLUsesPerHostCredentials(t *testing.T) {
+	var mu sync.Mutex
+	auth := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		host, _, _ := strings.Cut(r.Host, ":")
+		auth[host] = append(auth[host], r.Header.Get("Authorization"))
+		mu.Unlock()
+		_, _ = w.Write([]byte("Package: libfoo\nVersion: 1.0.0\n"))
+	}))
+	defer server.Close()
+
+	client := &repoClient{
+		user:     "global",
+		apiKey:   "global-key",
+		authMode: authModeBasic,
+		httpCfg:  normalizeHTTPConfig(0, 1, 1),
+		hostCredentials: map[string]ports.HostCredential{
+			"127.0.0.1": {User: "mirror", APIKey: "mirror-key"},
+			"localhost": {APIKey: "cdn-key"},
+		},
+	}
+	localhost := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	for _, url := range []string{server.URL, localhost} {
+		_, _, _, err := client.fetchURL(t.Context(), url+"/Packages")
+		require.NoError(t, err)
+	}
+	delete(client.hostCredentials, "localhost")
+	_, _, _, err := client.fetchURL(t.Context(), localhost+"/Packages.gz")
+	require.NoError(t, err)
+
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+	want := map[string][]string{
+		"127.0.0.1": {basic("mirror", "mirror-key")},
+		"localhost": {basic("api", "cdn-key"), basic("global", "global-key")},
+	}
+	if diff := cmp.Diff(want, auth); diff != "" {
+		t.Fatalf("unexpected authorization (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchURLSendsBearerToken(t *testing.T) {
+	var mu sync.Mutex
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		_, _ = w.Write([]byte("Package: libfoo\nVersion: 1.0.0\n"))
+	}))
+	defer server.Close()
+
+	for _, mode := range []string{"bearer", ""} {
+		authMode, err := normalizeAuthMode(mode)
+		require.NoError(t, err)
+		client := &repoClient{user: "ci", apiKey: "token", authMode: authMode, httpCfg: normalizeHTTPConfig(0, 1, 1)}
+		_, _, _, err = client.fetchURL(t.Context(), server.URL+"/Packages")
+		require.NoError(t, err)
+	}
+
+	want := []string{"Bearer token", "Basic " + base64.StdEncoding.EncodeToString([]byte("ci:token"))}
+	if diff := cmp.Diff(want, auth); diff != "" {
+		t.Fatalf("unexpected authorization (-want +got):\n%s", diff)
+	}
+
+	_, err := normalizeAuthMode("digest")
+	require.ErrorContains(t, err, "unsupported auth mode")
+}
+
+func TestFetchURLRevalidatesExpiredCache(t *testing.T) {
+	payload := "Package: libfoo\nVersion: 1.0.0\n"
+	var mu sync.Mutex
+	var conditions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditions = append(conditions, r.Header.Get("If-None-Match")+"|"+r.Header.Get("If-Modified-Since"))
+		mu.Unlock()
+		if r.URL.Path == "/plain" {
+			_, _ = w.Write([]byte(payload))
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Feb 2026 12:00:00 GMT")
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 1), cacheCfg: normalizeCacheConfig(t.TempDir(), 60)}
+	expire := func(url string) {
+		old := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(client.cacheCfg.dir, client.cacheKey(url)+".cache"), old, old))
+	}
+	fetch := func(url string) {
+		status, body, _, err := client.fetchURL(t.Context(), url)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, payload, string(body))
+	}
+
+	fetch(server.URL + "/Packages")
+	expire(server.URL + "/Packages")
+	fetch(server.URL + "/Packages")
+	fetch(server.URL + "/Packages")
+	fetch(server.URL + "/plain")
+	expire(server.URL + "/plain")
+	fetch(server.URL + "/plain")
+
+	want := []string{"|", `"v1"|Mon, 02 Feb 2026 12:00:00 GMT`, "|", "|"}
+	if diff := cmp.Diff(want, conditions); diff != "" {
+		t.Fatalf("unexpected conditional headers (-want +got):\n%s", diff)
+	}
+}
+
+func TestFetchPipPackageNamesContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+		wantErr     string
+	}{
+		{
+			name:        "html listing",
+			contentType: "text/html; charset=utf-8",
+			body:        `<a href="/simple/requests/">requests</a>`,
+			want:        []string{"requests"},
+		},
+		{
+			name:        "pep 691 json listing",
+			contentType: "application/vnd.pypi.simple.v1+json",
+			body:        `{"meta":{"api-version":"1.0"},"projects":[{"name":"Requests"},{"name":"zope.interface"}]}`,
+			want:        []string{"requests", "zope-interface"},
+		},
+		{
+			name:        "json error page",
+			contentType: "application/json",
+			body:        `{"error":"unauthorized","message":"<a href=\"/login\">sign in</a>"}`,
+			wantErr:     "not a PEP 691 simple response",
+		},
+		{
+			name:        "unexpected content type",
+			contentType: "text/plain",
+			body:        `<a href="/simple/requests/">requests</a>`,
+			wantErr:     "unexpected content type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 0)}
+			names, err := fetchPipPackageNames(t.Context(), server.URL+"/simple/", client)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				require.Empty(t, names)
+				return
+			}
+			require.NoError(t, err)
+			if diff := cmp.Diff(tt.want, names); diff != "" {
+				t.Fatalf("unexpected names (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFetchPipPackageVersionsParsesJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+		_, _ = w.Write([]byte(`{"meta":{"api-version":"1.1"},"name":"demo","files":[
+			{"filename":"demo-1.0.0-py3-none-any.whl"},
+			{"filename":"demo-1.2.0.tar.gz"},
+			{"filename":"demo-2.0.0rc1-py3-none-any.whl"}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 0)}
+	versions, err := fetchPipPackageVersions(t.Context(), server.URL+"/simple/", "demo", client)
+	require.NoError(t, err)
+	if diff := cmp.Diff([]string{"1.0.0", "1.2.0", "2.0.0rc1"}, versions); diff != "" {
+		t.Fatalf("unexpected versions (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildPipIndexRecordsRequiresDist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/simple/demo/":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte(`<a href="/files/demo-1.0.0.tar.gz">demo-1.0.0.tar.gz</a>` +
+				`<a href="/files/demo-1.0.0-py3-none-any.whl#sha256=ab" data-core-metadata="sha256=cd">demo-1.0.0-py3-none-any.whl</a>` +
+				`<a href="/files/demo-2.0.0-py3-none-any.whl" data-dist-info-metadata="false">demo-2.0.0-py3-none-any.whl</a>`))
+		case "/simple/util/":
+			w.Header().Set("Content-Type", "application/vnd.pypi.simple.v1+json")
+			_, _ = w.Write([]byte(`{"meta":{"api-version":"1.1"},"name":"util","files":[
+				{"filename":"util-0.5-py3-none-any.whl","url":"../../files/util-0.5-py3-none-any.whl","core-metadata":{"sha256":"ef"}}
+			]}`))
+		case "/files/demo-1.0.0-py3-none-any.whl.metadata":
+			_, _ = w.Write([]byte("Metadata-Version: 2.1\nName: demo\nVersion: 1.0.0\n" +
+				"Requires-Dist: util (>=0.5)\nRequires-Dist: pytest ; extra == 'test'\n\nRequires-Dist: not-a-header\n"))
+		case "/files/util-0.5-py3-none-any.whl.metadata":
+			_, _ = w.Write([]byte("Metadata-Version: 2.1\nName: util\nVersion: 0.5\n\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 0)}
+	index, packages, err := buildPipIndex(t.Context(), pipIndexRequest{
+		base:     server.URL + "/simple",
+		client:   client,
+		packages: []string{"demo", "util"},
+		metadata: true,
+	})
+	require.NoError(t, err)
+	if diff := cmp.Diff(map[string][]string{"demo": {"1.0.0", "2.0.0"}, "util": {"0.5"}}, index); diff != "" {
+		t.Fatalf("unexpected pip index (-want +got):\n%s", diff)
+	}
+	want := map[string][]types.PipPackageVersion{
+		"demo": {
+			{Version: "1.0.0", RequiresDist: []string{"util (>=0.5)", "pytest ; extra == 'test'"}},
+			{Version: "2.0.0"},
+		},
+		"util": {{Version: "0.5"}},
+	}
+	if diff := cmp.Diff(want, packages); diff != "" {
+		t.Fatalf("unexpected pip packages (-want +got):\n%s", diff)
+	}
+
+	_, packages, err = buildPipIndex(t.Context(), pipIndexRequest{base: server.URL + "/simple", client: client, packages: []string{"demo"}})
+	require.NoError(t, err)
+	require.Nil(t, packages)
+}
+
+func TestFetchPipPackageNamesFollowsPagination(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Query().Get("page") {
+		case "":
+			_, _ = w.Write([]byte(`<a href="/simple/numpy/">numpy</a><a href="/simple/requests/">requests</a>` +
+				`<a rel="next" href="?page=2">Next</a>`))
+		case "2":
+			_, _ = w.Write([]byte(`<link rel="next" href="` + server.URL + `/simple/?page=3">` +
+				`<a rel="prev" href="/simple/">Previous</a><a href="/simple/Flask/">Flask</a>`))
+		case "3":
+			_, _ = w.Write([]byte(`<a href="/simple/zope.interface/">zope.interface</a><a href="/simple/numpy/">numpy</a>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 0)}
+	names, err := fetchPipPackageNames(t.Context(), server.URL+"/simple/", client)
+	require.NoError(t, err)
+	want := []string{"flask", "numpy", "requests", "zope-interface"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("unexpected names (-want +got):\n%s", diff)
+	}
+}
+
+func TestHTTPRetryDelaySeeded(t *testing.T) {
+	cfg := httpRetryConfig{baseDelay: 100 * time.Millisecond}
+	sequence := func() []time.Duration {
+		shared.SetRandomSeed(42)
+		var delays []time.Duration
+		for attempt := 0; attempt < 5; attempt++ {
+			delays = append(delays, httpRetryDelay(attempt, cfg))
+		}
+		return delays
+	}
+
+	first := sequence()
+	second := sequence()
+	if diff := cmp.Diff(first, second); diff != "" {
+		t.Fatalf("unexpected retry delays (-want +got):\n%s", diff)
+	}
+	for attempt, delay := range first {
+		base := cfg.baseDelay * time.Duration(1<<attempt)
+		require.GreaterOrEqual(t, delay, base)
+		require.LessOrEqual(t, delay, base+base/2)
+	}
+}
+
+func TestFetchURLOfflineServesOnlyFromCache(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits.Add(1)
+		_, _ = w.Write([]byte("Package: libfoo\nVersion: 1.0.0\n"))
+	}))
+	defer server.Close()
+	cacheDir := t.TempDir()
+
+	online := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 1), cacheCfg: normalizeCacheConfig(cacheDir, 60)}
+	_, _, _, err := online.fetchURL(t.Context(), server.URL+"/Packages")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), hits.Load())
+
+	// Offline ignores the TTL: a cached entry is served however old it is.
+	offline := &repoClient{httpCfg: normalizeHTTPConfig(0, 1, 1), cacheCfg: cacheConfig{dir: cacheDir}, offline: true}
+	status, body, _, err := offline.fetchURL(t.Context(), server.URL+"/Packages")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "Package: libfoo\nVersion: 1.0.0\n", string(body))
+
+	_, _, _, err = offline.fetchURL(t.Context(), server.URL+"/Packages.gz")
+	require.ErrorContains(t, err, "offline mode: "+server.URL+"/Packages.gz is not cached")
+	require.Equal(t, errbuilder.CodeFailedPrecondition, errbuilder.CodeOf(err))
+	require.Equal(t, int32(1), hits.Load())
+}
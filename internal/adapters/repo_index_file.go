@@ -7,14 +7,12 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"avular-packages/internal/ports"
-	"avular-packages/internal/shared"
 	"avular-packages/internal/types"
 )
 
 type RepoIndexFileAdapter struct {
 	Path   string
-	cached types.RepoIndexFile
-	loaded bool
+	cached *RepoIndexMemoryAdapter
 }
 
 func NewRepoIndexFileAdapter(path string) *RepoIndexFileAdapter {
@@ -26,23 +24,7 @@ func (a *RepoIndexFileAdapter) AvailableVersions(depType types.DependencyType, n
 	if err != nil {
 		return nil, err
 	}
-	switch depType {
-	case types.DependencyTypeApt:
-		return index.Apt[name], nil
-	case types.DependencyTypePip:
-		if versions, ok := index.Pip[name]; ok && len(versions) > 0 {
-			return versions, nil
-		}
-		normalized := shared.NormalizePipName(name)
-		if normalized != name {
-			return index.Pip[normalized], nil
-		}
-		return index.Pip[name], nil
-	default:
-		return nil, errbuilder.New().
-			WithCode(errbuilder.CodeInvalidArgument).
-			WithMsg("unknown dependency type")
-	}
+	return index.AvailableVersions(depType, name)
 }
 
 func (a *RepoIndexFileAdapter) AptPackages() (map[string][]types.AptPackageVersion, error) {
@@ -50,53 +32,49 @@ func (a *RepoIndexFileAdapter) AptPackages() (map[string][]types.AptPackageVersi
 	if err != nil {
 		return nil, err
 	}
-	if index.AptPackages == nil {
-		return map[string][]types.AptPackageVersion{}, nil
+	return index.AptPackages()
+}
+
+// PipPackages returns the pip release metadata keyed by normalized
+// project name.
+func (a *RepoIndexFileAdapter) PipPackages() (map[string][]types.PipPackageVersion, error) {
+	index, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	return index.PipPackages()
+}
+
+// PipAllowPrerelease reports whether the index was built with
+// --pip-allow-prerelease.
+func (a *RepoIndexFileAdapter) PipAllowPrerelease() (bool, error) {
+	index, err := a.load()
+	if err != nil {
+		return false, err
 	}
-	return index.AptPackages, nil
+	return index.PipAllowPrerelease()
 }
 
-func (a *RepoIndexFileAdapter) load() (types.RepoIndexFile, error) {
-	if a.loaded {
+func (a *RepoIndexFileAdapter) load() (*RepoIndexMemoryAdapter, error) {
+	if a.cached != nil {
 		return a.cached, nil
 	}
 	data, err := os.ReadFile(a.Path)
 	if err != nil {
-		return types.RepoIndexFile{}, errbuilder.New().
+		return nil, errbuilder.New().
 			WithCode(errbuilder.CodeNotFound).
 			WithMsg("repo index file not found").
 			WithCause(err)
 	}
 	var idx types.RepoIndexFile
 	if err := yaml.Unmarshal(data, &idx); err != nil {
-		return types.RepoIndexFile{}, errbuilder.New().
+		return nil, errbuilder.New().
 			WithCode(errbuilder.CodeInvalidArgument).
 			WithMsg("invalid repo index format").
 			WithCause(err)
 	}
-	if idx.Apt == nil {
-		idx.Apt = map[string][]string{}
-	}
-	if idx.Pip == nil {
-		idx.Pip = map[string][]string{}
-	}
-	if len(idx.Apt) == 0 && len(idx.AptPackages) > 0 {
-		for name, versions := range idx.AptPackages {
-			for _, entry := range versions {
-				if entry.Version == "" {
-					continue
-				}
-				idx.Apt[name] = append(idx.Apt[name], entry.Version)
-			}
-			if len(idx.Apt[name]) > 1 {
-				idx.Apt[name] = uniqueStrings(idx.Apt[name])
-				idx.Apt[name] = sortDebVersions(idx.Apt[name])
-			}
-		}
-	}
-	a.cached = idx
-	a.loaded = true
-	return idx, nil
+	a.cached = NewRepoIndexMemoryAdapter(idx)
+	return a.cached, nil
 }
 
 var _ ports.RepoIndexPort = (*RepoIndexFileAdapter)(nil)
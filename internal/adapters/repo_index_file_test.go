@@ -3,6 +3,8 @@ package adapters
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -120,6 +122,26 @@ apt:
 	assert.Empty(t, packages)
 }
 
+func TestRepoIndexFileAdapter_PipAllowPrerelease(t *testing.T) {
+	dir := t.TempDir()
+	indexPath := filepath.Join(dir, "repo-index.yaml")
+	content := `
+pip:
+  demo:
+    - "2.0.0rc1"
+pip_allow_prerelease: true
+`
+	require.NoError(t, os.WriteFile(indexPath, []byte(content), 0o644))
+
+	allow, err := NewRepoIndexFileAdapter(indexPath).PipAllowPrerelease()
+	require.NoError(t, err)
+	assert.True(t, allow)
+
+	allow, err = NewRepoIndexMemoryAdapter(types.RepoIndexFile{}).PipAllowPrerelease()
+	require.NoError(t, err)
+	assert.False(t, allow)
+}
+
 func TestRepoIndexFileAdapter_Caching(t *testing.T) {
 	dir := t.TempDir()
 	indexPath := filepath.Join(dir, "repo-index.yaml")
@@ -192,6 +214,15 @@ func TestNormalizePipName(t *testing.T) {
 		{"My_Package", "my-package"},
 		{"Some.Lib", "some-lib"},
 		{"  Mixed_Case.Pkg  ", "mixed-case-pkg"},
+		{"PyYAML", "pyyaml"},
+		{"Zope.Interface", "zope-interface"},
+		{"ruamel.yaml.clib", "ruamel-yaml-clib"},
+		{"typing_Extensions", "typing-extensions"},
+		{"Jinja2", "jinja2"},
+		{"foo.__bar", "foo-bar"},
+		{"Foo-_-Bar", "foo-bar"},
+		{"a..b--c__d", "a-b-c-d"},
+		{"Friendly--Bard", "friendly-bard"},
 		{"", ""},
 	}
 	for _, tt := range tests {
@@ -200,3 +231,49 @@ func TestNormalizePipName(t *testing.T) {
 		})
 	}
 }
+
+// TestPipNameNormalizationIsConsistent guards against call sites drifting
+// from shared.NormalizePipName: index listings and explicit package lists
+// must produce the same keys the resolver uses.
+func TestPipNameNormalizationIsConsistent(t *testing.T) {
+	raw := []string{"PyYAML", "Zope.Interface", "ruamel.yaml.clib", "typing_Extensions", "My-Pkg", "foo.__bar"}
+	var html strings.Builder
+	var want []string
+	for _, name := range raw {
+		html.WriteString(`<a href="/simple/` + name + `/">` + name + `</a>`)
+		want = append(want, shared.NormalizePipName(name))
+	}
+	sort.Strings(want)
+
+	assert.Equal(t, want, parsePipSimpleNames(html.String()))
+	explicit := normalizePipNames(raw)
+	sort.Strings(explicit)
+	assert.Equal(t, want, explicit)
+}
+
+func TestRepoIndexMemoryAdapterDerivesVersions(t *testing.T) {
+	index := types.RepoIndexFile{
+		AptPackages: map[string][]types.AptPackageVersion{
+			"libfoo": {{Version: "2.0"}, {Version: "1.0"}, {Version: "1.0"}},
+		},
+		PipPackages: map[string][]types.PipPackageVersion{
+			"Foo_Bar": {{Version: "1.2"}},
+		},
+	}
+	adapter := NewRepoIndexMemoryAdapter(index)
+	index.AptPackages["libbaz"] = []types.AptPackageVersion{{Version: "1.0"}}
+
+	apt, err := adapter.AvailableVersions(types.DependencyTypeApt, "libfoo")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.0", "2.0"}, apt)
+	missing, err := adapter.AvailableVersions(types.DependencyTypeApt, "libbaz")
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+	packages, err := adapter.AptPackages()
+	require.NoError(t, err)
+	assert.NotContains(t, packages, "libbaz")
+
+	pip, err := adapter.PipPackages()
+	require.NoError(t, err)
+	assert.Contains(t, pip, "foo-bar")
+}
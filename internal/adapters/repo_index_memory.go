@@ -0,0 +1,122 @@
+package adapters
+
+import (
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/ports"
+	"avular-packages/internal/shared"
+	"avular-packages/internal/types"
+)
+
+// RepoIndexMemoryAdapter serves a repo index held in memory, for callers
+// that build the index themselves instead of writing a YAML file. Version
+// lists missing from Apt or Pip are derived from the package metadata,
+// exactly as for a loaded file.
+type RepoIndexMemoryAdapter struct {
+	index types.RepoIndexFile
+}
+
+// NewRepoIndexMemoryAdapter returns an adapter over index. The index is
+// copied, so later changes to the caller's maps are not observed.
+func NewRepoIndexMemoryAdapter(index types.RepoIndexFile) *RepoIndexMemoryAdapter {
+	return &RepoIndexMemoryAdapter{index: completeRepoIndex(index)}
+}
+
+func (a *RepoIndexMemoryAdapter) AvailableVersions(depType types.DependencyType, name string) ([]string, error) {
+	switch depType {
+	case types.DependencyTypeApt:
+		return a.index.Apt[name], nil
+	case types.DependencyTypePip:
+		if versions, ok := a.index.Pip[name]; ok && len(versions) > 0 {
+			return versions, nil
+		}
+		normalized := shared.NormalizePipName(name)
+		if normalized != name {
+			return a.index.Pip[normalized], nil
+		}
+		return a.index.Pip[name], nil
+	default:
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("unknown dependency type")
+	}
+}
+
+func (a *RepoIndexMemoryAdapter) AptPackages() (map[string][]types.AptPackageVersion, error) {
+	if a.index.AptPackages == nil {
+		return map[string][]types.AptPackageVersion{}, nil
+	}
+	return a.index.AptPackages, nil
+}
+
+// PipPackages returns the pip release metadata keyed by normalized
+// project name.
+func (a *RepoIndexMemoryAdapter) PipPackages() (map[string][]types.PipPackageVersion, error) {
+	out := make(map[string][]types.PipPackageVersion, len(a.index.PipPackages))
+	for name, versions := range a.index.PipPackages {
+		normalized := shared.NormalizePipName(name)
+		out[normalized] = append(out[normalized], versions...)
+	}
+	return out, nil
+}
+
+// PipAllowPrerelease reports whether open pip constraints may select
+// pre-releases.
+func (a *RepoIndexMemoryAdapter) PipAllowPrerelease() (bool, error) {
+	return a.index.PipAllowPrerelease, nil
+}
+
+// completeRepoIndex returns a copy of idx whose Apt and Pip version
+// lists are filled in from AptPackages and PipPackages where absent.
+func completeRepoIndex(idx types.RepoIndexFile) types.RepoIndexFile {
+	out := types.RepoIndexFile{
+		Apt:                make(map[string][]string, len(idx.Apt)),
+		Pip:                make(map[string][]string, len(idx.Pip)),
+		PipAllowPrerelease: idx.PipAllowPrerelease,
+	}
+	for name, versions := range idx.Apt {
+		out.Apt[name] = append([]string(nil), versions...)
+	}
+	for name, versions := range idx.Pip {
+		out.Pip[name] = append([]string(nil), versions...)
+	}
+	if idx.AptPackages != nil {
+		out.AptPackages = make(map[string][]types.AptPackageVersion, len(idx.AptPackages))
+		for name, versions := range idx.AptPackages {
+			out.AptPackages[name] = append([]types.AptPackageVersion(nil), versions...)
+		}
+	}
+	if idx.PipPackages != nil {
+		out.PipPackages = make(map[string][]types.PipPackageVersion, len(idx.PipPackages))
+		for name, versions := range idx.PipPackages {
+			out.PipPackages[name] = append([]types.PipPackageVersion(nil), versions...)
+		}
+	}
+	if len(out.Apt) == 0 && len(idx.AptPackages) > 0 {
+		for name, versions := range idx.AptPackages {
+			for _, entry := range versions {
+				if entry.Version == "" {
+					continue
+				}
+				out.Apt[name] = append(out.Apt[name], entry.Version)
+			}
+			if len(out.Apt[name]) > 1 {
+				out.Apt[name] = uniqueStrings(out.Apt[name])
+				out.Apt[name] = sortDebVersions(out.Apt[name])
+			}
+		}
+	}
+	for name, versions := range idx.PipPackages {
+		if len(out.Pip[name]) > 0 {
+			continue
+		}
+		for _, entry := range versions {
+			if entry.Version != "" {
+				out.Pip[name] = append(out.Pip[name], entry.Version)
+			}
+		}
+	}
+	return out
+}
+
+var _ ports.RepoIndexPort = (*RepoIndexMemoryAdapter)(nil)
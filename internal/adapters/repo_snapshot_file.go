@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
 
@@ -14,12 +15,38 @@ import (
 
 type RepoSnapshotFileAdapter struct {
 	Dir string
+	// DebsDir, when set, makes Publish also lay out an apt repository
+	// (pool/ and dists/<Suite>/) from the debs found there so clients
+	// can point apt directly at Dir.
+	DebsDir   string
+	Suite     string
+	Component string
+	Now       func() time.Time
+	// Signer, when set, signs the generated Release file.
+	Signer *ReleaseSigner
 }
 
 func NewRepoSnapshotFileAdapter(dir string) RepoSnapshotFileAdapter {
 	return RepoSnapshotFileAdapter{Dir: dir}
 }
 
+// WithAptRepository returns a copy of the adapter that writes an apt
+// repository for the debs in debsDir on Publish. An empty suite falls
+// back to the snapshot ID and an empty component to "main".
+func (a RepoSnapshotFileAdapter) WithAptRepository(debsDir string, suite string, component string) RepoSnapshotFileAdapter {
+	a.DebsDir = debsDir
+	a.Suite = suite
+	a.Component = component
+	return a
+}
+
+// WithReleaseSigner returns a copy of the adapter that signs the Release
+// file of its apt repository with signer.
+func (a RepoSnapshotFileAdapter) WithReleaseSigner(signer ReleaseSigner) RepoSnapshotFileAdapter {
+	a.Signer = &signer
+	return a
+}
+
 func (a RepoSnapshotFileAdapter) Publish(ctx context.Context, snapshotID string) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -52,7 +79,18 @@ func (a RepoSnapshotFileAdapter) Publish(ctx context.Context, snapshotID string)
 			WithCode(errbuilder.CodeAlreadyExists).
 			WithMsg("snapshot already exists")
 	}
-	if err := os.WriteFile(path, []byte(snapshotID+"\n"), 0644); err != nil {
+	if strings.TrimSpace(a.DebsDir) != "" {
+		releasePath, err := a.writeAptRepository(ctx, snapshotID)
+		if err != nil {
+			return err
+		}
+		if a.Signer != nil {
+			if err := a.Signer.Sign(ctx, releasePath); err != nil {
+				return err
+			}
+		}
+	}
+	if err := writeFileAtomic(path, []byte(snapshotID+"\n"), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write snapshot metadata").
@@ -61,6 +99,29 @@ func (a RepoSnapshotFileAdapter) Publish(ctx context.Context, snapshotID string)
 	return nil
 }
 
+// writeAptRepository lays out the apt repository for snapshotID and
+// returns the path of its Release file.
+func (a RepoSnapshotFileAdapter) writeAptRepository(ctx context.Context, snapshotID string) (string, error) {
+	suite := strings.TrimSpace(a.Suite)
+	if suite == "" {
+		suite = snapshotID
+	}
+	if strings.Contains(suite, string(os.PathSeparator)) {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("apt repository suite contains path separator")
+	}
+	component := strings.TrimSpace(a.Component)
+	if component == "" {
+		component = "main"
+	}
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+	return writeAptRepository(ctx, a.Dir, a.DebsDir, suite, component, now())
+}
+
 func (a RepoSnapshotFileAdapter) Promote(ctx context.Context, snapshotID string, channel string) error {
 	if err := ctx.Err(); err != nil {
 		return err
@@ -93,7 +154,7 @@ func (a RepoSnapshotFileAdapter) Promote(ctx context.Context, snapshotID string,
 			WithCause(err)
 	}
 	path := filepath.Join(channelsDir, channel)
-	if err := os.WriteFile(path, []byte(snapshotID+"\n"), 0644); err != nil {
+	if err := writeFileAtomic(path, []byte(snapshotID+"\n"), 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write channel pointer").
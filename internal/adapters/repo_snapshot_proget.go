@@ -2,6 +2,8 @@ package adapters
 
 import (
 	"context"
+	"crypto/md5" //nolint:gosec // used for the Content-MD5 upload header only
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,11 +11,14 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/rs/zerolog/log"
 
 	"avular-packages/internal/ports"
 	"avular-packages/internal/shared"
@@ -27,11 +32,41 @@ type RepoSnapshotProGetAdapter struct {
 	DebsDir        string
 	Username       string
 	APIKey         string
+	AuthMode       string
 	SnapshotPrefix string
 	Workers        int
 	Timeout        time.Duration
 	Retries        int
 	RetryDelay     time.Duration
+	UserAgent      string
+	Verify         bool
+	SkipExisting   bool
+	DryRun         bool
+	Quiet          bool
+	Channels       []string
+	Force          bool
+	HTTPClient     *http.Client
+	limiter        *rateLimiter
+	stats          *uploadCounters
+	planned        *plannedUploads
+}
+
+// plannedUploads collects the uploads a dry run would have performed.
+type plannedUploads struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+// UploadStats reports how many debs were uploaded and how many were
+// skipped because the feed already had them.
+type UploadStats struct {
+	Uploaded int
+	Skipped  int
+}
+
+type uploadCounters struct {
+	uploaded atomic.Int64
+	skipped  atomic.Int64
 }
 
 const defaultProgetUploadWorkers = 4
@@ -40,6 +75,14 @@ const defaultProgetRetryDelay = 200 * time.Millisecond
 const defaultProgetTimeout = 60 * time.Second
 const maxProgetRetryDelay = 2 * time.Second
 
+// ProGet authentication modes for ProGetConfig.AuthMode: basic auth with
+// the API key as password (the default), or the API key sent in the
+// X-ApiKey header.
+const (
+	ProGetAuthBasic  = "basic"
+	ProGetAuthAPIKey = "apikey"
+)
+
 // ProGetConfig bundles configuration for creating a ProGet snapshot adapter.
 type ProGetConfig struct {
 	Endpoint       string
@@ -48,11 +91,34 @@ type ProGetConfig struct {
 	DebsDir        string
 	Username       string
 	APIKey         string
+	AuthMode       string
 	SnapshotPrefix string
 	Workers        int
 	TimeoutSec     int
 	Retries        int
 	RetryDelayMs   int
+	UserAgent      string
+	// Verify re-queries the feed after uploading and fails when any
+	// uploaded deb is not listed.
+	Verify bool
+	// SkipExisting probes each deb's pool URL before uploading and skips
+	// the PUT when the feed already serves an artifact of the same size.
+	SkipExisting bool
+	// DryRun records the PUTs Publish and Promote would perform instead
+	// of sending them; see PlannedUploads.
+	DryRun bool
+	// Quiet suppresses the periodic upload progress log lines.
+	Quiet bool
+	// Channels names the distributions serving as promoted channels.
+	// DeleteSnapshot refuses to delete them unless Force is set.
+	Channels []string
+	Force    bool
+	// Transport tuning for the pooled HTTP client.
+	MaxIdleConnsPerHost int
+	IdleConnTimeoutSec  int
+	DisableHTTP2        bool
+	// RateLimitBytesPerSec caps aggregate upload throughput (0 = unlimited).
+	RateLimitBytesPerSec int
 }
 
 func NewRepoSnapshotProGetAdapter(cfg ProGetConfig) RepoSnapshotProGetAdapter {
@@ -60,6 +126,8 @@ func NewRepoSnapshotProGetAdapter(cfg ProGetConfig) RepoSnapshotProGetAdapter {
 	if component == "" {
 		component = "main"
 	}
+	timeout := normalizeProgetTimeout(cfg.TimeoutSec)
+	transportCfg := normalizeHTTPTransportConfig(cfg.MaxIdleConnsPerHost, cfg.IdleConnTimeoutSec, cfg.DisableHTTP2)
 	return RepoSnapshotProGetAdapter{
 		Endpoint:       cfg.Endpoint,
 		Feed:           cfg.Feed,
@@ -67,12 +135,57 @@ func NewRepoSnapshotProGetAdapter(cfg ProGetConfig) RepoSnapshotProGetAdapter {
 		DebsDir:        cfg.DebsDir,
 		Username:       cfg.Username,
 		APIKey:         cfg.APIKey,
+		AuthMode:       strings.ToLower(strings.TrimSpace(cfg.AuthMode)),
 		SnapshotPrefix: cfg.SnapshotPrefix,
 		Workers:        normalizeProgetWorkers(cfg.Workers),
-		Timeout:        normalizeProgetTimeout(cfg.TimeoutSec),
+		Timeout:        timeout,
 		Retries:        normalizeProgetRetries(cfg.Retries),
 		RetryDelay:     normalizeProgetRetryDelay(cfg.RetryDelayMs),
+		UserAgent:      cfg.UserAgent,
+		Verify:         cfg.Verify,
+		SkipExisting:   cfg.SkipExisting,
+		DryRun:         cfg.DryRun,
+		Quiet:          cfg.Quiet,
+		Channels:       cfg.Channels,
+		Force:          cfg.Force,
+		HTTPClient:     newHTTPClient(timeout, transportCfg),
+		limiter:        newRateLimiter(cfg.RateLimitBytesPerSec),
+		stats:          &uploadCounters{},
+		planned:        &plannedUploads{},
+	}
+}
+
+// PlannedUploads returns the "PUT <url> <deb>" lines recorded by dry-run
+// Publish and Promote calls, in the order they were planned.
+func (a RepoSnapshotProGetAdapter) PlannedUploads() []string {
+	if a.planned == nil {
+		return nil
 	}
+	a.planned.mu.Lock()
+	defer a.planned.mu.Unlock()
+	return append([]string(nil), a.planned.lines...)
+}
+
+// UploadStats returns the cumulative upload counts of this adapter.
+func (a RepoSnapshotProGetAdapter) UploadStats() UploadStats {
+	if a.stats == nil {
+		return UploadStats{}
+	}
+	return UploadStats{
+		Uploaded: int(a.stats.uploaded.Load()),
+		Skipped:  int(a.stats.skipped.Load()),
+	}
+}
+
+func (a RepoSnapshotProGetAdapter) countUpload(skipped bool) {
+	if a.stats == nil {
+		return
+	}
+	if skipped {
+		a.stats.skipped.Add(1)
+		return
+	}
+	a.stats.uploaded.Add(1)
 }
 
 func (a RepoSnapshotProGetAdapter) Publish(ctx context.Context, snapshotID string) error {
@@ -123,10 +236,98 @@ func (a RepoSnapshotProGetAdapter) uploadDistribution(ctx context.Context, distr
 			WithCode(errbuilder.CodeInvalidArgument).
 			WithMsg("no deb artifacts found")
 	}
-	return a.uploadDebsParallel(ctx, debs, distribution)
+	if a.DryRun {
+		a.planUploads(debs, distribution)
+		return nil
+	}
+	progress := newUploadProgress(debs, a.Quiet)
+	if err := a.uploadDebsParallel(ctx, debs, distribution, progress); err != nil {
+		return err
+	}
+	progress.finish()
+	if a.Verify {
+		return a.verifyUploads(ctx, debs)
+	}
+	return nil
+}
+
+func (a RepoSnapshotProGetAdapter) planUploads(debs []string, distribution string) {
+	if a.planned == nil {
+		return
+	}
+	a.planned.mu.Lock()
+	defer a.planned.mu.Unlock()
+	for _, deb := range debs {
+		a.planned.lines = append(a.planned.lines, fmt.Sprintf("PUT %s %s", a.uploadURL(distribution), deb))
+	}
+}
+
+func (a RepoSnapshotProGetAdapter) uploadURL(distribution string) string {
+	endpoint := strings.TrimRight(strings.TrimSpace(a.Endpoint), "/")
+	return fmt.Sprintf("%s/debian/%s/upload/%s/%s", endpoint, a.Feed, distribution, a.Component)
+}
+
+// verifyUploads lists the feed's packages once all uploads have finished
+// and reports every uploaded deb whose name and version are missing.
+func (a RepoSnapshotProGetAdapter) verifyUploads(ctx context.Context, debs []string) error {
+	listed, err := a.listFeedPackages(ctx)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, deb := range debs {
+		name, version, ok := debFileIdentity(deb)
+		if !ok {
+			continue
+		}
+		if _, found := listed[name+"="+version]; !found {
+			missing = append(missing, name+"="+version)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return errbuilder.New().
+		WithCode(errbuilder.CodeNotFound).
+		WithMsg("proget feed is missing uploaded packages").
+		WithCause(fmt.Errorf("missing: %s", strings.Join(missing, ", ")))
+}
+
+// listFeedPackages queries the ProGet packages API and returns the set of
+// name=version pairs present in the feed.
+func (a RepoSnapshotProGetAdapter) listFeedPackages(ctx context.Context) (map[string]struct{}, error) {
+	endpoint := strings.TrimRight(strings.TrimSpace(a.Endpoint), "/")
+	listURL := fmt.Sprintf("%s/api/packages/%s/versions", endpoint, a.Feed)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, listURL, nil)
+	if err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to create proget verify request").
+			WithCause(err)
+	}
+	applyUserAgent(req, a.UserAgent)
+	a.applyAuth(req)
+	client := a.httpClient()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("proget verify failed").
+			WithCause(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("proget verify failed").
+			WithCause(shared.HTTPStatusErrorWithBody(resp.StatusCode, listURL, strings.TrimSpace(string(body))))
+	}
+	return decodeProgetPackages(body)
 }
 
-func (a RepoSnapshotProGetAdapter) uploadDebsParallel(ctx context.Context, debs []string, distribution string) error {
+func (a RepoSnapshotProGetAdapter) uploadDebsParallel(ctx context.Context, debs []string, distribution string, progress *uploadProgress) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	var firstErr error
@@ -149,7 +350,7 @@ func (a RepoSnapshotProGetAdapter) uploadDebsParallel(ctx context.Context, debs
 					results <- ctx.Err()
 					continue
 				}
-				results <- a.uploadDeb(ctx, deb, distribution)
+				results <- a.uploadDeb(ctx, deb, distribution, progress)
 			}
 		}()
 	}
@@ -171,14 +372,20 @@ func (a RepoSnapshotProGetAdapter) uploadDebsParallel(ctx context.Context, debs
 	return firstErr
 }
 
-func (a RepoSnapshotProGetAdapter) uploadDeb(ctx context.Context, path string, distribution string) error {
+func (a RepoSnapshotProGetAdapter) uploadDeb(ctx context.Context, path string, distribution string, progress *uploadProgress) error {
+	progress.startFile()
+	if a.SkipExisting && a.debExists(ctx, path, distribution) {
+		a.countUpload(true)
+		return nil
+	}
 	var lastErr error
 	for attempt := 0; attempt < a.Retries; attempt++ {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
-		retry, err := a.uploadDebOnce(ctx, path, distribution)
+		existed, retry, err := a.uploadDebOnce(ctx, path, distribution, progress)
 		if err == nil {
+			a.countUpload(existed)
 			return nil
 		}
 		lastErr = err
@@ -195,64 +402,147 @@ func (a RepoSnapshotProGetAdapter) uploadDeb(ctx context.Context, path string, d
 	return lastErr
 }
 
-func (a RepoSnapshotProGetAdapter) uploadDebOnce(ctx context.Context, path string, distribution string) (bool, error) {
-	endpoint := strings.TrimRight(strings.TrimSpace(a.Endpoint), "/")
-	url := fmt.Sprintf("%s/debian/%s/upload/%s/%s", endpoint, a.Feed, distribution, a.Component)
+func (a RepoSnapshotProGetAdapter) uploadDebOnce(ctx context.Context, path string, distribution string, progress *uploadProgress) (bool, bool, error) {
+	url := a.uploadURL(distribution)
 	file, err := os.Open(path)
 	if err != nil {
-		return false, errbuilder.New().
+		return false, false, errbuilder.New().
 			WithCode(errbuilder.CodeNotFound).
 			WithMsg("failed to open deb artifact").
 			WithCause(err)
 	}
 	defer file.Close()
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, file)
+	digest, err := contentMD5(file)
+	if err != nil {
+		return false, false, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to hash deb artifact").
+			WithCause(err)
+	}
+	info, err := file.Stat()
 	if err != nil {
-		return false, errbuilder.New().
+		return false, false, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to stat deb artifact").
+			WithCause(err)
+	}
+	body := trackReader(limitReader(ctx, file, a.limiter), progress, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
+	if err != nil {
+		return false, false, errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to create proget request").
 			WithCause(err)
 	}
-	req.Header.Set("Content-Type", "application/octet-stream")
-	if strings.TrimSpace(a.APIKey) != "" {
-		user := strings.TrimSpace(a.Username)
-		if user == "" {
-			user = "api"
+	// The wrapped reader hides the file size from net/http, which would
+	// otherwise fall back to a chunked upload and could not resend the
+	// body on a redirect or connection retry.
+	req.ContentLength = info.Size()
+	req.GetBody = func() (io.ReadCloser, error) {
+		resent, err := os.Open(path)
+		if err != nil {
+			return nil, err
 		}
-		req.SetBasicAuth(user, a.APIKey)
+		discardProgress(body)
+		body = trackReader(limitReader(ctx, resent, a.limiter), progress, path)
+		return struct {
+			io.Reader
+			io.Closer
+		}{body, resent}, nil
 	}
-	client := &http.Client{Timeout: a.Timeout}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-MD5", digest)
+	applyUserAgent(req, a.UserAgent)
+	a.applyAuth(req)
+	client := a.httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
-		return true, errbuilder.New().
+		discardProgress(body)
+		return false, true, errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("proget upload failed").
 			WithCause(err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		return false, nil
+		return false, false, nil
 	}
-	body, _ := io.ReadAll(resp.Body)
-	message := strings.TrimSpace(string(body))
+	respBody, _ := io.ReadAll(resp.Body)
+	message := strings.TrimSpace(string(respBody))
 	lower := strings.ToLower(message)
 	if (resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusBadRequest) && strings.Contains(lower, "already") {
-		return false, nil
+		return true, false, nil
 	}
+	discardProgress(body)
 	retry := resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests
-	return retry, errbuilder.New().
+	return false, retry, errbuilder.New().
 		WithCode(errbuilder.CodeInternal).
 		WithMsg("proget upload failed").
 		WithCause(shared.HTTPStatusErrorWithBody(resp.StatusCode, url, message))
 }
 
+// contentMD5 streams file once to compute its base64 MD5 digest for the
+// Content-MD5 header, then rewinds it so the upload reads from the start.
+func contentMD5(file io.ReadSeeker) (string, error) {
+	hash := md5.New() //nolint:gosec // Content-MD5 is an integrity check, not a security boundary
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// debExists issues a HEAD request for the deb's pool URL within the
+// target distribution and reports whether the feed already serves an
+// artifact with the same size. Probe failures fall through to a normal
+// upload.
+func (a RepoSnapshotProGetAdapter) debExists(ctx context.Context, path string, distribution string) bool {
+	name, _, ok := debFileIdentity(path)
+	if !ok {
+		return false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	endpoint := strings.TrimRight(strings.TrimSpace(a.Endpoint), "/")
+	poolURL := fmt.Sprintf("%s/debian/%s/pool/%s/%s/%s/%s/%s",
+		endpoint, a.Feed, distribution, a.Component, debPoolPrefix(name), name, url.PathEscape(filepath.Base(path)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, poolURL, nil)
+	if err != nil {
+		return false
+	}
+	applyUserAgent(req, a.UserAgent)
+	a.applyAuth(req)
+	resp, err := a.httpClient().Do(req)
+	if err != nil {
+		log.Ctx(ctx).Debug().Str("url", poolURL).Err(err).Msg("proget existence check failed")
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false
+	}
+	return resp.ContentLength == info.Size()
+}
+
+// debPoolPrefix returns the Debian pool directory prefix for a package:
+// "libx" for lib* packages and the first letter otherwise.
+func debPoolPrefix(name string) string {
+	if strings.HasPrefix(name, "lib") && len(name) > 3 {
+		return name[:4]
+	}
+	return name[:1]
+}
+
 func (a RepoSnapshotProGetAdapter) progetRetryDelay(attempt int) time.Duration {
 	delay := a.RetryDelay * time.Duration(1<<attempt)
 	if delay > maxProgetRetryDelay {
 		delay = maxProgetRetryDelay
 	}
-	jitter := time.Duration(time.Now().UnixNano() % int64(delay/2+1))
-	return delay + jitter
+	return delay + shared.Jitter(delay/2)
 }
 
 func normalizeProgetWorkers(value int) int {
@@ -344,8 +634,9 @@ func (a RepoSnapshotProGetAdapter) ListSnapshots(ctx context.Context) ([]types.S
 			WithMsg("failed to create proget list request").
 			WithCause(err)
 	}
-	a.applyBasicAuth(req)
-	client := &http.Client{Timeout: a.Timeout}
+	applyUserAgent(req, a.UserAgent)
+	a.applyAuth(req)
+	client := a.httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, errbuilder.New().
@@ -386,6 +677,11 @@ func (a RepoSnapshotProGetAdapter) DeleteSnapshot(ctx context.Context, snapshotI
 			WithCode(errbuilder.CodeInvalidArgument).
 			WithMsg("snapshot id is empty")
 	}
+	if a.isChannel(trimmed) && !a.Force {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("refusing to delete distribution %s: it is a promoted channel (use --force to delete it)", trimmed))
+	}
 	deleteURL := fmt.Sprintf("%s/api/debian/%s/distributions/%s", endpoint, a.Feed, url.PathEscape(trimmed))
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, deleteURL, nil)
 	if err != nil {
@@ -394,8 +690,9 @@ func (a RepoSnapshotProGetAdapter) DeleteSnapshot(ctx context.Context, snapshotI
 			WithMsg("failed to create proget delete request").
 			WithCause(err)
 	}
-	a.applyBasicAuth(req)
-	client := &http.Client{Timeout: a.Timeout}
+	applyUserAgent(req, a.UserAgent)
+	a.applyAuth(req)
+	client := a.httpClient()
 	resp, err := client.Do(req)
 	if err != nil {
 		return errbuilder.New().
@@ -417,10 +714,38 @@ func (a RepoSnapshotProGetAdapter) DeleteSnapshot(ctx context.Context, snapshotI
 	return nil
 }
 
-func (a RepoSnapshotProGetAdapter) applyBasicAuth(req *http.Request) {
+// httpClient returns the shared pooled client, falling back to a plain
+// client for adapters constructed without NewRepoSnapshotProGetAdapter.
+func (a RepoSnapshotProGetAdapter) httpClient() *http.Client {
+	if a.HTTPClient != nil {
+		return a.HTTPClient
+	}
+	return &http.Client{Timeout: a.Timeout}
+}
+
+// ValidateProGetAuthMode checks that mode is a supported ProGet
+// authentication mode; an empty mode means basic auth.
+func ValidateProGetAuthMode(mode string) error {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", ProGetAuthBasic, ProGetAuthAPIKey:
+		return nil
+	default:
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("unsupported proget auth mode %q (expected basic or apikey)", mode))
+	}
+}
+
+// applyAuth authenticates req with the API key, either as the X-ApiKey
+// header or as the basic auth password depending on AuthMode.
+func (a RepoSnapshotProGetAdapter) applyAuth(req *http.Request) {
 	if strings.TrimSpace(a.APIKey) == "" {
 		return
 	}
+	if a.AuthMode == ProGetAuthAPIKey {
+		req.Header.Set("X-ApiKey", a.APIKey)
+		return
+	}
 	user := strings.TrimSpace(a.Username)
 	if user == "" {
 		user = "api"
@@ -428,6 +753,17 @@ func (a RepoSnapshotProGetAdapter) applyBasicAuth(req *http.Request) {
 	req.SetBasicAuth(user, a.APIKey)
 }
 
+// isChannel reports whether distribution is one of the configured
+// channel distributions.
+func (a RepoSnapshotProGetAdapter) isChannel(distribution string) bool {
+	for _, channel := range a.Channels {
+		if strings.EqualFold(strings.TrimSpace(channel), distribution) {
+			return true
+		}
+	}
+	return false
+}
+
 func decodeProgetDistributions(body []byte) ([]types.SnapshotInfo, error) {
 	var payload interface{}
 	if err := json.Unmarshal(body, &payload); err != nil {
@@ -456,6 +792,45 @@ func decodeProgetDistributions(body []byte) ([]types.SnapshotInfo, error) {
 	return snapshots, nil
 }
 
+func decodeProgetPackages(body []byte) (map[string]struct{}, error) {
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to parse proget package list").
+			WithCause(err)
+	}
+	packages := map[string]struct{}{}
+	for _, item := range extractDistributionItems(payload) {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := firstString(entry, "name", "Name", "packageName", "PackageName")
+		version := firstString(entry, "version", "Version")
+		if name == "" || version == "" {
+			continue
+		}
+		packages[name+"="+version] = struct{}{}
+	}
+	return packages, nil
+}
+
+// debFileIdentity extracts the package name and version from a deb file
+// named after the name_version_arch.deb convention.
+func debFileIdentity(path string) (string, string, bool) {
+	base := strings.TrimSuffix(filepath.Base(path), ".deb")
+	parts := strings.Split(base, "_")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	version := parts[1]
+	if decoded, err := url.PathUnescape(version); err == nil {
+		version = decoded
+	}
+	return parts[0], version, true
+}
+
 func extractDistributionItems(payload interface{}) []interface{} {
 	switch typed := payload.(type) {
 	case []interface{}:
@@ -0,0 +1,361 @@
+package adapters
+
+import (
+	"crypto/md5" //nolint:gosec // mirrors the Content-MD5 header
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProGetAdapterSendsUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	agents := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents[r.Method] = r.Header.Get("User-Agent")
+		mu.Unlock()
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+
+	debsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), []byte("deb"), 0644))
+
+	adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+		Endpoint:  server.URL,
+		Feed:      "avular",
+		DebsDir:   debsDir,
+		APIKey:    "secret",
+		Retries:   1,
+		UserAgent: "avular-packages/1.2.3",
+	})
+	require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+	_, err := adapter.ListSnapshots(t.Context())
+	require.NoError(t, err)
+	require.NoError(t, adapter.DeleteSnapshot(t.Context(), "snap-1"))
+
+	expected := map[string]string{
+		http.MethodPut:    "avular-packages/1.2.3",
+		http.MethodGet:    "avular-packages/1.2.3",
+		http.MethodDelete: "avular-packages/1.2.3",
+	}
+	if diff := cmp.Diff(expected, agents); diff != "" {
+		t.Fatalf("unexpected user agents (-want +got):\n%s", diff)
+	}
+}
+
+func TestProGetAdapterVerifiesUploads(t *testing.T) {
+	tests := []struct {
+		name     string
+		listed   string
+		wantErr  string
+		wantGets int
+	}{
+		{
+			name:     "all present",
+			listed:   `[{"name":"demo","version":"1.0.0"},{"name":"other","version":"2.0"}]`,
+			wantGets: 1,
+		},
+		{
+			name:     "missing artifact",
+			listed:   `[{"name":"demo","version":"1.0.0"}]`,
+			wantErr:  "other=2.0",
+			wantGets: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			gets := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet {
+					mu.Lock()
+					gets++
+					mu.Unlock()
+					require.Equal(t, "/api/packages/avular/versions", r.URL.Path)
+					_, _ = w.Write([]byte(tt.listed))
+				}
+			}))
+			defer server.Close()
+
+			debsDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), []byte("deb"), 0644))
+			require.NoError(t, os.WriteFile(filepath.Join(debsDir, "other_2.0_amd64.deb"), []byte("deb"), 0644))
+
+			adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+				Endpoint: server.URL,
+				Feed:     "avular",
+				DebsDir:  debsDir,
+				APIKey:   "secret",
+				Retries:  1,
+				Workers:  2,
+				Verify:   true,
+			})
+			err := adapter.Publish(t.Context(), "snap-1")
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.wantGets, gets)
+		})
+	}
+}
+
+func TestDebFileIdentity(t *testing.T) {
+	name, version, ok := debFileIdentity("/debs/python3-foo_1%3a2.0-1_all.deb")
+	require.True(t, ok)
+	require.Equal(t, "python3-foo", name)
+	require.Equal(t, "1:2.0-1", version)
+
+	_, _, ok = debFileIdentity("/debs/broken.deb")
+	require.False(t, ok)
+}
+
+func TestProGetAdapterSkipsExistingDebs(t *testing.T) {
+	var mu sync.Mutex
+	var puts []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			if r.URL.Path == "/debian/avular/pool/snap-1/main/libf/libfoo/libfoo_1.0_all.deb" {
+				w.Header().Set("Content-Length", "3")
+				return
+			}
+			if r.URL.Path == "/debian/avular/pool/snap-1/main/s/stale/stale_1.0_all.deb" {
+				w.Header().Set("Content-Length", "99")
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+		case http.MethodPut:
+			mu.Lock()
+			puts = append(puts, r.URL.Path)
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	debsDir := t.TempDir()
+	for _, name := range []string{"libfoo_1.0_all.deb", "stale_1.0_all.deb", "new_1.0_all.deb"} {
+		require.NoError(t, os.WriteFile(filepath.Join(debsDir, name), []byte("deb"), 0644))
+	}
+
+	adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+		Endpoint:     server.URL,
+		Feed:         "avular",
+		DebsDir:      debsDir,
+		APIKey:       "secret",
+		Retries:      1,
+		SkipExisting: true,
+	})
+	require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+
+	require.Len(t, puts, 2)
+	if diff := cmp.Diff(UploadStats{Uploaded: 2, Skipped: 1}, adapter.UploadStats()); diff != "" {
+		t.Fatalf("unexpected upload stats (-want +got):\n%s", diff)
+	}
+}
+
+func TestProGetAdapterCountsAlreadyUploadedAsSkipped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte("package already exists"))
+	}))
+	defer server.Close()
+
+	debsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), []byte("deb"), 0644))
+
+	adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+		Endpoint: server.URL,
+		Feed:     "avular",
+		DebsDir:  debsDir,
+		APIKey:   "secret",
+		Retries:  1,
+	})
+	require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+	if diff := cmp.Diff(UploadStats{Skipped: 1}, adapter.UploadStats()); diff != "" {
+		t.Fatalf("unexpected upload stats (-want +got):\n%s", diff)
+	}
+}
+
+func TestProGetAdapterSendsContentMD5(t *testing.T) {
+	var mu sync.Mutex
+	var header string
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		header = r.Header.Get("Content-MD5")
+		received = body
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	content := []byte("deb archive payload")
+	debsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), content, 0644))
+
+	adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+		Endpoint: server.URL,
+		Feed:     "avular",
+		DebsDir:  debsDir,
+		APIKey:   "secret",
+		Retries:  1,
+	})
+	require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+
+	sum := md5.Sum(content) //nolint:gosec // mirrors the Content-MD5 header
+	require.Equal(t, base64.StdEncoding.EncodeToString(sum[:]), header)
+	require.Equal(t, content, received)
+}
+
+func TestProGetAdapterUploadsWithContentLength(t *testing.T) {
+	var mu sync.Mutex
+	var lengths []int64
+	var encodings [][]string
+	var received []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		lengths = append(lengths, r.ContentLength)
+		encodings = append(encodings, r.TransferEncoding)
+		if !strings.HasPrefix(r.URL.Path, "/moved/") {
+			http.Redirect(w, r, "/moved"+r.URL.Path, http.StatusTemporaryRedirect)
+			return
+		}
+		received = body
+	}))
+	defer server.Close()
+
+	content := []byte("deb archive payload")
+	debsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), content, 0644))
+
+	adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+		Endpoint:             server.URL,
+		Feed:                 "avular",
+		DebsDir:              debsDir,
+		APIKey:               "secret",
+		Retries:              1,
+		RateLimitBytesPerSec: 1 << 20,
+	})
+	require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+
+	size := int64(len(content))
+	require.Equal(t, []int64{size, size}, lengths, "the redirected PUT must resend the whole body")
+	require.Equal(t, [][]string{nil, nil}, encodings)
+	require.Equal(t, content, received)
+}
+
+func TestProGetAdapterAuthModes(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		wantAPIKey string
+		wantBasic  bool
+	}{
+		{name: "default basic", mode: "", wantBasic: true},
+		{name: "basic", mode: "basic", wantBasic: true},
+		{name: "apikey", mode: "APIKey", wantAPIKey: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			seen := map[string]string{}
+			basic := map[string]bool{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _, ok := r.BasicAuth()
+				mu.Lock()
+				seen[r.Method] = r.Header.Get("X-ApiKey")
+				basic[r.Method] = ok
+				mu.Unlock()
+				if r.Method == http.MethodGet {
+					_, _ = w.Write([]byte(`[]`))
+				}
+			}))
+			defer server.Close()
+
+			debsDir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), []byte("deb"), 0644))
+
+			adapter := NewRepoSnapshotProGetAdapter(ProGetConfig{
+				Endpoint: server.URL,
+				Feed:     "avular",
+				DebsDir:  debsDir,
+				APIKey:   "secret",
+				AuthMode: tt.mode,
+				Retries:  1,
+			})
+			require.NoError(t, adapter.Publish(t.Context(), "snap-1"))
+			_, err := adapter.ListSnapshots(t.Context())
+			require.NoError(t, err)
+			require.NoError(t, adapter.DeleteSnapshot(t.Context(), "snap-1"))
+
+			for _, method := range []string{http.MethodPut, http.MethodGet, http.MethodDelete} {
+				require.Equal(t, tt.wantAPIKey, seen[method], method)
+				require.Equal(t, tt.wantBasic, basic[method], method)
+			}
+		})
+	}
+}
+
+func TestProGetAdapterRefusesToDeleteChannels(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			mu.Lock()
+			deleted = append(deleted, r.URL.Path)
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	cfg := ProGetConfig{
+		Endpoint: server.URL,
+		Feed:     "avular",
+		APIKey:   "secret",
+		Retries:  1,
+		Channels: []string{"dev"},
+	}
+	adapter := NewRepoSnapshotProGetAdapter(cfg)
+	err := adapter.DeleteSnapshot(t.Context(), "dev")
+	require.ErrorContains(t, err, "refusing to delete distribution dev")
+	require.Equal(t, errbuilder.CodeFailedPrecondition, errbuilder.CodeOf(err))
+	require.NoError(t, adapter.DeleteSnapshot(t.Context(), "dev-20260201"))
+
+	cfg.Force = true
+	require.NoError(t, NewRepoSnapshotProGetAdapter(cfg).DeleteSnapshot(t.Context(), "dev"))
+
+	want := []string{"/api/debian/avular/distributions/dev-20260201", "/api/debian/avular/distributions/dev"}
+	if diff := cmp.Diff(want, deleted); diff != "" {
+		t.Fatalf("unexpected deletes (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateProGetAuthMode(t *testing.T) {
+	require.NoError(t, ValidateProGetAuthMode(""))
+	require.NoError(t, ValidateProGetAuthMode("basic"))
+	require.NoError(t, ValidateProGetAuthMode(" ApiKey "))
+	require.Error(t, ValidateProGetAuthMode("token"))
+}
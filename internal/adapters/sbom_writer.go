@@ -122,7 +122,7 @@ func (a SBOMWriterAdapter) WriteSBOM(repoDir string, snapshotID string, createdA
 			WithCause(err)
 	}
 	path := filepath.Join(snapshotsDir, snapshotID+".sbom.json")
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := writeFileAtomic(path, data, 0644); err != nil {
 		return errbuilder.New().
 			WithCode(errbuilder.CodeInternal).
 			WithMsg("failed to write sbom file").
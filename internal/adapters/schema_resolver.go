@@ -2,6 +2,7 @@ package adapters
 
 import (
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
@@ -22,6 +23,10 @@ type SchemaResolverAdapter struct {
 
 	// layers tracks load order for debugging / provenance.
 	layers []string
+
+	// target is the normalized target release ("22.04") that selects
+	// per-target overrides; empty uses the top-level packages only.
+	target string
 }
 
 // NewSchemaResolverAdapter returns an empty resolver ready for schema
@@ -63,10 +68,10 @@ func (a *SchemaResolverAdapter) LoadSchema(path string) error {
 			continue
 		}
 
-		if mapping.Package == "" {
+		if problem := SchemaMappingProblem(mapping); problem != "" {
 			return errbuilder.New().
 				WithCode(errbuilder.CodeInvalidArgument).
-				WithMsg("schema key '" + normalizedKey + "' has empty package in " + path)
+				WithMsg("schema key '" + normalizedKey + "' " + problem + " in " + path)
 		}
 
 		if mapping.Type != types.DependencyTypeApt && mapping.Type != types.DependencyTypePip {
@@ -110,10 +115,10 @@ func (a *SchemaResolverAdapter) LoadSchemaInline(schema types.SchemaFile) error
 			continue
 		}
 
-		if mapping.Package == "" {
+		if problem := SchemaMappingProblem(mapping); problem != "" {
 			return errbuilder.New().
 				WithCode(errbuilder.CodeInvalidArgument).
-				WithMsg("inline schema key '" + normalizedKey + "' has empty package")
+				WithMsg("inline schema key '" + normalizedKey + "' " + problem)
 		}
 
 		if mapping.Type != types.DependencyTypeApt && mapping.Type != types.DependencyTypePip {
@@ -141,47 +146,64 @@ func (a *SchemaResolverAdapter) LoadSchemaInline(schema types.SchemaFile) error
 	return nil
 }
 
-// Resolve maps a single abstract key to a concrete Dependency.
-func (a *SchemaResolverAdapter) Resolve(key string) (types.Dependency, bool, error) {
+// SetTarget selects the target Ubuntu release ("24.04" or
+// "ubuntu-24.04") whose per-target overrides Resolve applies.
+func (a *SchemaResolverAdapter) SetTarget(target string) {
+	a.target = normalizeSchemaTarget(target)
+}
+
+// Resolve maps a single abstract key to its concrete Dependencies, one
+// per package the mapping lists for the current target.
+func (a *SchemaResolverAdapter) Resolve(key string) ([]types.Dependency, bool, error) {
 	normalizedKey := strings.TrimSpace(key)
 	mapping, ok := a.merged[normalizedKey]
 	if !ok {
-		return types.Dependency{}, false, nil
+		return nil, false, nil
 	}
-
-	dep := types.Dependency{
-		Name: mapping.Package,
-		Type: mapping.Type,
-	}
-
-	if mapping.Version != "" {
-		constraint, err := parseSchemaVersion(mapping.Package, mapping.Version, mapping.Type)
-		if err != nil {
-			return types.Dependency{}, false, errbuilder.New().
-				WithCode(errbuilder.CodeInvalidArgument).
-				WithMsg("failed to parse version for schema key '" + normalizedKey + "'").
-				WithCause(err)
+	mapping = applySchemaTarget(mapping, a.target)
+
+	packages, _ := schemaMappingPackages(mapping)
+	deps := make([]types.Dependency, 0, len(packages))
+	for _, pkg := range packages {
+		dep := types.Dependency{
+			Name: pkg,
+			Type: mapping.Type,
+		}
+		if mapping.Version != "" {
+			constraint, err := parseSchemaVersion(pkg, mapping.Version, mapping.Type)
+			if err != nil {
+				return nil, false, errbuilder.New().
+					WithCode(errbuilder.CodeInvalidArgument).
+					WithMsg("failed to parse version for schema key '" + normalizedKey + "'").
+					WithCause(err)
+			}
+			dep.Constraints = constraint
 		}
-		dep.Constraints = constraint
+		deps = append(deps, dep)
 	}
 
-	return dep, true, nil
+	return deps, true, nil
 }
 
 // ResolveAll maps a batch of ROS tag keys through the schema.
-// Unknown keys (no schema entry) are returned separately.
+// Unknown keys (no schema entry) are returned separately. Version
+// constraints declared on the tags are carried over to every mapped
+// package, accumulated across repeated keys.
 func (a *SchemaResolverAdapter) ResolveAll(keys []types.ROSTagDependency) ([]types.Dependency, []string, error) {
-	seen := make(map[string]struct{})
+	seen := make(map[string][]int)
 	var resolved []types.Dependency
 	var unknown []string
 
 	for _, tag := range keys {
-		if _, dup := seen[tag.Key]; dup {
+		if indexes, dup := seen[tag.Key]; dup {
+			for _, idx := range indexes {
+				resolved[idx].Constraints = append(resolved[idx].Constraints, tagConstraints(resolved[idx], tag)...)
+			}
 			continue
 		}
-		seen[tag.Key] = struct{}{}
+		seen[tag.Key] = nil
 
-		dep, ok, err := a.Resolve(tag.Key)
+		deps, ok, err := a.Resolve(tag.Key)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -190,19 +212,108 @@ func (a *SchemaResolverAdapter) ResolveAll(keys []types.ROSTagDependency) ([]typ
 			continue
 		}
 
-		// Annotate constraint source with the schema provenance
-		for i := range dep.Constraints {
-			if dep.Constraints[i].Source == "" {
-				dep.Constraints[i].Source = "schema:" + tag.Key
+		for _, dep := range deps {
+			// Annotate constraint source with the schema provenance
+			for i := range dep.Constraints {
+				if dep.Constraints[i].Source == "" {
+					dep.Constraints[i].Source = "schema:" + tag.Key
+				}
 			}
-		}
+			dep.Constraints = append(dep.Constraints, tagConstraints(dep, tag)...)
 
-		resolved = append(resolved, dep)
+			seen[tag.Key] = append(seen[tag.Key], len(resolved))
+			resolved = append(resolved, dep)
+		}
 	}
 
 	return resolved, unknown, nil
 }
 
+// SchemaMappingProblem describes why mapping or one of its per-target
+// overrides names no usable package, or returns "" when it is valid.
+func SchemaMappingProblem(mapping types.SchemaMapping) string {
+	if _, problem := schemaMappingPackages(mapping); problem != "" {
+		return problem
+	}
+	targets := make([]string, 0, len(mapping.Targets))
+	for target := range mapping.Targets {
+		targets = append(targets, target)
+	}
+	sort.Strings(targets)
+	for _, target := range targets {
+		override := mapping.Targets[target]
+		if normalizeSchemaTarget(target) == "" {
+			return "has an empty target"
+		}
+		if _, problem := schemaMappingPackages(types.SchemaMapping{Package: override.Package, Packages: override.Packages}); problem != "" {
+			return "target '" + target + "' " + problem
+		}
+	}
+	return ""
+}
+
+// applySchemaTarget returns mapping with the override for target, if
+// any, replacing its packages and, when set, its version.
+func applySchemaTarget(mapping types.SchemaMapping, target string) types.SchemaMapping {
+	if target == "" {
+		return mapping
+	}
+	for key, override := range mapping.Targets {
+		if normalizeSchemaTarget(key) != target {
+			continue
+		}
+		mapping.Package = override.Package
+		mapping.Packages = override.Packages
+		if override.Version != "" {
+			mapping.Version = override.Version
+		}
+		return mapping
+	}
+	return mapping
+}
+
+// normalizeSchemaTarget reduces "ubuntu-22.04" and "22.04" to "22.04".
+func normalizeSchemaTarget(target string) string {
+	normalized := strings.ToLower(strings.TrimSpace(target))
+	return strings.TrimSpace(strings.TrimPrefix(normalized, "ubuntu-"))
+}
+
+// schemaMappingPackages returns the concrete packages of mapping: its
+// packages list when set, otherwise the single package. When the mapping
+// names no usable package the second value describes why, e.g. "has
+// empty package"; it is empty for a valid mapping.
+func schemaMappingPackages(mapping types.SchemaMapping) ([]string, string) {
+	if mapping.Packages == nil {
+		if mapping.Package == "" {
+			return nil, "has empty package"
+		}
+		return []string{mapping.Package}, ""
+	}
+	if mapping.Package != "" {
+		return nil, "sets both package and packages"
+	}
+	if len(mapping.Packages) == 0 {
+		return nil, "has empty packages list"
+	}
+	for _, pkg := range mapping.Packages {
+		if strings.TrimSpace(pkg) == "" {
+			return nil, "has empty entry in packages"
+		}
+	}
+	return mapping.Packages, ""
+}
+
+// tagConstraints rebinds the version constraints of a ROS tag from its
+// abstract key to the concrete package it resolved to.
+func tagConstraints(dep types.Dependency, tag types.ROSTagDependency) []types.Constraint {
+	out := make([]types.Constraint, 0, len(tag.Constraints))
+	for _, constraint := range tag.Constraints {
+		constraint.Name = dep.Name
+		out = append(out, constraint)
+	}
+	return out
+}
+
 // HasKey returns true if the key exists in any loaded schema layer.
 func (a *SchemaResolverAdapter) HasKey(key string) bool {
 	_, ok := a.merged[strings.TrimSpace(key)]
@@ -35,7 +35,7 @@ mappings:
 	require.NoError(t, resolver.LoadSchema(schemaPath))
 
 	// Resolve known apt key
-	dep, ok, err := resolver.Resolve("fmt")
+	dep, ok, err := resolveSingle(t, resolver, "fmt")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "libfmt-dev", dep.Name)
@@ -45,14 +45,14 @@ mappings:
 	assert.Equal(t, "9.1.0", dep.Constraints[0].Version)
 
 	// Resolve known apt key without version
-	dep, ok, err = resolver.Resolve("rclcpp")
+	dep, ok, err = resolveSingle(t, resolver, "rclcpp")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "ros-humble-rclcpp", dep.Name)
 	assert.Empty(t, dep.Constraints)
 
 	// Resolve known pip key with compound constraint
-	dep, ok, err = resolver.Resolve("numpy")
+	dep, ok, err = resolveSingle(t, resolver, "numpy")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "numpy", dep.Name)
@@ -64,7 +64,7 @@ mappings:
 	assert.Equal(t, "2.0", dep.Constraints[1].Version)
 
 	// Resolve unknown key
-	_, ok, err = resolver.Resolve("unknown_pkg")
+	_, ok, err = resolveSingle(t, resolver, "unknown_pkg")
 	require.NoError(t, err)
 	assert.False(t, ok)
 }
@@ -104,7 +104,7 @@ mappings:
 	require.NoError(t, resolver.LoadSchema(overlay))
 
 	// numpy overridden by overlay
-	dep, ok, err := resolver.Resolve("numpy")
+	dep, ok, err := resolveSingle(t, resolver, "numpy")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	require.Len(t, dep.Constraints, 1)
@@ -112,13 +112,13 @@ mappings:
 	assert.Equal(t, "1.26.4", dep.Constraints[0].Version)
 
 	// fmt still from base
-	dep, ok, err = resolver.Resolve("fmt")
+	dep, ok, err = resolveSingle(t, resolver, "fmt")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "libfmt-dev", dep.Name)
 
 	// opencv added by overlay
-	dep, ok, err = resolver.Resolve("opencv")
+	dep, ok, err = resolveSingle(t, resolver, "opencv")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "libopencv-dev", dep.Name)
@@ -154,6 +154,38 @@ mappings:
 	assert.Equal(t, []string{"unknown_lib"}, unknown)
 }
 
+func TestSchemaResolverResolveAllCarriesTagConstraints(t *testing.T) {
+	resolver := NewSchemaResolverAdapter()
+	require.NoError(t, resolver.LoadSchemaInline(types.SchemaFile{
+		SchemaVersion: "v1",
+		Mappings: map[string]types.SchemaMapping{
+			"fmt": {Type: types.DependencyTypeApt, Package: "libfmt-dev"},
+		},
+	}))
+
+	tags := []types.ROSTagDependency{
+		{Key: "fmt", Scope: types.ROSDepScopeExec, Constraints: []types.Constraint{
+			{Name: "fmt", Op: types.ConstraintOpGte, Version: "1.2", Source: "package_xml:exec_depend"},
+		}},
+		{Key: "fmt", Scope: types.ROSDepScopeBuild, Constraints: []types.Constraint{
+			{Name: "fmt", Op: types.ConstraintOpLt, Version: "2.0", Source: "package_xml:build_depend"},
+		}},
+	}
+
+	resolved, unknown, err := resolver.ResolveAll(tags)
+	require.NoError(t, err)
+	assert.Empty(t, unknown)
+	want := []types.Dependency{{
+		Name: "libfmt-dev",
+		Type: types.DependencyTypeApt,
+		Constraints: []types.Constraint{
+			{Name: "libfmt-dev", Op: types.ConstraintOpGte, Version: "1.2", Source: "package_xml:exec_depend"},
+			{Name: "libfmt-dev", Op: types.ConstraintOpLt, Version: "2.0", Source: "package_xml:build_depend"},
+		},
+	}}
+	assert.Equal(t, want, resolved)
+}
+
 func TestSchemaResolverValidation(t *testing.T) {
 	dir := t.TempDir()
 
@@ -233,13 +265,13 @@ func TestSchemaResolverLoadInline(t *testing.T) {
 
 	require.NoError(t, resolver.LoadSchemaInline(schema))
 
-	dep, ok, err := resolver.Resolve("rclcpp")
+	dep, ok, err := resolveSingle(t, resolver, "rclcpp")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "ros-humble-rclcpp", dep.Name)
 	assert.Equal(t, types.DependencyTypeApt, dep.Type)
 
-	dep, ok, err = resolver.Resolve("numpy")
+	dep, ok, err = resolveSingle(t, resolver, "numpy")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "numpy", dep.Name)
@@ -278,7 +310,7 @@ mappings:
 	require.NoError(t, resolver.LoadSchema(filePath))
 
 	// numpy should be overridden by file
-	dep, ok, err := resolver.Resolve("numpy")
+	dep, ok, err := resolveSingle(t, resolver, "numpy")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	require.Len(t, dep.Constraints, 1)
@@ -286,7 +318,7 @@ mappings:
 	assert.Equal(t, "1.26.4", dep.Constraints[0].Version)
 
 	// rclcpp still from inline (file didn't define it)
-	dep, ok, err = resolver.Resolve("rclcpp")
+	dep, ok, err = resolveSingle(t, resolver, "rclcpp")
 	require.NoError(t, err)
 	assert.True(t, ok)
 	assert.Equal(t, "ros-humble-rclcpp", dep.Name)
@@ -324,3 +356,148 @@ func TestSchemaResolverInlineValidation(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "empty package")
 }
+
+// resolveSingle resolves key and expects a mapping with one package.
+func resolveSingle(t *testing.T, resolver *SchemaResolverAdapter, key string) (types.Dependency, bool, error) {
+	t.Helper()
+	deps, ok, err := resolver.Resolve(key)
+	if err != nil || !ok {
+		return types.Dependency{}, ok, err
+	}
+	require.Len(t, deps, 1)
+	return deps[0], ok, nil
+}
+
+func TestSchemaResolverMultiplePackages(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	require.NoError(t, os.WriteFile(schemaPath, []byte(`
+schema_version: "v1"
+mappings:
+  boost:
+    type: apt
+    packages: [libboost-dev, libboost-system-dev]
+    version: ">=1.74"
+  fmt:
+    type: apt
+    package: libfmt-dev
+`), 0644))
+
+	resolver := NewSchemaResolverAdapter()
+	require.NoError(t, resolver.LoadSchema(schemaPath))
+
+	deps, ok, err := resolver.Resolve("boost")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	require.Len(t, deps, 2)
+	assert.Equal(t, "libboost-dev", deps[0].Name)
+	assert.Equal(t, "libboost-system-dev", deps[1].Name)
+	for _, dep := range deps {
+		require.Len(t, dep.Constraints, 1)
+		assert.Equal(t, dep.Name, dep.Constraints[0].Name)
+		assert.Equal(t, "1.74", dep.Constraints[0].Version)
+	}
+
+	resolved, unknown, err := resolver.ResolveAll([]types.ROSTagDependency{
+		{Key: "boost", Scope: types.ROSDepScopeBuild},
+		{Key: "fmt", Scope: types.ROSDepScopeBuild},
+		{Key: "boost", Scope: types.ROSDepScopeExec, Constraints: []types.Constraint{
+			{Name: "boost", Op: types.ConstraintOpLt, Version: "2.0"},
+		}},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, unknown)
+	require.Len(t, resolved, 3)
+	assert.Equal(t, []string{"libboost-dev", "libboost-system-dev", "libfmt-dev"},
+		[]string{resolved[0].Name, resolved[1].Name, resolved[2].Name})
+	for _, dep := range resolved[:2] {
+		require.Len(t, dep.Constraints, 2)
+		assert.Equal(t, "schema", dep.Constraints[0].Source)
+		assert.Equal(t, dep.Name, dep.Constraints[1].Name)
+		assert.Equal(t, types.ConstraintOpLt, dep.Constraints[1].Op)
+	}
+}
+
+func TestSchemaMappingPackagesRejectsInvalidShapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping types.SchemaMapping
+		problem string
+	}{
+		{name: "empty package", mapping: types.SchemaMapping{}, problem: "has empty package"},
+		{name: "empty list", mapping: types.SchemaMapping{Packages: []string{}}, problem: "has empty packages list"},
+		{name: "blank entry", mapping: types.SchemaMapping{Packages: []string{"a", " "}}, problem: "has empty entry in packages"},
+		{name: "both shapes", mapping: types.SchemaMapping{Package: "a", Packages: []string{"b"}}, problem: "sets both package and packages"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packages, problem := schemaMappingPackages(tt.mapping)
+			assert.Nil(t, packages)
+			assert.Equal(t, tt.problem, problem)
+		})
+	}
+}
+
+func TestSchemaResolverTargetOverrides(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "schema.yaml")
+	require.NoError(t, os.WriteFile(schemaPath, []byte(`
+schema_version: "v1"
+mappings:
+  rclcpp:
+    type: apt
+    package: ros-rolling-rclcpp
+    version: ">=1.0"
+    targets:
+      ubuntu-22.04:
+        package: ros-humble-rclcpp
+      "24.04":
+        packages: [ros-jazzy-rclcpp, ros-jazzy-rcl]
+        version: ">=28.0"
+`), 0644))
+
+	resolver := NewSchemaResolverAdapter()
+	require.NoError(t, resolver.LoadSchema(schemaPath))
+
+	tests := []struct {
+		target  string
+		names   []string
+		version string
+	}{
+		{target: "", names: []string{"ros-rolling-rclcpp"}, version: "1.0"},
+		{target: "22.04", names: []string{"ros-humble-rclcpp"}, version: "1.0"},
+		{target: "ubuntu-24.04", names: []string{"ros-jazzy-rclcpp", "ros-jazzy-rcl"}, version: "28.0"},
+		{target: "20.04", names: []string{"ros-rolling-rclcpp"}, version: "1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			resolver.SetTarget(tt.target)
+			deps, ok, err := resolver.Resolve("rclcpp")
+			require.NoError(t, err)
+			require.True(t, ok)
+			var names []string
+			for _, dep := range deps {
+				names = append(names, dep.Name)
+				require.Len(t, dep.Constraints, 1)
+				assert.Equal(t, tt.version, dep.Constraints[0].Version)
+			}
+			assert.Equal(t, tt.names, names)
+		})
+	}
+}
+
+func TestSchemaResolverRejectsEmptyTargetOverride(t *testing.T) {
+	resolver := NewSchemaResolverAdapter()
+	err := resolver.LoadSchemaInline(types.SchemaFile{
+		SchemaVersion: "v1",
+		Mappings: map[string]types.SchemaMapping{
+			"rclcpp": {
+				Type:    types.DependencyTypeApt,
+				Package: "ros-rolling-rclcpp",
+				Targets: map[string]types.SchemaTargetMapping{"ubuntu-22.04": {}},
+			},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "target 'ubuntu-22.04' has empty package")
+}
@@ -0,0 +1,240 @@
+package adapters
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+
+	"avular-packages/internal/types"
+)
+
+// rosdepUbuntuReleases maps the Ubuntu codenames used as rosdep keys to
+// the release numbers schema targets are keyed by.
+var rosdepUbuntuReleases = map[string]string{
+	"bionic":   "18.04",
+	"focal":    "20.04",
+	"jammy":    "22.04",
+	"noble":    "24.04",
+	"oracular": "24.10",
+	"plucky":   "25.04",
+	"questing": "25.10",
+}
+
+// rosdepRule is the installer and package list a rosdep entry names for
+// one Ubuntu release.
+type rosdepRule struct {
+	Type     types.DependencyType
+	Packages []string
+}
+
+// LoadRosdepYAML reads a rosdep base.yaml-style file and merges its
+// Ubuntu rules as schema mappings, with the same last-write-wins
+// precedence as LoadSchema. Plain package lists (and apt installer
+// entries) become apt mappings; `pip: {packages: [...]}` entries become
+// pip mappings. Rules keyed by codename become per-target overrides,
+// with the `*` rule, or else the newest release, as the default. Keys
+// without an Ubuntu rule are skipped.
+func (a *SchemaResolverAdapter) LoadRosdepYAML(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeNotFound).
+			WithMsg("failed to read rosdep file: " + path).
+			WithCause(err)
+	}
+
+	var entries map[string]map[string]any
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("failed to parse rosdep file: " + path).
+			WithCause(err)
+	}
+
+	mappings := make(map[string]types.SchemaMapping, len(entries))
+	for key, platforms := range entries {
+		normalizedKey := strings.TrimSpace(key)
+		if normalizedKey == "" {
+			continue
+		}
+		mapping, ok, err := rosdepMapping(platforms["ubuntu"])
+		if err != nil {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg("rosdep key '" + normalizedKey + "' in " + path + ": " + err.Error())
+		}
+		if ok {
+			mappings[normalizedKey] = mapping
+		}
+	}
+
+	for key, mapping := range mappings {
+		if _, exists := a.merged[key]; exists {
+			log.Debug().
+				Str("key", key).
+				Str("layer", path).
+				Msg("schema key overridden by later layer")
+		}
+		a.merged[key] = mapping
+	}
+
+	a.layers = append(a.layers, path)
+	log.Debug().
+		Str("path", path).
+		Int("keys", len(mappings)).
+		Int("total", len(a.merged)).
+		Msg("rosdep layer loaded")
+
+	return nil
+}
+
+// rosdepMapping converts the ubuntu value of a rosdep entry. It is
+// either a rule for every release or a map from codename (or `*`) to
+// such a rule. It reports false when no release has packages.
+func rosdepMapping(value any) (types.SchemaMapping, bool, error) {
+	if byRelease, ok := value.(map[string]any); ok && !isRosdepInstaller(byRelease) {
+		return rosdepReleaseMapping(byRelease)
+	}
+	rule, ok, err := parseRosdepRule(value)
+	if err != nil || !ok {
+		return types.SchemaMapping{}, false, err
+	}
+	return types.SchemaMapping{Type: rule.Type, Packages: rule.Packages}, true, nil
+}
+
+// rosdepReleaseMapping converts codename-keyed rules into a mapping with
+// per-target overrides. Unknown codenames and null rules are skipped.
+func rosdepReleaseMapping(byRelease map[string]any) (types.SchemaMapping, bool, error) {
+	var fallback *rosdepRule
+	if value, ok := byRelease["*"]; ok {
+		rule, ok, err := parseRosdepRule(value)
+		if err != nil {
+			return types.SchemaMapping{}, false, err
+		}
+		if ok {
+			fallback = &rule
+		}
+	}
+
+	releases := map[string]rosdepRule{}
+	for codename, value := range byRelease {
+		release, known := rosdepUbuntuReleases[strings.ToLower(strings.TrimSpace(codename))]
+		if !known {
+			continue
+		}
+		rule, ok, err := parseRosdepRule(value)
+		if err != nil {
+			return types.SchemaMapping{}, false, err
+		}
+		if ok {
+			releases[release] = rule
+		}
+	}
+	if fallback == nil {
+		if len(releases) == 0 {
+			return types.SchemaMapping{}, false, nil
+		}
+		newest := sortedKeys(releases)[len(releases)-1]
+		rule := releases[newest]
+		fallback = &rule
+	}
+
+	mapping := types.SchemaMapping{Type: fallback.Type, Packages: fallback.Packages}
+	for _, release := range sortedKeys(releases) {
+		rule := releases[release]
+		if rule.Type != fallback.Type {
+			return types.SchemaMapping{}, false, fmt.Errorf("release %s uses %s but the default rule uses %s", release, rule.Type, fallback.Type)
+		}
+		if mapping.Targets == nil {
+			mapping.Targets = map[string]types.SchemaTargetMapping{}
+		}
+		mapping.Targets["ubuntu-"+release] = types.SchemaTargetMapping{Packages: rule.Packages}
+	}
+	return mapping, true, nil
+}
+
+// parseRosdepRule parses a single rule: a package name, a package list,
+// or an installer map such as `pip: {packages: [...]}`. Null and empty
+// rules report false.
+func parseRosdepRule(value any) (rosdepRule, bool, error) {
+	switch rule := value.(type) {
+	case nil:
+		return rosdepRule{}, false, nil
+	case string, []any:
+		packages, err := rosdepPackages(rule)
+		return rosdepRule{Type: types.DependencyTypeApt, Packages: packages}, len(packages) > 0, err
+	case map[string]any:
+		for _, depType := range []types.DependencyType{types.DependencyTypeApt, types.DependencyTypePip} {
+			installer := string(depType)
+			spec, ok := rule[installer]
+			if !ok {
+				continue
+			}
+			fields, ok := spec.(map[string]any)
+			if !ok {
+				return rosdepRule{}, false, fmt.Errorf("%s rule must be a map with packages", installer)
+			}
+			packages, err := rosdepPackages(fields["packages"])
+			return rosdepRule{Type: depType, Packages: packages}, len(packages) > 0, err
+		}
+		return rosdepRule{}, false, fmt.Errorf("unsupported installer (expected apt or pip)")
+	default:
+		return rosdepRule{}, false, fmt.Errorf("unsupported rule of type %T", value)
+	}
+}
+
+// rosdepPackages returns the package names of a scalar or list value.
+func rosdepPackages(value any) ([]string, error) {
+	switch packages := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if name := strings.TrimSpace(packages); name != "" {
+			return []string{name}, nil
+		}
+		return nil, nil
+	case []any:
+		var names []string
+		for _, item := range packages {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("package list entry %v is not a string", item)
+			}
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	default:
+		return nil, fmt.Errorf("packages must be a name or a list of names")
+	}
+}
+
+// rosdepInstallers are the rosdep installer keys; only apt and pip
+// rules can be imported.
+var rosdepInstallers = []string{"apt", "pip", "source", "gem", "npm"}
+
+// isRosdepInstaller reports whether m is an installer map rather than a
+// map keyed by release codename.
+func isRosdepInstaller(m map[string]any) bool {
+	for _, installer := range rosdepInstallers {
+		if _, ok := m[installer]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]rosdepRule) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+package adapters
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/types"
+)
+
+func TestSchemaResolverLoadRosdepYAML(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+
+	resolver := NewSchemaResolverAdapter()
+	require.NoError(t, resolver.LoadRosdepYAML(filepath.Join(root, "fixtures", "rosdep-base.yaml")))
+	assert.False(t, resolver.HasKey("fedora-only"))
+
+	if diff := cmp.Diff(map[string]types.SchemaMapping{
+		"boost": {Type: types.DependencyTypeApt, Packages: []string{"libboost-dev", "libboost-system-dev"}},
+		"eigen": {Type: types.DependencyTypeApt, Packages: []string{"libeigen3-dev"}},
+		"python3-numpy": {
+			Type:     types.DependencyTypeApt,
+			Packages: []string{"python3-numpy"},
+			Targets:  map[string]types.SchemaTargetMapping{"ubuntu-20.04": {Packages: []string{"python3-numpy"}}},
+		},
+		"rclcpp-vendor": {
+			Type:     types.DependencyTypeApt,
+			Packages: []string{"ros-jazzy-rclcpp"},
+			Targets: map[string]types.SchemaTargetMapping{
+				"ubuntu-22.04": {Packages: []string{"ros-humble-rclcpp"}},
+				"ubuntu-24.04": {Packages: []string{"ros-jazzy-rclcpp"}},
+			},
+		},
+		"python3-transforms3d-pip": {Type: types.DependencyTypePip, Packages: []string{"transforms3d"}},
+	}, resolver.merged); diff != "" {
+		t.Fatalf("unexpected mappings (-want +got):\n%s", diff)
+	}
+
+	resolver.SetTarget("ubuntu-22.04")
+	resolved, unknown, err := resolver.ResolveAll([]types.ROSTagDependency{
+		{Key: "rclcpp-vendor"},
+		{Key: "python3-transforms3d-pip"},
+		{Key: "fedora-only"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fedora-only"}, unknown)
+	require.Len(t, resolved, 2)
+	assert.Equal(t, "ros-humble-rclcpp", resolved[0].Name)
+	assert.Equal(t, types.DependencyTypePip, resolved[1].Type)
+}
+
+func TestSchemaResolverRosdepOverridesEarlierLayers(t *testing.T) {
+	dir := t.TempDir()
+	rosdepPath := filepath.Join(dir, "rosdep.yaml")
+	require.NoError(t, os.WriteFile(rosdepPath, []byte("fmt:\n  ubuntu: [libfmt-dev]\n"), 0644))
+
+	resolver := NewSchemaResolverAdapter()
+	require.NoError(t, resolver.LoadSchemaInline(types.SchemaFile{
+		SchemaVersion: "v1",
+		Mappings: map[string]types.SchemaMapping{
+			"fmt": {Type: types.DependencyTypeApt, Package: "libfmt9"},
+		},
+	}))
+	require.NoError(t, resolver.LoadRosdepYAML(rosdepPath))
+
+	dep, ok, err := resolveSingle(t, resolver, "fmt")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "libfmt-dev", dep.Name)
+}
+
+func TestSchemaResolverRosdepRejectsUnknownInstaller(t *testing.T) {
+	dir := t.TempDir()
+	rosdepPath := filepath.Join(dir, "rosdep.yaml")
+	require.NoError(t, os.WriteFile(rosdepPath, []byte("foo:\n  ubuntu:\n    source:\n      uri: http://example.com\n"), 0644))
+
+	err := NewSchemaResolverAdapter().LoadRosdepYAML(rosdepPath)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rosdep key 'foo'")
+}
@@ -0,0 +1,150 @@
+package adapters
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultProgressInterval throttles progress lines so that parallel
+// workers reading in small chunks do not flood the log.
+const defaultProgressInterval = 2 * time.Second
+
+// uploadProgress aggregates the bytes sent by all upload workers of one
+// distribution upload and periodically logs a summary such as
+// "uploading 12/80 (430MB/2.1GB)".
+type uploadProgress struct {
+	totalFiles int
+	totalBytes int64
+	started    atomic.Int64
+	sent       atomic.Int64
+	interval   time.Duration
+	logger     zerolog.Logger
+	now        func() time.Time
+
+	mu      sync.Mutex
+	lastLog time.Time
+}
+
+// newUploadProgress sizes the given debs up front. It returns nil when
+// quiet is set, which disables reporting for callers that wrap readers
+// unconditionally.
+func newUploadProgress(debs []string, quiet bool) *uploadProgress {
+	if quiet {
+		return nil
+	}
+	progress := &uploadProgress{
+		totalFiles: len(debs),
+		interval:   defaultProgressInterval,
+		logger:     log.Logger,
+		now:        time.Now,
+	}
+	for _, deb := range debs {
+		if info, err := os.Stat(deb); err == nil {
+			progress.totalBytes += info.Size()
+		}
+	}
+	return progress
+}
+
+// startFile records that another deb began uploading and returns its
+// 1-based position.
+func (p *uploadProgress) startFile() int {
+	if p == nil {
+		return 0
+	}
+	return int(p.started.Add(1))
+}
+
+func (p *uploadProgress) add(n int64, file string, fileSent int64) {
+	sent := p.sent.Add(n)
+	now := p.now()
+	p.mu.Lock()
+	if !p.lastLog.IsZero() && now.Sub(p.lastLog) < p.interval {
+		p.mu.Unlock()
+		return
+	}
+	p.lastLog = now
+	p.mu.Unlock()
+	started := p.started.Load()
+	p.logger.Info().
+		Str("deb", filepath.Base(file)).
+		Int64("deb_bytes", fileSent).
+		Int64("sent_bytes", sent).
+		Int64("total_bytes", p.totalBytes).
+		Msgf("uploading %d/%d (%s/%s)", started, p.totalFiles, formatByteSize(sent), formatByteSize(p.totalBytes))
+}
+
+// finish logs the final totals once every deb has been handled.
+func (p *uploadProgress) finish() {
+	if p == nil {
+		return
+	}
+	sent := p.sent.Load()
+	p.logger.Info().
+		Int64("sent_bytes", sent).
+		Msgf("uploaded %d/%d (%s)", p.started.Load(), p.totalFiles, formatByteSize(sent))
+}
+
+// progressReader counts bytes read from one deb into an uploadProgress.
+type progressReader struct {
+	reader   io.Reader
+	progress *uploadProgress
+	file     string
+	sent     int64
+}
+
+// trackReader wraps reader so its bytes are reported to progress,
+// returning reader unchanged when progress is disabled.
+func trackReader(reader io.Reader, progress *uploadProgress, file string) io.Reader {
+	if progress == nil {
+		return reader
+	}
+	return &progressReader{reader: reader, progress: progress, file: file}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.sent += int64(n)
+		r.progress.add(int64(n), r.file, r.sent)
+	}
+	return n, err
+}
+
+// discardProgress removes the bytes a failed attempt reported so that
+// retries do not inflate the aggregate.
+func discardProgress(reader io.Reader) {
+	tracked, ok := reader.(*progressReader)
+	if !ok {
+		return
+	}
+	tracked.progress.sent.Add(-tracked.sent)
+	tracked.sent = 0
+}
+
+// formatByteSize renders n with a decimal unit, e.g. "430MB" or "2.1GB".
+func formatByteSize(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for value := n / unit; value >= unit; value /= unit {
+		div *= unit
+		exp++
+	}
+	value := float64(n) / float64(div)
+	suffix := "kMGTPE"[exp]
+	if value >= 100 {
+		return fmt.Sprintf("%.0f%cB", value, suffix)
+	}
+	return fmt.Sprintf("%.1f%cB", value, suffix)
+}
@@ -0,0 +1,89 @@
+package adapters
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadProgressThrottlesLogLines(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "a_1.0_all.deb")
+	second := filepath.Join(dir, "b_1.0_all.deb")
+	require.NoError(t, os.WriteFile(first, bytes.Repeat([]byte("a"), 600), 0644))
+	require.NoError(t, os.WriteFile(second, bytes.Repeat([]byte("b"), 1400), 0644))
+
+	var out bytes.Buffer
+	now := time.Unix(0, 0)
+	progress := newUploadProgress([]string{first, second}, false)
+	progress.logger = zerolog.New(&out)
+	progress.now = func() time.Time { return now }
+
+	progress.startFile()
+	reader := trackReader(bytes.NewReader(make([]byte, 600)), progress, first)
+	buf := make([]byte, 100)
+	for {
+		_, err := reader.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	now = now.Add(defaultProgressInterval)
+	progress.startFile()
+	_, err := trackReader(bytes.NewReader(make([]byte, 1400)), progress, second).Read(make([]byte, 1400))
+	require.NoError(t, err)
+	progress.finish()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var messages []string
+	for _, line := range lines {
+		messages = append(messages, line[strings.Index(line, `"message":`):])
+	}
+	want := []string{
+		`"message":"uploading 1/2 (100B/2.0kB)"}`,
+		`"message":"uploading 2/2 (2.0kB/2.0kB)"}`,
+		`"message":"uploaded 2/2 (2.0kB)"}`,
+	}
+	if diff := cmp.Diff(want, messages); diff != "" {
+		t.Fatalf("unexpected progress lines (-want +got):\n%s", diff)
+	}
+}
+
+func TestUploadProgressDiscardsFailedAttempts(t *testing.T) {
+	progress := newUploadProgress(nil, false)
+	progress.logger = zerolog.Nop()
+	reader := trackReader(bytes.NewReader(make([]byte, 50)), progress, "a.deb")
+	_, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), progress.sent.Load())
+	discardProgress(reader)
+	require.Equal(t, int64(0), progress.sent.Load())
+}
+
+func TestUploadProgressQuiet(t *testing.T) {
+	require.Nil(t, newUploadProgress([]string{"a.deb"}, true))
+	source := bytes.NewReader(nil)
+	require.Same(t, io.Reader(source), trackReader(source, nil, "a.deb"))
+}
+
+func TestFormatByteSize(t *testing.T) {
+	tests := map[int64]string{
+		0:             "0B",
+		999:           "999B",
+		1500:          "1.5kB",
+		430_000_000:   "430MB",
+		2_100_000_000: "2.1GB",
+	}
+	for input, want := range tests {
+		require.Equal(t, want, formatByteSize(input), input)
+	}
+}
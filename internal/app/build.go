@@ -9,6 +9,7 @@ import (
 	"github.com/ZanzyTHEbar/errbuilder-go"
 
 	"avular-packages/internal/adapters"
+	"avular-packages/internal/core"
 	"avular-packages/internal/types"
 )
 
@@ -24,11 +25,14 @@ func (s Service) Build(ctx context.Context, req BuildRequest) (BuildResult, erro
 
 	// If we found a product, load it to apply build-specific defaults
 	// before evaluating outputDir and other fields.
+	var control types.DebControl
+	var warnings []Warning
 	if productPath != "" {
 		product, err := s.SpecLoader.LoadProduct(productPath)
 		if err == nil {
-			emitHints(checkBuildDefaultsHints(req, product.Defaults))
+			warnings = hintWarnings(WarningDefaultsHint, checkBuildDefaultsHints(req, product.Defaults))
 			req = applyBuildDefaults(req, product.Defaults)
+			control = product.Publish.Control
 		}
 	}
 
@@ -39,28 +43,67 @@ func (s Service) Build(ctx context.Context, req BuildRequest) (BuildResult, erro
 			WithMsg("output directory is required")
 	}
 
+	unlock, err := adapters.LockOutputDir(outputDir)
+	if err != nil {
+		return BuildResult{}, err
+	}
+	defer unlock()
+
 	resolveNeeded := productPath != "" ||
 		strings.TrimSpace(req.RepoIndex) != "" ||
-		strings.TrimSpace(req.TargetUbuntu) != ""
+		strings.TrimSpace(req.TargetUbuntu) != "" ||
+		len(req.ArchRepoIndexes) > 0
+	// Without a resolve phase the output directory holds the resolved
+	// inputs of the build, so it is expected to be non-empty.
+	if resolveNeeded {
+		if err := guardOutputDir(outputDir, req.Force, req.Clean); err != nil {
+			return BuildResult{}, err
+		}
+	}
 
 	if resolveNeeded {
-		_, err := s.Resolve(ctx, ResolveRequest{
-			ProductPath:          productPath,
-			Profiles:             req.Profiles,
-			Workspace:            req.Workspace,
-			RepoIndex:            req.RepoIndex,
-			OutputDir:            outputDir,
-			TargetUbuntu:         req.TargetUbuntu,
-			SchemaFiles:          req.SchemaFiles,
-			CompatGet:            true,
-			EmitAptPreferences:   req.EmitAptPreferences,
-			EmitAptInstallList:   req.EmitAptInstallList,
-			EmitSnapshotSources:  req.EmitSnapshotSources,
-			SnapshotAptBaseURL:   req.SnapshotAptBaseURL,
-			SnapshotAptComponent: req.SnapshotAptComponent,
-			SnapshotAptArchs:     req.SnapshotAptArchs,
-			AptSatSolver:         req.AptSatSolver,
-		})
+		resolved, err := s.resolve(ctx, ResolveRequest{
+			ProductPath:            productPath,
+			Profiles:               req.Profiles,
+			Workspace:              req.Workspace,
+			RepoIndex:              req.RepoIndex,
+			OutputDir:              outputDir,
+			TargetUbuntu:           req.TargetUbuntu,
+			SchemaFiles:            req.SchemaFiles,
+			CompatGet:              true,
+			EmitAptPreferences:     req.EmitAptPreferences,
+			EmitAptInstallList:     req.EmitAptInstallList,
+			EmitSnapshotSources:    req.EmitSnapshotSources,
+			SnapshotAptBaseURL:     req.SnapshotAptBaseURL,
+			SnapshotAptComponent:   req.SnapshotAptComponent,
+			SnapshotAptArchs:       req.SnapshotAptArchs,
+			AptSatSolver:           req.AptSatSolver,
+			PipSatSolver:           req.PipSatSolver,
+			BasePackages:           req.BasePackages,
+			AssumeEssential:        req.AssumeEssential,
+			SolverTimeout:          req.SolverTimeout,
+			AllowedScopes:          req.AllowedScopes,
+			IncludeBuildDeps:       req.IncludeBuildDeps,
+			ArchRepoIndexes:        req.ArchRepoIndexes,
+			UnsatCoreMaxIterations: req.UnsatCoreMaxIterations,
+			ToolVersion:            req.ToolVersion,
+		}, false)
+		if err != nil {
+			return BuildResult{}, err
+		}
+		for _, warning := range resolved.Warnings {
+			// The defaults hints above already cover the caller's flags;
+			// Resolve only sees the request after defaults were applied.
+			if warning.Code != WarningDefaultsHint {
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+	// Maintainer scripts come from the product's packaging groups whether
+	// or not this build resolved its inputs.
+	var maintainerScripts map[string]string
+	if productPath != "" {
+		maintainerScripts, err = s.productMaintainerScripts(ctx, productPath, req.Profiles)
 		if err != nil {
 			return BuildResult{}, err
 		}
@@ -87,11 +130,80 @@ func (s Service) Build(ctx context.Context, req BuildRequest) (BuildResult, erro
 		}
 	}
 
-	builder := adapters.NewPackageBuildAdapter(strings.TrimSpace(req.PipIndexURL))
+	buildLogDir := ""
+	if req.BuildLogs {
+		buildLogDir = filepath.Join(outputDir, "build-logs")
+	}
+	builder := adapters.NewPackageBuildAdapter(adapters.PackageBuildConfig{
+		PipIndexURL:       strings.TrimSpace(req.PipIndexURL),
+		Workers:           req.BuildWorkers,
+		Control:           control,
+		MaintainerScripts: maintainerScripts,
+		Compression:       req.DebCompression,
+		CompressionLevel:  req.DebCompressionLevel,
+		LogDir:            buildLogDir,
+		FailureSummary:    filepath.Join(outputDir, "build-failures.json"),
+		TempDir:           strings.TrimSpace(req.TempDir),
+		Transactional:     req.Transactional,
+		Architecture:      strings.TrimSpace(req.TargetArch),
+		PythonVersion:     buildPythonVersion(req),
+		CacheDir:          strings.TrimSpace(req.CacheDir),
+		CacheTTLMinutes:   req.CacheTTLMinutes,
+		Offline:           req.Offline,
+	})
+	if err := builder.CheckPipIndex(ctx, outputDir); err != nil {
+		return BuildResult{}, err
+	}
 	if err := builder.BuildDebs(outputDir, debsDir); err != nil {
 		return BuildResult{}, err
 	}
-	return BuildResult{DebsDir: debsDir}, nil
+	return BuildResult{DebsDir: debsDir, Warnings: warnings}, nil
+}
+
+// ubuntuPythonVersions maps Ubuntu releases to the python3 version
+// they ship as their default interpreter.
+var ubuntuPythonVersions = map[string]string{
+	"20.04": "3.8",
+	"22.04": "3.10",
+	"24.04": "3.12",
+	"24.10": "3.12",
+	"25.04": "3.13",
+	"25.10": "3.13",
+}
+
+// buildPythonVersion returns the python version debs are built for:
+// the requested one, else the default python of the target Ubuntu
+// release, else none (the version-independent python3 layout).
+func buildPythonVersion(req BuildRequest) string {
+	if version := strings.TrimSpace(req.PythonVersion); version != "" {
+		return version
+	}
+	return ubuntuPythonVersions[normalizeTargetUbuntu(req.TargetUbuntu)]
+}
+
+// productMaintainerScripts composes the product with its profiles and
+// returns the maintainer script directory of every packaging group that
+// sets one, keyed by group name.
+func (s Service) productMaintainerScripts(ctx context.Context, productPath string, profilePaths []string) (map[string]string, error) {
+	product, err := s.SpecLoader.LoadProduct(productPath)
+	if err != nil {
+		return nil, err
+	}
+	profiles, err := s.ProfileSource.LoadProfiles(product, profilePaths)
+	if err != nil {
+		return nil, err
+	}
+	composed, err := core.NewProductComposer().Compose(ctx, product, profiles)
+	if err != nil {
+		return nil, err
+	}
+	dirs := map[string]string{}
+	for _, group := range composed.Packaging.Groups {
+		if dir := strings.TrimSpace(group.MaintainerScripts); dir != "" {
+			dirs[group.Name] = dir
+		}
+	}
+	return dirs, nil
 }
 
 // applyBuildDefaults fills in BuildRequest fields from the product
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProductMaintainerScriptsComposesProfiles(t *testing.T) {
+	dir := t.TempDir()
+	profilePath := filepath.Join(dir, "profile.yaml")
+	require.NoError(t, os.WriteFile(profilePath, []byte(`api_version: "v1"
+kind: "profile"
+metadata:
+  name: "base-profile"
+  version: "2026.01"
+  owners: ["platform"]
+packaging:
+  groups:
+    - name: "pip-meta"
+      mode: "meta-bundle"
+      scope: "runtime"
+      matches: ["pip:*"]
+      targets: ["24.04"]
+      maintainer_scripts: "scripts/pip"
+    - name: "apt-individual"
+      mode: "individual"
+      scope: "runtime"
+      matches: ["apt:*"]
+      targets: ["24.04"]
+`), 0644))
+	productPath := filepath.Join(dir, "product.yaml")
+	require.NoError(t, os.WriteFile(productPath, []byte(`api_version: "v1"
+kind: "product"
+metadata:
+  name: "sample-product"
+  version: "2026.01.27"
+  owners: ["platform"]
+compose:
+  - name: "base-profile"
+    version: "2026.01"
+    source: "local"
+    path: "`+profilePath+`"
+`), 0644))
+
+	scripts, err := NewService().productMaintainerScripts(t.Context(), productPath, nil)
+	require.NoError(t, err)
+	if diff := cmp.Diff(map[string]string{"pip-meta": "scripts/pip"}, scripts); diff != "" {
+		t.Fatalf("unexpected maintainer scripts (-want +got):\n%s", diff)
+	}
+}
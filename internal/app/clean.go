@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/adapters"
+)
+
+// generatedOutputFiles are the files resolve and build write into the
+// output directory.
+var generatedOutputFiles = []string{
+	"apt.lock",
+	"apt.preferences",
+	"apt.install",
+	"bundle.manifest",
+	"snapshot.intent",
+	"snapshot.sources.list",
+	"resolution.report",
+	"resolve.json",
+	"effective-config.yaml",
+	"sbom.cdx.json",
+	"get-dependencies.apt",
+	"get-dependencies.pip",
+	"rosdep-mapping.yaml",
+	"build-failures.json",
+}
+
+// generatedOutputDirs are the directories build writes into the output
+// directory; they are removed with their contents.
+var generatedOutputDirs = []string{"debs", "build-logs"}
+
+// generatedOutputNames returns the files and directories resolve and
+// build generate in the output directory; clean and --clean remove
+// exactly these.
+func generatedOutputNames() []string {
+	return append(append([]string(nil), generatedOutputFiles...), generatedOutputDirs...)
+}
+
+func isGeneratedOutput(name string) bool {
+	for _, generated := range generatedOutputNames() {
+		if name == generated {
+			return true
+		}
+	}
+	return false
+}
+
+// Clean removes the generated artifacts from the output directory and
+// leaves every other file in place. Paths are reported relative to the
+// output directory.
+func (s Service) Clean(ctx context.Context, req CleanRequest) (CleanResult, error) {
+	outputDir := strings.TrimSpace(req.OutputDir)
+	if outputDir == "" {
+		return CleanResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("output directory is required")
+	}
+	result := CleanResult{DryRun: req.DryRun}
+	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
+		return result, nil
+	}
+	unlock, err := adapters.LockOutputDir(outputDir)
+	if err != nil {
+		return CleanResult{}, err
+	}
+	defer unlock()
+
+	for _, name := range generatedOutputNames() {
+		if err := ctx.Err(); err != nil {
+			return CleanResult{}, err
+		}
+		path := filepath.Join(outputDir, name)
+		if _, err := os.Lstat(path); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return CleanResult{}, errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to inspect " + path).
+				WithCause(err)
+		}
+		if !req.DryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return CleanResult{}, errbuilder.New().
+					WithCode(errbuilder.CodeInternal).
+					WithMsg("failed to remove " + path).
+					WithCause(err)
+			}
+		}
+		result.Removed = append(result.Removed, name)
+	}
+	sort.Strings(result.Removed)
+	return result, nil
+}
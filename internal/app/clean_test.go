@@ -0,0 +1,44 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanRemovesOnlyGeneratedArtifacts(t *testing.T) {
+	outDir := t.TempDir()
+	for _, name := range []string{"apt.lock", "bundle.manifest", "snapshot.intent", "notes.md", "debs/python3-demo_1.0.0_all.deb", "custom/keep.txt"} {
+		path := filepath.Join(outDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0644))
+	}
+	service := NewService()
+	want := []string{"apt.lock", "bundle.manifest", "debs", "snapshot.intent"}
+
+	result, err := service.Clean(t.Context(), CleanRequest{OutputDir: outDir, DryRun: true})
+	require.NoError(t, err)
+	if diff := cmp.Diff(CleanResult{Removed: want, DryRun: true}, result); diff != "" {
+		t.Fatalf("unexpected dry-run result (-want +got):\n%s", diff)
+	}
+	require.FileExists(t, filepath.Join(outDir, "apt.lock"))
+
+	result, err = service.Clean(t.Context(), CleanRequest{OutputDir: outDir})
+	require.NoError(t, err)
+	if diff := cmp.Diff(CleanResult{Removed: want}, result); diff != "" {
+		t.Fatalf("unexpected clean result (-want +got):\n%s", diff)
+	}
+	entries, err := os.ReadDir(outDir)
+	require.NoError(t, err)
+	var remaining []string
+	for _, entry := range entries {
+		remaining = append(remaining, entry.Name())
+	}
+	if diff := cmp.Diff([]string{"custom", "notes.md"}, remaining); diff != "" {
+		t.Fatalf("unexpected remaining files (-want +got):\n%s", diff)
+	}
+	require.FileExists(t, filepath.Join(outDir, "custom", "keep.txt"))
+}
@@ -87,6 +87,14 @@ func TestApplySpecDefaultsEmpty(t *testing.T) {
 	assert.Empty(t, result.OutputDir)
 }
 
+func TestBuildPythonVersionDefaultsToTargetUbuntu(t *testing.T) {
+	assert.Equal(t, "3.10", buildPythonVersion(BuildRequest{TargetUbuntu: "22.04"}))
+	assert.Equal(t, "3.12", buildPythonVersion(BuildRequest{TargetUbuntu: "ubuntu-24.04"}))
+	assert.Equal(t, "3.11", buildPythonVersion(BuildRequest{TargetUbuntu: "24.04", PythonVersion: "3.11"}))
+	assert.Empty(t, buildPythonVersion(BuildRequest{}))
+	assert.Empty(t, buildPythonVersion(BuildRequest{TargetUbuntu: "18.04"}))
+}
+
 func TestDiscoverProduct(t *testing.T) {
 	// discoverProduct looks in current directory; in test context
 	// there's no product.yaml so it should return empty
@@ -166,6 +174,21 @@ func TestValidateInlineSchemaEmptyPackage(t *testing.T) {
 	assert.Contains(t, err.Error(), "empty package")
 }
 
+func TestValidateInlineSchemaPackagesList(t *testing.T) {
+	schema := types.SchemaFile{
+		SchemaVersion: "v1",
+		Mappings: map[string]types.SchemaMapping{
+			"boost": {Type: types.DependencyTypeApt, Packages: []string{"libboost-dev", "libboost-system-dev"}},
+		},
+	}
+	assert.NoError(t, validateInlineSchema(schema))
+
+	schema.Mappings["boost"] = types.SchemaMapping{Type: types.DependencyTypeApt, Packages: []string{}}
+	err := validateInlineSchema(schema)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "empty packages list")
+}
+
 func TestValidateInlineProfileValid(t *testing.T) {
 	profile := types.InlineProfile{
 		Packaging: types.Packaging{
@@ -296,3 +319,53 @@ func TestCheckBuildDefaultsHints(t *testing.T) {
 		assert.Empty(t, hints)
 	})
 }
+
+func TestCheckUnusedDirectives(t *testing.T) {
+	unused := []types.ResolutionDirective{
+		{Dependency: "apt:libfoo", Action: "force", Value: "1.0.0", Owner: "platform"},
+	}
+
+	t.Run("hint per unused directive", func(t *testing.T) {
+		hints, err := checkUnusedDirectives(unused, false)
+		assert.NoError(t, err)
+		assert.Len(t, hints, 1)
+		assert.Contains(t, hints[0], "apt:libfoo (force, owner=platform) was not applied")
+	})
+
+	t.Run("strict turns unused directives into an error", func(t *testing.T) {
+		_, err := checkUnusedDirectives(unused, true)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unused resolution directives: apt:libfoo (force)")
+	})
+
+	t.Run("nothing to report", func(t *testing.T) {
+		hints, err := checkUnusedDirectives(nil, true)
+		assert.NoError(t, err)
+		assert.Empty(t, hints)
+	})
+}
+
+func TestCheckExpiredDirectives(t *testing.T) {
+	expired := []types.ResolutionDirective{
+		{Dependency: "apt:libfoo", Action: "force", Value: "1.0.0", Owner: "platform", ExpiresAt: "2026-01-31"},
+	}
+
+	t.Run("warning per expired directive", func(t *testing.T) {
+		hints, err := checkExpiredDirectives(expired, false)
+		assert.NoError(t, err)
+		assert.Len(t, hints, 1)
+		assert.Contains(t, hints[0], "warning: resolution directive apt:libfoo (force, owner=platform) expired on 2026-01-31")
+	})
+
+	t.Run("strict turns expired directives into an error", func(t *testing.T) {
+		_, err := checkExpiredDirectives(expired, true)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "expired resolution directives: apt:libfoo (expired 2026-01-31)")
+	})
+
+	t.Run("nothing to report", func(t *testing.T) {
+		hints, err := checkExpiredDirectives(nil, true)
+		assert.NoError(t, err)
+		assert.Empty(t, hints)
+	})
+}
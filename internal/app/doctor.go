@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"avular-packages/internal/adapters"
+	"avular-packages/internal/ports"
+)
+
+// Doctor checks the local environment for everything the build and
+// publish steps rely on: dpkg-deb, python3 and pip, gpg when a signing
+// key is configured, writable output and cache directories, and
+// reachable endpoints. Every check is reported; failing checks do not
+// stop the remaining ones.
+func (s Service) Doctor(ctx context.Context, req DoctorRequest) DoctorResult {
+	probe := s.Environment
+	if probe == nil {
+		probe = adapters.NewEnvironmentProbeAdapter()
+	}
+
+	var checks []DoctorCheck
+	checks = append(checks,
+		toolCheck(ctx, probe, "dpkg-deb", "dpkg-deb", "--version"),
+		toolCheck(ctx, probe, "python3", "python3", "--version"),
+		toolCheck(ctx, probe, "pip", "python3", "-m", "pip", "--version"),
+	)
+	if strings.TrimSpace(req.GpgKey) == "" {
+		checks = append(checks, DoctorCheck{Name: "gpg", Skipped: true, Detail: "no signing key configured"})
+	} else {
+		gpgBinary := strings.TrimSpace(req.GpgBinary)
+		if gpgBinary == "" {
+			gpgBinary = "gpg"
+		}
+		checks = append(checks, toolCheck(ctx, probe, "gpg", gpgBinary, "--version"))
+	}
+
+	for _, dir := range []struct{ name, path string }{
+		{"output dir", req.OutputDir},
+		{"cache dir", req.CacheDir},
+	} {
+		path := strings.TrimSpace(dir.path)
+		if path == "" {
+			checks = append(checks, DoctorCheck{Name: dir.name, Skipped: true, Detail: "not configured"})
+			continue
+		}
+		checks = append(checks, resultCheck(dir.name, path+" is writable", probe.CheckWritable(path)))
+	}
+
+	seen := map[string]struct{}{}
+	for _, endpoint := range req.Endpoints {
+		endpoint = strings.TrimSpace(endpoint)
+		if _, ok := seen[endpoint]; ok || endpoint == "" {
+			continue
+		}
+		seen[endpoint] = struct{}{}
+		checks = append(checks, resultCheck("endpoint "+endpoint, "reachable", probe.CheckEndpoint(ctx, endpoint)))
+	}
+	return DoctorResult{Checks: checks}
+}
+
+func toolCheck(ctx context.Context, probe ports.EnvironmentProbePort, name string, binary string, args ...string) DoctorCheck {
+	version, err := probe.ToolVersion(ctx, binary, args...)
+	return resultCheck(name, version, err)
+}
+
+func resultCheck(name string, detail string, err error) DoctorCheck {
+	if err != nil {
+		return DoctorCheck{Name: name, Detail: err.Error()}
+	}
+	return DoctorCheck{Name: name, OK: true, Detail: detail}
+}
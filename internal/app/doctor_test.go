@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeEnvironmentProbe struct {
+	missing map[string]bool
+}
+
+func (p fakeEnvironmentProbe) ToolVersion(_ context.Context, name string, _ ...string) (string, error) {
+	if p.missing[name] {
+		return "", errors.New(name + " not found in PATH")
+	}
+	return name + " 1.0", nil
+}
+
+func (p fakeEnvironmentProbe) CheckWritable(string) error {
+	return nil
+}
+
+func (p fakeEnvironmentProbe) CheckEndpoint(_ context.Context, endpoint string) error {
+	if p.missing[endpoint] {
+		return errors.New(endpoint + " is unreachable")
+	}
+	return nil
+}
+
+func TestDoctorReportsMissingTool(t *testing.T) {
+	service := Service{Environment: fakeEnvironmentProbe{missing: map[string]bool{
+		"dpkg-deb":             true,
+		"https://down.example": true,
+	}}}
+	result := service.Doctor(t.Context(), DoctorRequest{
+		OutputDir: "out",
+		GpgKey:    "ABCD",
+		Endpoints: []string{"https://up.example", "https://down.example", "https://up.example"},
+	})
+
+	want := []DoctorCheck{
+		{Name: "dpkg-deb", Detail: "dpkg-deb not found in PATH"},
+		{Name: "python3", OK: true, Detail: "python3 1.0"},
+		{Name: "pip", OK: true, Detail: "python3 1.0"},
+		{Name: "gpg", OK: true, Detail: "gpg 1.0"},
+		{Name: "output dir", OK: true, Detail: "out is writable"},
+		{Name: "cache dir", Skipped: true, Detail: "not configured"},
+		{Name: "endpoint https://up.example", OK: true, Detail: "reachable"},
+		{Name: "endpoint https://down.example", Detail: "https://down.example is unreachable"},
+	}
+	if diff := cmp.Diff(want, result.Checks); diff != "" {
+		t.Fatalf("unexpected doctor checks (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]DoctorCheck{want[0], want[7]}, result.Failed()); diff != "" {
+		t.Fatalf("unexpected failed checks (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,80 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/types"
+)
+
+// DumpDependencies collects the dependency set Resolve would resolve
+// for req, from the manual inputs, package.xml files and schemas, and
+// returns it without resolving any version. Neither a repo index nor
+// an output directory is needed.
+func (s Service) DumpDependencies(ctx context.Context, req ResolveRequest) (DumpDependenciesResult, error) {
+	productPath := strings.TrimSpace(req.ProductPath)
+	if productPath == "" {
+		productPath = discoverProduct()
+	}
+	if productPath == "" {
+		return DumpDependenciesResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("product spec path is required (provide --product or place product.yaml in current directory)")
+	}
+	product, err := s.SpecLoader.LoadProduct(productPath)
+	if err != nil {
+		return DumpDependenciesResult{}, err
+	}
+	req = applySpecDefaults(req, product.Defaults)
+	targetUbuntu := normalizeTargetUbuntu(req.TargetUbuntu)
+	if targetUbuntu == "" {
+		return DumpDependenciesResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("target Ubuntu release is required (provide --target-ubuntu or set defaults.target_ubuntu in product spec)")
+	}
+	_, deps, warnings, err := s.collectDependencies(ctx, req, productPath, product, targetUbuntu)
+	if err != nil {
+		return DumpDependenciesResult{}, err
+	}
+	return DumpDependenciesResult{Dump: buildDependencyDump(deps), Warnings: warnings}, nil
+}
+
+// buildDependencyDump converts collected dependencies into the dump
+// document, sorted by type and name. Slices are never nil so consumers
+// always see arrays.
+func buildDependencyDump(deps []types.Dependency) types.DependencyDump {
+	dump := types.DependencyDump{Dependencies: []types.DependencyDumpEntry{}}
+	for _, dep := range deps {
+		entry := types.DependencyDumpEntry{
+			Name:        dep.Name,
+			Type:        string(dep.Type),
+			Constraints: []types.DependencyDumpConstraint{},
+			Sources:     []string{},
+		}
+		seen := map[string]struct{}{}
+		for _, constraint := range dep.Constraints {
+			entry.Constraints = append(entry.Constraints, types.DependencyDumpConstraint{
+				Op:      string(constraint.Op),
+				Version: constraint.Version,
+				Source:  constraint.Source,
+			})
+			if _, ok := seen[constraint.Source]; ok || constraint.Source == "" {
+				continue
+			}
+			seen[constraint.Source] = struct{}{}
+			entry.Sources = append(entry.Sources, constraint.Source)
+		}
+		sort.Strings(entry.Sources)
+		dump.Dependencies = append(dump.Dependencies, entry)
+	}
+	sort.SliceStable(dump.Dependencies, func(i, j int) bool {
+		if dump.Dependencies[i].Type != dump.Dependencies[j].Type {
+			return dump.Dependencies[i].Type < dump.Dependencies[j].Type
+		}
+		return dump.Dependencies[i].Name < dump.Dependencies[j].Name
+	})
+	return dump
+}
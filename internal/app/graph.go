@@ -0,0 +1,83 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/adapters"
+	"avular-packages/internal/core"
+	"avular-packages/internal/types"
+)
+
+// Graph renders the apt dependency graph explored from the requested
+// roots (e.g. "postfix" or "libfoo>=1.2"). The rendering is written to
+// req.Output when set and returned in the result otherwise. Roots that
+// cannot be satisfied together still render, without selected versions
+// and with the conflict, and the result carries a warning.
+func (s Service) Graph(ctx context.Context, req GraphRequest) (GraphResult, error) {
+	repoIndex := strings.TrimSpace(req.RepoIndex)
+	if repoIndex == "" {
+		return GraphResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("repo index file is required (provide --repo-index)")
+	}
+	if len(req.Roots) == 0 {
+		return GraphResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("at least one root dependency is required")
+	}
+	var deps []types.Dependency
+	for _, root := range req.Roots {
+		constraint, err := core.ParseConstraint(root, "graph")
+		if err != nil {
+			return GraphResult{}, err
+		}
+		dep := types.Dependency{Name: constraint.Name, Type: types.DependencyTypeApt}
+		if constraint.Op != types.ConstraintOpNone {
+			dep.Constraints = []types.Constraint{constraint}
+		}
+		deps = append(deps, dep)
+	}
+
+	graph, err := core.BuildAptGraph(ctx, s.repoIndex(repoIndex), deps, req.BasePackages)
+	if err != nil {
+		return GraphResult{}, err
+	}
+	rendered, err := adapters.RenderDependencyGraph(graph, req.Format)
+	if err != nil {
+		return GraphResult{}, err
+	}
+	result := GraphResult{NodeCount: len(graph.Nodes), EdgeCount: len(graph.Edges)}
+	if graph.Unsatisfiable {
+		msg := "warning: apt solver found no satisfiable solution; the graph marks no selected versions"
+		if len(graph.Conflict) > 0 {
+			msg += "; conflict likely involves: " + strings.Join(graph.Conflict, ", ")
+		}
+		result.Warnings = append(result.Warnings, Warning{Code: WarningUnsatisfiable, Message: msg})
+	}
+	output := strings.TrimSpace(req.Output)
+	if output == "" {
+		result.Rendered = rendered
+		return result, nil
+	}
+	if dir := filepath.Dir(output); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return GraphResult{}, errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to create graph output directory").
+				WithCause(err)
+		}
+	}
+	if err := adapters.WriteFileAtomic(output, []byte(rendered), 0644); err != nil {
+		return GraphResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to write dependency graph").
+			WithCause(err)
+	}
+	result.OutputPath = output
+	return result, nil
+}
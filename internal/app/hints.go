@@ -2,9 +2,10 @@ package app
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
 	"avular-packages/internal/types"
 )
 
@@ -103,9 +104,72 @@ func checkBuildDefaultsHints(req BuildRequest, defaults types.SpecDefaults) []st
 	return hints
 }
 
-// emitHints writes hint messages to stderr.
-func emitHints(hints []string) {
-	for _, h := range hints {
-		fmt.Fprintln(os.Stderr, h)
+// checkUnusedDirectives returns a hint per resolution directive that
+// never fired. Under strict the unused directives are an error instead,
+// since a stale force or replace can mask real drift.
+func checkUnusedDirectives(unused []types.ResolutionDirective, strict bool) ([]string, error) {
+	if len(unused) == 0 {
+		return nil, nil
+	}
+	var hints []string
+	var names []string
+	for _, directive := range unused {
+		names = append(names, fmt.Sprintf("%s (%s)", directive.Dependency, directive.Action))
+		hints = append(hints, fmt.Sprintf(
+			"hint: resolution directive %s (%s, owner=%s) was not applied; consider removing it",
+			directive.Dependency, directive.Action, directive.Owner,
+		))
+	}
+	if strict {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg("unused resolution directives: " + strings.Join(names, ", "))
+	}
+	return hints, nil
+}
+
+// checkExpiredDirectives returns a warning per resolution directive
+// whose expires_at has passed. With strict set, any expired directive is
+// an error instead.
+func checkExpiredDirectives(expired []types.ResolutionDirective, strict bool) ([]string, error) {
+	if len(expired) == 0 {
+		return nil, nil
+	}
+	var hints []string
+	var names []string
+	for _, directive := range expired {
+		names = append(names, fmt.Sprintf("%s (expired %s)", directive.Dependency, directive.ExpiresAt))
+		hints = append(hints, fmt.Sprintf(
+			"warning: resolution directive %s (%s, owner=%s) expired on %s; revisit or remove it",
+			directive.Dependency, directive.Action, directive.Owner, directive.ExpiresAt,
+		))
+	}
+	if strict {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg("expired resolution directives: " + strings.Join(names, ", "))
+	}
+	return hints, nil
+}
+
+// hintWarnings wraps hint messages as warnings of the given code.
+func hintWarnings(code string, hints []string) []Warning {
+	var warnings []Warning
+	for _, hint := range hints {
+		warnings = append(warnings, Warning{Code: code, Message: hint})
+	}
+	return warnings
+}
+
+// unknownSchemaKeyWarnings reports the ROS tag keys that had no schema
+// mapping and were skipped.
+func unknownSchemaKeyWarnings(keys []string) []Warning {
+	if len(keys) == 0 {
+		return nil
 	}
+	return []Warning{{
+		Code: WarningUnknownSchemaKey,
+		Message: fmt.Sprintf("warning: %d ROS tag keys not found in schema (skipped): %s",
+			len(keys), strings.Join(keys, ", ")),
+	}}
 }
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/adapters"
+	"avular-packages/internal/types"
+)
+
+// CheckLock re-runs resolution for req.Resolve in a scratch directory
+// and compares the fresh apt.lock with the one committed in the output
+// directory. Entries are compared by package, so only genuine version
+// changes count as drift. The committed lock is looked up in the output
+// directory resolve would use, including the product's defaults.output.
+// With Update the fresh lock replaces the committed one when they
+// differ; no other output is written.
+func (s Service) CheckLock(ctx context.Context, req LockCheckRequest) (LockCheckResult, error) {
+	outputDir := strings.TrimSpace(req.Resolve.OutputDir)
+	if outputDir == "" {
+		productPath := strings.TrimSpace(req.Resolve.ProductPath)
+		if productPath == "" {
+			productPath = discoverProduct()
+		}
+		if productPath != "" {
+			product, err := s.SpecLoader.LoadProduct(productPath)
+			if err != nil {
+				return LockCheckResult{}, err
+			}
+			outputDir = strings.TrimSpace(applySpecDefaults(req.Resolve, product.Defaults).OutputDir)
+		}
+	}
+	if outputDir == "" {
+		outputDir = "out"
+	}
+	lockPath := filepath.Join(outputDir, "apt.lock")
+
+	scratch, err := os.MkdirTemp("", "avular-lock-check-")
+	if err != nil {
+		return LockCheckResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to create lock check directory").
+			WithCause(err)
+	}
+	defer os.RemoveAll(scratch)
+
+	resolveReq := req.Resolve
+	resolveReq.OutputDir = scratch
+	resolveReq.Force = false
+	resolveReq.Clean = false
+	resolved, err := s.resolve(ctx, resolveReq, true)
+	if err != nil {
+		return LockCheckResult{}, err
+	}
+	fresh, err := s.OutputReader.ReadAptLock(filepath.Join(scratch, "apt.lock"))
+	if err != nil {
+		return LockCheckResult{}, err
+	}
+	var committed []types.AptLockEntry
+	if _, err := os.Stat(lockPath); err == nil {
+		committed, err = s.OutputReader.ReadAptLock(lockPath)
+		if err != nil {
+			return LockCheckResult{}, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return LockCheckResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg(fmt.Sprintf("failed to stat %s", lockPath)).
+			WithCause(err)
+	}
+
+	result := LockCheckResult{
+		LockPath: lockPath,
+		Diff:     diffAptLocks(committed, fresh),
+		Warnings: resolved.Warnings,
+	}
+	if !req.Update || len(result.Diff) == 0 {
+		return result, nil
+	}
+	unlock, err := adapters.LockOutputDir(outputDir)
+	if err != nil {
+		return LockCheckResult{}, err
+	}
+	defer unlock()
+	if err := adapters.NewOutputFileAdapter(outputDir).WriteAptLock(fresh); err != nil {
+		return LockCheckResult{}, err
+	}
+	result.Updated = true
+	return result, nil
+}
+
+// diffAptLocks returns the changes from committed to fresh as
+// "-package=version" and "+package=version" lines, ordered by package.
+func diffAptLocks(committed []types.AptLockEntry, fresh []types.AptLockEntry) []string {
+	before := lockVersions(committed)
+	after := lockVersions(fresh)
+	packages := make([]string, 0, len(before)+len(after))
+	for name := range before {
+		packages = append(packages, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			packages = append(packages, name)
+		}
+	}
+	sort.Strings(packages)
+
+	var diff []string
+	for _, name := range packages {
+		oldVersion, hadOld := before[name]
+		newVersion, hasNew := after[name]
+		if hadOld && hasNew && oldVersion == newVersion {
+			continue
+		}
+		if hadOld {
+			diff = append(diff, fmt.Sprintf("-%s=%s", name, oldVersion))
+		}
+		if hasNew {
+			diff = append(diff, fmt.Sprintf("+%s=%s", name, newVersion))
+		}
+	}
+	return diff
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+)
+
+// guardOutputDir refuses to write into a non-empty output directory so
+// artifacts of an earlier run are not silently mixed with new ones.
+// force allows writing over them; clean removes the generated artifacts
+// first, and still refuses when other files are present unless force
+// is set, which leaves them in place. The directory itself is kept,
+// since the caller holds a lock on it.
+func guardOutputDir(dir string, force bool, clean bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to read output directory").
+			WithCause(err)
+	}
+	if len(entries) == 0 || (force && !clean) {
+		return nil
+	}
+	if !clean {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeAlreadyExists).
+			WithMsg(fmt.Sprintf("output directory %s is not empty (use --force to overwrite or --clean to empty it first)", dir))
+	}
+	var generated, unknown []string
+	for _, entry := range entries {
+		if isGeneratedOutput(entry.Name()) {
+			generated = append(generated, entry.Name())
+		} else {
+			unknown = append(unknown, entry.Name())
+		}
+	}
+	if len(unknown) > 0 && !force {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeAlreadyExists).
+			WithMsg(fmt.Sprintf("output directory %s contains files that were not generated: %s (use --force to keep them)", dir, strings.Join(unknown, ", ")))
+	}
+	for _, name := range generated {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg("failed to clean output directory").
+				WithCause(err)
+		}
+	}
+	return nil
+}
@@ -29,16 +29,28 @@ func (s Service) PruneSnapshots(ctx context.Context, req PruneRequest) (PruneRes
 	policy := types.SnapshotRetentionPolicy{
 		KeepLast:        req.KeepLast,
 		KeepDays:        req.KeepDays,
+		KeepWithin:      req.KeepWithin,
 		ProtectChannels: req.ProtectChannels,
 		ProtectPrefixes: req.ProtectPrefixes,
 		DryRun:          req.DryRun,
+		Force:           req.Force,
 	}
 	now := timeNow(s.Clock)
 	plan := BuildPrunePlan(snapshots, policy, now)
+	kept := make([]string, 0, len(plan.Keep))
+	for _, snapshot := range plan.Keep {
+		kept = append(kept, snapshot.SnapshotID)
+	}
 	if policy.DryRun {
+		planned := make([]string, 0, len(plan.Delete))
+		for _, snapshot := range plan.Delete {
+			planned = append(planned, snapshot.SnapshotID)
+		}
 		return PruneResult{
 			KeepCount:   len(plan.Keep),
 			DeleteCount: len(plan.Delete),
+			Kept:        kept,
+			Deleted:     planned,
 			DryRun:      true,
 		}, nil
 	}
@@ -52,6 +64,7 @@ func (s Service) PruneSnapshots(ctx context.Context, req PruneRequest) (PruneRes
 	return PruneResult{
 		KeepCount:   len(plan.Keep),
 		DeleteCount: len(deleted),
+		Kept:        kept,
 		Deleted:     deleted,
 		DryRun:      false,
 	}, nil
@@ -92,6 +105,9 @@ func buildPruneAdapter(backend string, req PruneRequest) (ports.RepoSnapshotPort
 				WithCode(errbuilder.CodeInvalidArgument).
 				WithMsg("proget api key is required")
 		}
+		if err := adapters.ValidateProGetAuthMode(req.ProGetAuthMode); err != nil {
+			return nil, err
+		}
 		component := strings.TrimSpace(req.ProGetComponent)
 		user := strings.TrimSpace(req.ProGetUser)
 		adapter := adapters.NewRepoSnapshotProGetAdapter(adapters.ProGetConfig{
@@ -100,9 +116,13 @@ func buildPruneAdapter(backend string, req PruneRequest) (ports.RepoSnapshotPort
 			Component:    component,
 			Username:     user,
 			APIKey:       apiKey,
+			AuthMode:     req.ProGetAuthMode,
 			TimeoutSec:   req.ProGetTimeoutSec,
 			Retries:      req.ProGetRetries,
 			RetryDelayMs: req.ProGetRetryDelayMs,
+			UserAgent:    strings.TrimSpace(req.UserAgent),
+			Channels:     req.ProtectChannels,
+			Force:        req.Force,
 		})
 		return adapter, nil
 	default:
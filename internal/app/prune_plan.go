@@ -8,6 +8,10 @@ import (
 	"avular-packages/internal/types"
 )
 
+// BuildPrunePlan splits snapshots into the ones policy keeps and the ones
+// to delete. Snapshots with a Channel (a channel points at them) are
+// never deleted, whether or not the channel is listed in ProtectChannels,
+// unless the policy forces it.
 func BuildPrunePlan(snapshots []types.SnapshotInfo, policy types.SnapshotRetentionPolicy, now time.Time) types.SnapshotPrunePlan {
 	if now.IsZero() {
 		now = time.Now().UTC()
@@ -23,7 +27,7 @@ func BuildPrunePlan(snapshots []types.SnapshotInfo, policy types.SnapshotRetenti
 		if strings.TrimSpace(current.Prefix) == "" {
 			current.Prefix = inferSnapshotPrefix(current.SnapshotID)
 		}
-		if isProtected(current, protectedChannels, protectedPrefixes) {
+		if isProtected(current, protectedChannels, protectedPrefixes, normalized.Force) {
 			keepIDs[current.SnapshotID] = struct{}{}
 		}
 		if normalized.KeepDays > 0 && !current.CreatedAt.IsZero() {
@@ -32,6 +36,11 @@ func BuildPrunePlan(snapshots []types.SnapshotInfo, policy types.SnapshotRetenti
 				keepIDs[current.SnapshotID] = struct{}{}
 			}
 		}
+		if normalized.KeepWithin > 0 && !current.CreatedAt.IsZero() {
+			if !current.CreatedAt.Before(now.Add(-normalized.KeepWithin)) {
+				keepIDs[current.SnapshotID] = struct{}{}
+			}
+		}
 		group := retentionGroupKey(current)
 		grouped[group] = append(grouped[group], current)
 	}
@@ -75,6 +84,9 @@ func normalizeRetentionPolicy(policy types.SnapshotRetentionPolicy) types.Snapsh
 	if normalized.KeepDays < 0 {
 		normalized.KeepDays = 0
 	}
+	if normalized.KeepWithin < 0 {
+		normalized.KeepWithin = 0
+	}
 	return normalized
 }
 
@@ -90,9 +102,15 @@ func normalizeSet(values []string) map[string]struct{} {
 	return set
 }
 
-func isProtected(snapshot types.SnapshotInfo, channels map[string]struct{}, prefixes map[string]struct{}) bool {
-	if snapshot.Channel != "" {
-		if _, ok := channels[strings.ToLower(snapshot.Channel)]; ok {
+// isProtected reports whether snapshot is exempt from pruning: it is
+// the target of a channel pointer, or its channel or prefix is
+// protected explicitly. force lifts the channel protection only.
+func isProtected(snapshot types.SnapshotInfo, channels map[string]struct{}, prefixes map[string]struct{}, force bool) bool {
+	if !force {
+		if strings.TrimSpace(snapshot.Channel) != "" {
+			return true
+		}
+		if _, ok := channels[strings.ToLower(snapshot.SnapshotID)]; ok {
 			return true
 		}
 	}
@@ -45,6 +45,39 @@ func TestBuildPrunePlanKeepDays(t *testing.T) {
 	require.ElementsMatch(t, []string{"pfx-old"}, deleted)
 }
 
+func TestBuildPrunePlanKeepWithin(t *testing.T) {
+	now := time.Date(2026, 2, 2, 12, 0, 0, 0, time.UTC)
+	snapshots := []types.SnapshotInfo{
+		{SnapshotID: "pfx-recent", Prefix: "pfx", CreatedAt: now.Add(-6 * time.Hour)},
+		{SnapshotID: "pfx-old", Prefix: "pfx", CreatedAt: now.Add(-36 * time.Hour)},
+	}
+	policy := types.SnapshotRetentionPolicy{KeepWithin: 12 * time.Hour}
+
+	plan := BuildPrunePlan(snapshots, policy, now)
+	kept := snapshotIDs(plan.Keep)
+	deleted := snapshotIDs(plan.Delete)
+
+	require.ElementsMatch(t, []string{"pfx-recent"}, kept)
+	require.ElementsMatch(t, []string{"pfx-old"}, deleted)
+}
+
+func TestBuildPrunePlanKeepsChannelPointedSnapshots(t *testing.T) {
+	now := time.Date(2026, 2, 2, 12, 0, 0, 0, time.UTC)
+	snapshots := []types.SnapshotInfo{
+		{SnapshotID: "pfx-111", Channel: "stable", Prefix: "pfx", CreatedAt: now.AddDate(0, 0, -90)},
+		{SnapshotID: "pfx-222", Prefix: "pfx", CreatedAt: now.AddDate(0, 0, -60)},
+		{SnapshotID: "pfx-333", Prefix: "pfx", CreatedAt: now.AddDate(0, 0, -1)},
+	}
+	policy := types.SnapshotRetentionPolicy{KeepLast: 1}
+
+	plan := BuildPrunePlan(snapshots, policy, now)
+	kept := snapshotIDs(plan.Keep)
+	deleted := snapshotIDs(plan.Delete)
+
+	require.ElementsMatch(t, []string{"pfx-111", "pfx-333"}, kept)
+	require.ElementsMatch(t, []string{"pfx-222"}, deleted)
+}
+
 func TestBuildPrunePlanProtectChannelsAndPrefixes(t *testing.T) {
 	now := time.Date(2026, 2, 2, 12, 0, 0, 0, time.UTC)
 	snapshots := []types.SnapshotInfo{
@@ -1,8 +1,12 @@
 package app
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -33,9 +37,47 @@ func TestPruneSnapshotsFileBackend(t *testing.T) {
 	})
 	require.NoError(t, err)
 	require.Equal(t, 1, result.DeleteCount)
+	require.Equal(t, []string{"snap-2"}, result.Kept)
+	require.Equal(t, []string{"snap-1"}, result.Deleted)
 
 	_, err = os.Stat(filepath.Join(dir, "snapshots", "snap-1.snapshot"))
 	require.Error(t, err)
 	_, err = os.Stat(filepath.Join(dir, "snapshots", "snap-2.snapshot"))
 	require.NoError(t, err)
 }
+
+func TestPruneSnapshotsProGetSkipsChannelDistributions(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write([]byte(`[{"name":"dev","createdAt":"2026-01-01T00:00:00Z"},{"name":"snap-1","createdAt":"2026-01-02T00:00:00Z"},{"name":"snap-2","createdAt":"2026-01-03T00:00:00Z"}]`))
+		case http.MethodDelete:
+			mu.Lock()
+			deleted = append(deleted, path.Base(r.URL.Path))
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+
+	req := PruneRequest{
+		RepoBackend:     "proget",
+		ProtectChannels: []string{"dev"},
+		ProGetEndpoint:  server.URL,
+		ProGetFeed:      "avular",
+		ProGetAPIKey:    "secret",
+		ProGetRetries:   1,
+	}
+	result, err := NewService().PruneSnapshots(t.Context(), req)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dev"}, result.Kept)
+	require.ElementsMatch(t, []string{"snap-1", "snap-2"}, deleted)
+
+	deleted = nil
+	req.Force = true
+	result, err = NewService().PruneSnapshots(t.Context(), req)
+	require.NoError(t, err)
+	require.Empty(t, result.Kept)
+	require.ElementsMatch(t, []string{"dev", "snap-1", "snap-2"}, deleted)
+}
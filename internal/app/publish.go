@@ -2,18 +2,21 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"path/filepath"
 	"strings"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
 
 	"avular-packages/internal/adapters"
+	"avular-packages/internal/core"
 	"avular-packages/internal/types"
 )
 
-// Publish creates a repository snapshot using the configured backend
-// (file, aptly, or proget), optionally generates an SBOM, and returns
-// the snapshot identifier.
+// Publish creates a repository snapshot using the backend registered
+// under req.RepoBackend in Service.RepoBackends (file, aptly and proget
+// by default), promotes it to the intent's channel, optionally generates
+// SPDX and CycloneDX SBOMs, and returns the snapshot identifier.
 func (s Service) Publish(ctx context.Context, req PublishRequest) (PublishResult, error) {
 	outputDir := strings.TrimSpace(req.OutputDir)
 	if outputDir == "" {
@@ -31,124 +34,81 @@ func (s Service) Publish(ctx context.Context, req PublishRequest) (PublishResult
 	}
 	repoBackend := strings.ToLower(strings.TrimSpace(req.RepoBackend))
 	if repoBackend == "" {
-		repoBackend = "file"
+		repoBackend = RepoBackendFile
 	}
 
-	switch repoBackend {
-	case "file":
-		if err := publishFile(ctx, repoDir, intent); err != nil {
-			return PublishResult{}, err
-		}
-	case "aptly":
-		if err := publishAptly(ctx, outputDir, req, intent); err != nil {
-			return PublishResult{}, err
-		}
-	case "proget":
-		if err := publishProGet(ctx, outputDir, req, intent); err != nil {
-			return PublishResult{}, err
-		}
-	default:
+	backends := s.repoBackends()
+	factory, ok := backends.Lookup(repoBackend)
+	if !ok {
 		return PublishResult{}, errbuilder.New().
 			WithCode(errbuilder.CodeInvalidArgument).
-			WithMsg("unsupported repo backend")
+			WithMsg(fmt.Sprintf("unsupported repo backend %q (registered: %s)", repoBackend, strings.Join(backends.Names(), ", ")))
 	}
-
-	if req.SBOM {
-		locks, err := s.OutputReader.ReadAptLock(filepath.Join(outputDir, "apt.lock"))
-		if err != nil {
-			return PublishResult{}, err
-		}
-		if err := s.SBOMWriter.WriteSBOM(repoDir, intent.SnapshotID, intent.CreatedAt, locks); err != nil {
-			return PublishResult{}, err
-		}
-	}
-	return PublishResult{SnapshotID: intent.SnapshotID}, nil
-}
-
-// publishFile creates a file-backed snapshot and promotes it to a
-// channel if one is configured.
-func publishFile(ctx context.Context, repoDir string, intent types.SnapshotIntent) error {
-	adapter := adapters.NewRepoSnapshotFileAdapter(repoDir)
-	if err := adapter.Publish(ctx, intent.SnapshotID); err != nil {
-		return err
+	adapter, err := factory(req, intent, outputDir, repoDir)
+	if err != nil {
+		return PublishResult{}, err
 	}
-	if strings.TrimSpace(intent.Channel) != "" {
-		return adapter.Promote(ctx, intent.SnapshotID, intent.Channel)
+	planner, canPlan := adapter.(uploadPlanner)
+	if req.DryRun && !canPlan {
+		return PublishResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("dry run is only supported for the proget backend and other backends that plan uploads")
 	}
-	return nil
-}
 
-// publishAptly creates a snapshot via the Aptly CLI adapter, uploading
-// debs and publishing to a prefix/endpoint with GPG signing.
-func publishAptly(ctx context.Context, outputDir string, req PublishRequest, intent types.SnapshotIntent) error {
-	debsDir := strings.TrimSpace(req.DebsDir)
-	if debsDir == "" {
-		debsDir = filepath.Join(outputDir, "debs")
+	if err := adapter.Publish(ctx, intent.SnapshotID); err != nil {
+		return PublishResult{}, err
 	}
-	repoName := strings.TrimSpace(req.AptlyRepo)
-	if repoName == "" {
-		repoName = intent.Repository
+	var uploadStats adapters.UploadStats
+	if counter, ok := adapter.(uploadCounter); ok {
+		uploadStats = counter.UploadStats()
 	}
-	component := strings.TrimSpace(req.AptlyComponent)
-	prefix := strings.TrimSpace(req.AptlyPrefix)
-	endpoint := strings.TrimSpace(req.AptlyEndpoint)
-	gpgKey := strings.TrimSpace(req.GpgKey)
-	if gpgKey == "" {
-		gpgKey = intent.SigningKey
+	if strings.TrimSpace(intent.Channel) != "" {
+		if err := adapter.Promote(ctx, intent.SnapshotID, intent.Channel); err != nil {
+			return PublishResult{}, err
+		}
 	}
-	if strings.TrimSpace(gpgKey) == "" {
-		return errbuilder.New().
-			WithCode(errbuilder.CodeInvalidArgument).
-			WithMsg("gpg key is required for aptly backend")
+	var warnings []Warning
+	if repoBackend == RepoBackendFile && req.AptLayout && releaseSigningKey(req, intent) == "" {
+		warnings = append(warnings, Warning{
+			Code:    WarningUnsignedRelease,
+			Message: "warning: apt repository Release is unsigned; set --gpg-key or publish.signing_key to sign it",
+		})
 	}
 
-	adapter := adapters.NewRepoSnapshotAptlyAdapter(repoName, intent.Channel, component, debsDir, prefix, endpoint, gpgKey)
-	if err := adapter.Publish(ctx, intent.SnapshotID); err != nil {
-		return err
+	if req.DryRun {
+		return PublishResult{SnapshotID: intent.SnapshotID, PlannedUploads: planner.PlannedUploads(), Warnings: warnings}, nil
 	}
-	return adapter.Promote(ctx, intent.SnapshotID, intent.Channel)
-}
 
-// publishProGet creates a snapshot via the ProGet HTTP API adapter,
-// uploading debs and optionally promoting to a channel.
-func publishProGet(ctx context.Context, outputDir string, req PublishRequest, intent types.SnapshotIntent) error {
-	debsDir := strings.TrimSpace(req.DebsDir)
-	if debsDir == "" {
-		debsDir = filepath.Join(outputDir, "debs")
-	}
-	endpoint := strings.TrimSpace(req.ProGetEndpoint)
-	feed := strings.TrimSpace(req.ProGetFeed)
-	if feed == "" {
-		feed = intent.Repository
-	}
-	component := strings.TrimSpace(req.ProGetComponent)
-	user := strings.TrimSpace(req.ProGetUser)
-	apiKey := strings.TrimSpace(req.ProGetAPIKey)
-	workers := req.ProGetWorkers
-	if strings.TrimSpace(apiKey) == "" {
-		return errbuilder.New().
-			WithCode(errbuilder.CodeInvalidArgument).
-			WithMsg("proget api key is required for proget backend")
+	if req.SBOM {
+		locks, err := s.OutputReader.ReadAptLock(filepath.Join(outputDir, "apt.lock"))
+		if err != nil {
+			return PublishResult{}, err
+		}
+		if err := s.SBOMWriter.WriteSBOM(repoDir, intent.SnapshotID, intent.CreatedAt, locks); err != nil {
+			return PublishResult{}, err
+		}
+		manifest, err := s.OutputReader.ReadBundleManifest(filepath.Join(outputDir, "bundle.manifest"))
+		if err != nil {
+			return PublishResult{}, err
+		}
+		bom := core.BuildCycloneDXBOM(intent, locks, manifest)
+		if err := adapters.NewOutputFileAdapter(outputDir).WriteCycloneDXSBOM(bom); err != nil {
+			return PublishResult{}, err
+		}
 	}
+	return PublishResult{
+		SnapshotID: intent.SnapshotID,
+		Uploaded:   uploadStats.Uploaded,
+		Skipped:    uploadStats.Skipped,
+		Warnings:   warnings,
+	}, nil
+}
 
-	adapter := adapters.NewRepoSnapshotProGetAdapter(adapters.ProGetConfig{
-		Endpoint:       endpoint,
-		Feed:           feed,
-		Component:      component,
-		DebsDir:        debsDir,
-		Username:       user,
-		APIKey:         apiKey,
-		SnapshotPrefix: intent.SnapshotPrefix,
-		Workers:        workers,
-		TimeoutSec:     req.ProGetTimeoutSec,
-		Retries:        req.ProGetRetries,
-		RetryDelayMs:   req.ProGetRetryDelayMs,
-	})
-	if err := adapter.Publish(ctx, intent.SnapshotID); err != nil {
-		return err
-	}
-	if strings.TrimSpace(intent.Channel) != "" {
-		return adapter.Promote(ctx, intent.SnapshotID, intent.Channel)
+// releaseSigningKey returns the gpg key that signs the file backend's
+// Release: --gpg-key when given, else the spec's signing key.
+func releaseSigningKey(req PublishRequest, intent types.SnapshotIntent) string {
+	if gpgKey := strings.TrimSpace(req.GpgKey); gpgKey != "" {
+		return gpgKey
 	}
-	return nil
+	return strings.TrimSpace(intent.SigningKey)
 }
@@ -2,11 +2,14 @@ package app
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"avular-packages/internal/ports"
 	"avular-packages/internal/types"
 )
 
@@ -115,3 +118,108 @@ func TestPublish_BackendDefaultsToFile(t *testing.T) {
 		assert.NotContains(t, err.Error(), "unsupported repo backend")
 	}
 }
+
+func TestPublish_DryRunRequiresProGet(t *testing.T) {
+	svc := Service{
+		OutputReader: stubOutputReader{intent: types.SnapshotIntent{SnapshotID: "test-snap"}},
+	}
+	_, err := svc.Publish(context.Background(), PublishRequest{
+		OutputDir:   "/tmp/test-publish",
+		RepoBackend: "file",
+		DryRun:      true,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dry run is only supported for the proget backend")
+}
+
+func TestPublish_ProGetDryRunListsUploads(t *testing.T) {
+	outputDir := t.TempDir()
+	debsDir := filepath.Join(outputDir, "debs")
+	require.NoError(t, os.MkdirAll(debsDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(debsDir, "demo_1.0.0_all.deb"), []byte("deb"), 0o644))
+
+	svc := Service{
+		OutputReader: stubOutputReader{
+			intent: types.SnapshotIntent{
+				SnapshotID:     "snap-1",
+				SnapshotPrefix: "avular",
+				Repository:     "feed",
+				Channel:        "stable",
+			},
+		},
+		SBOMWriter: stubSBOMWriter{},
+	}
+	result, err := svc.Publish(context.Background(), PublishRequest{
+		OutputDir:      outputDir,
+		RepoBackend:    "proget",
+		ProGetEndpoint: "http://127.0.0.1:1",
+		ProGetAPIKey:   "secret",
+		SBOM:           true,
+		DryRun:         true,
+	})
+	require.NoError(t, err)
+	deb := filepath.Join(debsDir, "demo_1.0.0_all.deb")
+	assert.Equal(t, []string{
+		"PUT http://127.0.0.1:1/debian/feed/upload/avular-snap-1/main " + deb,
+		"PUT http://127.0.0.1:1/debian/feed/upload/stable/main " + deb,
+	}, result.PlannedUploads)
+	_, err = os.Stat(filepath.Join(outputDir, "repo"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// recordingRepoSnapshot is a fake backend recording the calls Publish
+// makes on it.
+type recordingRepoSnapshot struct {
+	calls *[]string
+}
+
+func (r recordingRepoSnapshot) Publish(_ context.Context, snapshotID string) error {
+	*r.calls = append(*r.calls, "publish "+snapshotID)
+	return nil
+}
+
+func (r recordingRepoSnapshot) Promote(_ context.Context, snapshotID string, channel string) error {
+	*r.calls = append(*r.calls, "promote "+snapshotID+" "+channel)
+	return nil
+}
+
+func (r recordingRepoSnapshot) ListSnapshots(_ context.Context) ([]types.SnapshotInfo, error) {
+	return nil, nil
+}
+
+func (r recordingRepoSnapshot) DeleteSnapshot(_ context.Context, _ string) error {
+	return nil
+}
+
+func TestPublish_RegisteredBackend(t *testing.T) {
+	var calls []string
+	var gotOutputDir string
+	backends := DefaultRepoBackends()
+	backends.Register("Memory", func(_ PublishRequest, _ types.SnapshotIntent, outputDir string, _ string) (ports.RepoSnapshotPort, error) {
+		gotOutputDir = outputDir
+		return recordingRepoSnapshot{calls: &calls}, nil
+	})
+	svc := Service{
+		OutputReader: stubOutputReader{
+			intent: types.SnapshotIntent{SnapshotID: "snap-1", Channel: "stable"},
+		},
+		RepoBackends: backends,
+	}
+	result, err := svc.Publish(context.Background(), PublishRequest{
+		OutputDir:   "/tmp/test-publish",
+		RepoBackend: "memory",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "snap-1", result.SnapshotID)
+	assert.Equal(t, "/tmp/test-publish", gotOutputDir)
+	assert.Equal(t, []string{"publish snap-1", "promote snap-1 stable"}, calls)
+
+	_, err = svc.Publish(context.Background(), PublishRequest{
+		OutputDir:   "/tmp/test-publish",
+		RepoBackend: "memory",
+		DryRun:      true,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dry run is only supported")
+	assert.Equal(t, []string{"publish snap-1", "promote snap-1 stable"}, calls)
+}
@@ -0,0 +1,182 @@
+package app
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
+	"avular-packages/internal/adapters"
+	"avular-packages/internal/ports"
+	"avular-packages/internal/types"
+)
+
+const (
+	RepoBackendFile   = "file"
+	RepoBackendAptly  = "aptly"
+	RepoBackendProGet = "proget"
+)
+
+// RepoBackendFactory builds the snapshot adapter Publish pushes the
+// outputs in outputDir to. repoDir is the resolved --repo-dir and only
+// matters to directory-backed stores. Missing settings are reported
+// here, before anything is published.
+type RepoBackendFactory func(req PublishRequest, intent types.SnapshotIntent, outputDir string, repoDir string) (ports.RepoSnapshotPort, error)
+
+// RepoBackendRegistry maps a repo backend name, as given by --repo-backend
+// or publish.repo_backend, to the factory of its adapter.
+type RepoBackendRegistry map[string]RepoBackendFactory
+
+// uploadPlanner is implemented by adapters that can record the uploads
+// of a dry run instead of performing them.
+type uploadPlanner interface {
+	PlannedUploads() []string
+}
+
+// uploadCounter is implemented by adapters that count uploaded and
+// skipped artifacts.
+type uploadCounter interface {
+	UploadStats() adapters.UploadStats
+}
+
+// DefaultRepoBackends returns a registry holding the built-in file,
+// aptly and proget backends.
+func DefaultRepoBackends() RepoBackendRegistry {
+	registry := RepoBackendRegistry{}
+	registry.Register(RepoBackendFile, newFileRepoBackend)
+	registry.Register(RepoBackendAptly, newAptlyRepoBackend)
+	registry.Register(RepoBackendProGet, newProGetRepoBackend)
+	return registry
+}
+
+// Register adds factory under name, replacing any backend already
+// registered with that name. Names are case-insensitive.
+func (r RepoBackendRegistry) Register(name string, factory RepoBackendFactory) {
+	r[normalizeRepoBackend(name)] = factory
+}
+
+// Lookup returns the factory registered under name.
+func (r RepoBackendRegistry) Lookup(name string) (RepoBackendFactory, bool) {
+	factory, ok := r[normalizeRepoBackend(name)]
+	return factory, ok && factory != nil
+}
+
+// Names returns the registered backend names in sorted order.
+func (r RepoBackendRegistry) Names() []string {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func normalizeRepoBackend(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// repoBackends returns the configured registry, falling back to the
+// built-in backends when none is set.
+func (s Service) repoBackends() RepoBackendRegistry {
+	if s.RepoBackends == nil {
+		return DefaultRepoBackends()
+	}
+	return s.RepoBackends
+}
+
+// publishDebsDir returns --debs-dir, defaulting to <output>/debs.
+func publishDebsDir(req PublishRequest, outputDir string) string {
+	if debsDir := strings.TrimSpace(req.DebsDir); debsDir != "" {
+		return debsDir
+	}
+	return filepath.Join(outputDir, "debs")
+}
+
+// newFileRepoBackend creates a file-backed snapshot store in repoDir.
+// With AptLayout set the repo directory also becomes an apt repository
+// whose suite is the channel, signed when a gpg key is configured.
+func newFileRepoBackend(req PublishRequest, intent types.SnapshotIntent, outputDir string, repoDir string) (ports.RepoSnapshotPort, error) {
+	adapter := adapters.NewRepoSnapshotFileAdapter(repoDir)
+	if req.AptLayout {
+		adapter = adapter.WithAptRepository(publishDebsDir(req, outputDir), intent.Channel, "main")
+		if gpgKey := releaseSigningKey(req, intent); gpgKey != "" {
+			adapter = adapter.WithReleaseSigner(adapters.NewReleaseSigner(req.GpgBinary, gpgKey))
+		}
+	}
+	return adapter, nil
+}
+
+// newAptlyRepoBackend creates the Aptly CLI adapter, uploading debs and
+// publishing to a prefix/endpoint with GPG signing. Aptly publishes to
+// the channel as its distribution, so one is required.
+func newAptlyRepoBackend(req PublishRequest, intent types.SnapshotIntent, outputDir string, _ string) (ports.RepoSnapshotPort, error) {
+	repoName := strings.TrimSpace(req.AptlyRepo)
+	if repoName == "" {
+		repoName = intent.Repository
+	}
+	gpgKey := strings.TrimSpace(req.GpgKey)
+	if gpgKey == "" {
+		gpgKey = strings.TrimSpace(intent.SigningKey)
+	}
+	if gpgKey == "" {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("gpg key is required for aptly backend")
+	}
+	if strings.TrimSpace(intent.Channel) == "" {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("distribution is empty for publish")
+	}
+	return adapters.NewRepoSnapshotAptlyAdapter(
+		repoName,
+		intent.Channel,
+		strings.TrimSpace(req.AptlyComponent),
+		publishDebsDir(req, outputDir),
+		strings.TrimSpace(req.AptlyPrefix),
+		strings.TrimSpace(req.AptlyEndpoint),
+		gpgKey,
+	), nil
+}
+
+// newProGetRepoBackend creates the ProGet HTTP API adapter. In a dry run
+// it uploads nothing and records the planned PUTs instead.
+func newProGetRepoBackend(req PublishRequest, intent types.SnapshotIntent, outputDir string, _ string) (ports.RepoSnapshotPort, error) {
+	apiKey := strings.TrimSpace(req.ProGetAPIKey)
+	if apiKey == "" {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("proget api key is required for proget backend")
+	}
+	if err := adapters.ValidateProGetAuthMode(req.ProGetAuthMode); err != nil {
+		return nil, err
+	}
+	feed := strings.TrimSpace(req.ProGetFeed)
+	if feed == "" {
+		feed = intent.Repository
+	}
+	return adapters.NewRepoSnapshotProGetAdapter(adapters.ProGetConfig{
+		Endpoint:             strings.TrimSpace(req.ProGetEndpoint),
+		Feed:                 feed,
+		Component:            strings.TrimSpace(req.ProGetComponent),
+		DebsDir:              publishDebsDir(req, outputDir),
+		Username:             strings.TrimSpace(req.ProGetUser),
+		APIKey:               apiKey,
+		AuthMode:             req.ProGetAuthMode,
+		SnapshotPrefix:       intent.SnapshotPrefix,
+		Workers:              req.ProGetWorkers,
+		TimeoutSec:           req.ProGetTimeoutSec,
+		Retries:              req.ProGetRetries,
+		RetryDelayMs:         req.ProGetRetryDelayMs,
+		UserAgent:            strings.TrimSpace(req.UserAgent),
+		MaxIdleConnsPerHost:  req.ProGetMaxIdleConnsPerHost,
+		IdleConnTimeoutSec:   req.ProGetIdleConnTimeoutSec,
+		DisableHTTP2:         req.ProGetDisableHTTP2,
+		RateLimitBytesPerSec: req.ProGetRateLimitBytesPerSec,
+		Verify:               req.ProGetVerify,
+		SkipExisting:         req.ProGetSkipExisting,
+		DryRun:               req.DryRun,
+		Quiet:                req.Quiet,
+	}), nil
+}
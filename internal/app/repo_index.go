@@ -2,32 +2,54 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
+	"github.com/ZanzyTHEbar/errbuilder-go"
+
 	"avular-packages/internal/ports"
 )
 
 func (s Service) RepoIndex(ctx context.Context, req RepoIndexRequest) (RepoIndexResult, error) {
+	hostCredentials, err := parseHostCredentials(req.HostCredentials)
+	if err != nil {
+		return RepoIndexResult{}, err
+	}
 	buildRequest := ports.RepoIndexBuildRequest{
-		AptSources:       req.AptSources,
-		AptEndpoint:      strings.TrimSpace(req.AptEndpoint),
-		AptDistribution:  strings.TrimSpace(req.AptDistribution),
-		AptComponent:     strings.TrimSpace(req.AptComponent),
-		AptArch:          strings.TrimSpace(req.AptArch),
-		AptUser:          strings.TrimSpace(req.AptUser),
-		AptAPIKey:        strings.TrimSpace(req.AptAPIKey),
-		AptWorkers:       req.AptWorkers,
-		PipIndex:         strings.TrimSpace(req.PipIndex),
-		PipUser:          strings.TrimSpace(req.PipUser),
-		PipAPIKey:        strings.TrimSpace(req.PipAPIKey),
-		PipPackages:      req.PipPackages,
-		PipMax:           req.PipMax,
-		PipWorkers:       req.PipWorkers,
-		HTTPTimeoutSec:   req.HTTPTimeoutSec,
-		HTTPRetries:      req.HTTPRetries,
-		HTTPRetryDelayMs: req.HTTPRetryDelayMs,
-		CacheDir:         strings.TrimSpace(req.CacheDir),
-		CacheTTLMinutes:  req.CacheTTLMinutes,
+		AptSources:              req.AptSources,
+		AptDebDirs:              req.AptDebDirs,
+		AptEndpoint:             strings.TrimSpace(req.AptEndpoint),
+		AptDistribution:         strings.TrimSpace(req.AptDistribution),
+		AptComponent:            strings.TrimSpace(req.AptComponent),
+		AptArch:                 strings.TrimSpace(req.AptArch),
+		AptUser:                 strings.TrimSpace(req.AptUser),
+		AptAPIKey:               strings.TrimSpace(req.AptAPIKey),
+		AptAuthMode:             strings.TrimSpace(req.AptAuthMode),
+		AptWorkers:              req.AptWorkers,
+		PipIndex:                strings.TrimSpace(req.PipIndex),
+		PipUser:                 strings.TrimSpace(req.PipUser),
+		PipAPIKey:               strings.TrimSpace(req.PipAPIKey),
+		PipAuthMode:             strings.TrimSpace(req.PipAuthMode),
+		PipPackages:             req.PipPackages,
+		PipMax:                  req.PipMax,
+		PipWorkers:              req.PipWorkers,
+		PipAllowPrerelease:      req.PipAllowPrerelease,
+		PipMetadata:             req.PipMetadata,
+		HTTPTimeoutSec:          req.HTTPTimeoutSec,
+		HTTPRetries:             req.HTTPRetries,
+		HTTPRetryDelayMs:        req.HTTPRetryDelayMs,
+		HTTPMaxIdleConnsPerHost: req.HTTPMaxIdleConnsPerHost,
+		HTTPIdleConnTimeoutSec:  req.HTTPIdleConnTimeoutSec,
+		HTTPDisableHTTP2:        req.HTTPDisableHTTP2,
+		HTTPAuthRedirectHosts:   req.HTTPAuthRedirectHosts,
+		HTTPProxy:               strings.TrimSpace(req.HTTPProxy),
+		HTTPCABundle:            strings.TrimSpace(req.HTTPCABundle),
+		RateLimitBytesPerSec:    req.RateLimitBytesPerSec,
+		CacheDir:                strings.TrimSpace(req.CacheDir),
+		CacheTTLMinutes:         req.CacheTTLMinutes,
+		UserAgent:               strings.TrimSpace(req.UserAgent),
+		HostCredentials:         hostCredentials,
+		Offline:                 req.Offline,
 	}
 	index, err := s.RepoIndexBuild.Build(ctx, buildRequest)
 	if err != nil {
@@ -42,3 +64,33 @@ func (s Service) RepoIndex(ctx context.Context, req RepoIndexRequest) (RepoIndex
 		PipCount:   len(index.Pip),
 	}, nil
 }
+
+// parseHostCredentials parses host=user:key entries; the user may be
+// omitted (host=key). Host names are matched case-insensitively.
+func parseHostCredentials(values []string) (map[string]ports.HostCredential, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	credentials := make(map[string]ports.HostCredential, len(values))
+	for _, value := range values {
+		host, secret, ok := strings.Cut(value, "=")
+		host = strings.ToLower(strings.TrimSpace(host))
+		user, key, hasUser := strings.Cut(secret, ":")
+		if !hasUser {
+			user, key = "", secret
+		}
+		credential := ports.HostCredential{User: strings.TrimSpace(user), APIKey: strings.TrimSpace(key)}
+		if !ok || host == "" || credential.APIKey == "" {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("invalid host credential for %q (expected host=user:key or host=key)", host))
+		}
+		if _, exists := credentials[host]; exists {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("duplicate host credential for %s", host))
+		}
+		credentials[host] = credential
+	}
+	return credentials, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
@@ -16,10 +18,18 @@ import (
 	"avular-packages/internal/adapters"
 	"avular-packages/internal/core"
 	"avular-packages/internal/policies"
+	"avular-packages/internal/ports"
 	"avular-packages/internal/types"
 )
 
 func (s Service) Resolve(ctx context.Context, req ResolveRequest) (ResolveResult, error) {
+	return s.resolve(ctx, req, true)
+}
+
+// resolve runs a resolve; guardOutput takes the output directory lock
+// for its duration and applies the collision guard, and is false when
+// the caller (Build) already did both.
+func (s Service) resolve(ctx context.Context, req ResolveRequest, guardOutput bool) (ResolveResult, error) {
 	productPath := strings.TrimSpace(req.ProductPath)
 	if productPath == "" {
 		productPath = discoverProduct()
@@ -35,8 +45,8 @@ func (s Service) Resolve(ctx context.Context, req ResolveRequest) (ResolveResult
 		return ResolveResult{}, err
 	}
 
-	// Emit hints about flags that duplicate spec defaults (before applying).
-	emitHints(checkResolveDefaultsHints(req, product.Defaults))
+	// Collect hints about flags that duplicate spec defaults (before applying).
+	warnings := hintWarnings(WarningDefaultsHint, checkResolveDefaultsHints(req, product.Defaults))
 
 	// Apply spec defaults for values not provided by the caller
 	req = applySpecDefaults(req, product.Defaults)
@@ -51,6 +61,16 @@ func (s Service) Resolve(ctx context.Context, req ResolveRequest) (ResolveResult
 	if outputDir == "" {
 		outputDir = "out"
 	}
+	if guardOutput {
+		unlock, err := adapters.LockOutputDir(outputDir)
+		if err != nil {
+			return ResolveResult{}, err
+		}
+		defer unlock()
+		if err := guardOutputDir(outputDir, req.Force, req.Clean); err != nil {
+			return ResolveResult{}, err
+		}
+	}
 	targetUbuntu := strings.TrimSpace(req.TargetUbuntu)
 	if targetUbuntu == "" {
 		return ResolveResult{}, errbuilder.New().
@@ -59,67 +79,81 @@ func (s Service) Resolve(ctx context.Context, req ResolveRequest) (ResolveResult
 	}
 	targetUbuntu = normalizeTargetUbuntu(targetUbuntu)
 
-	profiles, err := s.ProfileSource.LoadProfiles(product, req.Profiles)
+	composed, deps, depWarnings, err := s.collectDependencies(ctx, req, productPath, product, targetUbuntu)
 	if err != nil {
 		return ResolveResult{}, err
 	}
-	composer := core.NewProductComposer()
-	compiler := core.NewSpecCompiler()
-	composed, err := composer.Compose(ctx, product, profiles)
+	warnings = append(warnings, depWarnings...)
+
+	policy := policies.NewPackagingPolicy(composed.Packaging.Groups, targetUbuntu)
+	resolver := core.NewResolverCore(s.repoIndex(repoIndex), policy)
+	resolver.UseAptSolver = req.AptSatSolver
+	resolver.UsePipSolver = req.PipSatSolver
+	resolver.PipEnvironment = core.PipEnvironment{PythonVersion: ubuntuPythonVersions[targetUbuntu]}
+	resolver.BasePackages = req.BasePackages
+	resolver.AssumeEssential = req.AssumeEssential
+	resolver.AllowUnresolved = req.AllowUnresolved
+	resolver.BestEffort = req.BestEffort
+	resolver.AptOnly = req.NoPip
+	resolver.SolverTimeout = req.SolverTimeout
+	resolver.UnsatCoreMaxIterations = req.UnsatCoreMaxIterations
+	constraintPolicy, err := packageXMLConstraintPolicy(req.PackageXMLConstraints)
 	if err != nil {
 		return ResolveResult{}, err
 	}
-	if err := compiler.ValidateSpec(ctx, composed); err != nil {
-		return ResolveResult{}, err
-	}
-
-	// Auto-discover schemas from a schemas/ directory next to the product spec.
-	// These sit between inline schemas (lowest) and explicit schema_files (higher).
-	discoveredSchemas := discoverSchemaFiles(productPath)
-
-	// Build the final schema files list with correct precedence:
-	//   1. Inline schema  (handled by builder, lowest)
-	//   2. Auto-discovered ./schemas/*.yaml
-	//   3. Explicit schema_files from spec
-	//   4. CLI --schema flags (highest)
-	resolveInputs := composed.Inputs
-	if len(discoveredSchemas) > 0 {
-		resolveInputs.PackageXML.SchemaFiles = append(
-			discoveredSchemas,
-			resolveInputs.PackageXML.SchemaFiles...,
-		)
-	}
-	if len(req.SchemaFiles) > 0 {
-		resolveInputs.PackageXML.SchemaFiles = append(
-			resolveInputs.PackageXML.SchemaFiles,
-			req.SchemaFiles...,
-		)
-	}
-
-	// Collect inline schema from the composed spec.  The composer
-	// merges schemas from all profiles and the product (product wins
-	// per key), so this captures the fully-merged inline schema.
-	var inlineSchema *types.SchemaFile
-	if composed.Schema != nil && len(composed.Schema.Mappings) > 0 {
-		inlineSchema = composed.Schema
-	}
-
-	builder := core.NewDependencyBuilder(s.Workspace, s.PackageXML)
-	if s.SchemaResolver != nil {
-		builder = builder.WithSchemaResolver(s.SchemaResolver)
+	resolver.PackageXMLConstraints = constraintPolicy
+	if len(req.ArchRepoIndexes) > 0 {
+		if !req.AptSatSolver {
+			return ResolveResult{}, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg("arch repo indexes require the apt SAT solver (--apt-sat-solver)")
+		}
+		if req.AllowUnresolved || req.BestEffort {
+			return ResolveResult{}, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg("arch repo indexes cannot be combined with --allow-unresolved or --best-effort")
+		}
+		archs, archIndexes, err := s.archRepoIndexes(req.ArchRepoIndexes)
+		if err != nil {
+			return ResolveResult{}, err
+		}
+		resolver.Architectures = archs
+		resolver.ArchRepoIndexes = archIndexes
+	}
+	var previousLock map[string]string
+	if preferLock := strings.TrimSpace(req.PreferLock); preferLock != "" {
+		locks, err := s.OutputReader.ReadAptLock(preferLock)
+		if err != nil {
+			return ResolveResult{}, err
+		}
+		previousLock = lockVersions(locks)
+		resolver.PreferLock = previousLock
+	} else if req.FailOnDowngrade {
+		return ResolveResult{}, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("fail-on-downgrade requires a previous lock (--prefer-lock)")
 	}
-	deps, err := builder.BuildFromSpecsWithSchema(ctx, product, profiles, resolveInputs, req.Workspace, inlineSchema)
+	result, err := resolver.Resolve(ctx, deps, composed.Resolutions)
 	if err != nil {
 		return ResolveResult{}, err
 	}
-
-	policy := policies.NewPackagingPolicy(composed.Packaging.Groups, targetUbuntu)
-	resolver := core.NewResolverCore(adapters.NewRepoIndexFileAdapter(repoIndex), policy)
-	resolver.UseAptSolver = req.AptSatSolver
-	result, err := resolver.Resolve(ctx, deps, composed.Resolutions)
+	if req.FailOnDowngrade {
+		if err := checkDowngrades(core.AptDowngrades(previousLock, result.AptLocks)); err != nil {
+			return ResolveResult{}, err
+		}
+	}
+	if req.ReportUnused {
+		hints, err := checkUnusedDirectives(result.UnusedDirectives, req.StrictDirectives)
+		if err != nil {
+			return ResolveResult{}, err
+		}
+		warnings = append(warnings, hintWarnings(WarningUnusedDirective, hints)...)
+	}
+	hints, err := checkExpiredDirectives(core.ExpiredDirectives(appliedDirectives(result.Resolution), timeNow(s.Clock)), req.StrictDirectives)
 	if err != nil {
 		return ResolveResult{}, err
 	}
+	warnings = append(warnings, hintWarnings(WarningExpiredDirective, hints)...)
 
 	snapshotID := strings.TrimSpace(req.SnapshotID)
 	if snapshotID == "" {
@@ -130,10 +164,21 @@ func (s Service) Resolve(ctx context.Context, req ResolveRequest) (ResolveResult
 	if err := writeResolveOutputs(outputDir, req, result, intent); err != nil {
 		return ResolveResult{}, err
 	}
+	req.TargetUbuntu = targetUbuntu
+	req.OutputDir = outputDir
+	effective, err := buildEffectiveConfig(req, productPath, repoIndex, snapshotID, composed)
+	if err != nil {
+		return ResolveResult{}, err
+	}
+	if err := adapters.NewOutputFileAdapter(outputDir).WriteEffectiveConfig(effective); err != nil {
+		return ResolveResult{}, err
+	}
 	return ResolveResult{
 		ProductName: composed.Metadata.Name,
 		SnapshotID:  snapshotID,
 		OutputDir:   outputDir,
+		Unresolved:  result.Unresolved,
+		Warnings:    warnings,
 	}, nil
 }
 
@@ -169,6 +214,11 @@ func writeResolveOutputs(outputDir string, req ResolveRequest, result core.Resol
 			return err
 		}
 	}
+	if req.EmitResolveJSON {
+		if err := output.WriteResolveSummary(buildResolveSummary(intent.SnapshotID, result)); err != nil {
+			return err
+		}
+	}
 	if req.CompatGet {
 		compat := adapters.NewCompatibilityOutputAdapter(outputDir)
 		if err := compat.WriteGetDependencies(result.ResolvedDeps); err != nil {
@@ -184,6 +234,190 @@ func writeResolveOutputs(outputDir string, req ResolveRequest, result core.Resol
 	return nil
 }
 
+// buildEffectiveConfig records the inputs of a resolve run: req after
+// spec defaults, the repo index with its hash, and the composed spec.
+func buildEffectiveConfig(req ResolveRequest, productPath string, repoIndex string, snapshotID string, composed types.Spec) (types.EffectiveConfig, error) {
+	// A repo index supplied through RepoIndexLoad may have no file behind
+	// its path; it is recorded without a digest.
+	var digest string
+	data, err := os.ReadFile(repoIndex)
+	switch {
+	case err == nil:
+		sum := sha256.Sum256(data)
+		digest = hex.EncodeToString(sum[:])
+	case !errors.Is(err, fs.ErrNotExist):
+		return types.EffectiveConfig{}, errbuilder.New().
+			WithCode(errbuilder.CodeNotFound).
+			WithMsg("failed to read repo index").
+			WithCause(err)
+	}
+	toolVersion := strings.TrimSpace(req.ToolVersion)
+	if toolVersion == "" {
+		toolVersion = "dev"
+	}
+	return types.EffectiveConfig{
+		ToolVersion: toolVersion,
+		ProductPath: productPath,
+		SnapshotID:  snapshotID,
+		RepoIndex: types.EffectiveRepoIndex{
+			Path:   repoIndex,
+			SHA256: digest,
+		},
+		Settings: types.EffectiveResolveSettings{
+			Profiles:        req.Profiles,
+			Workspace:       req.Workspace,
+			TargetUbuntu:    req.TargetUbuntu,
+			OutputDir:       req.OutputDir,
+			SchemaFiles:     req.SchemaFiles,
+			AptSatSolver:    req.AptSatSolver,
+			PipSatSolver:    req.PipSatSolver,
+			PreferLock:      req.PreferLock,
+			FailOnDowngrade: req.FailOnDowngrade,
+			BasePackages:    req.BasePackages,
+			AssumeEssential: req.AssumeEssential,
+			AllowUnresolved: req.AllowUnresolved,
+			BestEffort:      req.BestEffort,
+			NoPip:           req.NoPip,
+			AllowedScopes:   req.AllowedScopes,
+		},
+		Spec: composed,
+	}, nil
+}
+
+// collectDependencies composes the product with its profiles and builds
+// the dependency set to resolve from the manual inputs, package.xml
+// files and schemas, without consulting a repo index. It also returns
+// warnings for schema keys no layer maps.
+func (s Service) collectDependencies(ctx context.Context, req ResolveRequest, productPath string, product types.Spec, targetUbuntu string) (types.Spec, []types.Dependency, []Warning, error) {
+	profiles, err := s.ProfileSource.LoadProfiles(product, req.Profiles)
+	if err != nil {
+		return types.Spec{}, nil, nil, err
+	}
+	composer := core.NewProductComposer()
+	compiler := core.NewSpecCompiler()
+	composed, err := composer.Compose(ctx, product, profiles)
+	if err != nil {
+		return types.Spec{}, nil, nil, err
+	}
+	if err := compiler.ValidateSpec(ctx, composed); err != nil {
+		return types.Spec{}, nil, nil, err
+	}
+	if err := core.ValidateAllowedScopes(composed.Packaging.Groups, req.AllowedScopes); err != nil {
+		return types.Spec{}, nil, nil, err
+	}
+
+	// Auto-discover schemas from a schemas/ directory next to the product spec.
+	// These sit between inline schemas (lowest) and explicit schema_files (higher).
+	discoveredSchemas := discoverSchemaFiles(productPath)
+
+	// Build the final schema files list with correct precedence:
+	//   1. Inline schema  (handled by builder, lowest)
+	//   2. Auto-discovered ./schemas/*.yaml
+	//   3. Explicit schema_files from spec
+	//   4. CLI --schema flags (highest)
+	resolveInputs := composed.Inputs
+	if len(discoveredSchemas) > 0 {
+		resolveInputs.PackageXML.SchemaFiles = append(
+			discoveredSchemas,
+			resolveInputs.PackageXML.SchemaFiles...,
+		)
+	}
+	if len(req.SchemaFiles) > 0 {
+		resolveInputs.PackageXML.SchemaFiles = append(
+			resolveInputs.PackageXML.SchemaFiles,
+			req.SchemaFiles...,
+		)
+	}
+
+	// Collect inline schema from the composed spec.  The composer
+	// merges schemas from all profiles and the product (product wins
+	// per key), so this captures the fully-merged inline schema.
+	var inlineSchema *types.SchemaFile
+	if composed.Schema != nil && len(composed.Schema.Mappings) > 0 {
+		inlineSchema = composed.Schema
+	}
+
+	var unknownKeys []string
+	builder := core.NewDependencyBuilder(s.Workspace, s.PackageXML).
+		WithUnknownKeysHandler(func(keys []string) { unknownKeys = append(unknownKeys, keys...) }).
+		WithTarget(targetUbuntu).
+		WithBuildDeps(req.IncludeBuildDeps)
+	if s.SchemaResolver != nil {
+		builder = builder.WithSchemaResolver(s.SchemaResolver)
+	}
+	deps, err := builder.BuildFromSpecsWithSchema(ctx, product, profiles, resolveInputs, req.Workspace, inlineSchema)
+	if err != nil {
+		return types.Spec{}, nil, nil, err
+	}
+	return composed, deps, unknownSchemaKeyWarnings(unknownKeys), nil
+}
+
+// buildResolveSummary collects the resolver artifacts into the
+// resolve.json document. Slices are never nil so consumers always see
+// arrays.
+func buildResolveSummary(snapshotID string, result core.ResolveResult) types.ResolveSummary {
+	summary := types.ResolveSummary{
+		SchemaVersion: types.ResolveSummarySchemaVersion,
+		SnapshotID:    snapshotID,
+		AptLocks:      []types.ResolveSummaryLock{},
+		Dependencies:  []types.ResolveSummaryDependency{},
+		Resolutions:   []types.ResolveSummaryRecord{},
+	}
+	for _, entry := range result.AptLocks {
+		summary.AptLocks = append(summary.AptLocks, types.ResolveSummaryLock(entry))
+	}
+	for _, dep := range result.ResolvedDeps {
+		summary.Dependencies = append(summary.Dependencies, types.ResolveSummaryDependency{
+			Type:    string(dep.Type),
+			Package: dep.Package,
+			Version: dep.Version,
+		})
+	}
+	for _, record := range result.Resolution.Records {
+		summary.Resolutions = append(summary.Resolutions, types.ResolveSummaryRecord(record))
+	}
+	return summary
+}
+
+// appliedDirectives returns the directives recorded in report, once per
+// dependency.
+func appliedDirectives(report types.ResolutionReport) []types.ResolutionDirective {
+	var directives []types.ResolutionDirective
+	seen := map[string]struct{}{}
+	for _, record := range report.Records {
+		if _, ok := seen[record.Dependency]; ok {
+			continue
+		}
+		seen[record.Dependency] = struct{}{}
+		directives = append(directives, types.ResolutionDirective(record))
+	}
+	return directives
+}
+
+// checkDowngrades fails with every package the resolution moved to a
+// lower version than the previous lock.
+func checkDowngrades(downgrades []core.VersionDowngrade) error {
+	if len(downgrades) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(downgrades))
+	for _, downgrade := range downgrades {
+		names = append(names, fmt.Sprintf("%s %s -> %s", downgrade.Package, downgrade.Previous, downgrade.Current))
+	}
+	return errbuilder.New().
+		WithCode(errbuilder.CodeFailedPrecondition).
+		WithMsg("resolution downgrades locked packages: " + strings.Join(names, ", "))
+}
+
+// lockVersions converts apt.lock entries into a package -> version map.
+func lockVersions(locks []types.AptLockEntry) map[string]string {
+	out := make(map[string]string, len(locks))
+	for _, entry := range locks {
+		out[entry.Package] = entry.Version
+	}
+	return out
+}
+
 // applySpecDefaults fills in ResolveRequest fields from the product
 // spec's defaults section when the request field is empty.
 func applySpecDefaults(req ResolveRequest, defaults types.SpecDefaults) ResolveRequest {
@@ -303,3 +537,43 @@ func normalizeTargetUbuntu(value string) string {
 	}
 	return normalized
 }
+
+// archRepoIndexes parses arch=path values into the solved architectures,
+// in the given order, and the repo index of each.
+func (s Service) archRepoIndexes(values []string) ([]string, map[string]ports.RepoIndexPort, error) {
+	var archs []string
+	indexes := map[string]ports.RepoIndexPort{}
+	for _, value := range values {
+		arch, path, ok := strings.Cut(value, "=")
+		arch = strings.TrimSpace(arch)
+		path = strings.TrimSpace(path)
+		if !ok || arch == "" || path == "" {
+			return nil, nil, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("invalid arch repo index %q (expected arch=path)", value))
+		}
+		if _, exists := indexes[arch]; exists {
+			return nil, nil, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("duplicate arch repo index for %s", arch))
+		}
+		archs = append(archs, arch)
+		indexes[arch] = s.repoIndex(path)
+	}
+	return archs, indexes, nil
+}
+
+// packageXMLConstraintPolicy parses the package.xml constraint policy of
+// a resolve request; an empty value means hard.
+func packageXMLConstraintPolicy(value string) (types.PackageXMLConstraintPolicy, error) {
+	switch policy := types.PackageXMLConstraintPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case "", types.PackageXMLConstraintsHard:
+		return types.PackageXMLConstraintsHard, nil
+	case types.PackageXMLConstraintsSoft:
+		return policy, nil
+	default:
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("unsupported package.xml constraint policy: %s (expected hard or soft)", value))
+	}
+}
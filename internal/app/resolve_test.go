@@ -0,0 +1,477 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"avular-packages/internal/adapters"
+	"avular-packages/internal/core"
+	"avular-packages/internal/types"
+)
+
+func TestResolveWritesJSONSummary(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	outDir := t.TempDir()
+
+	service := NewService()
+	result, err := service.Resolve(t.Context(), ResolveRequest{
+		ProductPath:     filepath.Join(root, "fixtures", "product-sample.yaml"),
+		Profiles:        []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:       []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:       filepath.Join(root, "fixtures", "repo-index.yaml"),
+		OutputDir:       outDir,
+		TargetUbuntu:    "24.04",
+		EmitResolveJSON: true,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outDir, "resolve.json"))
+	require.NoError(t, err)
+	var summary types.ResolveSummary
+	require.NoError(t, json.Unmarshal(data, &summary))
+	require.Equal(t, types.ResolveSummarySchemaVersion, summary.SchemaVersion)
+	require.Equal(t, result.SnapshotID, summary.SnapshotID)
+	require.NotEmpty(t, summary.AptLocks)
+	require.NotEmpty(t, summary.Dependencies)
+}
+
+func TestBuildResolveSummary(t *testing.T) {
+	result := core.ResolveResult{
+		AptLocks: []types.AptLockEntry{{Package: "libfoo", Version: "1.0"}},
+		ResolvedDeps: []types.ResolvedDependency{
+			{Type: types.DependencyTypePip, Package: "requests", Version: "2.31.0"},
+		},
+		Resolution: types.ResolutionReport{Records: []types.ResolutionRecord{
+			{Dependency: "apt:libfoo", Action: "force", Value: "1.0", Reason: "cve", Owner: "platform"},
+		}},
+	}
+
+	got := buildResolveSummary("snap-1", result)
+
+	want := types.ResolveSummary{
+		SchemaVersion: types.ResolveSummarySchemaVersion,
+		SnapshotID:    "snap-1",
+		AptLocks:      []types.ResolveSummaryLock{{Package: "libfoo", Version: "1.0"}},
+		Dependencies: []types.ResolveSummaryDependency{
+			{Type: "pip", Package: "requests", Version: "2.31.0"},
+		},
+		Resolutions: []types.ResolveSummaryRecord{
+			{Dependency: "apt:libfoo", Action: "force", Value: "1.0", Reason: "cve", Owner: "platform"},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected resolve summary (-want +got):\n%s", diff)
+	}
+
+	empty, err := json.Marshal(buildResolveSummary("snap-2", core.ResolveResult{}))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"schema_version":1,"snapshot_id":"snap-2","apt_locks":[],"dependencies":[],"resolutions":[]}`, string(empty))
+}
+
+func TestResolveWritesEffectiveConfig(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	outDir := t.TempDir()
+	repoIndex := filepath.Join(root, "fixtures", "repo-index.yaml")
+
+	service := NewService()
+	result, err := service.Resolve(t.Context(), ResolveRequest{
+		ProductPath:  filepath.Join(root, "fixtures", "product-sample.yaml"),
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:    repoIndex,
+		OutputDir:    outDir,
+		TargetUbuntu: "ubuntu-24.04",
+		ToolVersion:  "1.2.3",
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outDir, "effective-config.yaml"))
+	require.NoError(t, err)
+	var config types.EffectiveConfig
+	require.NoError(t, yaml.Unmarshal(data, &config))
+
+	indexData, err := os.ReadFile(repoIndex)
+	require.NoError(t, err)
+	sum := sha256.Sum256(indexData)
+	require.Equal(t, hex.EncodeToString(sum[:]), config.RepoIndex.SHA256)
+	require.Equal(t, repoIndex, config.RepoIndex.Path)
+	require.Equal(t, "1.2.3", config.ToolVersion)
+	require.Equal(t, result.SnapshotID, config.SnapshotID)
+	require.Equal(t, "24.04", config.Settings.TargetUbuntu)
+
+	var groups []string
+	for _, group := range config.Spec.Packaging.Groups {
+		groups = append(groups, group.Name)
+	}
+	require.Subset(t, groups, []string{"apt-individual", "pip-meta"})
+}
+
+func TestResolveReturnsWarnings(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(root, "fixtures", "product-sample.yaml"))
+	require.NoError(t, err)
+	productPath := filepath.Join(t.TempDir(), "product.yaml")
+	data = append(data, []byte("defaults:\n  target_ubuntu: \"24.04\"\n")...)
+	require.NoError(t, os.WriteFile(productPath, data, 0644))
+
+	service := NewService()
+	result, err := service.Resolve(t.Context(), ResolveRequest{
+		ProductPath:  productPath,
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:    filepath.Join(root, "fixtures", "repo-index.yaml"),
+		OutputDir:    t.TempDir(),
+		TargetUbuntu: "24.04",
+	})
+	require.NoError(t, err)
+
+	want := []Warning{{
+		Code:    WarningDefaultsHint,
+		Message: "hint: --target-ubuntu is also set in product spec (defaults.target_ubuntu); you can omit the flag",
+	}}
+	if diff := cmp.Diff(want, result.Warnings); diff != "" {
+		t.Fatalf("unexpected warnings (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolveFailOnDowngrade(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	req := ResolveRequest{
+		ProductPath:  filepath.Join(root, "fixtures", "product-sample.yaml"),
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:    filepath.Join(root, "fixtures", "repo-index.yaml"),
+		OutputDir:    t.TempDir(),
+		TargetUbuntu: "24.04",
+	}
+	service := NewService()
+	_, err = service.Resolve(t.Context(), req)
+	require.NoError(t, err)
+	locks, err := service.OutputReader.ReadAptLock(filepath.Join(req.OutputDir, "apt.lock"))
+	require.NoError(t, err)
+	require.NotEmpty(t, locks)
+
+	// Pin the first package higher than anything the index offers so the
+	// new resolution is a downgrade.
+	previous := []string{locks[0].Package + "=99.0"}
+	for _, entry := range locks[1:] {
+		previous = append(previous, entry.Package+"="+entry.Version)
+	}
+	lockPath := filepath.Join(t.TempDir(), "apt.lock")
+	require.NoError(t, os.WriteFile(lockPath, []byte(strings.Join(previous, "\n")), 0644))
+
+	req.OutputDir = t.TempDir()
+	req.PreferLock = lockPath
+	req.FailOnDowngrade = true
+	_, err = service.Resolve(t.Context(), req)
+	require.ErrorContains(t, err, "resolution downgrades locked packages: "+locks[0].Package+" 99.0 -> "+locks[0].Version)
+
+	req.PreferLock = ""
+	_, err = service.Resolve(t.Context(), req)
+	require.ErrorContains(t, err, "fail-on-downgrade requires a previous lock")
+}
+
+func TestResolveRejectsLockedOutputDir(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	outDir := t.TempDir()
+	req := ResolveRequest{
+		ProductPath:  filepath.Join(root, "fixtures", "product-sample.yaml"),
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:    filepath.Join(root, "fixtures", "repo-index.yaml"),
+		OutputDir:    outDir,
+		TargetUbuntu: "24.04",
+	}
+
+	unlock, err := adapters.LockOutputDir(outDir)
+	require.NoError(t, err)
+	service := NewService()
+	_, err = service.Resolve(t.Context(), req)
+	require.ErrorContains(t, err, "output directory "+outDir+" is in use by another run")
+	require.Equal(t, errbuilder.CodeFailedPrecondition, errbuilder.CodeOf(err))
+	require.NoFileExists(t, filepath.Join(outDir, "apt.lock"))
+
+	unlock()
+	_, err = service.Resolve(t.Context(), req)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(outDir, "apt.lock"))
+}
+
+func TestResolveGuardsNonEmptyOutputDir(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	outDir := t.TempDir()
+	stale := filepath.Join(outDir, "stale.txt")
+	require.NoError(t, os.WriteFile(stale, []byte("old run"), 0644))
+	req := ResolveRequest{
+		ProductPath:  filepath.Join(root, "fixtures", "product-sample.yaml"),
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:    filepath.Join(root, "fixtures", "repo-index.yaml"),
+		OutputDir:    outDir,
+		TargetUbuntu: "24.04",
+	}
+	service := NewService()
+
+	_, err = service.Resolve(t.Context(), req)
+	require.ErrorContains(t, err, "output directory "+outDir+" is not empty")
+	require.Equal(t, errbuilder.CodeAlreadyExists, errbuilder.CodeOf(err))
+	require.NoFileExists(t, filepath.Join(outDir, "apt.lock"))
+
+	req.Force = true
+	_, err = service.Resolve(t.Context(), req)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(outDir, "apt.lock"))
+	require.FileExists(t, stale)
+
+	failures := filepath.Join(outDir, "build-failures.json")
+	require.NoError(t, os.WriteFile(failures, []byte("[]"), 0644))
+	req.Force = false
+	req.Clean = true
+	_, err = service.Resolve(t.Context(), req)
+	require.ErrorContains(t, err, "contains files that were not generated: stale.txt")
+	require.Equal(t, errbuilder.CodeAlreadyExists, errbuilder.CodeOf(err))
+	require.FileExists(t, failures)
+
+	req.Force = true
+	_, err = service.Resolve(t.Context(), req)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(outDir, "apt.lock"))
+	require.NoFileExists(t, failures)
+	require.FileExists(t, stale)
+
+	require.NoError(t, os.Remove(stale))
+	require.NoError(t, os.WriteFile(failures, []byte("[]"), 0644))
+	req.Force = false
+	_, err = service.Resolve(t.Context(), req)
+	require.NoError(t, err)
+	require.FileExists(t, filepath.Join(outDir, "apt.lock"))
+	require.NoFileExists(t, failures)
+}
+
+func TestResolveRejectsArchRepoIndexesWithBestEffort(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	repoIndex := filepath.Join(root, "fixtures", "repo-index.yaml")
+	req := ResolveRequest{
+		ProductPath:     filepath.Join(root, "fixtures", "product-sample.yaml"),
+		Profiles:        []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:       []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:       repoIndex,
+		OutputDir:       t.TempDir(),
+		TargetUbuntu:    "24.04",
+		AptSatSolver:    true,
+		ArchRepoIndexes: []string{"amd64=" + repoIndex, "arm64=" + repoIndex},
+	}
+	for _, mutate := range []func(*ResolveRequest){
+		func(req *ResolveRequest) { req.AllowUnresolved = true },
+		func(req *ResolveRequest) { req.BestEffort = true },
+	} {
+		req := req
+		mutate(&req)
+		_, err := NewService().Resolve(t.Context(), req)
+		require.ErrorContains(t, err, "arch repo indexes cannot be combined with --allow-unresolved or --best-effort")
+		require.Equal(t, errbuilder.CodeInvalidArgument, errbuilder.CodeOf(err))
+	}
+}
+
+func TestDumpDependenciesCollectsManualAndPackageXMLDeps(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(root, "fixtures", "product-sample.yaml"))
+	require.NoError(t, err)
+	product := strings.Replace(string(data), "inputs:\n", "inputs:\n  manual:\n    apt:\n      - \"libextra>=2.0\"\n", 1)
+	productPath := filepath.Join(t.TempDir(), "product.yaml")
+	require.NoError(t, os.WriteFile(productPath, []byte(product), 0644))
+
+	result, err := NewService().DumpDependencies(t.Context(), ResolveRequest{
+		ProductPath:  productPath,
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		TargetUbuntu: "24.04",
+	})
+	require.NoError(t, err)
+
+	entries := map[string]types.DependencyDumpEntry{}
+	for _, entry := range result.Dump.Dependencies {
+		entries[entry.Type+":"+entry.Name] = entry
+	}
+	require.Contains(t, entries, "apt:libfoo")
+	require.Contains(t, entries, "apt:libbar")
+	require.Contains(t, entries, "pip:requests")
+	require.Contains(t, entries, "apt:libextra")
+	want := []types.DependencyDumpConstraint{{Op: ">=", Version: "2.0", Source: "product:manual:apt"}}
+	if diff := cmp.Diff(want, entries["apt:libextra"].Constraints); diff != "" {
+		t.Fatalf("unexpected libextra constraints (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"package_xml:debian_depend"}, entries["apt:libfoo"].Sources); diff != "" {
+		t.Fatalf("unexpected libfoo sources (-want +got):\n%s", diff)
+	}
+}
+
+func TestCheckLockReportsDrift(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	outDir := t.TempDir()
+	req := ResolveRequest{
+		ProductPath:  filepath.Join(root, "fixtures", "product-sample.yaml"),
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:    filepath.Join(root, "fixtures", "repo-index.yaml"),
+		OutputDir:    outDir,
+		TargetUbuntu: "24.04",
+	}
+	service := NewService()
+	_, err = service.Resolve(t.Context(), req)
+	require.NoError(t, err)
+	lockPath := filepath.Join(outDir, "apt.lock")
+	committed, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(committed)), "\n")
+	require.GreaterOrEqual(t, len(lines), 2)
+
+	// Reordering entries is not drift.
+	reordered := append([]string{lines[len(lines)-1]}, lines[:len(lines)-1]...)
+	require.NoError(t, os.WriteFile(lockPath, []byte(strings.Join(reordered, "\n")+"\n"), 0644))
+	result, err := service.CheckLock(t.Context(), LockCheckRequest{Resolve: req})
+	require.NoError(t, err)
+	require.Empty(t, result.Diff)
+	require.False(t, result.Updated)
+
+	name, version, ok := strings.Cut(lines[0], "=")
+	require.True(t, ok)
+	drifted := append([]string{name + "=0.0.1", "stale=1.0"}, lines[1:]...)
+	require.NoError(t, os.WriteFile(lockPath, []byte(strings.Join(drifted, "\n")), 0644))
+	result, err = service.CheckLock(t.Context(), LockCheckRequest{Resolve: req})
+	require.NoError(t, err)
+	want := []string{"-" + name + "=0.0.1", "+" + name + "=" + version, "-stale=1.0"}
+	if diff := cmp.Diff(want, result.Diff); diff != "" {
+		t.Fatalf("unexpected lock drift (-want +got):\n%s", diff)
+	}
+	require.False(t, result.Updated)
+	data, err := os.ReadFile(lockPath)
+	require.NoError(t, err)
+	require.Equal(t, strings.Join(drifted, "\n"), string(data), "check must not rewrite the lock")
+
+	result, err = service.CheckLock(t.Context(), LockCheckRequest{Resolve: req, Update: true})
+	require.NoError(t, err)
+	require.True(t, result.Updated)
+	data, err = os.ReadFile(lockPath)
+	require.NoError(t, err)
+	require.Equal(t, string(committed), string(data))
+}
+
+func TestCheckLockUsesProductOutputDefault(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	outDir := t.TempDir()
+	data, err := os.ReadFile(filepath.Join(root, "fixtures", "product-sample.yaml"))
+	require.NoError(t, err)
+	productPath := filepath.Join(t.TempDir(), "product.yaml")
+	data = append(data, []byte("defaults:\n  output: \""+outDir+"\"\n")...)
+	require.NoError(t, os.WriteFile(productPath, data, 0644))
+	req := ResolveRequest{
+		ProductPath:  productPath,
+		Profiles:     []string{filepath.Join(root, "fixtures", "profile-base.yaml")},
+		Workspace:    []string{filepath.Join(root, "fixtures", "workspace")},
+		RepoIndex:    filepath.Join(root, "fixtures", "repo-index.yaml"),
+		TargetUbuntu: "24.04",
+	}
+	service := NewService()
+	_, err = service.Resolve(t.Context(), req)
+	require.NoError(t, err)
+
+	result, err := service.CheckLock(t.Context(), LockCheckRequest{Resolve: req})
+	require.NoError(t, err)
+	require.Equal(t, filepath.Join(outDir, "apt.lock"), result.LockPath)
+	require.Empty(t, result.Diff)
+}
+
+func TestResolveUnsatCoreMaxIterationsReachesSolver(t *testing.T) {
+	dir := t.TempDir()
+	productPath := filepath.Join(dir, "product.yaml")
+	require.NoError(t, os.WriteFile(productPath, []byte(`api_version: "v1"
+kind: "product"
+metadata:
+  name: "unsat-product"
+  version: "2026.02.09"
+  owners: ["platform"]
+compose:
+  - name: "default-profile"
+    source: "inline"
+    profile:
+      packaging:
+        groups:
+          - name: "apt-individual"
+            mode: "individual"
+            scope: "runtime"
+            matches: ["apt:*"]
+            targets: ["24.04"]
+inputs:
+  package_xml:
+    enabled: false
+  manual:
+    apt:
+      - "app"
+      - "libfoo=1.0"
+publish:
+  repository:
+    name: "avular"
+    channel: "dev"
+    snapshot_prefix: "unsat"
+    signing_key: "avular-release"
+`), 0644))
+	repoIndex := filepath.Join(dir, "repo-index.yaml")
+	require.NoError(t, os.WriteFile(repoIndex, []byte(`apt:
+  app: ["1.0"]
+  libfoo: ["1.0", "2.0"]
+apt_packages:
+  app:
+    - version: "1.0"
+      depends: ["libfoo (>= 2.0)"]
+  libfoo:
+    - version: "1.0"
+    - version: "2.0"
+pip: {}
+`), 0644))
+
+	tests := []struct {
+		name          string
+		maxIterations int
+		explained     bool
+	}{
+		{name: "default", explained: true},
+		{name: "disabled", maxIterations: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewService().Resolve(t.Context(), ResolveRequest{
+				ProductPath:            productPath,
+				RepoIndex:              repoIndex,
+				OutputDir:              t.TempDir(),
+				TargetUbuntu:           "24.04",
+				AptSatSolver:           true,
+				UnsatCoreMaxIterations: tt.maxIterations,
+			})
+			require.ErrorContains(t, err, "apt solver found no satisfiable solution")
+			if diff := cmp.Diff(tt.explained, strings.Contains(err.Error(), "conflict likely involves")); diff != "" {
+				t.Fatalf("unexpected unsat core explanation (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
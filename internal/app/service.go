@@ -18,6 +18,9 @@ type Service struct {
 	RepoIndexBuild  ports.RepoIndexBuilderPort
 	RepoIndexWriter ports.RepoIndexWriterPort
 	InternalDebs    ports.InternalDebsPort
+	RepoIndexLoad   func(path string) ports.RepoIndexPort
+	RepoBackends    RepoBackendRegistry
+	Environment     ports.EnvironmentProbePort
 	Clock           func() time.Time
 }
 
@@ -34,6 +37,24 @@ func NewService() Service {
 		RepoIndexBuild:  adapters.NewRepoIndexBuilderAdapter(),
 		RepoIndexWriter: adapters.NewRepoIndexWriterAdapter(),
 		InternalDebs:    adapters.NewInternalDebsAdapter(),
+		RepoIndexLoad:   openRepoIndexFile,
+		RepoBackends:    DefaultRepoBackends(),
+		Environment:     adapters.NewEnvironmentProbeAdapter(),
 		Clock:           time.Now,
 	}
 }
+
+// openRepoIndexFile is the default RepoIndexLoad, reading the repo index
+// YAML file at path.
+func openRepoIndexFile(path string) ports.RepoIndexPort {
+	return adapters.NewRepoIndexFileAdapter(path)
+}
+
+// repoIndex returns the repo index at path through RepoIndexLoad, falling
+// back to the file adapter when none is configured.
+func (s Service) repoIndex(path string) ports.RepoIndexPort {
+	if s.RepoIndexLoad == nil {
+		return openRepoIndexFile(path)
+	}
+	return s.RepoIndexLoad(path)
+}
@@ -1,16 +1,40 @@
 package app
 
-import "avular-packages/internal/types"
+import (
+	"time"
+
+	"avular-packages/internal/types"
+)
 
 type ValidateRequest struct {
-	ProductPath string
-	Profiles    []string
+	ProductPath      string
+	Profiles         []string
+	StrictDirectives bool
+	AllowedScopes    []string
 }
 
 type ValidateResult struct {
 	ProductName string
+	Warnings    []Warning
+}
+
+// Warning is a non-fatal finding of a Service call. Code identifies the
+// kind of warning for programmatic handling; Message is the text the CLI
+// prints.
+type Warning struct {
+	Code    string
+	Message string
 }
 
+const (
+	WarningDefaultsHint     = "defaults_hint"
+	WarningUnusedDirective  = "unused_directive"
+	WarningExpiredDirective = "expired_directive"
+	WarningUnknownSchemaKey = "unknown_schema_key"
+	WarningUnsignedRelease  = "unsigned_release"
+	WarningUnsatisfiable    = "unsatisfiable"
+)
+
 type ResolveRequest struct {
 	ProductPath          string
 	Profiles             []string
@@ -29,12 +53,47 @@ type ResolveRequest struct {
 	SnapshotAptComponent string
 	SnapshotAptArchs     []string
 	AptSatSolver         bool
+	PipSatSolver         bool
+	PreferLock           string
+	FailOnDowngrade      bool
+	BasePackages         []string
+	AssumeEssential      bool
+	AllowUnresolved      bool
+	BestEffort           bool
+	NoPip                bool
+	EmitResolveJSON      bool
+	ReportUnused         bool
+	StrictDirectives     bool
+	AllowedScopes        []string
+	ToolVersion          string
+	// ArchRepoIndexes lists arch=path repo indexes solved together by
+	// the apt SAT solver, e.g. amd64=index-amd64.yaml.
+	ArchRepoIndexes []string
+	// SolverTimeout bounds each apt SAT solve (0 = no limit).
+	SolverTimeout time.Duration
+	// UnsatCoreMaxIterations bounds the SAT calls spent explaining an
+	// unsatisfiable problem (0 = default, negative = disabled).
+	UnsatCoreMaxIterations int
+	// PackageXMLConstraints is "hard" (default) to enforce package.xml
+	// version constraints or "soft" to keep them as minimum hints.
+	PackageXMLConstraints string
+	// IncludeBuildDeps also resolves build_depend and test_depend ROS
+	// tags, for dev and CI images.
+	IncludeBuildDeps bool
+	// Force writes into a non-empty output directory; Clean empties it
+	// first.
+	Force bool
+	Clean bool
 }
 
 type ResolveResult struct {
 	ProductName string
 	SnapshotID  string
 	OutputDir   string
+	// Unresolved lists the dependencies left out when AllowUnresolved or
+	// BestEffort was requested, with the reason each one failed.
+	Unresolved []types.UnresolvedDependency
+	Warnings   []Warning
 }
 
 type BuildRequest struct {
@@ -56,36 +115,90 @@ type BuildRequest struct {
 	SnapshotAptComponent string
 	SnapshotAptArchs     []string
 	AptSatSolver         bool
+	PipSatSolver         bool
+	BasePackages         []string
+	AssumeEssential      bool
+	SolverTimeout        time.Duration
+	AllowedScopes        []string
+	ToolVersion          string
+	BuildWorkers         int
+	DebCompression       string
+	DebCompressionLevel  int
+	BuildLogs            bool
+	TempDir              string
+	Transactional        bool
+	Force                bool
+	Clean                bool
+	// TargetArch is the Debian architecture of debs that contain
+	// platform wheels (empty = host architecture).
+	TargetArch string
+	// PythonVersion is the python3 minor version the debs install into,
+	// e.g. 3.12; it defaults to the python of TargetUbuntu.
+	PythonVersion string
+	// CacheDir and CacheTTLMinutes configure the pip resolve cache.
+	CacheDir        string
+	CacheTTLMinutes int
+	// IncludeBuildDeps also resolves build_depend and test_depend ROS
+	// tags, for dev and CI images.
+	IncludeBuildDeps bool
+	// Offline installs pip packages only from a local PipIndexURL and
+	// fails instead of reaching a remote index.
+	Offline bool
+	// ArchRepoIndexes lists arch=path repo indexes solved together by
+	// the apt SAT solver in the resolve phase.
+	ArchRepoIndexes []string
+	// UnsatCoreMaxIterations bounds the SAT calls spent explaining an
+	// unsatisfiable problem (0 = default, negative = disabled).
+	UnsatCoreMaxIterations int
 }
 
 type BuildResult struct {
-	DebsDir string
+	DebsDir  string
+	Warnings []Warning
 }
 
 type PublishRequest struct {
-	OutputDir          string
-	RepoDir            string
-	SBOM               bool
-	RepoBackend        string
-	DebsDir            string
-	AptlyRepo          string
-	AptlyComponent     string
-	AptlyPrefix        string
-	AptlyEndpoint      string
-	GpgKey             string
-	ProGetEndpoint     string
-	ProGetFeed         string
-	ProGetComponent    string
-	ProGetUser         string
-	ProGetAPIKey       string
-	ProGetWorkers      int
-	ProGetTimeoutSec   int
-	ProGetRetries      int
-	ProGetRetryDelayMs int
+	OutputDir                  string
+	RepoDir                    string
+	SBOM                       bool
+	RepoBackend                string
+	AptLayout                  bool
+	DebsDir                    string
+	AptlyRepo                  string
+	AptlyComponent             string
+	AptlyPrefix                string
+	AptlyEndpoint              string
+	GpgKey                     string
+	GpgBinary                  string
+	ProGetEndpoint             string
+	ProGetFeed                 string
+	ProGetComponent            string
+	ProGetUser                 string
+	ProGetAPIKey               string
+	ProGetAuthMode             string
+	ProGetWorkers              int
+	ProGetTimeoutSec           int
+	ProGetRetries              int
+	ProGetRetryDelayMs         int
+	ProGetMaxIdleConnsPerHost  int
+	ProGetIdleConnTimeoutSec   int
+	ProGetDisableHTTP2         bool
+	ProGetRateLimitBytesPerSec int
+	ProGetVerify               bool
+	ProGetSkipExisting         bool
+	DryRun                     bool
+	Quiet                      bool
+	UserAgent                  string
 }
 
 type PublishResult struct {
 	SnapshotID string
+	// Uploaded and Skipped count deb uploads for backends that track them.
+	Uploaded int
+	Skipped  int
+	// PlannedUploads lists the "PUT <url> <deb>" lines of a dry run.
+	PlannedUploads []string
+	Warnings       []Warning
 }
 
 type PruneRequest struct {
@@ -93,47 +206,73 @@ type PruneRequest struct {
 	RepoDir            string
 	KeepLast           int
 	KeepDays           int
+	KeepWithin         time.Duration
 	ProtectChannels    []string
 	ProtectPrefixes    []string
 	DryRun             bool
+	Force              bool
 	ProGetEndpoint     string
 	ProGetFeed         string
 	ProGetComponent    string
 	ProGetUser         string
 	ProGetAPIKey       string
+	ProGetAuthMode     string
 	ProGetTimeoutSec   int
 	ProGetRetries      int
 	ProGetRetryDelayMs int
+	UserAgent          string
 }
 
+// PruneResult summarizes a prune for auditing. Deleted lists the
+// snapshots removed or, in a dry run, the ones that would be.
 type PruneResult struct {
 	KeepCount   int
 	DeleteCount int
+	Kept        []string
 	Deleted     []string
 	DryRun      bool
 }
 
 type RepoIndexRequest struct {
-	Output           string
-	AptSources       []string
-	AptEndpoint      string
-	AptDistribution  string
-	AptComponent     string
-	AptArch          string
-	AptUser          string
-	AptAPIKey        string
-	AptWorkers       int
-	PipIndex         string
-	PipUser          string
-	PipAPIKey        string
-	PipPackages      []string
-	PipMax           int
-	PipWorkers       int
-	HTTPTimeoutSec   int
-	HTTPRetries      int
-	HTTPRetryDelayMs int
-	CacheDir         string
-	CacheTTLMinutes  int
+	Output                  string
+	AptSources              []string
+	AptDebDirs              []string
+	AptEndpoint             string
+	AptDistribution         string
+	AptComponent            string
+	AptArch                 string
+	AptUser                 string
+	AptAPIKey               string
+	AptAuthMode             string
+	AptWorkers              int
+	PipIndex                string
+	PipUser                 string
+	PipAPIKey               string
+	PipAuthMode             string
+	PipPackages             []string
+	PipMax                  int
+	PipWorkers              int
+	PipAllowPrerelease      bool
+	PipMetadata             bool
+	HTTPTimeoutSec          int
+	HTTPRetries             int
+	HTTPRetryDelayMs        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeoutSec  int
+	HTTPDisableHTTP2        bool
+	HTTPAuthRedirectHosts   []string
+	HTTPProxy               string
+	HTTPCABundle            string
+	RateLimitBytesPerSec    int
+	CacheDir                string
+	CacheTTLMinutes         int
+	UserAgent               string
+	// HostCredentials are host=user:key (or host=key) entries giving a
+	// host its own credentials instead of the apt or pip ones.
+	HostCredentials []string
+	// Offline serves every fetch from CacheDir and fails on a cache miss
+	// instead of touching the network.
+	Offline bool
 }
 
 type RepoIndexResult struct {
@@ -158,3 +297,87 @@ type InspectResult struct {
 	Groups            []InspectGroupSummary
 	ResolutionRecords []types.ResolutionRecord
 }
+
+type GraphRequest struct {
+	RepoIndex    string
+	Roots        []string
+	BasePackages []string
+	Format       string
+	Output       string
+}
+
+type GraphResult struct {
+	Rendered   string
+	OutputPath string
+	NodeCount  int
+	EdgeCount  int
+	Warnings   []Warning
+}
+
+type DoctorRequest struct {
+	OutputDir string
+	CacheDir  string
+	GpgKey    string
+	GpgBinary string
+	Endpoints []string
+}
+
+// DoctorCheck is the outcome of one environment check. Skipped checks
+// are not applicable to the configuration and never fail.
+type DoctorCheck struct {
+	Name    string
+	OK      bool
+	Skipped bool
+	Detail  string
+}
+
+type DoctorResult struct {
+	Checks []DoctorCheck
+}
+
+// Failed returns the checks that did not pass.
+func (r DoctorResult) Failed() []DoctorCheck {
+	var failed []DoctorCheck
+	for _, check := range r.Checks {
+		if !check.OK && !check.Skipped {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+type CleanRequest struct {
+	OutputDir string
+	DryRun    bool
+}
+
+// CleanResult lists the generated artifacts removed from the output
+// directory, or those that would be removed under DryRun.
+type CleanResult struct {
+	Removed []string
+	DryRun  bool
+}
+
+// DumpDependenciesResult is the dependency set collected for a resolve
+// request, before resolution.
+type DumpDependenciesResult struct {
+	Dump     types.DependencyDump
+	Warnings []Warning
+}
+
+// LockCheckRequest re-resolves Resolve to compare its apt.lock with the
+// one committed in Resolve.OutputDir, replacing it when Update is set.
+type LockCheckRequest struct {
+	Resolve ResolveRequest
+	Update  bool
+}
+
+// LockCheckResult is the drift between the committed and the freshly
+// resolved apt.lock, as -/+ package=version lines; it is empty when
+// the lock is in sync. Updated reports that the lock was rewritten.
+type LockCheckResult struct {
+	LockPath string
+	Diff     []string
+	Updated  bool
+	Warnings []Warning
+}
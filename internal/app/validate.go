@@ -7,6 +7,7 @@ import (
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
 
+	"avular-packages/internal/adapters"
 	"avular-packages/internal/core"
 	"avular-packages/internal/types"
 )
@@ -68,7 +69,17 @@ func (s Service) Validate(ctx context.Context, req ValidateRequest) (ValidateRes
 	if err := compiler.ValidateSpec(ctx, composed); err != nil {
 		return ValidateResult{}, err
 	}
-	return ValidateResult{ProductName: composed.Metadata.Name}, nil
+	if err := core.ValidateAllowedScopes(composed.Packaging.Groups, req.AllowedScopes); err != nil {
+		return ValidateResult{}, err
+	}
+	hints, err := checkExpiredDirectives(core.ExpiredDirectives(composed.Resolutions, timeNow(s.Clock)), req.StrictDirectives)
+	if err != nil {
+		return ValidateResult{}, err
+	}
+	return ValidateResult{
+		ProductName: composed.Metadata.Name,
+		Warnings:    hintWarnings(WarningExpiredDirective, hints),
+	}, nil
 }
 
 // validateInlineSchema checks structural validity of an inline schema
@@ -84,10 +95,10 @@ func validateInlineSchema(schema types.SchemaFile) error {
 		if normalizedKey == "" {
 			continue
 		}
-		if mapping.Package == "" {
+		if problem := adapters.SchemaMappingProblem(mapping); problem != "" {
 			return errbuilder.New().
 				WithCode(errbuilder.CodeInvalidArgument).
-				WithMsg(fmt.Sprintf("inline schema key '%s' has empty package", normalizedKey))
+				WithMsg(fmt.Sprintf("inline schema key '%s' %s", normalizedKey, problem))
 		}
 		if mapping.Type != types.DependencyTypeApt && mapping.Type != types.DependencyTypePip {
 			return errbuilder.New().
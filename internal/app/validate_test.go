@@ -1,11 +1,17 @@
 package app
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/adapters"
 )
 
 func TestValidateApp(t *testing.T) {
@@ -24,3 +30,78 @@ func TestValidateApp(t *testing.T) {
 		t.Fatalf("unexpected product name (-want +got):\n%s", diff)
 	}
 }
+
+func TestValidateAppRejectsDisallowedScopes(t *testing.T) {
+	root, err := filepath.Abs(filepath.Join("..", ".."))
+	require.NoError(t, err)
+	productPath := filepath.Join(root, "fixtures", "product-sample.yaml")
+	profilePath := filepath.Join(root, "fixtures", "profile-base.yaml")
+
+	service := NewService()
+	_, err = service.Validate(t.Context(), ValidateRequest{
+		ProductPath:   productPath,
+		Profiles:      []string{profilePath},
+		AllowedScopes: []string{"dev"},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "disallowed scope")
+}
+
+func TestValidateAppValidatesHTTPComposedProfile(t *testing.T) {
+	profile := `api_version: "v1"
+kind: "profile"
+metadata:
+  name: "remote-profile"
+  version: "2026.01"
+  owners: ["platform"]
+packaging:
+  groups:
+    - name: "apt-individual"
+      mode: "individual"
+      scope: "%s"
+      matches: ["apt:*"]
+      targets: ["24.04"]
+`
+	scope := "runtime"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = fmt.Fprintf(w, profile, scope)
+	}))
+	defer server.Close()
+
+	productPath := filepath.Join(t.TempDir(), "product.yaml")
+	product := fmt.Sprintf(`api_version: "v1"
+kind: "product"
+metadata:
+  name: "remote-product"
+  version: "2026.01"
+  owners: ["platform"]
+compose:
+  - name: "remote-profile"
+    source: "http"
+    url: "%s/profile.yaml"
+publish:
+  repository:
+    name: "avular"
+    channel: "dev"
+    snapshot_prefix: "remote"
+    signing_key: "avular-release"
+`, server.URL)
+	require.NoError(t, os.WriteFile(productPath, []byte(product), 0o644))
+
+	newService := func() Service {
+		service := NewService()
+		source := adapters.NewProfileSourceAdapter(adapters.NewSpecFileAdapter())
+		source.CacheDir = t.TempDir()
+		service.ProfileSource = source
+		return service
+	}
+
+	result, err := newService().Validate(t.Context(), ValidateRequest{ProductPath: productPath})
+	require.NoError(t, err)
+	require.Equal(t, "remote-product", result.ProductName)
+
+	scope = "production"
+	_, err = newService().Validate(t.Context(), ValidateRequest{ProductPath: productPath})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid scope production")
+}
@@ -1,7 +1,19 @@
 package cli
 
-import "avular-packages/internal/app"
+import (
+	"fmt"
+	"os"
+
+	"avular-packages/internal/app"
+)
 
 func newAppService() app.Service {
 	return app.NewService()
 }
+
+// printWarnings writes the warnings of a Service call to stderr.
+func printWarnings(warnings []app.Warning) {
+	for _, warning := range warnings {
+		fmt.Fprintln(os.Stderr, warning.Message)
+	}
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -11,24 +12,45 @@ import (
 )
 
 type buildOptions struct {
-	Product              string
-	Profiles             []string
-	Workspace            []string
-	RepoIndex            string
-	OutputDir            string
-	DebsDir              string
-	TargetUbuntu         string
-	SchemaFiles          []string
-	PipIndexURL          string
-	InternalDebDir       string
-	InternalSrc          []string
-	AptPreferences       bool
-	AptInstallList       bool
-	SnapshotSources      bool
-	SnapshotAptBaseURL   string
-	SnapshotAptComponent string
-	SnapshotAptArchs     []string
-	AptSatSolver         bool
+	Product                string
+	Profiles               []string
+	Workspace              []string
+	RepoIndex              string
+	OutputDir              string
+	DebsDir                string
+	TargetUbuntu           string
+	SchemaFiles            []string
+	PipIndexURL            string
+	InternalDebDir         string
+	InternalSrc            []string
+	AptPreferences         bool
+	AptInstallList         bool
+	SnapshotSources        bool
+	SnapshotAptBaseURL     string
+	SnapshotAptComponent   string
+	SnapshotAptArchs       []string
+	AptSatSolver           bool
+	PipSatSolver           bool
+	BasePackages           []string
+	AssumeEssential        bool
+	SolverTimeout          time.Duration
+	AllowedScopes          []string
+	BuildWorkers           int
+	DebCompression         string
+	DebCompressionLevel    int
+	BuildLogs              bool
+	TempDir                string
+	Transactional          bool
+	Force                  bool
+	Clean                  bool
+	TargetArch             string
+	PythonVersion          string
+	CacheDir               string
+	CacheTTLMinutes        int
+	IncludeBuildDeps       bool
+	Offline                bool
+	ArchRepoIndexes        []string
+	UnsatCoreMaxIterations int
 }
 
 func newBuildCommand() *cobra.Command {
@@ -49,7 +71,7 @@ func newBuildCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.DebsDir, "debs-dir", "", "Directory for built debs")
 	cmd.Flags().StringVar(&opts.TargetUbuntu, "target-ubuntu", "", "Target Ubuntu release")
 	cmd.Flags().StringSliceVar(&opts.SchemaFiles, "schema", nil, "Schema mapping file(s) for ROS tag resolution (layered, last wins)")
-	cmd.Flags().StringVar(&opts.PipIndexURL, "pip-index-url", "", "Optional PIP index URL override")
+	cmd.Flags().StringVar(&opts.PipIndexURL, "pip-index-url", "", "Optional PIP index URL override; resolved pip versions are checked against it before building")
 	cmd.Flags().StringVar(&opts.InternalDebDir, "internal-deb-dir", "", "Directory containing prebuilt internal debs")
 	cmd.Flags().StringSliceVar(&opts.InternalSrc, "internal-src", nil, "Internal package source directory (debian)")
 	cmd.Flags().BoolVar(&opts.AptPreferences, "apt-preferences", false, "Emit apt preferences pin file from apt.lock")
@@ -59,6 +81,27 @@ func newBuildCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.SnapshotAptComponent, "snapshot-apt-component", "main", "Component for snapshot apt source")
 	cmd.Flags().StringSliceVar(&opts.SnapshotAptArchs, "snapshot-apt-arch", nil, "Optional arch list for snapshot apt source")
 	cmd.Flags().BoolVar(&opts.AptSatSolver, "apt-sat-solver", false, "Resolve apt versions with SAT-based dependency closure")
+	cmd.Flags().BoolVar(&opts.PipSatSolver, "pip-sat-solver", false, "Resolve pip versions with SAT-based Requires-Dist closure")
+	cmd.Flags().StringSliceVar(&opts.BasePackages, "base-package", nil, "Base-system apt packages the SAT solver treats as always installed")
+	cmd.Flags().BoolVar(&opts.AssumeEssential, "assume-essential", false, "Treat Essential and Priority: required apt packages of the repo index as base packages (changes lock contents)")
+	cmd.Flags().DurationVar(&opts.SolverTimeout, "solver-timeout", 0, "Abort an apt SAT solve that takes longer than this (e.g. 5m; 0 = no limit)")
+	cmd.Flags().IntVar(&opts.UnsatCoreMaxIterations, "unsat-core-max-iterations", 0, "Bound the SAT calls spent explaining an unsatisfiable solve (0 = default of 64, negative = no explanation)")
+	cmd.Flags().StringSliceVar(&opts.AllowedScopes, "allowed-scope", nil, "Packaging group scopes this product may contain (runtime, dev, test, doc); other groups are rejected")
+	cmd.Flags().IntVar(&opts.BuildWorkers, "build-workers", 0, "Concurrent deb build workers (0 = GOMAXPROCS)")
+	cmd.Flags().StringVar(&opts.DebCompression, "deb-compression", "xz", "dpkg-deb compressor: xz, gzip, zstd, or none (xz falls back to gzip when unsupported)")
+	cmd.Flags().IntVar(&opts.DebCompressionLevel, "deb-compression-level", 0, "dpkg-deb compression level 1-9 (0 = compressor default)")
+	cmd.Flags().BoolVar(&opts.BuildLogs, "build-logs", false, "Write the pip and dpkg-deb output of each built package to <output>/build-logs")
+	cmd.Flags().StringVar(&opts.TempDir, "temp-dir", "", "Base directory for pip and deb staging directories (defaults to the OS temp directory)")
+	cmd.Flags().BoolVar(&opts.Transactional, "transactional", false, "Move built debs into the debs directory only when every package built successfully")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Write into a non-empty output directory, overwriting earlier artifacts")
+	cmd.Flags().BoolVar(&opts.Clean, "clean", false, "Remove generated artifacts from the output directory before writing")
+	cmd.Flags().StringVar(&opts.TargetArch, "target-arch", "", "Debian architecture of debs that contain platform wheels, e.g. amd64 or arm64 (default: host architecture)")
+	cmd.Flags().StringVar(&opts.PythonVersion, "python-version", "", "Python version the debs install into, e.g. 3.12 (default: the python of --target-ubuntu)")
+	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", "", "Optional cache directory for pip resolve results")
+	cmd.Flags().BoolVar(&opts.IncludeBuildDeps, "include-build-deps", false, "Also resolve build_depend and test_depend ROS tags, e.g. for a dev or CI image")
+	cmd.Flags().StringSliceVar(&opts.ArchRepoIndexes, "arch-repo-index", nil, "Per-architecture repo index (arch=path) solved together with --apt-sat-solver; repeat for each architecture")
+	cmd.Flags().BoolVar(&opts.Offline, "offline", false, "Install pip packages only from a local --pip-index-url directory and fail instead of using the network")
+	cmd.Flags().IntVar(&opts.CacheTTLMinutes, "cache-ttl-minutes", 60, "Cache TTL in minutes (0 = no caching)")
 
 	_ = viper.BindPFlag("product", cmd.Flags().Lookup("product"))
 	_ = viper.BindPFlag("profiles", cmd.Flags().Lookup("profile"))
@@ -78,6 +121,27 @@ func newBuildCommand() *cobra.Command {
 	_ = viper.BindPFlag("snapshot_apt_component", cmd.Flags().Lookup("snapshot-apt-component"))
 	_ = viper.BindPFlag("snapshot_apt_arch", cmd.Flags().Lookup("snapshot-apt-arch"))
 	_ = viper.BindPFlag("apt_sat_solver", cmd.Flags().Lookup("apt-sat-solver"))
+	_ = viper.BindPFlag("pip_sat_solver", cmd.Flags().Lookup("pip-sat-solver"))
+	_ = viper.BindPFlag("base_packages", cmd.Flags().Lookup("base-package"))
+	_ = viper.BindPFlag("assume_essential", cmd.Flags().Lookup("assume-essential"))
+	_ = viper.BindPFlag("solver_timeout", cmd.Flags().Lookup("solver-timeout"))
+	_ = viper.BindPFlag("unsat_core_max_iterations", cmd.Flags().Lookup("unsat-core-max-iterations"))
+	_ = viper.BindPFlag("allowed_scopes", cmd.Flags().Lookup("allowed-scope"))
+	_ = viper.BindPFlag("build_workers", cmd.Flags().Lookup("build-workers"))
+	_ = viper.BindPFlag("deb_compression", cmd.Flags().Lookup("deb-compression"))
+	_ = viper.BindPFlag("deb_compression_level", cmd.Flags().Lookup("deb-compression-level"))
+	_ = viper.BindPFlag("build_logs", cmd.Flags().Lookup("build-logs"))
+	_ = viper.BindPFlag("build_temp_dir", cmd.Flags().Lookup("temp-dir"))
+	_ = viper.BindPFlag("build_transactional", cmd.Flags().Lookup("transactional"))
+	_ = viper.BindPFlag("output_force", cmd.Flags().Lookup("force"))
+	_ = viper.BindPFlag("output_clean", cmd.Flags().Lookup("clean"))
+	_ = viper.BindPFlag("build_target_arch", cmd.Flags().Lookup("target-arch"))
+	_ = viper.BindPFlag("build_cache_dir", cmd.Flags().Lookup("cache-dir"))
+	_ = viper.BindPFlag("build_cache_ttl_minutes", cmd.Flags().Lookup("cache-ttl-minutes"))
+	_ = viper.BindPFlag("include_build_deps", cmd.Flags().Lookup("include-build-deps"))
+	_ = viper.BindPFlag("offline", cmd.Flags().Lookup("offline"))
+	_ = viper.BindPFlag("arch_repo_indexes", cmd.Flags().Lookup("arch-repo-index"))
+	_ = viper.BindPFlag("build_python_version", cmd.Flags().Lookup("python-version"))
 
 	return cmd
 }
@@ -85,28 +149,51 @@ func newBuildCommand() *cobra.Command {
 func runBuild(ctx context.Context, cmd *cobra.Command, opts buildOptions) error {
 	service := newAppService()
 	result, err := service.Build(ctx, app.BuildRequest{
-		ProductPath:          resolveString(cmd, opts.Product, "product", "product"),
-		Profiles:             resolveStrings(cmd, opts.Profiles, "profiles", "profile"),
-		Workspace:            resolveStrings(cmd, opts.Workspace, "workspace", "workspace"),
-		RepoIndex:            resolveString(cmd, opts.RepoIndex, "repo_index", "repo-index"),
-		OutputDir:            resolveString(cmd, opts.OutputDir, "output", "output"),
-		DebsDir:              resolveString(cmd, opts.DebsDir, "debs_dir", "debs-dir"),
-		TargetUbuntu:         resolveString(cmd, opts.TargetUbuntu, "target_ubuntu", "target-ubuntu"),
-		SchemaFiles:          resolveStrings(cmd, opts.SchemaFiles, "schema_files", "schema"),
-		PipIndexURL:          resolveString(cmd, opts.PipIndexURL, "pip_index_url", "pip-index-url"),
-		InternalDebDir:       resolveString(cmd, opts.InternalDebDir, "internal_deb_dir", "internal-deb-dir"),
-		InternalSrc:          resolveStrings(cmd, opts.InternalSrc, "internal_src", "internal-src"),
-		EmitAptPreferences:   resolveBool(cmd, opts.AptPreferences, "apt_preferences", "apt-preferences"),
-		EmitAptInstallList:   resolveBool(cmd, opts.AptInstallList, "apt_install_list", "apt-install-list"),
-		EmitSnapshotSources:  resolveBool(cmd, opts.SnapshotSources, "snapshot_apt_sources", "snapshot-apt-sources"),
-		SnapshotAptBaseURL:   resolveString(cmd, opts.SnapshotAptBaseURL, "snapshot_apt_base_url", "snapshot-apt-base-url"),
-		SnapshotAptComponent: resolveString(cmd, opts.SnapshotAptComponent, "snapshot_apt_component", "snapshot-apt-component"),
-		SnapshotAptArchs:     resolveStrings(cmd, opts.SnapshotAptArchs, "snapshot_apt_arch", "snapshot-apt-arch"),
-		AptSatSolver:         resolveBool(cmd, opts.AptSatSolver, "apt_sat_solver", "apt-sat-solver"),
+		ProductPath:            resolveString(cmd, opts.Product, "product", "product"),
+		Profiles:               resolveStrings(cmd, opts.Profiles, "profiles", "profile"),
+		Workspace:              resolveStrings(cmd, opts.Workspace, "workspace", "workspace"),
+		RepoIndex:              resolveString(cmd, opts.RepoIndex, "repo_index", "repo-index"),
+		OutputDir:              resolveString(cmd, opts.OutputDir, "output", "output"),
+		DebsDir:                resolveString(cmd, opts.DebsDir, "debs_dir", "debs-dir"),
+		TargetUbuntu:           resolveString(cmd, opts.TargetUbuntu, "target_ubuntu", "target-ubuntu"),
+		SchemaFiles:            resolveStrings(cmd, opts.SchemaFiles, "schema_files", "schema"),
+		PipIndexURL:            resolveString(cmd, opts.PipIndexURL, "pip_index_url", "pip-index-url"),
+		InternalDebDir:         resolveString(cmd, opts.InternalDebDir, "internal_deb_dir", "internal-deb-dir"),
+		InternalSrc:            resolveStrings(cmd, opts.InternalSrc, "internal_src", "internal-src"),
+		EmitAptPreferences:     resolveBool(cmd, opts.AptPreferences, "apt_preferences", "apt-preferences"),
+		EmitAptInstallList:     resolveBool(cmd, opts.AptInstallList, "apt_install_list", "apt-install-list"),
+		EmitSnapshotSources:    resolveBool(cmd, opts.SnapshotSources, "snapshot_apt_sources", "snapshot-apt-sources"),
+		SnapshotAptBaseURL:     resolveString(cmd, opts.SnapshotAptBaseURL, "snapshot_apt_base_url", "snapshot-apt-base-url"),
+		SnapshotAptComponent:   resolveString(cmd, opts.SnapshotAptComponent, "snapshot_apt_component", "snapshot-apt-component"),
+		SnapshotAptArchs:       resolveStrings(cmd, opts.SnapshotAptArchs, "snapshot_apt_arch", "snapshot-apt-arch"),
+		AptSatSolver:           resolveBool(cmd, opts.AptSatSolver, "apt_sat_solver", "apt-sat-solver"),
+		PipSatSolver:           resolveBool(cmd, opts.PipSatSolver, "pip_sat_solver", "pip-sat-solver"),
+		BasePackages:           resolveStrings(cmd, opts.BasePackages, "base_packages", "base-package"),
+		AssumeEssential:        resolveBool(cmd, opts.AssumeEssential, "assume_essential", "assume-essential"),
+		SolverTimeout:          resolveDuration(cmd, opts.SolverTimeout, "solver_timeout", "solver-timeout"),
+		AllowedScopes:          resolveStrings(cmd, opts.AllowedScopes, "allowed_scopes", "allowed-scope"),
+		ToolVersion:            version,
+		BuildWorkers:           resolveInt(cmd, opts.BuildWorkers, "build_workers", "build-workers"),
+		DebCompression:         resolveString(cmd, opts.DebCompression, "deb_compression", "deb-compression"),
+		DebCompressionLevel:    resolveInt(cmd, opts.DebCompressionLevel, "deb_compression_level", "deb-compression-level"),
+		BuildLogs:              resolveBool(cmd, opts.BuildLogs, "build_logs", "build-logs"),
+		TempDir:                resolveString(cmd, opts.TempDir, "build_temp_dir", "temp-dir"),
+		Transactional:          resolveBool(cmd, opts.Transactional, "build_transactional", "transactional"),
+		Force:                  resolveBool(cmd, opts.Force, "output_force", "force"),
+		Clean:                  resolveBool(cmd, opts.Clean, "output_clean", "clean"),
+		TargetArch:             resolveString(cmd, opts.TargetArch, "build_target_arch", "target-arch"),
+		PythonVersion:          resolveString(cmd, opts.PythonVersion, "build_python_version", "python-version"),
+		CacheDir:               resolveString(cmd, opts.CacheDir, "build_cache_dir", "cache-dir"),
+		CacheTTLMinutes:        resolveInt(cmd, opts.CacheTTLMinutes, "build_cache_ttl_minutes", "cache-ttl-minutes"),
+		IncludeBuildDeps:       resolveBool(cmd, opts.IncludeBuildDeps, "include_build_deps", "include-build-deps"),
+		Offline:                resolveBool(cmd, opts.Offline, "offline", "offline"),
+		ArchRepoIndexes:        resolveStrings(cmd, opts.ArchRepoIndexes, "arch_repo_indexes", "arch-repo-index"),
+		UnsatCoreMaxIterations: resolveInt(cmd, opts.UnsatCoreMaxIterations, "unsat_core_max_iterations", "unsat-core-max-iterations"),
 	})
 	if err != nil {
 		return err
 	}
+	printWarnings(result.Warnings)
 	fmt.Printf("built debs: %s\n", result.DebsDir)
 	return nil
 }
@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"avular-packages/internal/app"
+)
+
+type cleanOptions struct {
+	OutputDir string
+	DryRun    bool
+}
+
+func newCleanCommand() *cobra.Command {
+	opts := cleanOptions{}
+	cmd := &cobra.Command{
+		Use:   "clean",
+		Short: "Remove generated artifacts from the output directory",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runClean(cmd, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.OutputDir, "output", "out", "Output directory")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Only list the artifacts that would be removed")
+	_ = viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+	_ = viper.BindPFlag("clean_dry_run", cmd.Flags().Lookup("dry-run"))
+	return cmd
+}
+
+func runClean(cmd *cobra.Command, opts cleanOptions) error {
+	service := newAppService()
+	result, err := service.Clean(cmd.Context(), app.CleanRequest{
+		OutputDir: resolveString(cmd, opts.OutputDir, "output", "output"),
+		DryRun:    resolveBool(cmd, opts.DryRun, "clean_dry_run", "dry-run"),
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range result.Removed {
+		if result.DryRun {
+			fmt.Printf("would remove: %s\n", name)
+		} else {
+			fmt.Printf("removed: %s\n", name)
+		}
+	}
+	if result.DryRun {
+		fmt.Printf("dry-run: %d artifact(s)\n", len(result.Removed))
+		return nil
+	}
+	fmt.Printf("cleaned: %d artifact(s)\n", len(result.Removed))
+	return nil
+}
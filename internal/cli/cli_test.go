@@ -20,6 +20,7 @@ func TestRootCommandHasSubcommands(t *testing.T) {
 	expected := []string{
 		"validate", "resolve", "lock", "build",
 		"publish", "inspect", "repo-index", "prune",
+		"graph",
 	}
 	for _, name := range expected {
 		assert.Contains(t, names, name, "missing subcommand: %s", name)
@@ -40,7 +41,8 @@ func TestResolveCommandFlags(t *testing.T) {
 		"apt-preferences", "apt-install-list",
 		"snapshot-apt-sources", "snapshot-apt-base-url",
 		"snapshot-apt-component", "snapshot-apt-arch",
-		"apt-sat-solver",
+		"apt-sat-solver", "allowed-scope", "json",
+		"unsat-core-max-iterations",
 	}
 	for _, name := range flags {
 		flag := cmd.Flags().Lookup(name)
@@ -48,12 +50,19 @@ func TestResolveCommandFlags(t *testing.T) {
 	}
 }
 
+func TestBuildCommandFlags(t *testing.T) {
+	cmd := newBuildCommand()
+	for _, name := range []string{"product", "repo-index", "output", "apt-sat-solver", "arch-repo-index", "unsat-core-max-iterations"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag: %s", name)
+	}
+}
+
 func TestPublishCommandFlags(t *testing.T) {
 	cmd := newPublishCommand()
 	flags := []string{
-		"output", "repo-dir", "sbom", "repo-backend",
+		"output", "repo-dir", "sbom", "repo-backend", "apt-layout",
 		"debs-dir", "aptly-repo", "aptly-component",
-		"aptly-prefix", "aptly-endpoint", "gpg-key",
+		"aptly-prefix", "aptly-endpoint", "gpg-key", "gpg-binary",
 		"proget-endpoint", "proget-feed", "proget-component",
 		"proget-user", "proget-api-key", "proget-workers",
 		"proget-timeout", "proget-retries", "proget-retry-delay-ms",
@@ -64,6 +73,13 @@ func TestPublishCommandFlags(t *testing.T) {
 	}
 }
 
+func TestGraphCommandFlags(t *testing.T) {
+	cmd := newGraphCommand()
+	for _, name := range []string{"repo-index", "base-package", "format", "output"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "missing flag: %s", name)
+	}
+}
+
 func TestValidateCommandFlags(t *testing.T) {
 	cmd := newValidateCommand()
 	assert.NotNil(t, cmd.Flags().Lookup("product"))
@@ -229,6 +245,13 @@ func TestExitCodeForError(t *testing.T) {
 				WithMsg("boom"),
 			expected: 5,
 		},
+		{
+			name: "best-effort unresolved",
+			err: errbuilder.New().
+				WithCode(errbuilder.CodeFailedPrecondition).
+				WithMsg("unresolved dependencies: 2 left unresolved by best-effort resolve"),
+			expected: 6,
+		},
 		{
 			name:     "unknown error",
 			err:      assert.AnError,
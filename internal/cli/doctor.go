@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"avular-packages/internal/app"
+)
+
+type doctorOptions struct {
+	OutputDir string
+	CacheDir  string
+	GpgKey    string
+	GpgBinary string
+	Endpoints []string
+}
+
+// doctorEndpointKeys are the configured endpoints the doctor checks for
+// reachability in addition to --endpoint.
+var doctorEndpointKeys = []string{"apt_endpoint", "pip_index", "pip_index_url", "proget_endpoint"}
+
+func newDoctorCommand() *cobra.Command {
+	opts := doctorOptions{}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the local toolchain, directories and endpoints",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runDoctor(cmd, opts)
+		},
+	}
+	cmd.Flags().StringVar(&opts.OutputDir, "output", "out", "Output directory")
+	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", "", "Optional cache directory for repo-index fetches")
+	cmd.Flags().StringVar(&opts.GpgKey, "gpg-key", "", "GPG key ID for signing; gpg is only checked when set")
+	cmd.Flags().StringVar(&opts.GpgBinary, "gpg-binary", "gpg", "gpg executable used to sign Release files of the file backend")
+	cmd.Flags().StringSliceVar(&opts.Endpoints, "endpoint", nil, "Additional endpoint URL to check for reachability (repeatable)")
+	_ = viper.BindPFlag("output", cmd.Flags().Lookup("output"))
+	_ = viper.BindPFlag("repo_index_cache_dir", cmd.Flags().Lookup("cache-dir"))
+	_ = viper.BindPFlag("gpg_key", cmd.Flags().Lookup("gpg-key"))
+	_ = viper.BindPFlag("gpg_binary", cmd.Flags().Lookup("gpg-binary"))
+	_ = viper.BindPFlag("doctor_endpoints", cmd.Flags().Lookup("endpoint"))
+	return cmd
+}
+
+func runDoctor(cmd *cobra.Command, opts doctorOptions) error {
+	endpoints := resolveStrings(cmd, opts.Endpoints, "doctor_endpoints", "endpoint")
+	for _, key := range doctorEndpointKeys {
+		if endpoint := viper.GetString(key); endpoint != "" {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+
+	service := newAppService()
+	result := service.Doctor(cmd.Context(), app.DoctorRequest{
+		OutputDir: resolveString(cmd, opts.OutputDir, "output", "output"),
+		CacheDir:  resolveString(cmd, opts.CacheDir, "repo_index_cache_dir", "cache-dir"),
+		GpgKey:    resolveString(cmd, opts.GpgKey, "gpg_key", "gpg-key"),
+		GpgBinary: resolveString(cmd, opts.GpgBinary, "gpg_binary", "gpg-binary"),
+		Endpoints: endpoints,
+	})
+
+	for _, check := range result.Checks {
+		status := "PASS"
+		switch {
+		case check.Skipped:
+			status = "SKIP"
+		case !check.OK:
+			status = "FAIL"
+		}
+		fmt.Printf("%s %s: %s\n", status, check.Name, check.Detail)
+	}
+	if failed := result.Failed(); len(failed) > 0 {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("doctor found %d failing check(s)", len(failed)))
+	}
+	return nil
+}
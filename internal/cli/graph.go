@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"avular-packages/internal/app"
+)
+
+type graphOptions struct {
+	RepoIndex    string
+	BasePackages []string
+	Format       string
+	Output       string
+}
+
+func newGraphCommand() *cobra.Command {
+	opts := graphOptions{}
+	cmd := &cobra.Command{
+		Use:   "graph [flags] <dependency>...",
+		Short: "Emit the apt dependency graph explored from root dependencies",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGraph(cmd, opts, args)
+		},
+	}
+	cmd.Flags().StringVar(&opts.RepoIndex, "repo-index", "", "Repository index file")
+	cmd.Flags().StringSliceVar(&opts.BasePackages, "base-package", nil, "Base-system apt packages treated as always installed")
+	cmd.Flags().StringVar(&opts.Format, "format", "dot", "Graph format: dot or mermaid")
+	cmd.Flags().StringVar(&opts.Output, "output", "", "Write the graph to this file instead of stdout")
+	_ = viper.BindPFlag("repo_index", cmd.Flags().Lookup("repo-index"))
+	_ = viper.BindPFlag("base_packages", cmd.Flags().Lookup("base-package"))
+	_ = viper.BindPFlag("graph_format", cmd.Flags().Lookup("format"))
+	_ = viper.BindPFlag("graph_output", cmd.Flags().Lookup("output"))
+	return cmd
+}
+
+func runGraph(cmd *cobra.Command, opts graphOptions, roots []string) error {
+	service := newAppService()
+	result, err := service.Graph(cmd.Context(), app.GraphRequest{
+		RepoIndex:    resolveString(cmd, opts.RepoIndex, "repo_index", "repo-index"),
+		Roots:        roots,
+		BasePackages: resolveStrings(cmd, opts.BasePackages, "base_packages", "base-package"),
+		Format:       resolveString(cmd, opts.Format, "graph_format", "format"),
+		Output:       resolveString(cmd, opts.Output, "graph_output", "output"),
+	})
+	if err != nil {
+		return err
+	}
+	printWarnings(result.Warnings)
+	if result.OutputPath == "" {
+		fmt.Print(result.Rendered)
+		return nil
+	}
+	fmt.Printf("graph written: %s (%d nodes, %d edges)\n", result.OutputPath, result.NodeCount, result.EdgeCount)
+	return nil
+}
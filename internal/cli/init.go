@@ -271,6 +271,12 @@ func generateConfigScaffold() string {
 # Resolve apt versions with SAT-based dependency closure
 # apt_sat_solver: false
 
+# Resolve pip versions with SAT-based Requires-Dist closure
+# pip_sat_solver: false
+
+# Previous apt.lock to keep versions stable under the SAT solver
+# prefer_lock: ""
+
 # Snapshot apt source configuration
 # snapshot_apt_sources: false
 # snapshot_apt_base_url: ""
@@ -1,8 +1,21 @@
 package cli
 
-import "github.com/spf13/cobra"
+import (
+	"context"
+	"fmt"
 
-type lockOptions = resolveOptions
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"avular-packages/internal/app"
+)
+
+type lockOptions struct {
+	resolveOptions
+	Check  bool
+	Update bool
+}
 
 func newLockCommand() *cobra.Command {
 	opts := lockOptions{}
@@ -10,7 +23,7 @@ func newLockCommand() *cobra.Command {
 		Use:   "lock",
 		Short: "Resolve dependencies and produce lock outputs",
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return runResolve(cmd.Context(), cmd, opts)
+			return runLock(cmd.Context(), cmd, opts)
 		},
 	}
 
@@ -23,6 +36,45 @@ func newLockCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.TargetUbuntu, "target-ubuntu", "", "Target Ubuntu release")
 	cmd.Flags().BoolVar(&opts.CompatGetDeps, "compat-get-dependencies", false, "Emit get-dependencies compatible outputs")
 	cmd.Flags().BoolVar(&opts.CompatRosdep, "compat-rosdep", false, "Emit rosdep-style mapping output")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Write into a non-empty output directory, overwriting earlier artifacts")
+	cmd.Flags().BoolVar(&opts.Clean, "clean", false, "Empty a non-empty output directory before writing")
+	cmd.Flags().BoolVar(&opts.Check, "check", false, "Re-resolve and fail with a diff when <output>/apt.lock is out of date; writes nothing")
+	cmd.Flags().BoolVar(&opts.Update, "update", false, "Re-resolve and rewrite <output>/apt.lock when it is out of date; writes nothing else")
+	cmd.MarkFlagsMutuallyExclusive("check", "update")
+
+	_ = viper.BindPFlag("lock_check", cmd.Flags().Lookup("check"))
+	_ = viper.BindPFlag("lock_update", cmd.Flags().Lookup("update"))
 
 	return cmd
 }
+
+func runLock(ctx context.Context, cmd *cobra.Command, opts lockOptions) error {
+	check := resolveBool(cmd, opts.Check, "lock_check", "check")
+	update := resolveBool(cmd, opts.Update, "lock_update", "update")
+	if !check && !update {
+		return runResolve(ctx, cmd, opts.resolveOptions)
+	}
+	service := newAppService()
+	result, err := service.CheckLock(ctx, app.LockCheckRequest{
+		Resolve: newResolveRequest(cmd, opts.resolveOptions),
+		Update:  update,
+	})
+	if err != nil {
+		return err
+	}
+	printWarnings(result.Warnings)
+	for _, line := range result.Diff {
+		fmt.Println(line)
+	}
+	switch {
+	case len(result.Diff) == 0:
+		fmt.Printf("apt.lock is up to date: %s\n", result.LockPath)
+	case result.Updated:
+		fmt.Printf("updated apt.lock: %s\n", result.LockPath)
+	default:
+		return errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("apt.lock is out of date: %s differs from a fresh resolve in %d line(s); run lock --update", result.LockPath, len(result.Diff)))
+	}
+	return nil
+}
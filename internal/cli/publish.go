@@ -11,25 +11,36 @@ import (
 )
 
 type publishOptions struct {
-	OutputDir          string
-	RepoDir            string
-	SBOM               bool
-	RepoBackend        string
-	DebsDir            string
-	AptlyRepo          string
-	AptlyComponent     string
-	AptlyPrefix        string
-	AptlyEndpoint      string
-	GpgKey             string
-	ProGetEndpoint     string
-	ProGetFeed         string
-	ProGetComponent    string
-	ProGetUser         string
-	ProGetAPIKey       string
-	ProGetWorkers      int
-	ProGetTimeoutSec   int
-	ProGetRetries      int
-	ProGetRetryDelayMs int
+	OutputDir                 string
+	RepoDir                   string
+	SBOM                      bool
+	RepoBackend               string
+	AptLayout                 bool
+	DebsDir                   string
+	AptlyRepo                 string
+	AptlyComponent            string
+	AptlyPrefix               string
+	AptlyEndpoint             string
+	GpgKey                    string
+	GpgBinary                 string
+	ProGetEndpoint            string
+	ProGetFeed                string
+	ProGetComponent           string
+	ProGetUser                string
+	ProGetAPIKey              string
+	ProGetAuthMode            string
+	ProGetWorkers             int
+	ProGetTimeoutSec          int
+	ProGetRetries             int
+	ProGetRetryDelayMs        int
+	ProGetMaxIdleConnsPerHost int
+	ProGetIdleConnTimeoutSec  int
+	ProGetDisableHTTP2        bool
+	ProGetRateLimit           int
+	ProGetVerify              bool
+	ProGetSkipExisting        bool
+	DryRun                    bool
+	Quiet                     bool
 }
 
 func newPublishCommand() *cobra.Command {
@@ -45,69 +56,114 @@ func newPublishCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.RepoDir, "repo-dir", "", "Repository directory for snapshot metadata")
 	cmd.Flags().BoolVar(&opts.SBOM, "sbom", true, "Generate SBOM alongside snapshot metadata")
 	cmd.Flags().StringVar(&opts.RepoBackend, "repo-backend", "file", "Repository backend (file, aptly, or proget)")
-	cmd.Flags().StringVar(&opts.DebsDir, "debs-dir", "", "Directory with deb artifacts (aptly/proget backends)")
+	cmd.Flags().BoolVar(&opts.AptLayout, "apt-layout", false, "Also write pool/ and dists/<channel>/ so the file backend repo dir is usable by apt")
+	cmd.Flags().StringVar(&opts.DebsDir, "debs-dir", "", "Directory with deb artifacts (aptly/proget backends, file backend with --apt-layout)")
 	cmd.Flags().StringVar(&opts.AptlyRepo, "aptly-repo", "", "Aptly repo name (defaults to snapshot intent repository)")
 	cmd.Flags().StringVar(&opts.AptlyComponent, "aptly-component", "main", "Aptly component name")
 	cmd.Flags().StringVar(&opts.AptlyPrefix, "aptly-prefix", ".", "Aptly publish prefix")
 	cmd.Flags().StringVar(&opts.AptlyEndpoint, "aptly-endpoint", "", "Aptly publish endpoint (e.g., s3:repo)")
-	cmd.Flags().StringVar(&opts.GpgKey, "gpg-key", "", "GPG key ID for signing")
+	cmd.Flags().StringVar(&opts.GpgKey, "gpg-key", "", "GPG key ID for signing (defaults to publish.repository.signing_key)")
+	cmd.Flags().StringVar(&opts.GpgBinary, "gpg-binary", "gpg", "gpg executable used to sign Release files of the file backend")
 	cmd.Flags().StringVar(&opts.ProGetEndpoint, "proget-endpoint", "", "ProGet base URL (e.g., https://packages.example.com)")
 	cmd.Flags().StringVar(&opts.ProGetFeed, "proget-feed", "", "ProGet Debian feed name (defaults to snapshot intent repository)")
 	cmd.Flags().StringVar(&opts.ProGetComponent, "proget-component", "main", "ProGet Debian component name")
 	cmd.Flags().StringVar(&opts.ProGetUser, "proget-user", "", "ProGet username for basic auth (defaults to api)")
 	cmd.Flags().StringVar(&opts.ProGetAPIKey, "proget-api-key", "", "ProGet API key or password for basic auth")
+	cmd.Flags().StringVar(&opts.ProGetAuthMode, "proget-auth-mode", "basic", "ProGet authentication mode (basic or apikey for the X-ApiKey header)")
 	cmd.Flags().IntVar(&opts.ProGetWorkers, "proget-workers", 4, "Concurrent ProGet upload workers (0 = default)")
 	cmd.Flags().IntVar(&opts.ProGetTimeoutSec, "proget-timeout", 60, "ProGet HTTP timeout in seconds (0 = default)")
 	cmd.Flags().IntVar(&opts.ProGetRetries, "proget-retries", 3, "ProGet upload retries (0 = default)")
 	cmd.Flags().IntVar(&opts.ProGetRetryDelayMs, "proget-retry-delay-ms", 200, "ProGet retry base delay in ms (0 = default)")
+	cmd.Flags().IntVar(&opts.ProGetMaxIdleConnsPerHost, "proget-max-idle-conns-per-host", 8, "Maximum idle keep-alive connections per ProGet host (0 = default)")
+	cmd.Flags().IntVar(&opts.ProGetIdleConnTimeoutSec, "proget-idle-conn-timeout", 90, "ProGet idle keep-alive connection timeout in seconds (0 = default)")
+	cmd.Flags().BoolVar(&opts.ProGetDisableHTTP2, "proget-disable-http2", false, "Use HTTP/1.1 only for ProGet uploads")
+	cmd.Flags().IntVar(&opts.ProGetRateLimit, "proget-rate-limit", 0, "ProGet upload rate limit in bytes per second (0 = unlimited)")
+	cmd.Flags().BoolVar(&opts.ProGetVerify, "proget-verify", false, "Re-query the ProGet feed after uploading and fail if any deb is missing")
+	cmd.Flags().BoolVar(&opts.ProGetSkipExisting, "proget-skip-existing", false, "Skip uploading debs the ProGet feed already serves with the same size")
+	cmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "List the uploads that would be performed without publishing (proget backend)")
+	cmd.Flags().BoolVar(&opts.Quiet, "quiet", false, "Suppress upload progress logging")
 	_ = viper.BindPFlag("output", cmd.Flags().Lookup("output"))
 	_ = viper.BindPFlag("repo_dir", cmd.Flags().Lookup("repo-dir"))
 	_ = viper.BindPFlag("sbom", cmd.Flags().Lookup("sbom"))
 	_ = viper.BindPFlag("repo_backend", cmd.Flags().Lookup("repo-backend"))
+	_ = viper.BindPFlag("apt_layout", cmd.Flags().Lookup("apt-layout"))
 	_ = viper.BindPFlag("debs_dir", cmd.Flags().Lookup("debs-dir"))
 	_ = viper.BindPFlag("aptly_repo", cmd.Flags().Lookup("aptly-repo"))
 	_ = viper.BindPFlag("aptly_component", cmd.Flags().Lookup("aptly-component"))
 	_ = viper.BindPFlag("aptly_prefix", cmd.Flags().Lookup("aptly-prefix"))
 	_ = viper.BindPFlag("aptly_endpoint", cmd.Flags().Lookup("aptly-endpoint"))
 	_ = viper.BindPFlag("gpg_key", cmd.Flags().Lookup("gpg-key"))
+	_ = viper.BindPFlag("gpg_binary", cmd.Flags().Lookup("gpg-binary"))
 	_ = viper.BindPFlag("proget_endpoint", cmd.Flags().Lookup("proget-endpoint"))
 	_ = viper.BindPFlag("proget_feed", cmd.Flags().Lookup("proget-feed"))
 	_ = viper.BindPFlag("proget_component", cmd.Flags().Lookup("proget-component"))
 	_ = viper.BindPFlag("proget_user", cmd.Flags().Lookup("proget-user"))
 	_ = viper.BindPFlag("proget_api_key", cmd.Flags().Lookup("proget-api-key"))
+	_ = viper.BindPFlag("proget_auth_mode", cmd.Flags().Lookup("proget-auth-mode"))
 	_ = viper.BindPFlag("proget_workers", cmd.Flags().Lookup("proget-workers"))
 	_ = viper.BindPFlag("proget_timeout_sec", cmd.Flags().Lookup("proget-timeout"))
 	_ = viper.BindPFlag("proget_retries", cmd.Flags().Lookup("proget-retries"))
 	_ = viper.BindPFlag("proget_retry_delay_ms", cmd.Flags().Lookup("proget-retry-delay-ms"))
+	_ = viper.BindPFlag("proget_max_idle_conns_per_host", cmd.Flags().Lookup("proget-max-idle-conns-per-host"))
+	_ = viper.BindPFlag("proget_idle_conn_timeout_sec", cmd.Flags().Lookup("proget-idle-conn-timeout"))
+	_ = viper.BindPFlag("proget_disable_http2", cmd.Flags().Lookup("proget-disable-http2"))
+	_ = viper.BindPFlag("proget_rate_limit", cmd.Flags().Lookup("proget-rate-limit"))
+	_ = viper.BindPFlag("proget_verify", cmd.Flags().Lookup("proget-verify"))
+	_ = viper.BindPFlag("proget_skip_existing", cmd.Flags().Lookup("proget-skip-existing"))
+	_ = viper.BindPFlag("publish_dry_run", cmd.Flags().Lookup("dry-run"))
+	_ = viper.BindPFlag("publish_quiet", cmd.Flags().Lookup("quiet"))
 	return cmd
 }
 
 func runPublish(_ context.Context, cmd *cobra.Command, opts publishOptions) error {
 	service := newAppService()
 	result, err := service.Publish(cmd.Context(), app.PublishRequest{
-		OutputDir:          resolveString(cmd, opts.OutputDir, "output", "output"),
-		RepoDir:            resolveString(cmd, opts.RepoDir, "repo_dir", "repo-dir"),
-		SBOM:               resolveBool(cmd, opts.SBOM, "sbom", "sbom"),
-		RepoBackend:        resolveString(cmd, opts.RepoBackend, "repo_backend", "repo-backend"),
-		DebsDir:            resolveString(cmd, opts.DebsDir, "debs_dir", "debs-dir"),
-		AptlyRepo:          resolveString(cmd, opts.AptlyRepo, "aptly_repo", "aptly-repo"),
-		AptlyComponent:     resolveString(cmd, opts.AptlyComponent, "aptly_component", "aptly-component"),
-		AptlyPrefix:        resolveString(cmd, opts.AptlyPrefix, "aptly_prefix", "aptly-prefix"),
-		AptlyEndpoint:      resolveString(cmd, opts.AptlyEndpoint, "aptly_endpoint", "aptly-endpoint"),
-		GpgKey:             resolveString(cmd, opts.GpgKey, "gpg_key", "gpg-key"),
-		ProGetEndpoint:     resolveString(cmd, opts.ProGetEndpoint, "proget_endpoint", "proget-endpoint"),
-		ProGetFeed:         resolveString(cmd, opts.ProGetFeed, "proget_feed", "proget-feed"),
-		ProGetComponent:    resolveString(cmd, opts.ProGetComponent, "proget_component", "proget-component"),
-		ProGetUser:         resolveString(cmd, opts.ProGetUser, "proget_user", "proget-user"),
-		ProGetAPIKey:       resolveString(cmd, opts.ProGetAPIKey, "proget_api_key", "proget-api-key"),
-		ProGetWorkers:      resolveInt(cmd, opts.ProGetWorkers, "proget_workers", "proget-workers"),
-		ProGetTimeoutSec:   resolveInt(cmd, opts.ProGetTimeoutSec, "proget_timeout_sec", "proget-timeout"),
-		ProGetRetries:      resolveInt(cmd, opts.ProGetRetries, "proget_retries", "proget-retries"),
-		ProGetRetryDelayMs: resolveInt(cmd, opts.ProGetRetryDelayMs, "proget_retry_delay_ms", "proget-retry-delay-ms"),
+		OutputDir:                  resolveString(cmd, opts.OutputDir, "output", "output"),
+		RepoDir:                    resolveString(cmd, opts.RepoDir, "repo_dir", "repo-dir"),
+		SBOM:                       resolveBool(cmd, opts.SBOM, "sbom", "sbom"),
+		RepoBackend:                resolveString(cmd, opts.RepoBackend, "repo_backend", "repo-backend"),
+		AptLayout:                  resolveBool(cmd, opts.AptLayout, "apt_layout", "apt-layout"),
+		DebsDir:                    resolveString(cmd, opts.DebsDir, "debs_dir", "debs-dir"),
+		AptlyRepo:                  resolveString(cmd, opts.AptlyRepo, "aptly_repo", "aptly-repo"),
+		AptlyComponent:             resolveString(cmd, opts.AptlyComponent, "aptly_component", "aptly-component"),
+		AptlyPrefix:                resolveString(cmd, opts.AptlyPrefix, "aptly_prefix", "aptly-prefix"),
+		AptlyEndpoint:              resolveString(cmd, opts.AptlyEndpoint, "aptly_endpoint", "aptly-endpoint"),
+		GpgKey:                     resolveString(cmd, opts.GpgKey, "gpg_key", "gpg-key"),
+		GpgBinary:                  resolveString(cmd, opts.GpgBinary, "gpg_binary", "gpg-binary"),
+		ProGetEndpoint:             resolveString(cmd, opts.ProGetEndpoint, "proget_endpoint", "proget-endpoint"),
+		ProGetFeed:                 resolveString(cmd, opts.ProGetFeed, "proget_feed", "proget-feed"),
+		ProGetComponent:            resolveString(cmd, opts.ProGetComponent, "proget_component", "proget-component"),
+		ProGetUser:                 resolveString(cmd, opts.ProGetUser, "proget_user", "proget-user"),
+		ProGetAPIKey:               resolveString(cmd, opts.ProGetAPIKey, "proget_api_key", "proget-api-key"),
+		ProGetAuthMode:             resolveString(cmd, opts.ProGetAuthMode, "proget_auth_mode", "proget-auth-mode"),
+		ProGetWorkers:              resolveInt(cmd, opts.ProGetWorkers, "proget_workers", "proget-workers"),
+		ProGetTimeoutSec:           resolveInt(cmd, opts.ProGetTimeoutSec, "proget_timeout_sec", "proget-timeout"),
+		ProGetRetries:              resolveInt(cmd, opts.ProGetRetries, "proget_retries", "proget-retries"),
+		ProGetRetryDelayMs:         resolveInt(cmd, opts.ProGetRetryDelayMs, "proget_retry_delay_ms", "proget-retry-delay-ms"),
+		ProGetMaxIdleConnsPerHost:  resolveInt(cmd, opts.ProGetMaxIdleConnsPerHost, "proget_max_idle_conns_per_host", "proget-max-idle-conns-per-host"),
+		ProGetIdleConnTimeoutSec:   resolveInt(cmd, opts.ProGetIdleConnTimeoutSec, "proget_idle_conn_timeout_sec", "proget-idle-conn-timeout"),
+		ProGetDisableHTTP2:         resolveBool(cmd, opts.ProGetDisableHTTP2, "proget_disable_http2", "proget-disable-http2"),
+		ProGetRateLimitBytesPerSec: resolveInt(cmd, opts.ProGetRateLimit, "proget_rate_limit", "proget-rate-limit"),
+		ProGetVerify:               resolveBool(cmd, opts.ProGetVerify, "proget_verify", "proget-verify"),
+		ProGetSkipExisting:         resolveBool(cmd, opts.ProGetSkipExisting, "proget_skip_existing", "proget-skip-existing"),
+		DryRun:                     resolveBool(cmd, opts.DryRun, "publish_dry_run", "dry-run"),
+		Quiet:                      resolveBool(cmd, opts.Quiet, "publish_quiet", "quiet"),
+		UserAgent:                  resolveUserAgent(),
 	})
 	if err != nil {
 		return err
 	}
+	printWarnings(result.Warnings)
+	if len(result.PlannedUploads) > 0 {
+		for _, line := range result.PlannedUploads {
+			fmt.Println(line)
+		}
+		fmt.Printf("dry run: %d uploads planned for snapshot %s\n", len(result.PlannedUploads), result.SnapshotID)
+		return nil
+	}
+	if result.Uploaded > 0 || result.Skipped > 0 {
+		fmt.Printf("uploaded %d, skipped %d\n", result.Uploaded, result.Skipped)
+	}
 	fmt.Printf("published snapshot: %s\n", result.SnapshotID)
 	return nil
 }
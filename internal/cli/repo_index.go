@@ -11,26 +11,40 @@ import (
 )
 
 type repoIndexOptions struct {
-	Output           string
-	AptSources       []string
-	AptEndpoint      string
-	AptDistribution  string
-	AptComponent     string
-	AptArch          string
-	AptUser          string
-	AptAPIKey        string
-	AptWorkers       int
-	PipIndex         string
-	PipUser          string
-	PipAPIKey        string
-	PipPackages      []string
-	PipMax           int
-	PipWorkers       int
-	HTTPTimeoutSec   int
-	HTTPRetries      int
-	HTTPRetryDelayMs int
-	CacheDir         string
-	CacheTTLMinutes  int
+	Output                  string
+	AptSources              []string
+	AptDebDirs              []string
+	AptEndpoint             string
+	AptDistribution         string
+	AptComponent            string
+	AptArch                 string
+	AptUser                 string
+	AptAPIKey               string
+	AptAuthMode             string
+	AptWorkers              int
+	PipIndex                string
+	PipUser                 string
+	PipAPIKey               string
+	PipAuthMode             string
+	PipPackages             []string
+	PipMax                  int
+	PipWorkers              int
+	PipAllowPrerelease      bool
+	PipMetadata             bool
+	HTTPTimeoutSec          int
+	HTTPRetries             int
+	HTTPRetryDelayMs        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeoutSec  int
+	HTTPDisableHTTP2        bool
+	HTTPAuthRedirectHosts   []string
+	HTTPProxy               string
+	HTTPCABundle            string
+	RateLimitBytesPerSec    int
+	CacheDir                string
+	CacheTTLMinutes         int
+	HostCredentials         []string
+	Offline                 bool
 }
 
 func newRepoIndexCommand() *cobra.Command {
@@ -44,46 +58,74 @@ func newRepoIndexCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&opts.Output, "output", "repo-index.yaml", "Output path for repo index YAML")
-	cmd.Flags().StringSliceVar(&opts.AptSources, "apt-source", nil, "APT source entry: endpoint|distribution|component|arch")
+	cmd.Flags().StringSliceVar(&opts.AptSources, "apt-source", nil, "APT source entry: endpoint|distribution|component|arch (endpoint|./ for a flat repository), or a local Packages file path (file:// or plain)")
+	cmd.Flags().StringSliceVar(&opts.AptDebDirs, "apt-deb-dir", nil, "Local directory of .deb files to index from their control files (repeatable)")
 	cmd.Flags().StringVar(&opts.AptEndpoint, "apt-endpoint", "", "APT feed base URL (e.g., https://packages.avular.dev/debian/avular)")
-	cmd.Flags().StringVar(&opts.AptDistribution, "apt-distribution", "", "APT distribution (e.g., dev, staging, snapshot)")
+	cmd.Flags().StringVar(&opts.AptDistribution, "apt-distribution", "", "APT distribution (e.g., dev, staging, snapshot; ./ for a flat repository)")
 	cmd.Flags().StringVar(&opts.AptComponent, "apt-component", "main", "APT component")
 	cmd.Flags().StringVar(&opts.AptArch, "apt-arch", "amd64", "APT architecture")
 	cmd.Flags().StringVar(&opts.AptUser, "apt-user", "", "APT basic auth user (defaults to api)")
 	cmd.Flags().StringVar(&opts.AptAPIKey, "apt-api-key", "", "APT basic auth password/API key")
+	cmd.Flags().StringVar(&opts.AptAuthMode, "apt-auth-mode", "basic", "APT auth mode: basic, or bearer to send the API key as a bearer token")
 	cmd.Flags().IntVar(&opts.AptWorkers, "apt-workers", 4, "Concurrent APT fetch workers (0 = default)")
 	cmd.Flags().StringVar(&opts.PipIndex, "pip-index", "", "PyPI simple index base URL (e.g., https://packages.avular.dev/pypi/avular)")
 	cmd.Flags().StringVar(&opts.PipUser, "pip-user", "", "PyPI basic auth user (defaults to api)")
 	cmd.Flags().StringVar(&opts.PipAPIKey, "pip-api-key", "", "PyPI basic auth password/API key")
+	cmd.Flags().StringVar(&opts.PipAuthMode, "pip-auth-mode", "basic", "PyPI auth mode: basic, or bearer to send the API key as a bearer token")
 	cmd.Flags().StringSliceVar(&opts.PipPackages, "pip-package", nil, "Limit indexing to specified package(s)")
 	cmd.Flags().IntVar(&opts.PipMax, "pip-max", 0, "Maximum number of PyPI packages to index (0 = all)")
 	cmd.Flags().IntVar(&opts.PipWorkers, "pip-workers", 8, "Concurrent PyPI fetch workers (0 = default)")
+	cmd.Flags().BoolVar(&opts.PipAllowPrerelease, "pip-allow-prerelease", false, "Let open pip constraints such as >=1.0 select PEP 440 pre-release and dev versions")
+	cmd.Flags().BoolVar(&opts.PipMetadata, "pip-metadata", false, "Record the Requires-Dist of every pip release from PEP 658 metadata files (used by --pip-sat-solver)")
 	cmd.Flags().IntVar(&opts.HTTPTimeoutSec, "http-timeout", 60, "HTTP timeout in seconds (0 = default)")
 	cmd.Flags().IntVar(&opts.HTTPRetries, "http-retries", 3, "HTTP retries (0 = default)")
 	cmd.Flags().IntVar(&opts.HTTPRetryDelayMs, "http-retry-delay-ms", 200, "HTTP retry base delay in ms (0 = default)")
+	cmd.Flags().IntVar(&opts.HTTPMaxIdleConnsPerHost, "http-max-idle-conns-per-host", 8, "Maximum idle keep-alive connections per host (0 = default)")
+	cmd.Flags().IntVar(&opts.HTTPIdleConnTimeoutSec, "http-idle-conn-timeout", 90, "Idle keep-alive connection timeout in seconds (0 = default)")
+	cmd.Flags().BoolVar(&opts.HTTPDisableHTTP2, "http-disable-http2", false, "Use HTTP/1.1 only for repo-index fetches")
+	cmd.Flags().StringSliceVar(&opts.HTTPAuthRedirectHosts, "http-auth-redirect-host", nil, "Host (and its subdomains) that keeps basic auth when a fetch is redirected to it, e.g. a mirror's CDN (repeatable)")
+	cmd.Flags().StringVar(&opts.HTTPProxy, "http-proxy", "", "Proxy URL for repo-index fetches (defaults to HTTP_PROXY/HTTPS_PROXY)")
+	cmd.Flags().StringVar(&opts.HTTPCABundle, "http-ca-bundle", "", "PEM bundle of extra CA certificates to trust for repo-index fetches")
+	cmd.Flags().IntVar(&opts.RateLimitBytesPerSec, "http-rate-limit", 0, "Download rate limit in bytes per second (0 = unlimited)")
 	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", "", "Optional cache directory for repo-index fetches")
 	cmd.Flags().IntVar(&opts.CacheTTLMinutes, "cache-ttl-minutes", 60, "Cache TTL in minutes (0 = no caching)")
+	cmd.Flags().StringSliceVar(&opts.HostCredentials, "host-credential", nil, "Credentials for one host as host=user:key or host=key, overriding the apt and pip credentials for it (repeatable)")
+	cmd.Flags().BoolVar(&opts.Offline, "offline", false, "Serve every fetch from --cache-dir, whatever its age, and fail on a cache miss instead of using the network")
 
 	_ = viper.BindPFlag("repo_index_output", cmd.Flags().Lookup("output"))
 	_ = viper.BindPFlag("apt_sources", cmd.Flags().Lookup("apt-source"))
+	_ = viper.BindPFlag("apt_deb_dirs", cmd.Flags().Lookup("apt-deb-dir"))
 	_ = viper.BindPFlag("apt_endpoint", cmd.Flags().Lookup("apt-endpoint"))
 	_ = viper.BindPFlag("apt_distribution", cmd.Flags().Lookup("apt-distribution"))
 	_ = viper.BindPFlag("apt_component", cmd.Flags().Lookup("apt-component"))
 	_ = viper.BindPFlag("apt_arch", cmd.Flags().Lookup("apt-arch"))
 	_ = viper.BindPFlag("apt_user", cmd.Flags().Lookup("apt-user"))
 	_ = viper.BindPFlag("apt_api_key", cmd.Flags().Lookup("apt-api-key"))
+	_ = viper.BindPFlag("apt_auth_mode", cmd.Flags().Lookup("apt-auth-mode"))
 	_ = viper.BindPFlag("apt_workers", cmd.Flags().Lookup("apt-workers"))
 	_ = viper.BindPFlag("pip_index", cmd.Flags().Lookup("pip-index"))
 	_ = viper.BindPFlag("pip_user", cmd.Flags().Lookup("pip-user"))
 	_ = viper.BindPFlag("pip_api_key", cmd.Flags().Lookup("pip-api-key"))
+	_ = viper.BindPFlag("pip_auth_mode", cmd.Flags().Lookup("pip-auth-mode"))
 	_ = viper.BindPFlag("pip_packages", cmd.Flags().Lookup("pip-package"))
 	_ = viper.BindPFlag("pip_max", cmd.Flags().Lookup("pip-max"))
 	_ = viper.BindPFlag("pip_workers", cmd.Flags().Lookup("pip-workers"))
+	_ = viper.BindPFlag("pip_allow_prerelease", cmd.Flags().Lookup("pip-allow-prerelease"))
+	_ = viper.BindPFlag("pip_metadata", cmd.Flags().Lookup("pip-metadata"))
 	_ = viper.BindPFlag("http_timeout_sec", cmd.Flags().Lookup("http-timeout"))
 	_ = viper.BindPFlag("http_retries", cmd.Flags().Lookup("http-retries"))
 	_ = viper.BindPFlag("http_retry_delay_ms", cmd.Flags().Lookup("http-retry-delay-ms"))
+	_ = viper.BindPFlag("http_max_idle_conns_per_host", cmd.Flags().Lookup("http-max-idle-conns-per-host"))
+	_ = viper.BindPFlag("http_idle_conn_timeout_sec", cmd.Flags().Lookup("http-idle-conn-timeout"))
+	_ = viper.BindPFlag("http_disable_http2", cmd.Flags().Lookup("http-disable-http2"))
+	_ = viper.BindPFlag("http_auth_redirect_hosts", cmd.Flags().Lookup("http-auth-redirect-host"))
+	_ = viper.BindPFlag("http_proxy", cmd.Flags().Lookup("http-proxy"))
+	_ = viper.BindPFlag("http_ca_bundle", cmd.Flags().Lookup("http-ca-bundle"))
+	_ = viper.BindPFlag("http_rate_limit", cmd.Flags().Lookup("http-rate-limit"))
 	_ = viper.BindPFlag("repo_index_cache_dir", cmd.Flags().Lookup("cache-dir"))
 	_ = viper.BindPFlag("repo_index_cache_ttl_minutes", cmd.Flags().Lookup("cache-ttl-minutes"))
+	_ = viper.BindPFlag("host_credentials", cmd.Flags().Lookup("host-credential"))
+	_ = viper.BindPFlag("offline", cmd.Flags().Lookup("offline"))
 
 	return cmd
 }
@@ -91,26 +133,41 @@ func newRepoIndexCommand() *cobra.Command {
 func runRepoIndex(ctx context.Context, cmd *cobra.Command, opts repoIndexOptions) error {
 	service := newAppService()
 	result, err := service.RepoIndex(ctx, app.RepoIndexRequest{
-		Output:           resolveString(cmd, opts.Output, "repo_index_output", "output"),
-		AptSources:       resolveStrings(cmd, opts.AptSources, "apt_sources", "apt-source"),
-		AptEndpoint:      resolveString(cmd, opts.AptEndpoint, "apt_endpoint", "apt-endpoint"),
-		AptDistribution:  resolveString(cmd, opts.AptDistribution, "apt_distribution", "apt-distribution"),
-		AptComponent:     resolveString(cmd, opts.AptComponent, "apt_component", "apt-component"),
-		AptArch:          resolveString(cmd, opts.AptArch, "apt_arch", "apt-arch"),
-		AptUser:          resolveString(cmd, opts.AptUser, "apt_user", "apt-user"),
-		AptAPIKey:        resolveString(cmd, opts.AptAPIKey, "apt_api_key", "apt-api-key"),
-		AptWorkers:       resolveInt(cmd, opts.AptWorkers, "apt_workers", "apt-workers"),
-		PipIndex:         resolveString(cmd, opts.PipIndex, "pip_index", "pip-index"),
-		PipUser:          resolveString(cmd, opts.PipUser, "pip_user", "pip-user"),
-		PipAPIKey:        resolveString(cmd, opts.PipAPIKey, "pip_api_key", "pip-api-key"),
-		PipPackages:      resolveStrings(cmd, opts.PipPackages, "pip_packages", "pip-package"),
-		PipMax:           resolveInt(cmd, opts.PipMax, "pip_max", "pip-max"),
-		PipWorkers:       resolveInt(cmd, opts.PipWorkers, "pip_workers", "pip-workers"),
-		HTTPTimeoutSec:   resolveInt(cmd, opts.HTTPTimeoutSec, "http_timeout_sec", "http-timeout"),
-		HTTPRetries:      resolveInt(cmd, opts.HTTPRetries, "http_retries", "http-retries"),
-		HTTPRetryDelayMs: resolveInt(cmd, opts.HTTPRetryDelayMs, "http_retry_delay_ms", "http-retry-delay-ms"),
-		CacheDir:         resolveString(cmd, opts.CacheDir, "repo_index_cache_dir", "cache-dir"),
-		CacheTTLMinutes:  resolveInt(cmd, opts.CacheTTLMinutes, "repo_index_cache_ttl_minutes", "cache-ttl-minutes"),
+		Output:                  resolveString(cmd, opts.Output, "repo_index_output", "output"),
+		AptSources:              resolveStrings(cmd, opts.AptSources, "apt_sources", "apt-source"),
+		AptDebDirs:              resolveStrings(cmd, opts.AptDebDirs, "apt_deb_dirs", "apt-deb-dir"),
+		AptEndpoint:             resolveString(cmd, opts.AptEndpoint, "apt_endpoint", "apt-endpoint"),
+		AptDistribution:         resolveString(cmd, opts.AptDistribution, "apt_distribution", "apt-distribution"),
+		AptComponent:            resolveString(cmd, opts.AptComponent, "apt_component", "apt-component"),
+		AptArch:                 resolveString(cmd, opts.AptArch, "apt_arch", "apt-arch"),
+		AptUser:                 resolveString(cmd, opts.AptUser, "apt_user", "apt-user"),
+		AptAPIKey:               resolveString(cmd, opts.AptAPIKey, "apt_api_key", "apt-api-key"),
+		AptAuthMode:             resolveString(cmd, opts.AptAuthMode, "apt_auth_mode", "apt-auth-mode"),
+		AptWorkers:              resolveInt(cmd, opts.AptWorkers, "apt_workers", "apt-workers"),
+		PipIndex:                resolveString(cmd, opts.PipIndex, "pip_index", "pip-index"),
+		PipUser:                 resolveString(cmd, opts.PipUser, "pip_user", "pip-user"),
+		PipAPIKey:               resolveString(cmd, opts.PipAPIKey, "pip_api_key", "pip-api-key"),
+		PipAuthMode:             resolveString(cmd, opts.PipAuthMode, "pip_auth_mode", "pip-auth-mode"),
+		PipPackages:             resolveStrings(cmd, opts.PipPackages, "pip_packages", "pip-package"),
+		PipMax:                  resolveInt(cmd, opts.PipMax, "pip_max", "pip-max"),
+		PipWorkers:              resolveInt(cmd, opts.PipWorkers, "pip_workers", "pip-workers"),
+		PipAllowPrerelease:      resolveBool(cmd, opts.PipAllowPrerelease, "pip_allow_prerelease", "pip-allow-prerelease"),
+		PipMetadata:             resolveBool(cmd, opts.PipMetadata, "pip_metadata", "pip-metadata"),
+		HTTPTimeoutSec:          resolveInt(cmd, opts.HTTPTimeoutSec, "http_timeout_sec", "http-timeout"),
+		HTTPRetries:             resolveInt(cmd, opts.HTTPRetries, "http_retries", "http-retries"),
+		HTTPRetryDelayMs:        resolveInt(cmd, opts.HTTPRetryDelayMs, "http_retry_delay_ms", "http-retry-delay-ms"),
+		HTTPMaxIdleConnsPerHost: resolveInt(cmd, opts.HTTPMaxIdleConnsPerHost, "http_max_idle_conns_per_host", "http-max-idle-conns-per-host"),
+		HTTPIdleConnTimeoutSec:  resolveInt(cmd, opts.HTTPIdleConnTimeoutSec, "http_idle_conn_timeout_sec", "http-idle-conn-timeout"),
+		HTTPDisableHTTP2:        resolveBool(cmd, opts.HTTPDisableHTTP2, "http_disable_http2", "http-disable-http2"),
+		HTTPAuthRedirectHosts:   resolveStrings(cmd, opts.HTTPAuthRedirectHosts, "http_auth_redirect_hosts", "http-auth-redirect-host"),
+		HTTPProxy:               resolveString(cmd, opts.HTTPProxy, "http_proxy", "http-proxy"),
+		HTTPCABundle:            resolveString(cmd, opts.HTTPCABundle, "http_ca_bundle", "http-ca-bundle"),
+		RateLimitBytesPerSec:    resolveInt(cmd, opts.RateLimitBytesPerSec, "http_rate_limit", "http-rate-limit"),
+		CacheDir:                resolveString(cmd, opts.CacheDir, "repo_index_cache_dir", "cache-dir"),
+		CacheTTLMinutes:         resolveInt(cmd, opts.CacheTTLMinutes, "repo_index_cache_ttl_minutes", "cache-ttl-minutes"),
+		UserAgent:               resolveUserAgent(),
+		HostCredentials:         resolveStrings(cmd, opts.HostCredentials, "host_credentials", "host-credential"),
+		Offline:                 resolveBool(cmd, opts.Offline, "offline", "offline"),
 	})
 	if err != nil {
 		return err
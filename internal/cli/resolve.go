@@ -2,32 +2,58 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/ZanzyTHEbar/errbuilder-go"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 
 	"avular-packages/internal/app"
+	"avular-packages/internal/types"
 )
 
 type resolveOptions struct {
-	Product              string
-	Profiles             []string
-	Workspace            []string
-	RepoIndex            string
-	OutputDir            string
-	SnapshotID           string
-	TargetUbuntu         string
-	SchemaFiles          []string
-	CompatGetDeps        bool
-	CompatRosdep         bool
-	AptPreferences       bool
-	AptInstallList       bool
-	SnapshotSources      bool
-	SnapshotAptBaseURL   string
-	SnapshotAptComponent string
-	SnapshotAptArchs     []string
-	AptSatSolver         bool
+	Product                string
+	Profiles               []string
+	Workspace              []string
+	RepoIndex              string
+	OutputDir              string
+	SnapshotID             string
+	TargetUbuntu           string
+	SchemaFiles            []string
+	CompatGetDeps          bool
+	CompatRosdep           bool
+	AptPreferences         bool
+	AptInstallList         bool
+	SnapshotSources        bool
+	SnapshotAptBaseURL     string
+	SnapshotAptComponent   string
+	SnapshotAptArchs       []string
+	AptSatSolver           bool
+	PipSatSolver           bool
+	BasePackages           []string
+	AssumeEssential        bool
+	SolverTimeout          time.Duration
+	AllowedScopes          []string
+	PreferLock             string
+	FailOnDowngrade        bool
+	AllowUnresolved        bool
+	BestEffort             bool
+	NoPip                  bool
+	EmitResolveJSON        bool
+	ReportUnused           bool
+	StrictDirectives       bool
+	Force                  bool
+	Clean                  bool
+	ArchRepoIndexes        []string
+	DumpDeps               string
+	PackageXMLConstraints  string
+	IncludeBuildDeps       bool
+	UnsatCoreMaxIterations int
 }
 
 func newResolveCommand() *cobra.Command {
@@ -56,6 +82,27 @@ func newResolveCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.SnapshotAptComponent, "snapshot-apt-component", "main", "Component for snapshot apt source")
 	cmd.Flags().StringSliceVar(&opts.SnapshotAptArchs, "snapshot-apt-arch", nil, "Optional arch list for snapshot apt source")
 	cmd.Flags().BoolVar(&opts.AptSatSolver, "apt-sat-solver", false, "Resolve apt versions with SAT-based dependency closure")
+	cmd.Flags().BoolVar(&opts.PipSatSolver, "pip-sat-solver", false, "Resolve pip versions with SAT-based Requires-Dist closure")
+	cmd.Flags().StringSliceVar(&opts.BasePackages, "base-package", nil, "Base-system apt packages the SAT solver treats as always installed")
+	cmd.Flags().BoolVar(&opts.AssumeEssential, "assume-essential", false, "Treat Essential and Priority: required apt packages of the repo index as base packages (changes lock contents)")
+	cmd.Flags().DurationVar(&opts.SolverTimeout, "solver-timeout", 0, "Abort an apt SAT solve that takes longer than this (e.g. 5m; 0 = no limit)")
+	cmd.Flags().IntVar(&opts.UnsatCoreMaxIterations, "unsat-core-max-iterations", 0, "Bound the SAT calls spent explaining an unsatisfiable solve (0 = default of 64, negative = no explanation)")
+	cmd.Flags().StringSliceVar(&opts.AllowedScopes, "allowed-scope", nil, "Packaging group scopes this product may contain (runtime, dev, test, doc); other groups are rejected")
+	cmd.Flags().BoolVar(&opts.AllowUnresolved, "allow-unresolved", false, "Let the apt SAT solver drop unsatisfiable root demands and report them instead of failing")
+	cmd.Flags().BoolVar(&opts.BestEffort, "best-effort", false, "Report every dependency that cannot be resolved instead of stopping at the first; exits with code 6 when any remain")
+	cmd.Flags().BoolVar(&opts.NoPip, "no-pip", false, "Resolve apt dependencies only, ignoring all pip inputs")
+	cmd.Flags().BoolVar(&opts.EmitResolveJSON, "json", false, "Also write resolve.json with the snapshot ID, locks, resolved dependencies and resolution records")
+	cmd.Flags().BoolVar(&opts.ReportUnused, "report-unused-directives", false, "Print a hint for resolution directives that were never applied")
+	cmd.Flags().BoolVar(&opts.StrictDirectives, "strict-directives", false, "Fail instead of hinting about directive problems")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Write into a non-empty output directory, overwriting earlier artifacts")
+	cmd.Flags().BoolVar(&opts.Clean, "clean", false, "Remove generated artifacts from the output directory before writing")
+	cmd.Flags().StringVar(&opts.PreferLock, "prefer-lock", "", "Previous apt.lock whose versions the apt SAT solver keeps unless constraints force a change")
+	cmd.Flags().BoolVar(&opts.FailOnDowngrade, "fail-on-downgrade", false, "Fail when a package resolves to a lower version than in the --prefer-lock lock")
+	cmd.Flags().StringVar(&opts.PackageXMLConstraints, "package-xml-constraints", "", "Treat package.xml version constraints as hard (default) or soft minimum hints the resolver may upgrade past")
+	cmd.Flags().BoolVar(&opts.IncludeBuildDeps, "include-build-deps", false, "Also resolve build_depend and test_depend ROS tags, e.g. for a dev or CI image")
+	cmd.Flags().StringSliceVar(&opts.ArchRepoIndexes, "arch-repo-index", nil, "Per-architecture repo index (arch=path) solved together with --apt-sat-solver; repeat for each architecture")
+	cmd.Flags().StringVar(&opts.DumpDeps, "dump-deps", "", "Print the collected dependency set before solving as json or yaml (--dump-deps alone means json) and exit")
+	cmd.Flags().Lookup("dump-deps").NoOptDefVal = "json"
 	cmd.Flags().StringSliceVar(&opts.SchemaFiles, "schema", nil, "Schema mapping file(s) for ROS tag resolution (layered, last wins)")
 
 	_ = viper.BindPFlag("product", cmd.Flags().Lookup("product"))
@@ -74,35 +121,143 @@ func newResolveCommand() *cobra.Command {
 	_ = viper.BindPFlag("snapshot_apt_component", cmd.Flags().Lookup("snapshot-apt-component"))
 	_ = viper.BindPFlag("snapshot_apt_arch", cmd.Flags().Lookup("snapshot-apt-arch"))
 	_ = viper.BindPFlag("apt_sat_solver", cmd.Flags().Lookup("apt-sat-solver"))
+	_ = viper.BindPFlag("report_unused_directives", cmd.Flags().Lookup("report-unused-directives"))
+	_ = viper.BindPFlag("strict_directives", cmd.Flags().Lookup("strict-directives"))
+	_ = viper.BindPFlag("output_force", cmd.Flags().Lookup("force"))
+	_ = viper.BindPFlag("output_clean", cmd.Flags().Lookup("clean"))
+	_ = viper.BindPFlag("pip_sat_solver", cmd.Flags().Lookup("pip-sat-solver"))
+	_ = viper.BindPFlag("base_packages", cmd.Flags().Lookup("base-package"))
+	_ = viper.BindPFlag("assume_essential", cmd.Flags().Lookup("assume-essential"))
+	_ = viper.BindPFlag("solver_timeout", cmd.Flags().Lookup("solver-timeout"))
+	_ = viper.BindPFlag("unsat_core_max_iterations", cmd.Flags().Lookup("unsat-core-max-iterations"))
+	_ = viper.BindPFlag("allowed_scopes", cmd.Flags().Lookup("allowed-scope"))
 	_ = viper.BindPFlag("schema_files", cmd.Flags().Lookup("schema"))
+	_ = viper.BindPFlag("arch_repo_indexes", cmd.Flags().Lookup("arch-repo-index"))
+	_ = viper.BindPFlag("package_xml_constraints", cmd.Flags().Lookup("package-xml-constraints"))
+	_ = viper.BindPFlag("include_build_deps", cmd.Flags().Lookup("include-build-deps"))
+	_ = viper.BindPFlag("prefer_lock", cmd.Flags().Lookup("prefer-lock"))
+	_ = viper.BindPFlag("fail_on_downgrade", cmd.Flags().Lookup("fail-on-downgrade"))
+	_ = viper.BindPFlag("allow_unresolved", cmd.Flags().Lookup("allow-unresolved"))
+	_ = viper.BindPFlag("best_effort", cmd.Flags().Lookup("best-effort"))
+	_ = viper.BindPFlag("no_pip", cmd.Flags().Lookup("no-pip"))
+	_ = viper.BindPFlag("resolve_json", cmd.Flags().Lookup("json"))
+	_ = viper.BindPFlag("resolve_dump_deps", cmd.Flags().Lookup("dump-deps"))
 
 	return cmd
 }
 
 func runResolve(ctx context.Context, cmd *cobra.Command, opts resolveOptions) error {
 	service := newAppService()
-	result, err := service.Resolve(ctx, app.ResolveRequest{
-		ProductPath:          resolveString(cmd, opts.Product, "product", "product"),
-		Profiles:             resolveStrings(cmd, opts.Profiles, "profiles", "profile"),
-		Workspace:            resolveStrings(cmd, opts.Workspace, "workspace", "workspace"),
-		RepoIndex:            resolveString(cmd, opts.RepoIndex, "repo_index", "repo-index"),
-		OutputDir:            resolveString(cmd, opts.OutputDir, "output", "output"),
-		SnapshotID:           resolveString(cmd, opts.SnapshotID, "snapshot_id", "snapshot-id"),
-		TargetUbuntu:         resolveString(cmd, opts.TargetUbuntu, "target_ubuntu", "target-ubuntu"),
-		SchemaFiles:          resolveStrings(cmd, opts.SchemaFiles, "schema_files", "schema"),
-		CompatGet:            resolveBool(cmd, opts.CompatGetDeps, "compat_get_dependencies", "compat-get-dependencies"),
-		CompatRosdep:         resolveBool(cmd, opts.CompatRosdep, "compat_rosdep", "compat-rosdep"),
-		EmitAptPreferences:   resolveBool(cmd, opts.AptPreferences, "apt_preferences", "apt-preferences"),
-		EmitAptInstallList:   resolveBool(cmd, opts.AptInstallList, "apt_install_list", "apt-install-list"),
-		EmitSnapshotSources:  resolveBool(cmd, opts.SnapshotSources, "snapshot_apt_sources", "snapshot-apt-sources"),
-		SnapshotAptBaseURL:   resolveString(cmd, opts.SnapshotAptBaseURL, "snapshot_apt_base_url", "snapshot-apt-base-url"),
-		SnapshotAptComponent: resolveString(cmd, opts.SnapshotAptComponent, "snapshot_apt_component", "snapshot-apt-component"),
-		SnapshotAptArchs:     resolveStrings(cmd, opts.SnapshotAptArchs, "snapshot_apt_arch", "snapshot-apt-arch"),
-		AptSatSolver:         resolveBool(cmd, opts.AptSatSolver, "apt_sat_solver", "apt-sat-solver"),
-	})
+	req := newResolveRequest(cmd, opts)
+	if format := resolveString(cmd, opts.DumpDeps, "resolve_dump_deps", "dump-deps"); format != "" {
+		return runDumpDeps(ctx, service, req, format)
+	}
+	result, err := service.Resolve(ctx, req)
 	if err != nil {
 		return err
 	}
+	printWarnings(result.Warnings)
+	for _, dep := range result.Unresolved {
+		fmt.Printf("unresolved: %s: %s\n", formatDemand(dep.Dependency), dep.Reason)
+	}
 	fmt.Printf("resolved: %s\n", result.ProductName)
+	if resolveBool(cmd, opts.BestEffort, "best_effort", "best-effort") && len(result.Unresolved) > 0 {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("unresolved dependencies: %d left unresolved by best-effort resolve", len(result.Unresolved)))
+	}
 	return nil
 }
+
+// newResolveRequest builds the resolve request from the flags, config
+// file and environment.
+func newResolveRequest(cmd *cobra.Command, opts resolveOptions) app.ResolveRequest {
+	return app.ResolveRequest{
+		ProductPath:            resolveString(cmd, opts.Product, "product", "product"),
+		Profiles:               resolveStrings(cmd, opts.Profiles, "profiles", "profile"),
+		Workspace:              resolveStrings(cmd, opts.Workspace, "workspace", "workspace"),
+		RepoIndex:              resolveString(cmd, opts.RepoIndex, "repo_index", "repo-index"),
+		OutputDir:              resolveString(cmd, opts.OutputDir, "output", "output"),
+		SnapshotID:             resolveString(cmd, opts.SnapshotID, "snapshot_id", "snapshot-id"),
+		TargetUbuntu:           resolveString(cmd, opts.TargetUbuntu, "target_ubuntu", "target-ubuntu"),
+		SchemaFiles:            resolveStrings(cmd, opts.SchemaFiles, "schema_files", "schema"),
+		CompatGet:              resolveBool(cmd, opts.CompatGetDeps, "compat_get_dependencies", "compat-get-dependencies"),
+		CompatRosdep:           resolveBool(cmd, opts.CompatRosdep, "compat_rosdep", "compat-rosdep"),
+		EmitAptPreferences:     resolveBool(cmd, opts.AptPreferences, "apt_preferences", "apt-preferences"),
+		EmitAptInstallList:     resolveBool(cmd, opts.AptInstallList, "apt_install_list", "apt-install-list"),
+		EmitSnapshotSources:    resolveBool(cmd, opts.SnapshotSources, "snapshot_apt_sources", "snapshot-apt-sources"),
+		SnapshotAptBaseURL:     resolveString(cmd, opts.SnapshotAptBaseURL, "snapshot_apt_base_url", "snapshot-apt-base-url"),
+		SnapshotAptComponent:   resolveString(cmd, opts.SnapshotAptComponent, "snapshot_apt_component", "snapshot-apt-component"),
+		SnapshotAptArchs:       resolveStrings(cmd, opts.SnapshotAptArchs, "snapshot_apt_arch", "snapshot-apt-arch"),
+		AptSatSolver:           resolveBool(cmd, opts.AptSatSolver, "apt_sat_solver", "apt-sat-solver"),
+		PipSatSolver:           resolveBool(cmd, opts.PipSatSolver, "pip_sat_solver", "pip-sat-solver"),
+		BasePackages:           resolveStrings(cmd, opts.BasePackages, "base_packages", "base-package"),
+		AssumeEssential:        resolveBool(cmd, opts.AssumeEssential, "assume_essential", "assume-essential"),
+		SolverTimeout:          resolveDuration(cmd, opts.SolverTimeout, "solver_timeout", "solver-timeout"),
+		AllowedScopes:          resolveStrings(cmd, opts.AllowedScopes, "allowed_scopes", "allowed-scope"),
+		ToolVersion:            version,
+		PreferLock:             resolveString(cmd, opts.PreferLock, "prefer_lock", "prefer-lock"),
+		FailOnDowngrade:        resolveBool(cmd, opts.FailOnDowngrade, "fail_on_downgrade", "fail-on-downgrade"),
+		AllowUnresolved:        resolveBool(cmd, opts.AllowUnresolved, "allow_unresolved", "allow-unresolved"),
+		BestEffort:             resolveBool(cmd, opts.BestEffort, "best_effort", "best-effort"),
+		NoPip:                  resolveBool(cmd, opts.NoPip, "no_pip", "no-pip"),
+		EmitResolveJSON:        resolveBool(cmd, opts.EmitResolveJSON, "resolve_json", "json"),
+		ReportUnused:           resolveBool(cmd, opts.ReportUnused, "report_unused_directives", "report-unused-directives"),
+		StrictDirectives:       resolveBool(cmd, opts.StrictDirectives, "strict_directives", "strict-directives"),
+		Force:                  resolveBool(cmd, opts.Force, "output_force", "force"),
+		Clean:                  resolveBool(cmd, opts.Clean, "output_clean", "clean"),
+		ArchRepoIndexes:        resolveStrings(cmd, opts.ArchRepoIndexes, "arch_repo_indexes", "arch-repo-index"),
+		PackageXMLConstraints:  resolveString(cmd, opts.PackageXMLConstraints, "package_xml_constraints", "package-xml-constraints"),
+		IncludeBuildDeps:       resolveBool(cmd, opts.IncludeBuildDeps, "include_build_deps", "include-build-deps"),
+		UnsatCoreMaxIterations: resolveInt(cmd, opts.UnsatCoreMaxIterations, "unsat_core_max_iterations", "unsat-core-max-iterations"),
+	}
+}
+
+// runDumpDeps prints the dependency set collected for req as json or
+// yaml, without resolving it.
+func runDumpDeps(ctx context.Context, service app.Service, req app.ResolveRequest, format string) error {
+	var encode func(any) ([]byte, error)
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "json":
+		encode = func(v any) ([]byte, error) {
+			data, err := json.MarshalIndent(v, "", "  ")
+			return append(data, '\n'), err
+		}
+	case "yaml":
+		encode = yaml.Marshal
+	default:
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("unsupported --dump-deps format %q (expected json or yaml)", format))
+	}
+	result, err := service.DumpDependencies(ctx, req)
+	if err != nil {
+		return err
+	}
+	data, err := encode(result.Dump)
+	if err != nil {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeInternal).
+			WithMsg("failed to encode dependency dump").
+			WithCause(err)
+	}
+	printWarnings(result.Warnings)
+	fmt.Print(string(data))
+	return nil
+}
+
+// formatDemand renders a dependency with its version constraints, e.g.
+// "libfoo (>= 1.0)".
+func formatDemand(dep types.Dependency) string {
+	var parts []string
+	for _, constraint := range dep.Constraints {
+		if constraint.Op == types.ConstraintOpNone || strings.TrimSpace(constraint.Version) == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", constraint.Op, constraint.Version))
+	}
+	if len(parts) == 0 {
+		return dep.Name
+	}
+	return fmt.Sprintf("%s (%s)", dep.Name, strings.Join(parts, ", "))
+}
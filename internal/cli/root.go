@@ -10,6 +10,8 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"avular-packages/internal/shared"
 )
 
 // version is set at build time via ldflags.
@@ -20,6 +22,8 @@ const envPrefix = "AVULAR_PACKAGES"
 type RootConfig struct {
 	ConfigFile string
 	LogLevel   string
+	UserAgent  string
+	Seed       int64
 }
 
 func Execute() {
@@ -40,12 +44,19 @@ func newRootCommand() *cobra.Command {
 				return err
 			}
 			setupLogging(viper.GetString("log_level"))
+			if seed := viper.GetInt64("seed"); seed != 0 {
+				shared.SetRandomSeed(seed)
+			}
 			return nil
 		},
 	}
 	cmd.PersistentFlags().StringVar(&cfg.ConfigFile, "config", "", "Config file path")
 	cmd.PersistentFlags().StringVar(&cfg.LogLevel, "log-level", "info", "Log level")
+	cmd.PersistentFlags().StringVar(&cfg.UserAgent, "user-agent", "", "User-Agent header for outbound HTTP requests (defaults to avular-packages/<version>)")
+	cmd.PersistentFlags().Int64Var(&cfg.Seed, "seed", 0, "Seed for randomized behaviour such as retry jitter (0 = seed from the clock)")
 	_ = viper.BindPFlag("log_level", cmd.PersistentFlags().Lookup("log-level"))
+	_ = viper.BindPFlag("user_agent", cmd.PersistentFlags().Lookup("user-agent"))
+	_ = viper.BindPFlag("seed", cmd.PersistentFlags().Lookup("seed"))
 
 	cmd.AddCommand(newInitCommand())
 	cmd.AddCommand(newValidateCommand())
@@ -56,6 +67,9 @@ func newRootCommand() *cobra.Command {
 	cmd.AddCommand(newInspectCommand())
 	cmd.AddCommand(newRepoIndexCommand())
 	cmd.AddCommand(newPruneCommand())
+	cmd.AddCommand(newGraphCommand())
+	cmd.AddCommand(newDoctorCommand())
+	cmd.AddCommand(newCleanCommand())
 	return cmd
 }
 
@@ -84,6 +98,15 @@ func initConfig(configFile string) error {
 	return nil
 }
 
+// resolveUserAgent returns the configured User-Agent override, or the
+// default product token carrying the build version.
+func resolveUserAgent() string {
+	if value := strings.TrimSpace(viper.GetString("user_agent")); value != "" {
+		return value
+	}
+	return shared.UserAgent(version)
+}
+
 func setupLogging(level string) {
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
 	switch level {
@@ -111,6 +134,9 @@ func exitCodeForError(err error) int {
 		if strings.HasPrefix(message, "no compatible version") {
 			return 4
 		}
+		if strings.HasPrefix(message, "unresolved dependencies") {
+			return 6
+		}
 		return 4
 	case errbuilder.CodePermissionDenied:
 		return 3
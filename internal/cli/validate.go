@@ -12,8 +12,10 @@ import (
 )
 
 type validateOptions struct {
-	Product  string
-	Profiles []string
+	Product          string
+	Profiles         []string
+	StrictDirectives bool
+	AllowedScopes    []string
 }
 
 func newValidateCommand() *cobra.Command {
@@ -28,19 +30,26 @@ func newValidateCommand() *cobra.Command {
 	cmd.Flags().StringVar(&opts.Product, "product", "", "Product spec path")
 	cmd.Flags().StringSliceVar(&opts.Profiles, "profile", nil, "Profile spec paths")
 	_ = viper.BindPFlag("product", cmd.Flags().Lookup("product"))
+	cmd.Flags().BoolVar(&opts.StrictDirectives, "strict-directives", false, "Fail instead of warning about expired resolution directives")
+	cmd.Flags().StringSliceVar(&opts.AllowedScopes, "allowed-scope", nil, "Packaging group scopes this product may contain (runtime, dev, test, doc); other groups are rejected")
 	_ = viper.BindPFlag("profiles", cmd.Flags().Lookup("profile"))
+	_ = viper.BindPFlag("allowed_scopes", cmd.Flags().Lookup("allowed-scope"))
+	_ = viper.BindPFlag("strict_directives", cmd.Flags().Lookup("strict-directives"))
 	return cmd
 }
 
 func runValidate(ctx context.Context, cmd *cobra.Command, opts validateOptions) error {
 	service := newAppService()
 	result, err := service.Validate(ctx, app.ValidateRequest{
-		ProductPath: resolveString(cmd, opts.Product, "product", "product"),
-		Profiles:    resolveStrings(cmd, opts.Profiles, "profiles", "profile"),
+		ProductPath:      resolveString(cmd, opts.Product, "product", "product"),
+		Profiles:         resolveStrings(cmd, opts.Profiles, "profiles", "profile"),
+		StrictDirectives: resolveBool(cmd, opts.StrictDirectives, "strict_directives", "strict-directives"),
+		AllowedScopes:    resolveStrings(cmd, opts.AllowedScopes, "allowed_scopes", "allowed-scope"),
 	})
 	if err != nil {
 		return err
 	}
+	printWarnings(result.Warnings)
 	fmt.Printf("validated: %s\n", result.ProductName)
 	return nil
 }
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/crillab/gophersat/solver"
+
+	"avular-packages/internal/ports"
+	"avular-packages/internal/types"
+)
+
+// BuildAptGraph walks the Depends and Pre-Depends edges the apt solver
+// explores from the root demands and marks the versions it selects.
+// Only versions reachable from a root candidate are included; groups
+// satisfied by a base package are not followed. Unsatisfiable demands
+// still yield the graph, without selection marks and with the unsat core.
+func BuildAptGraph(ctx context.Context, repo ports.RepoIndexPort, deps []types.Dependency, basePackages []string) (types.DependencyGraph, error) {
+	aptPackages, err := repo.AptPackages()
+	if err != nil {
+		return types.DependencyGraph{}, err
+	}
+	if len(aptPackages) == 0 {
+		return types.DependencyGraph{}, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg("dependency graph requires repo index with apt package metadata")
+	}
+	state := newAptSolverState(aptPackages, aptSolverOptions{BasePackages: basePackages})
+	selected, conflict, err := solveAptGraph(ctx, state, deps)
+	if err != nil {
+		return types.DependencyGraph{}, err
+	}
+
+	graph := types.DependencyGraph{Unsatisfiable: selected == nil, Conflict: conflict}
+	roots := map[int]struct{}{}
+	var queue []int
+	for _, dep := range deps {
+		if strings.TrimSpace(dep.Name) == "" || state.isBasePackage(dep.Name) {
+			continue
+		}
+		graph.Roots = append(graph.Roots, dep.Name)
+		ids, err := candidatesForSpec(dep.Name, dep.Constraints, state.nameToVersionID, state.packageVars, state.providers, state.varMeta, state.cache)
+		if err != nil {
+			return types.DependencyGraph{}, err
+		}
+		for _, id := range ids {
+			roots[id] = struct{}{}
+			queue = append(queue, id)
+		}
+	}
+
+	visited := map[int]struct{}{}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if _, seen := visited[id]; seen {
+			continue
+		}
+		visited[id] = struct{}{}
+		if ctx.Err() != nil {
+			return types.DependencyGraph{}, ctx.Err()
+		}
+		meta := state.varMeta[id]
+		key := state.varKey[id]
+		_, isRoot := roots[id]
+		graph.Nodes = append(graph.Nodes, types.DependencyGraphNode{
+			Package:  key.Name,
+			Version:  key.Version,
+			Root:     isRoot,
+			Selected: selected[key.Name] == key.Version,
+		})
+
+		groups := append([]string{}, meta.Depends...)
+		groups = append(groups, meta.PreDepends...)
+		for i, group := range groups {
+			alts := parseAptAlternatives(group)
+			if state.anyBasePackage(alts) {
+				continue
+			}
+			var candidates []int
+			for _, alt := range alts {
+				ids, err := candidatesForSpec(alt.Name, alt.Constraints, state.nameToVersionID, state.packageVars, state.providers, state.varMeta, state.cache)
+				if err != nil {
+					return types.DependencyGraph{}, err
+				}
+				candidates = append(candidates, ids...)
+			}
+			for _, candidate := range uniqueInts(candidates) {
+				target := state.varKey[candidate]
+				graph.Edges = append(graph.Edges, types.DependencyGraphEdge{
+					FromPackage: key.Name,
+					FromVersion: key.Version,
+					ToPackage:   target.Name,
+					ToVersion:   target.Version,
+					Relation:    strings.TrimSpace(group),
+					PreDepends:  i >= len(meta.Depends),
+				})
+				queue = append(queue, candidate)
+			}
+		}
+	}
+	return graph, nil
+}
+
+// solveAptGraph selects the versions the graph marks. An unsatisfiable
+// problem is not an error here: it returns a nil selection and the unsat
+// core, so the graph can show where the demands conflict.
+func solveAptGraph(ctx context.Context, state aptSolverState, deps []types.Dependency) (map[string]string, []string, error) {
+	if len(deps) == 0 {
+		return map[string]string{}, nil, nil
+	}
+	clauses, origins, _, err := buildSolverClauses(state, deps, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	problem := solver.ParseSliceNb(clauses, state.varID)
+	problem.SetCostFunc(state.costLits, state.costWeights)
+	sat := solver.New(problem)
+	cost, err := minimizeSAT(ctx, sat, "apt", state.varID, len(clauses), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cost < 0 {
+		return nil, explainUnsat(ctx, state.varID, clauses, origins, 0), nil
+	}
+	model := sat.Model()
+	selected := map[string]string{}
+	for id, key := range state.varKey {
+		if id-1 < len(model) && model[id-1] {
+			selected[key.Name] = key.Version
+		}
+	}
+	return selected, nil, nil
+}
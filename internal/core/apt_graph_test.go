@@ -0,0 +1,94 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/types"
+)
+
+func TestBuildAptGraphMarksSelectedVersions(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "1.0", Depends: []string{"mail-transport-agent"}, PreDepends: []string{"libc6"}},
+			},
+			"postfix": {
+				{Version: "3.6", Provides: []string{"mail-transport-agent"}},
+			},
+			"libc6":   {{Version: "2.35"}},
+			"unused":  {{Version: "1.0"}},
+			"libfoo":  {{Version: "1.0"}, {Version: "2.0"}},
+			"libuser": {{Version: "1.0", Depends: []string{"libfoo (>= 1.0)"}}},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+		{Name: "libuser", Type: types.DependencyTypeApt},
+	}
+	graph, err := BuildAptGraph(t.Context(), repo, deps, nil)
+	require.NoError(t, err)
+
+	want := types.DependencyGraph{
+		Roots: []string{"app", "libuser"},
+		Nodes: []types.DependencyGraphNode{
+			{Package: "app", Version: "1.0", Root: true, Selected: true},
+			{Package: "libuser", Version: "1.0", Root: true, Selected: true},
+			{Package: "postfix", Version: "3.6", Selected: true},
+			{Package: "libc6", Version: "2.35", Selected: true},
+			{Package: "libfoo", Version: "1.0"},
+			{Package: "libfoo", Version: "2.0", Selected: true},
+		},
+		Edges: []types.DependencyGraphEdge{
+			{FromPackage: "app", FromVersion: "1.0", ToPackage: "postfix", ToVersion: "3.6", Relation: "mail-transport-agent"},
+			{FromPackage: "app", FromVersion: "1.0", ToPackage: "libc6", ToVersion: "2.35", Relation: "libc6", PreDepends: true},
+			{FromPackage: "libuser", FromVersion: "1.0", ToPackage: "libfoo", ToVersion: "1.0", Relation: "libfoo (>= 1.0)"},
+			{FromPackage: "libuser", FromVersion: "1.0", ToPackage: "libfoo", ToVersion: "2.0", Relation: "libfoo (>= 1.0)"},
+		},
+	}
+	if diff := cmp.Diff(want, graph); diff != "" {
+		t.Fatalf("unexpected graph (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildAptGraphSkipsBasePackages(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app":   {{Version: "1.0", Depends: []string{"libc6"}}},
+			"libc6": {{Version: "2.35"}},
+		},
+	}
+	graph, err := BuildAptGraph(t.Context(), repo, []types.Dependency{{Name: "app", Type: types.DependencyTypeApt}}, []string{"libc6"})
+	require.NoError(t, err)
+	if diff := cmp.Diff(0, len(graph.Edges)); diff != "" {
+		t.Fatalf("unexpected edge count (-want +got):\n%s", diff)
+	}
+}
+
+func TestBuildAptGraphRendersUnsatisfiableDemands(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app":    {{Version: "1.0", Depends: []string{"libfoo (>= 2.0)"}}},
+			"libfoo": {{Version: "1.0"}, {Version: "2.0"}},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+		{Name: "libfoo", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libfoo", Op: types.ConstraintOpEq, Version: "1.0"},
+		}},
+	}
+	graph, err := BuildAptGraph(t.Context(), repo, deps, nil)
+	require.NoError(t, err)
+
+	require.True(t, graph.Unsatisfiable)
+	require.NotEmpty(t, graph.Conflict)
+	for _, node := range graph.Nodes {
+		require.False(t, node.Selected, "unexpected selection mark on %s=%s", node.Package, node.Version)
+	}
+	if diff := cmp.Diff(3, len(graph.Nodes)); diff != "" {
+		t.Fatalf("unexpected node count (-want +got):\n%s", diff)
+	}
+}
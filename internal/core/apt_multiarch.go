@@ -0,0 +1,232 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/crillab/gophersat/solver"
+
+	"avular-packages/internal/ports"
+	"avular-packages/internal/types"
+)
+
+// aptArchVar is a solver variable of the combined multi-arch problem:
+// a package version of one architecture.
+type aptArchVar struct {
+	Arch    string
+	Name    string
+	Version string
+}
+
+// solveAptMultiArch solves deps against the apt index of every
+// architecture in archs as one SAT problem and returns the selected
+// versions per architecture. Every architecture gets its own variables,
+// keyed by (name, version, arch), and its own clauses. Ties between
+// architectures are soft: selecting different versions of a package on
+// two architectures whose indexes share a version of it costs more than
+// every version preference together, so packages only split where their
+// versions cannot agree and the remaining ties still hold.
+// AllowUnresolved is not supported across architectures and is
+// rejected.
+func solveAptMultiArch(ctx context.Context, repos map[string]ports.RepoIndexPort, archs []string, deps []types.Dependency, opts aptSolverOptions) (map[string]map[string]string, error) {
+	if opts.AllowUnresolved {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg("unresolved apt demands cannot be allowed when solving several architectures")
+	}
+	selected := map[string]map[string]string{}
+	for _, arch := range archs {
+		selected[arch] = map[string]string{}
+	}
+	if len(deps) == 0 {
+		return selected, nil
+	}
+	var clauses [][]int
+	var origins []aptClauseOrigin
+	var costLits []solver.Lit
+	var costWeights []int
+	vars := map[int]aptArchVar{}
+	archVars := map[string]map[string][]int{}
+	offset := 0
+	for _, arch := range archs {
+		repo, ok := repos[arch]
+		if !ok || repo == nil {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("no repo index for architecture %s", arch))
+		}
+		aptPackages, err := repo.AptPackages()
+		if err != nil {
+			return nil, err
+		}
+		state := newAptSolverState(aptPackages, opts)
+		if state.varID == 0 {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeFailedPrecondition).
+				WithMsg(fmt.Sprintf("apt solver received no package versions to solve for %s", arch))
+		}
+		archClauses, archOrigins, _, err := buildSolverClauses(state, deps, false)
+		if err != nil {
+			return nil, errbuilder.New().
+				WithCode(errbuilder.CodeOf(err)).
+				WithMsg(fmt.Sprintf("%s: %s", arch, err.Error()))
+		}
+		for i, clause := range archClauses {
+			shifted := make([]int, len(clause))
+			for j, lit := range clause {
+				if lit < 0 {
+					shifted[j] = lit - offset
+				} else {
+					shifted[j] = lit + offset
+				}
+			}
+			clauses = append(clauses, shifted)
+			origin := archOrigins[i]
+			origin.Label = arch + ": " + origin.Label
+			origins = append(origins, origin)
+		}
+		for i, lit := range state.costLits {
+			costLits = append(costLits, solver.IntToLit(lit.Int()+int32(offset))) //nolint:gosec // offset is bounded by the number of package versions
+			costWeights = append(costWeights, state.costWeights[i])
+		}
+		archVars[arch] = map[string][]int{}
+		for _, id := range state.sortedVarIDs() {
+			key := state.varKey[id]
+			vars[id+offset] = aptArchVar{Arch: arch, Name: key.Name, Version: key.Version}
+			archVars[arch][key.Name] = append(archVars[arch][key.Name], id+offset)
+		}
+		offset += state.varID
+	}
+
+	ties, mismatches := aptArchTieClauses(archs, archVars, vars, offset)
+	mismatchWeight := 1
+	for _, weight := range costWeights {
+		mismatchWeight += weight
+	}
+	for _, id := range mismatches {
+		costLits = append(costLits, solver.IntToLit(int32(id))) //nolint:gosec // id is bounded by the number of package versions and ties
+		costWeights = append(costWeights, mismatchWeight)
+	}
+	nbVars := offset + len(mismatches)
+	model, ok, err := minimizeAptMultiArch(ctx, nbVars, append(append([][]int(nil), clauses...), ties...), costLits, costWeights, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		msg := "apt solver found no satisfiable solution across architectures " + strings.Join(archs, ", ")
+		if core := explainUnsat(ctx, offset, clauses, origins, opts.UnsatCoreMaxIterations); len(core) > 0 {
+			msg += "; conflict likely involves: " + strings.Join(core, ", ")
+		}
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(msg)
+	}
+	for id, v := range vars {
+		if id-1 < len(model) && model[id-1] {
+			selected[v.Arch][v.Name] = v.Version
+		}
+	}
+	return selected, nil
+}
+
+// aptArchTieClauses ties, for every package that two architectures
+// publish in a common version, the selections of both. Each tie gets a
+// mismatch variable, numbered from after nbVars, and a clause per pair
+// of differing versions that forces it true when the architectures
+// select different versions. Packages without a common version are left
+// untied. It returns the clauses and the mismatch variables.
+func aptArchTieClauses(archs []string, archVars map[string]map[string][]int, vars map[int]aptArchVar, nbVars int) ([][]int, []int) {
+	var clauses [][]int
+	var mismatches []int
+	for i, first := range archs {
+		for _, second := range archs[i+1:] {
+			names := make([]string, 0, len(archVars[first]))
+			for name := range archVars[first] {
+				if _, ok := archVars[second][name]; ok {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if !shareAptVersion(archVars[first][name], archVars[second][name], vars) {
+					continue
+				}
+				mismatch := nbVars + len(mismatches) + 1
+				mismatches = append(mismatches, mismatch)
+				for _, x := range archVars[first][name] {
+					for _, y := range archVars[second][name] {
+						if vars[x].Version != vars[y].Version {
+							clauses = append(clauses, []int{-x, -y, mismatch})
+						}
+					}
+				}
+			}
+		}
+	}
+	return clauses, mismatches
+}
+
+func shareAptVersion(first []int, second []int, vars map[int]aptArchVar) bool {
+	for _, x := range first {
+		for _, y := range second {
+			if vars[x].Version == vars[y].Version {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// minimizeAptMultiArch solves the combined problem with the given costs
+// and returns the model, or false when it is unsatisfiable.
+func minimizeAptMultiArch(ctx context.Context, nbVars int, clauses [][]int, costLits []solver.Lit, costWeights []int, timeout time.Duration) ([]bool, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	problem := solver.ParseSliceNb(clauses, nbVars)
+	problem.SetCostFunc(costLits, costWeights)
+	sat := solver.New(problem)
+	cost, err := minimizeSAT(ctx, sat, "apt", nbVars, len(clauses), timeout)
+	if err != nil || cost < 0 {
+		return nil, false, err
+	}
+	return sat.Model(), true, nil
+}
+
+// multiArchLockEntries turns per-architecture selections into apt.lock
+// entries. A package selected with the same version on every
+// architecture gets one plain entry; otherwise it gets an arch-qualified
+// entry (name:arch) for every architecture it was selected on.
+func multiArchLockEntries(archs []string, selected map[string]map[string]string) map[string]string {
+	names := map[string]struct{}{}
+	for _, arch := range archs {
+		for name := range selected[arch] {
+			names[name] = struct{}{}
+		}
+	}
+	entries := map[string]string{}
+	for name := range names {
+		versions := map[string]struct{}{}
+		present := 0
+		for _, arch := range archs {
+			if version, ok := selected[arch][name]; ok {
+				versions[version] = struct{}{}
+				present++
+			}
+		}
+		if present == len(archs) && len(versions) == 1 {
+			entries[name] = selected[archs[0]][name]
+			continue
+		}
+		for _, arch := range archs {
+			if version, ok := selected[arch][name]; ok {
+				entries[name+":"+arch] = version
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/ports"
+	"avular-packages/internal/types"
+)
+
+func TestSolveAptMultiArchTiesVersions(t *testing.T) {
+	repos := map[string]ports.RepoIndexPort{
+		"amd64": testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "2.0.0", Depends: []string{"libcommon", "libx86-accel"}},
+				{Version: "1.0.0", Depends: []string{"libcommon", "libx86-accel"}},
+			},
+			"libcommon":    {{Version: "1.5"}},
+			"libx86-accel": {{Version: "3.1"}},
+			"libbar":       {{Version: "2.0"}},
+		}},
+		"arm64": testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{
+			"app":       {{Version: "1.0.0", Depends: []string{"libcommon"}}},
+			"libcommon": {{Version: "1.5"}},
+			"libbar":    {{Version: "1.0"}},
+		}},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+		{Name: "libbar", Type: types.DependencyTypeApt},
+	}
+	archs := []string{"amd64", "arm64"}
+
+	selected, err := solveAptMultiArch(context.Background(), repos, archs, deps, aptSolverOptions{})
+	require.NoError(t, err)
+	want := map[string]map[string]string{
+		"amd64": {"app": "1.0.0", "libcommon": "1.5", "libx86-accel": "3.1", "libbar": "2.0"},
+		"arm64": {"app": "1.0.0", "libcommon": "1.5", "libbar": "1.0"},
+	}
+	if diff := cmp.Diff(want, selected); diff != "" {
+		t.Fatalf("unexpected selection (-want +got):\n%s", diff)
+	}
+
+	wantLocks := map[string]string{
+		"app":                "1.0.0",
+		"libcommon":          "1.5",
+		"libx86-accel:amd64": "3.1",
+		"libbar:amd64":       "2.0",
+		"libbar:arm64":       "1.0",
+	}
+	if diff := cmp.Diff(wantLocks, multiArchLockEntries(archs, selected)); diff != "" {
+		t.Fatalf("unexpected lock entries (-want +got):\n%s", diff)
+	}
+}
+
+func TestSolveAptMultiArchKeepsTiesOfAgreeingPackages(t *testing.T) {
+	repos := map[string]ports.RepoIndexPort{
+		"amd64": testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{
+			"app":       {{Version: "1.0.0", Depends: []string{"libfoo (>= 2)"}}},
+			"libfoo":    {{Version: "2"}, {Version: "1"}},
+			"libcommon": {{Version: "2.0"}, {Version: "1.0"}},
+		}},
+		"arm64": testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{
+			"app":       {{Version: "1.0.0", Depends: []string{"libfoo (<< 2)"}}},
+			"libfoo":    {{Version: "2"}, {Version: "1"}},
+			"libcommon": {{Version: "1.0"}},
+		}},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+		{Name: "libcommon", Type: types.DependencyTypeApt},
+	}
+
+	selected, err := solveAptMultiArch(context.Background(), repos, []string{"amd64", "arm64"}, deps, aptSolverOptions{})
+	require.NoError(t, err)
+	// libfoo cannot agree across architectures, which must not release
+	// the tie that keeps libcommon on the version both publish.
+	want := map[string]map[string]string{
+		"amd64": {"app": "1.0.0", "libfoo": "2", "libcommon": "1.0"},
+		"arm64": {"app": "1.0.0", "libfoo": "1", "libcommon": "1.0"},
+	}
+	if diff := cmp.Diff(want, selected); diff != "" {
+		t.Fatalf("unexpected selection (-want +got):\n%s", diff)
+	}
+}
+
+func TestSolveAptMultiArchRequiresIndexPerArch(t *testing.T) {
+	repos := map[string]ports.RepoIndexPort{
+		"amd64": testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{"app": {{Version: "1.0.0"}}}},
+	}
+	deps := []types.Dependency{{Name: "app", Type: types.DependencyTypeApt}}
+
+	_, err := solveAptMultiArch(context.Background(), repos, []string{"amd64", "arm64"}, deps, aptSolverOptions{})
+	require.ErrorContains(t, err, "no repo index for architecture arm64")
+}
+
+func TestSolveAptMultiArchRejectsAllowUnresolved(t *testing.T) {
+	repo := testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{"app": {{Version: "1.0.0"}}}}
+	repos := map[string]ports.RepoIndexPort{"amd64": repo, "arm64": repo}
+	deps := []types.Dependency{{Name: "app", Type: types.DependencyTypeApt}}
+
+	_, err := solveAptMultiArch(context.Background(), repos, []string{"amd64", "arm64"}, deps, aptSolverOptions{AllowUnresolved: true})
+	require.ErrorContains(t, err, "unresolved apt demands cannot be allowed when solving several architectures")
+	require.Equal(t, errbuilder.CodeInvalidArgument, errbuilder.CodeOf(err))
+}
@@ -2,9 +2,11 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
 	"github.com/crillab/gophersat/solver"
@@ -14,9 +16,13 @@ import (
 )
 
 // aptVarKey maps a SAT variable ID back to its package name and version.
+// When the key describes a virtual package provider, ProvidedVersion
+// carries the version declared on the Provides entry (e.g. "2.0" for
+// "Provides: foo (= 2.0)") and is empty for unversioned provides.
 type aptVarKey struct {
-	Name    string
-	Version string
+	Name            string
+	Version         string
+	ProvidedVersion string
 }
 
 // aptDepSpec represents a single parsed APT dependency specification
@@ -26,6 +32,64 @@ type aptDepSpec struct {
 	Constraints []types.Constraint
 }
 
+// aptClauseOrigin records which dependency edge produced a clause so an
+// unsatisfiable problem can be explained in terms of the inputs.
+type aptClauseOrigin struct {
+	Root  bool
+	Label string
+	// Demand is the root dependency behind a Root clause.
+	Demand types.Dependency
+}
+
+// defaultUnsatCoreMaxIterations bounds the number of extra SAT calls
+// spent minimizing an unsatisfiable core.
+const defaultUnsatCoreMaxIterations = 64
+
+// aptSolverOptions carries tunables for a single solver invocation.
+type aptSolverOptions struct {
+	// UnsatCoreMaxIterations caps core minimization (0 = default,
+	// negative = disabled).
+	UnsatCoreMaxIterations int
+	// PreferLock maps package names to previously locked versions. When
+	// set, those versions are cheapest so the solver only moves a package
+	// when a constraint forces it.
+	PreferLock map[string]string
+	// BasePackages names packages that are always installed on the target
+	// (libc6, dpkg, ...). Dependencies on them count as satisfied without
+	// requiring a candidate in the index.
+	BasePackages []string
+	// AssumeEssential adds every Essential or Priority: required package
+	// in the index to BasePackages, as minimal base images ship them.
+	AssumeEssential bool
+	// AllowUnresolved drops root demands that cannot be satisfied instead
+	// of failing the solve; the dropped demands are reported back.
+	AllowUnresolved bool
+	// Blocked names packages excluded by block directives; no version of
+	// them may be selected, even to satisfy another package.
+	Blocked []string
+	// Timeout bounds every minimizeSAT call (0 = no limit beyond ctx).
+	Timeout time.Duration
+}
+
+// aptSolveOutcome is the result of a solver invocation: the selected
+// versions plus, under AllowUnresolved, the root demands left out.
+type aptSolveOutcome struct {
+	Selected   map[string]string
+	Unresolved []types.Dependency
+	// Alternatives lists the `a | b` dependency groups of selected
+	// packages that were satisfied by exactly one of their alternatives.
+	Alternatives []aptAlternativeChoice
+}
+
+// aptAlternativeChoice records which package the solver selected to
+// satisfy a dependency group with more than one alternative.
+type aptAlternativeChoice struct {
+	Package  string
+	Group    string
+	Selected string
+	Version  string
+}
+
 // aptSolverState holds all bookkeeping for one SAT solver invocation.
 // Isolating this avoids passing seven maps through every helper call.
 type aptSolverState struct {
@@ -34,6 +98,8 @@ type aptSolverState struct {
 	varMeta         map[int]types.AptPackageVersion
 	varKey          map[int]aptVarKey
 	providers       map[string][]aptVarKey
+	base            map[string]struct{}
+	blocked         map[string]struct{}
 	cache           *versionCache
 	varID           int
 	costLits        []solver.Lit
@@ -43,38 +109,65 @@ type aptSolverState struct {
 // resolveAptWithSolver uses a SAT solver to select the best compatible set
 // of APT packages for the given dependency list, including transitive
 // dependencies declared in Depends and Pre-Depends fields.
-func resolveAptWithSolver(ctx context.Context, repo ports.RepoIndexPort, deps []types.Dependency) (map[string]string, error) {
+func resolveAptWithSolver(ctx context.Context, repo ports.RepoIndexPort, deps []types.Dependency, opts aptSolverOptions) (map[string]string, error) {
+	outcome, err := solveApt(ctx, repo, deps, opts)
+	if err != nil {
+		return nil, err
+	}
+	return outcome.Selected, nil
+}
+
+// solveApt runs the solver and returns the full outcome, including the
+// root demands dropped when opts.AllowUnresolved is set.
+func solveApt(ctx context.Context, repo ports.RepoIndexPort, deps []types.Dependency, opts aptSolverOptions) (aptSolveOutcome, error) {
 	if len(deps) == 0 {
-		return map[string]string{}, nil
+		return aptSolveOutcome{Selected: map[string]string{}}, nil
 	}
 	aptPackages, err := repo.AptPackages()
 	if err != nil {
-		return nil, err
+		return aptSolveOutcome{}, err
 	}
 	if len(aptPackages) == 0 {
-		return nil, errbuilder.New().
+		return aptSolveOutcome{}, errbuilder.New().
 			WithCode(errbuilder.CodeFailedPrecondition).
 			WithMsg("apt solver requires repo index with apt package metadata")
 	}
 
-	state := buildSolverState(aptPackages)
+	state := newAptSolverState(aptPackages, opts)
 	if state.varID == 0 {
-		return nil, errbuilder.New().
+		return aptSolveOutcome{}, errbuilder.New().
 			WithCode(errbuilder.CodeFailedPrecondition).
 			WithMsg("apt solver received no package versions to solve")
 	}
 
-	clauses, err := buildSolverClauses(state, deps)
+	clauses, origins, unresolved, err := buildSolverClauses(state, deps, opts.AllowUnresolved)
 	if err != nil {
-		return nil, err
+		return aptSolveOutcome{}, err
 	}
 
-	return solveSAT(ctx, state, clauses)
+	outcome, err := solveSAT(ctx, state, clauses, origins, opts)
+	if err != nil {
+		return aptSolveOutcome{}, err
+	}
+	outcome.Unresolved = append(unresolved, outcome.Unresolved...)
+	sort.SliceStable(outcome.Unresolved, func(i, j int) bool {
+		return outcome.Unresolved[i].Name < outcome.Unresolved[j].Name
+	})
+	return outcome, nil
 }
 
 // buildSolverState enumerates every (package, version) pair as a SAT
 // variable and builds lookup indexes for candidates and providers.
-func buildSolverState(aptPackages map[string][]types.AptPackageVersion) aptSolverState {
+// Cost weights prefer newer versions; a version listed in preferLock
+// costs nothing and every other version of that package is shifted up
+// by one, so the locked version wins unless it is infeasible.
+//
+// Packages are numbered in name order and every selected variable adds a
+// unit secondary cost, scaled below the version preference, so among
+// equally new solutions the smallest one wins. Together with the stable
+// variable and clause order this makes repeated solves pick identical
+// versions.
+func buildSolverState(aptPackages map[string][]types.AptPackageVersion, preferLock map[string]string) aptSolverState {
 	s := aptSolverState{
 		nameToVersionID: map[string]map[string]int{},
 		packageVars:     map[string][]int{},
@@ -83,9 +176,10 @@ func buildSolverState(aptPackages map[string][]types.AptPackageVersion) aptSolve
 		cache:           newVersionCache(types.DependencyTypeApt),
 	}
 
-	for name, versions := range aptPackages {
-		ordered := sortAptPackageVersions(versions, s.cache)
+	for _, name := range sortedAptPackageNames(aptPackages) {
+		ordered := sortAptPackageVersions(aptPackages[name], s.cache)
 		ids := make([]int, 0, len(ordered))
+		locked, hasLock := preferLock[name]
 		for i, entry := range ordered {
 			if entry.Version == "" {
 				continue
@@ -100,6 +194,13 @@ func buildSolverState(aptPackages map[string][]types.AptPackageVersion) aptSolve
 			s.varMeta[id] = entry
 			s.varKey[id] = aptVarKey{Name: name, Version: entry.Version}
 			weight := len(ordered) - 1 - i
+			if hasLock {
+				if entry.Version == locked {
+					weight = 0
+				} else {
+					weight++
+				}
+			}
 			s.costLits = append(s.costLits, solver.IntToLit(int32(id))) //nolint:gosec // id is bounded by the number of package versions, well within int32 range
 			s.costWeights = append(s.costWeights, weight)
 		}
@@ -108,94 +209,284 @@ func buildSolverState(aptPackages map[string][]types.AptPackageVersion) aptSolve
 		}
 	}
 	s.providers = buildProvideIndex(aptPackages)
+	scale := len(s.packageVars) + 1
+	for i, weight := range s.costWeights {
+		s.costWeights[i] = weight*scale + 1
+	}
 	return s
 }
 
+// newAptSolverState builds the solver state of aptPackages with the
+// base and blocked packages of opts applied.
+func newAptSolverState(aptPackages map[string][]types.AptPackageVersion, opts aptSolverOptions) aptSolverState {
+	state := buildSolverState(aptPackages, opts.PreferLock)
+	state.base = basePackageSet(opts.BasePackages)
+	if opts.AssumeEssential {
+		for _, name := range essentialAptPackages(aptPackages) {
+			state.base[name] = struct{}{}
+		}
+	}
+	state.blocked = basePackageSet(opts.Blocked)
+	return state
+}
+
+func sortedAptPackageNames(aptPackages map[string][]types.AptPackageVersion) []string {
+	names := make([]string, 0, len(aptPackages))
+	for name := range aptPackages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sortedVarIDs returns the variable IDs of s in ascending order.
+func (s aptSolverState) sortedVarIDs() []int {
+	ids := make([]int, 0, len(s.varMeta))
+	for id := range s.varMeta {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
 // buildSolverClauses generates three kinds of SAT clauses:
-//  1. At-most-one: only one version of each package can be selected.
+//  1. At-most-one: only one version of each package can be selected,
+//     and no version of a blocked package.
 //  2. Root demands: each requested dependency must have at least one candidate.
 //  3. Transitive: if a version is selected its Depends/PreDepends must be satisfiable.
-func buildSolverClauses(s aptSolverState, deps []types.Dependency) ([][]int, error) {
+//  4. Negative: a selected version excludes the versions its Breaks/Conflicts name.
+//
+// The returned origins slice is parallel to the clauses and records
+// which dependency produced each clause. With allowUnresolved, root
+// demands without any candidate are returned as unresolved instead of
+// failing.
+func buildSolverClauses(s aptSolverState, deps []types.Dependency, allowUnresolved bool) ([][]int, []aptClauseOrigin, []types.Dependency, error) {
 	var clauses [][]int
+	var origins []aptClauseOrigin
+	var unresolved []types.Dependency
 
 	// At-most-one per package
-	for _, ids := range s.packageVars {
+	names := make([]string, 0, len(s.packageVars))
+	for name := range s.packageVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ids := s.packageVars[name]
 		for i := 0; i < len(ids); i++ {
 			for j := i + 1; j < len(ids); j++ {
 				clauses = append(clauses, []int{-ids[i], -ids[j]})
+				origins = append(origins, aptClauseOrigin{Label: "single version of " + name})
 			}
 		}
 	}
 
+	// Blocked packages
+	for _, name := range names {
+		if _, ok := s.blocked[name]; !ok {
+			continue
+		}
+		for _, id := range s.packageVars[name] {
+			clauses = append(clauses, []int{-id})
+			origins = append(origins, aptClauseOrigin{Label: "blocked " + name})
+		}
+	}
+
 	// Root dependency demands
 	for _, dep := range deps {
-		if strings.TrimSpace(dep.Name) == "" {
+		if strings.TrimSpace(dep.Name) == "" || s.isBasePackage(dep.Name) {
 			continue
 		}
 		candidates, err := candidatesForSpec(dep.Name, dep.Constraints, s.nameToVersionID, s.packageVars, s.providers, s.varMeta, s.cache)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
+		}
+		if len(candidates) == 0 && allowUnresolved {
+			unresolved = append(unresolved, dep)
+			continue
 		}
 		if len(candidates) == 0 {
-			return nil, errbuilder.New().
+			return nil, nil, nil, errbuilder.New().
 				WithCode(errbuilder.CodeFailedPrecondition).
 				WithMsg(fmt.Sprintf("no apt candidates for %s", dep.Name))
 		}
 		clauses = append(clauses, candidates)
+		origins = append(origins, aptClauseOrigin{Root: true, Label: formatAptDemand(dep.Name, dep.Constraints), Demand: dep})
 	}
 
 	// Transitive dependency clauses
-	transitives, err := buildTransitiveClauses(s)
+	transitives, transitiveOrigins, err := buildTransitiveClauses(s)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 	clauses = append(clauses, transitives...)
-	return clauses, nil
+	origins = append(origins, transitiveOrigins...)
+
+	negatives, negativeOrigins, err := buildNegativeClauses(s)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	clauses = append(clauses, negatives...)
+	origins = append(origins, negativeOrigins...)
+	return clauses, origins, unresolved, nil
+}
+
+// buildNegativeClauses emits a binary exclusion clause for every version
+// named by a Breaks or Conflicts entry. Versioned entries only exclude the
+// versions inside the stated range, so "Breaks: libfoo (<< 2.0)" still
+// allows libfoo 2.0 alongside the breaking package. A package never
+// conflicts with itself, which keeps "Conflicts" on a virtual name it also
+// provides from excluding the package.
+func buildNegativeClauses(s aptSolverState) ([][]int, []aptClauseOrigin, error) {
+	var clauses [][]int
+	var origins []aptClauseOrigin
+	for _, id := range s.sortedVarIDs() {
+		meta := s.varMeta[id]
+		key := s.varKey[id]
+		relations := append([]string{}, meta.Breaks...)
+		relations = append(relations, meta.Conflicts...)
+		for _, relation := range relations {
+			spec := parseAptDepSpec(relation)
+			if spec.Name == "" {
+				continue
+			}
+			ids, err := candidatesForSpec(spec.Name, spec.Constraints, s.nameToVersionID, s.packageVars, s.providers, s.varMeta, s.cache)
+			if err != nil {
+				return nil, nil, err
+			}
+			origin := aptClauseOrigin{Label: fmt.Sprintf("%s=%s conflicts with %s", key.Name, key.Version, strings.TrimSpace(relation))}
+			for _, other := range ids {
+				if s.varKey[other].Name == key.Name {
+					continue
+				}
+				clauses = append(clauses, []int{-id, -other})
+				origins = append(origins, origin)
+			}
+		}
+	}
+	return clauses, origins, nil
 }
 
 // buildTransitiveClauses emits implication clauses for every version's
 // Depends and PreDepends entries: if variable X is true, at least one
 // candidate satisfying its dependency group must also be true.
-func buildTransitiveClauses(s aptSolverState) ([][]int, error) {
+func buildTransitiveClauses(s aptSolverState) ([][]int, []aptClauseOrigin, error) {
 	var clauses [][]int
-	for id, meta := range s.varMeta {
+	var origins []aptClauseOrigin
+	for _, id := range s.sortedVarIDs() {
+		meta := s.varMeta[id]
+		key := s.varKey[id]
 		groups := append([]string{}, meta.Depends...)
 		groups = append(groups, meta.PreDepends...)
 		for _, group := range groups {
 			alts := parseAptAlternatives(group)
+			if s.anyBasePackage(alts) {
+				continue
+			}
 			var candidates []int
 			for _, alt := range alts {
 				ids, err := candidatesForSpec(alt.Name, alt.Constraints, s.nameToVersionID, s.packageVars, s.providers, s.varMeta, s.cache)
 				if err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 				candidates = append(candidates, ids...)
 			}
 			candidates = uniqueInts(candidates)
+			origin := aptClauseOrigin{Label: fmt.Sprintf("%s=%s depends on %s", key.Name, key.Version, strings.TrimSpace(group))}
 			if len(candidates) == 0 {
 				clauses = append(clauses, []int{-id})
+				origins = append(origins, origin)
 				continue
 			}
 			clause := append([]int{-id}, candidates...)
 			clauses = append(clauses, uniqueInts(clause))
+			origins = append(origins, origin)
+		}
+	}
+	return clauses, origins, nil
+}
+
+// basePackageSet normalizes configured apt package names (base or
+// blocked packages) into a set.
+func basePackageSet(names []string) map[string]struct{} {
+	set := map[string]struct{}{}
+	for _, name := range names {
+		if normalized := normalizeAptDepName(name); normalized != "" {
+			set[normalized] = struct{}{}
+		}
+	}
+	return set
+}
+
+// essentialAptPackages returns the sorted names of packages with any
+// version marked Essential: yes or Priority: required.
+func essentialAptPackages(aptPackages map[string][]types.AptPackageVersion) []string {
+	var names []string
+	for _, name := range sortedAptPackageNames(aptPackages) {
+		for _, entry := range aptPackages[name] {
+			if entry.Essential || entry.Priority == "required" {
+				names = append(names, normalizeAptDepName(name))
+				break
+			}
 		}
 	}
-	return clauses, nil
+	return names
+}
+
+func (s aptSolverState) isBasePackage(name string) bool {
+	_, ok := s.base[normalizeAptDepName(name)]
+	return ok
+}
+
+// anyBasePackage reports whether an alternatives group can be satisfied by
+// a base package, in which case the group needs no clause.
+func (s aptSolverState) anyBasePackage(alts []aptDepSpec) bool {
+	for _, alt := range alts {
+		if s.isBasePackage(alt.Name) {
+			return true
+		}
+	}
+	return false
 }
 
 // solveSAT feeds the clauses to gophersat's optimization solver, extracts
 // the selected (name, version) pairs from the model, and returns them.
-func solveSAT(ctx context.Context, s aptSolverState, clauses [][]int) (map[string]string, error) {
+func solveSAT(ctx context.Context, s aptSolverState, clauses [][]int, origins []aptClauseOrigin, opts aptSolverOptions) (aptSolveOutcome, error) {
 	problem := solver.ParseSliceNb(clauses, s.varID)
 	problem.SetCostFunc(s.costLits, s.costWeights)
 	sat := solver.New(problem)
 	if ctx.Err() != nil {
-		return nil, ctx.Err()
+		return aptSolveOutcome{}, ctx.Err()
 	}
-	if cost := sat.Minimize(); cost < 0 {
-		return nil, errbuilder.New().
-			WithCode(errbuilder.CodeFailedPrecondition).
-			WithMsg("apt solver found no satisfiable solution")
+	cost, err := minimizeSAT(ctx, sat, "apt", s.varID, len(clauses), opts.Timeout)
+	if err != nil {
+		return aptSolveOutcome{}, err
+	}
+	var unresolved []types.Dependency
+	if cost < 0 {
+		if !opts.AllowUnresolved {
+			msg := "apt solver found no satisfiable solution"
+			if core := explainUnsat(ctx, s.varID, clauses, origins, opts.UnsatCoreMaxIterations); len(core) > 0 {
+				msg += "; conflict likely involves: " + strings.Join(core, ", ")
+			}
+			return aptSolveOutcome{}, errbuilder.New().
+				WithCode(errbuilder.CodeFailedPrecondition).
+				WithMsg(msg)
+		}
+		var kept [][]int
+		kept, unresolved = relaxRootDemands(ctx, s.varID, clauses, origins)
+		problem = solver.ParseSliceNb(kept, s.varID)
+		problem.SetCostFunc(s.costLits, s.costWeights)
+		sat = solver.New(problem)
+		cost, err = minimizeSAT(ctx, sat, "apt", s.varID, len(kept), opts.Timeout)
+		if err != nil {
+			return aptSolveOutcome{}, err
+		}
+		if cost < 0 {
+			return aptSolveOutcome{}, errbuilder.New().
+				WithCode(errbuilder.CodeFailedPrecondition).
+				WithMsg("apt solver found no satisfiable solution")
+		}
 	}
 	model := sat.Model()
 	selected := map[string]string{}
@@ -208,20 +499,206 @@ func solveSAT(ctx context.Context, s aptSolverState, clauses [][]int) (map[strin
 		}
 		selected[key.Name] = key.Version
 	}
-	if len(selected) == 0 {
-		return nil, errbuilder.New().
+	if len(selected) == 0 && len(unresolved) == 0 {
+		return aptSolveOutcome{}, errbuilder.New().
 			WithCode(errbuilder.CodeFailedPrecondition).
 			WithMsg("apt solver produced empty selection")
 	}
-	return selected, nil
+	alternatives, err := aptAlternativeChoices(s, model)
+	if err != nil {
+		return aptSolveOutcome{}, err
+	}
+	return aptSolveOutcome{Selected: selected, Unresolved: unresolved, Alternatives: alternatives}, nil
+}
+
+// aptAlternativeChoices walks the dependency groups of every selected
+// package and reports the groups with more than one alternative where
+// the model selects candidates of exactly one alternative.
+func aptAlternativeChoices(s aptSolverState, model []bool) ([]aptAlternativeChoice, error) {
+	isSelected := func(id int) bool {
+		return id-1 >= 0 && id-1 < len(model) && model[id-1]
+	}
+	var choices []aptAlternativeChoice
+	for _, id := range s.sortedVarIDs() {
+		if !isSelected(id) {
+			continue
+		}
+		meta := s.varMeta[id]
+		groups := append([]string{}, meta.Depends...)
+		groups = append(groups, meta.PreDepends...)
+		for _, group := range groups {
+			alts := parseAptAlternatives(group)
+			if len(alts) < 2 || s.anyBasePackage(alts) {
+				continue
+			}
+			names := make([]string, 0, len(alts))
+			chosen := -1
+			satisfied := 0
+			for _, alt := range alts {
+				names = append(names, alt.Name)
+				ids, err := candidatesForSpec(alt.Name, alt.Constraints, s.nameToVersionID, s.packageVars, s.providers, s.varMeta, s.cache)
+				if err != nil {
+					return nil, err
+				}
+				for _, candidate := range ids {
+					if isSelected(candidate) {
+						chosen = candidate
+						satisfied++
+						break
+					}
+				}
+			}
+			if satisfied != 1 {
+				continue
+			}
+			choices = append(choices, aptAlternativeChoice{
+				Package:  s.varKey[id].Name,
+				Group:    strings.Join(names, "|"),
+				Selected: s.varKey[chosen].Name,
+				Version:  s.varKey[chosen].Version,
+			})
+		}
+	}
+	return choices, nil
+}
+
+// minimizeSAT runs sat.Optimal in a goroutine and closes its stop
+// channel when ctx is cancelled or timeout (if positive) elapses. It
+// always waits for the search to return before reporting, so no solver
+// goroutine outlives the call. It returns -1 when the problem is
+// unsatisfiable, mirroring sat.Minimize.
+func minimizeSAT(ctx context.Context, sat *solver.Solver, label string, nbVars int, nbClauses int, timeout time.Duration) (int, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	stop := make(chan struct{})
+	done := make(chan solver.Result, 1)
+	go func() {
+		done <- sat.Optimal(nil, stop)
+	}()
+	var res solver.Result
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		close(stop)
+		res = <-done
+	}
+	if err := ctx.Err(); err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return 0, err
+		}
+		return 0, errbuilder.New().
+			WithCode(errbuilder.CodeDeadlineExceeded).
+			WithMsg(fmt.Sprintf("%s solver timed out on a problem with %d variables and %d clauses", label, nbVars, nbClauses)).
+			WithCause(err)
+	}
+	if res.Status == solver.Unsat {
+		return -1, nil
+	}
+	return res.Weight, nil
+}
+
+// relaxRootDemands greedily re-adds root demands, in order, on top of
+// the non-root clauses and drops every demand that would make the
+// problem unsatisfiable. It returns the satisfiable clause set and the
+// dropped demands.
+func relaxRootDemands(ctx context.Context, nbVars int, clauses [][]int, origins []aptClauseOrigin) ([][]int, []types.Dependency) {
+	var kept [][]int
+	var roots []int
+	for i, clause := range clauses {
+		if i < len(origins) && origins[i].Root {
+			roots = append(roots, i)
+			continue
+		}
+		kept = append(kept, clause)
+	}
+	var dropped []types.Dependency
+	for _, idx := range roots {
+		trial := append(append([][]int(nil), kept...), clauses[idx])
+		if ctx.Err() == nil && solver.New(solver.ParseSliceNb(trial, nbVars)).Solve() == solver.Sat {
+			kept = trial
+			continue
+		}
+		dropped = append(dropped, origins[idx].Demand)
+	}
+	return kept, dropped
+}
+
+// explainUnsat runs a deletion-based minimization over the root demand
+// clauses: each root demand is dropped in turn and kept out if the
+// remaining problem is still unsatisfiable. The surviving root demands
+// form a minimal unsatisfiable core whose labels are returned. At most
+// maxIterations SAT calls are spent; when the cap is hit the partially
+// minimized core is returned.
+func explainUnsat(ctx context.Context, nbVars int, clauses [][]int, origins []aptClauseOrigin, maxIterations int) []string {
+	if maxIterations == 0 {
+		maxIterations = defaultUnsatCoreMaxIterations
+	}
+	if maxIterations < 0 {
+		return nil
+	}
+	var hard [][]int
+	var roots []int
+	for i, clause := range clauses {
+		if i < len(origins) && origins[i].Root {
+			roots = append(roots, i)
+			continue
+		}
+		hard = append(hard, clause)
+	}
+	if len(roots) == 0 {
+		return nil
+	}
+	core := append([]int(nil), roots...)
+	for i, iterations := 0, 0; i < len(core) && iterations < maxIterations; iterations++ {
+		if ctx.Err() != nil {
+			break
+		}
+		trial := make([][]int, 0, len(hard)+len(core)-1)
+		trial = append(trial, hard...)
+		for j, idx := range core {
+			if j != i {
+				trial = append(trial, clauses[idx])
+			}
+		}
+		if solver.New(solver.ParseSliceNb(trial, nbVars)).Solve() == solver.Unsat {
+			core = append(core[:i], core[i+1:]...)
+			continue
+		}
+		i++
+	}
+	labels := make([]string, 0, len(core))
+	for _, idx := range core {
+		labels = append(labels, origins[idx].Label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// formatAptDemand renders a dependency and its constraints in Debian
+// relation syntax, e.g. "libfoo (>= 5.0)".
+func formatAptDemand(name string, constraints []types.Constraint) string {
+	var parts []string
+	for _, constraint := range constraints {
+		if constraint.Op == types.ConstraintOpNone || strings.TrimSpace(constraint.Version) == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", constraint.Op, constraint.Version))
+	}
+	if len(parts) == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.Join(parts, ", "))
 }
 
 // buildProvideIndex creates a reverse map from virtual package names to the
 // concrete (package, version) pairs that declare them via Provides fields.
 func buildProvideIndex(aptPackages map[string][]types.AptPackageVersion) map[string][]aptVarKey {
 	out := map[string][]aptVarKey{}
-	for name, versions := range aptPackages {
-		for _, entry := range versions {
+	for _, name := range sortedAptPackageNames(aptPackages) {
+		for _, entry := range aptPackages[name] {
 			if entry.Version == "" {
 				continue
 			}
@@ -230,7 +707,11 @@ func buildProvideIndex(aptPackages map[string][]types.AptPackageVersion) map[str
 				if parsed.Name == "" {
 					continue
 				}
-				out[parsed.Name] = append(out[parsed.Name], aptVarKey{Name: name, Version: entry.Version})
+				key := aptVarKey{Name: name, Version: entry.Version}
+				if len(parsed.Constraints) > 0 && parsed.Constraints[0].Op == types.ConstraintOpEq {
+					key.ProvidedVersion = parsed.Constraints[0].Version
+				}
+				out[parsed.Name] = append(out[parsed.Name], key)
 			}
 		}
 	}
@@ -302,15 +783,41 @@ func parseAptDepSpec(value string) aptDepSpec {
 	}
 }
 
-// normalizeAptDepName strips architecture suffixes (":amd64") and
-// whitespace from a raw APT package name.
+// aptMultiarchQualifiers are the Multi-Arch qualifiers Debian policy
+// allows after a package name in a relationship field.
+var aptMultiarchQualifiers = map[string]struct{}{
+	"any":    {},
+	"native": {},
+}
+
+// aptArchitectures lists the Debian architecture names that may appear as
+// an explicit ":arch" qualifier.
+var aptArchitectures = map[string]struct{}{
+	"all": {}, "amd64": {}, "arm64": {}, "armel": {}, "armhf": {},
+	"i386": {}, "loong64": {}, "mips64el": {}, "mipsel": {}, "ppc64": {},
+	"ppc64el": {}, "riscv64": {}, "s390x": {}, "x32": {},
+}
+
+// normalizeAptDepName strips a trailing architecture (":amd64") or
+// Multi-Arch (":any", ":native") qualifier and whitespace from a raw APT
+// package name. Debian package names cannot contain a colon, so any other
+// suffix is not a qualifier and the name is returned unchanged rather than
+// being silently truncated to a different package.
 func normalizeAptDepName(value string) string {
 	name := strings.TrimSpace(value)
 	if name == "" {
 		return ""
 	}
-	if idx := strings.Index(name, ":"); idx >= 0 {
-		name = strings.TrimSpace(name[:idx])
+	idx := strings.LastIndex(name, ":")
+	if idx < 0 {
+		return name
+	}
+	qualifier := strings.ToLower(strings.TrimSpace(name[idx+1:]))
+	if _, ok := aptMultiarchQualifiers[qualifier]; ok {
+		return strings.TrimSpace(name[:idx])
+	}
+	if _, ok := aptArchitectures[qualifier]; ok {
+		return strings.TrimSpace(name[:idx])
 	}
 	return name
 }
@@ -337,7 +844,9 @@ func aptConstraintOp(token string) (types.ConstraintOp, bool) {
 
 // candidatesForSpec returns the SAT variable IDs of all package versions
 // that satisfy the given name and constraints, including versions from
-// virtual package providers.
+// virtual package providers. Providers are matched on the version they
+// provide rather than their own version; following Debian policy, an
+// unversioned Provides never satisfies a versioned dependency.
 func candidatesForSpec(
 	name string,
 	constraints []types.Constraint,
@@ -370,8 +879,14 @@ func candidatesForSpec(
 			if !ok {
 				continue
 			}
-			meta := varMeta[id]
-			okMatch, err := versionSatisfiesConstraints(meta.Version, constraints, cache)
+			if len(constraints) == 0 {
+				out = append(out, id)
+				continue
+			}
+			if provider.ProvidedVersion == "" {
+				continue
+			}
+			okMatch, err := versionSatisfiesConstraints(provider.ProvidedVersion, constraints, cache)
 			if err != nil {
 				return nil, err
 			}
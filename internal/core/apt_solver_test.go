@@ -2,8 +2,12 @@ package core
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/crillab/gophersat/solver"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -35,6 +39,16 @@ func TestParseAptDepSpec(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:  "multiarch any qualifier with constraint",
+			input: "python3:any (>= 3.10)",
+			expect: aptDepSpec{
+				Name: "python3",
+				Constraints: []types.Constraint{
+					{Name: "python3", Op: types.ConstraintOpGte, Version: "3.10", Source: "apt:dep"},
+				},
+			},
+		},
 		{
 			name:  "with version constraint <<",
 			input: "libfoo (<< 2.0.0)",
@@ -182,6 +196,10 @@ func TestNormalizeAptDepName(t *testing.T) {
 		{"libfoo", "libfoo"},
 		{"libfoo:amd64", "libfoo"},
 		{"libfoo:arm64", "libfoo"},
+		{"python3:any", "python3"},
+		{"perl:native", "perl"},
+		{"libfoo:all", "libfoo"},
+		{"libfoo:weird", "libfoo:weird"},
 		{"  libfoo  ", "libfoo"},
 		{"", ""},
 		{"   ", ""},
@@ -275,8 +293,12 @@ func TestBuildProvideIndex(t *testing.T) {
 	assert.Equal(t, "libfoo", providers["libfoo-compat"][1].Name)
 	assert.Equal(t, "2.0.0", providers["libfoo-compat"][1].Version)
 
-	// libbar-api should have one provider
+	assert.Equal(t, "1.0.0", providers["libfoo-compat"][0].ProvidedVersion)
+	assert.Equal(t, "2.0.0", providers["libfoo-compat"][1].ProvidedVersion)
+
+	// libbar-api should have one unversioned provider
 	assert.Len(t, providers["libbar-api"], 1)
+	assert.Empty(t, providers["libbar-api"][0].ProvidedVersion)
 
 	// libbaz provides nothing
 	_, hasBaz := providers["libbaz"]
@@ -337,7 +359,7 @@ func TestResolveAptWithSolverEmptyDeps(t *testing.T) {
 		},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, nil)
+	result, err := resolveAptWithSolver(context.Background(), repo, nil, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Empty(t, result)
 }
@@ -350,7 +372,7 @@ func TestResolveAptWithSolverEmptyRepo(t *testing.T) {
 		{Name: "libfoo", Type: types.DependencyTypeApt},
 	}
 
-	_, err := resolveAptWithSolver(context.Background(), repo, deps)
+	_, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "apt solver requires repo index")
 }
@@ -368,7 +390,7 @@ func TestResolveAptWithSolverSinglePackage(t *testing.T) {
 		{Name: "libfoo", Type: types.DependencyTypeApt},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, deps)
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Contains(t, result, "libfoo")
 	// SAT solver with cost minimization should prefer the latest version
@@ -395,7 +417,7 @@ func TestResolveAptWithSolverConstrainedVersion(t *testing.T) {
 		},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, deps)
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Equal(t, "2.0.0", result["libfoo"])
 }
@@ -416,7 +438,7 @@ func TestResolveAptWithSolverTransitiveDeps(t *testing.T) {
 		{Name: "app", Type: types.DependencyTypeApt},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, deps)
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Contains(t, result, "app")
 	assert.Contains(t, result, "liba")
@@ -441,7 +463,7 @@ func TestResolveAptWithSolverAlternativeDeps(t *testing.T) {
 		{Name: "app", Type: types.DependencyTypeApt},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, deps)
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Contains(t, result, "app")
 	// At least one of the alternatives must be selected
@@ -462,7 +484,7 @@ func TestResolveAptWithSolverNoCandidate(t *testing.T) {
 		{Name: "missing-pkg", Type: types.DependencyTypeApt},
 	}
 
-	_, err := resolveAptWithSolver(context.Background(), repo, deps)
+	_, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no apt candidates for missing-pkg")
 }
@@ -486,7 +508,7 @@ func TestResolveAptWithSolverUnsatisfiableConstraints(t *testing.T) {
 		},
 	}
 
-	_, err := resolveAptWithSolver(context.Background(), repo, deps)
+	_, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no apt candidates for libfoo")
 }
@@ -506,7 +528,7 @@ func TestResolveAptWithSolverPreDepends(t *testing.T) {
 		{Name: "app", Type: types.DependencyTypeApt},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, deps)
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Contains(t, result, "app")
 	assert.Contains(t, result, "libc")
@@ -527,12 +549,158 @@ func TestResolveAptWithSolverProvidesVirtualPackage(t *testing.T) {
 		{Name: "app", Type: types.DependencyTypeApt},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, deps)
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Contains(t, result, "app")
 	assert.Contains(t, result, "postfix")
 }
 
+func TestResolveAptWithSolverPreferLockKeepsLockedVersion(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "1.0.0", Depends: []string{"liba (>= 1.0.0)"}},
+			},
+			"liba": {
+				{Version: "1.0.0"},
+				{Version: "2.0.0"},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+	}
+
+	unlocked, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0", unlocked["liba"])
+
+	locked, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{
+		PreferLock: map[string]string{"app": "1.0.0", "liba": "1.0.0"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", locked["liba"])
+}
+
+func TestResolveAptWithSolverPreferLockYieldsToConstraints(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"liba": {
+				{Version: "1.0.0"},
+				{Version: "2.0.0"},
+				{Version: "3.0.0"},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{
+			Name: "liba",
+			Type: types.DependencyTypeApt,
+			Constraints: []types.Constraint{
+				{Name: "liba", Op: types.ConstraintOpGte, Version: "2.0.0", Source: "apt:dep"},
+			},
+		},
+	}
+
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{
+		PreferLock: map[string]string{"liba": "1.0.0"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "3.0.0", result["liba"])
+}
+
+func TestResolveAptWithSolverReportsUnsatCore(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"libfoo": {{Version: "1.0.0"}, {Version: "2.0.0"}},
+			"libbar": {{Version: "1.0.0", Depends: []string{"libfoo (<< 2.0)"}}},
+			"libbaz": {{Version: "1.0.0"}},
+		},
+	}
+	deps := []types.Dependency{
+		{
+			Name: "libfoo",
+			Type: types.DependencyTypeApt,
+			Constraints: []types.Constraint{
+				{Name: "libfoo", Op: types.ConstraintOpGte, Version: "2.0", Source: "apt:dep"},
+			},
+		},
+		{Name: "libbar", Type: types.DependencyTypeApt},
+		{Name: "libbaz", Type: types.DependencyTypeApt},
+	}
+
+	_, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "apt solver found no satisfiable solution")
+	assert.Contains(t, err.Error(), "conflict likely involves: libbar, libfoo (>= 2.0)")
+	assert.NotContains(t, err.Error(), "libbaz")
+}
+
+func TestExplainUnsatRespectsIterationCap(t *testing.T) {
+	// Variables 1 and 2 are mutually exclusive; roots demand 1, 2 and 3.
+	clauses := [][]int{{-1, -2}, {1}, {2}, {3}}
+	origins := []aptClauseOrigin{
+		{Label: "single version"},
+		{Root: true, Label: "a"},
+		{Root: true, Label: "b"},
+		{Root: true, Label: "c"},
+	}
+
+	full := explainUnsat(context.Background(), 3, clauses, origins, 0)
+	assert.Equal(t, []string{"a", "b"}, full)
+
+	capped := explainUnsat(context.Background(), 3, clauses, origins, 1)
+	assert.Equal(t, []string{"a", "b", "c"}, capped)
+
+	assert.Nil(t, explainUnsat(context.Background(), 3, clauses, origins, -1))
+}
+
+func TestResolveAptWithSolverVersionedProvides(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "1.0.0", Depends: []string{"mail-transport-agent (>= 2.0)"}},
+			},
+			"oldmta": {
+				{Version: "9.0.0", Provides: []string{"mail-transport-agent (= 1.0)"}},
+			},
+			"newmta": {
+				{Version: "1.0.0", Provides: []string{"mail-transport-agent (= 2.1)"}},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+	}
+
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, result, "newmta")
+	assert.NotContains(t, result, "oldmta")
+}
+
+// The provider's own version (3.6.4) would violate "<< 2.0"; only the
+// provided virtual version (1.0) satisfies both relations.
+func TestResolveAptWithSolverComparesProvidedVersion(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"mailer": {
+				{Version: "1.0.0", Depends: []string{"mail-transport-agent (>= 1.0)", "mail-transport-agent (<< 2.0)"}},
+			},
+			"postfix": {
+				{Version: "3.6.4", Provides: []string{"mail-transport-agent (= 1.0)"}},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "mailer", Type: types.DependencyTypeApt},
+	}
+
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"mailer": "1.0.0", "postfix": "3.6.4"}, result)
+}
+
 func TestResolveAptWithSolverSkipsBlankDepNames(t *testing.T) {
 	repo := testRepoIndex{
 		aptPackages: map[string][]types.AptPackageVersion{
@@ -545,7 +713,7 @@ func TestResolveAptWithSolverSkipsBlankDepNames(t *testing.T) {
 		{Name: "libfoo", Type: types.DependencyTypeApt},
 	}
 
-	result, err := resolveAptWithSolver(context.Background(), repo, deps)
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
 	require.NoError(t, err)
 	assert.Contains(t, result, "libfoo")
 }
@@ -563,7 +731,7 @@ func TestResolveAptWithSolverContextCancelled(t *testing.T) {
 		{Name: "libfoo", Type: types.DependencyTypeApt},
 	}
 
-	_, err := resolveAptWithSolver(ctx, repo, deps)
+	_, err := resolveAptWithSolver(ctx, repo, deps, aptSolverOptions{})
 	require.Error(t, err)
 }
 
@@ -616,4 +784,181 @@ func TestCandidatesForSpec(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, []int{2}, candidates)
 	})
+
+	t.Run("versioned provide matches provided version", func(t *testing.T) {
+		providersWithVirtual := map[string][]aptVarKey{
+			"virtual-pkg": {
+				{Name: "libfoo", Version: "3.0.0", ProvidedVersion: "1.0"},
+				{Name: "libfoo", Version: "1.0.0", ProvidedVersion: "2.0"},
+			},
+		}
+		constraints := []types.Constraint{
+			{Name: "virtual-pkg", Op: types.ConstraintOpGte, Version: "2.0"},
+		}
+		candidates, err := candidatesForSpec("virtual-pkg", constraints, nameToVersionID, packageVars, providersWithVirtual, varMeta, cache)
+		require.NoError(t, err)
+		assert.Equal(t, []int{1}, candidates)
+	})
+
+	t.Run("unversioned provide does not satisfy versioned dependency", func(t *testing.T) {
+		providersWithVirtual := map[string][]aptVarKey{
+			"virtual-pkg": {{Name: "libfoo", Version: "3.0.0"}},
+		}
+		constraints := []types.Constraint{
+			{Name: "virtual-pkg", Op: types.ConstraintOpGte, Version: "1.0"},
+		}
+		candidates, err := candidatesForSpec("virtual-pkg", constraints, nameToVersionID, packageVars, providersWithVirtual, varMeta, cache)
+		require.NoError(t, err)
+		assert.Empty(t, candidates)
+	})
+}
+
+func TestResolveAptWithSolverVersionedBreaksExcludesOnlyRange(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "1.0.0", Depends: []string{"libfoo"}, Breaks: []string{"libfoo (<< 2.0)"}},
+			},
+			"libfoo": {
+				{Version: "1.5"},
+				{Version: "2.0"},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+	}
+
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{PreferLock: map[string]string{"libfoo": "1.5"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "1.0.0", "libfoo": "2.0"}, result)
+
+	deps = append(deps, types.Dependency{
+		Name: "libfoo", Type: types.DependencyTypeApt,
+		Constraints: []types.Constraint{{Name: "libfoo", Op: types.ConstraintOpLt, Version: "2.0"}},
+	})
+	_, err = resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no satisfiable solution")
+}
+
+func TestResolveAptWithSolverConflictsAllowsSelfProvide(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"postfix": {
+				{Version: "3.6.4", Provides: []string{"mail-transport-agent"}, Conflicts: []string{"mail-transport-agent"}},
+			},
+			"exim4": {
+				{Version: "4.95", Provides: []string{"mail-transport-agent"}, Conflicts: []string{"mail-transport-agent"}},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "postfix", Type: types.DependencyTypeApt},
+	}
+
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"postfix": "3.6.4"}, result)
+
+	deps = append(deps, types.Dependency{Name: "exim4", Type: types.DependencyTypeApt})
+	_, err = resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.Error(t, err)
+}
+
+func TestResolveAptWithSolverBasePackagesArePreSatisfied(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "1.0.0", Depends: []string{"libc6 (>= 2.35)", "libfoo"}, PreDepends: []string{"dpkg:any (>= 1.19)"}},
+			},
+			"libfoo": {
+				{Version: "2.0", Depends: []string{"libc6"}},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+		{Name: "libc6", Type: types.DependencyTypeApt},
+	}
+
+	_, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.Error(t, err)
+
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{BasePackages: []string{"libc6", "dpkg"}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "1.0.0", "libfoo": "2.0"}, result)
+}
+
+func TestResolveAptWithSolverAssumeEssential(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "1.0.0", Depends: []string{"base-files (>= 12)", "coreutils", "libfoo"}},
+			},
+			"base-files": {{Version: "12ubuntu4", Essential: true, Priority: "required"}},
+			"coreutils":  {{Version: "8.32", Priority: "required"}},
+			"libfoo":     {{Version: "2.0", Priority: "optional"}},
+		},
+	}
+	deps := []types.Dependency{{Name: "app", Type: types.DependencyTypeApt}}
+
+	result, err := resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "1.0.0", "base-files": "12ubuntu4", "coreutils": "8.32", "libfoo": "2.0"}, result)
+
+	result, err = resolveAptWithSolver(context.Background(), repo, deps, aptSolverOptions{AssumeEssential: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"app": "1.0.0", "libfoo": "2.0"}, result)
+	assert.Equal(t, []string{"base-files", "coreutils"}, essentialAptPackages(repo.aptPackages))
+}
+
+func TestSolveAptAllowUnresolvedReturnsPartialSelection(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"libfoo": {{Version: "1.0.0"}},
+			"app":    {{Version: "2.0.0", Depends: []string{"libmissing"}}},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "libfoo", Type: types.DependencyTypeApt},
+		{Name: "app", Type: types.DependencyTypeApt},
+		{Name: "ghost", Type: types.DependencyTypeApt},
+	}
+
+	_, err := solveApt(context.Background(), repo, deps, aptSolverOptions{})
+	require.Error(t, err)
+
+	outcome, err := solveApt(context.Background(), repo, deps, aptSolverOptions{AllowUnresolved: true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"libfoo": "1.0.0"}, outcome.Selected)
+	assert.Equal(t, []types.Dependency{deps[1], deps[2]}, outcome.Unresolved)
+}
+
+func TestMinimizeSATTimesOut(t *testing.T) {
+	// Pigeonhole: 7 pigeons into 6 holes is unsatisfiable and cannot be
+	// refuted within a nanosecond; minimizeSAT still waits for the search
+	// before reporting the timeout, so no solver goroutine is leaked.
+	const pigeons, holes = 7, 6
+	v := func(p, h int) int { return p*holes + h + 1 }
+	var clauses [][]int
+	for p := 0; p < pigeons; p++ {
+		clause := make([]int, 0, holes)
+		for h := 0; h < holes; h++ {
+			clause = append(clause, v(p, h))
+		}
+		clauses = append(clauses, clause)
+	}
+	for h := 0; h < holes; h++ {
+		for p := 0; p < pigeons; p++ {
+			for q := p + 1; q < pigeons; q++ {
+				clauses = append(clauses, []int{-v(p, h), -v(q, h)})
+			}
+		}
+	}
+	sat := solver.New(solver.ParseSliceNb(clauses, pigeons*holes))
+
+	_, err := minimizeSAT(context.Background(), sat, "apt", pigeons*holes, len(clauses), time.Nanosecond)
+	require.ErrorContains(t, err, fmt.Sprintf("apt solver timed out on a problem with %d variables and %d clauses", pigeons*holes, len(clauses)))
+	assert.Equal(t, errbuilder.CodeDeadlineExceeded, errbuilder.CodeOf(err))
 }
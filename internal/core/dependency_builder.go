@@ -16,6 +16,12 @@ type DependencyBuilder struct {
 	Workspace      ports.WorkspacePort
 	PackageXML     ports.PackageXMLPort
 	SchemaResolver ports.SchemaResolverPort
+	OnUnknownKeys  func(keys []string)
+	Target         string
+	// IncludeBuildDeps resolves build- and test-only ROS tags too, for
+	// dev and CI images; by default only tags needed at runtime (exec)
+	// are resolved.
+	IncludeBuildDeps bool
 }
 
 func NewDependencyBuilder(workspace ports.WorkspacePort, pkgXML ports.PackageXMLPort) DependencyBuilder {
@@ -25,6 +31,13 @@ func NewDependencyBuilder(workspace ports.WorkspacePort, pkgXML ports.PackageXML
 	}
 }
 
+// WithUnknownKeysHandler registers fn to receive the ROS tag keys that
+// have no schema mapping and are skipped.
+func (b DependencyBuilder) WithUnknownKeysHandler(fn func(keys []string)) DependencyBuilder {
+	b.OnUnknownKeys = fn
+	return b
+}
+
 // WithSchemaResolver attaches a schema resolver for mapping standard ROS
 // tags to concrete typed dependencies.
 func (b DependencyBuilder) WithSchemaResolver(sr ports.SchemaResolverPort) DependencyBuilder {
@@ -32,6 +45,20 @@ func (b DependencyBuilder) WithSchemaResolver(sr ports.SchemaResolverPort) Depen
 	return b
 }
 
+// WithTarget sets the target Ubuntu release that selects per-target
+// schema mapping overrides.
+func (b DependencyBuilder) WithTarget(target string) DependencyBuilder {
+	b.Target = target
+	return b
+}
+
+// WithBuildDeps makes the builder resolve build_depend and test_depend
+// ROS tags in addition to the runtime ones.
+func (b DependencyBuilder) WithBuildDeps(include bool) DependencyBuilder {
+	b.IncludeBuildDeps = include
+	return b
+}
+
 func (b DependencyBuilder) Build(ctx context.Context, inputs types.Inputs, workspaceRoots []string) ([]types.Dependency, error) {
 	return b.BuildWithSchema(ctx, inputs, workspaceRoots, nil)
 }
@@ -243,6 +270,9 @@ func (b DependencyBuilder) resolveROSTags(ctx context.Context, packageXMLPaths [
 	if err != nil {
 		return nil, err
 	}
+	if !b.IncludeBuildDeps {
+		rosTags = runtimeROSTags(rosTags)
+	}
 	if len(rosTags) == 0 {
 		return nil, nil
 	}
@@ -258,6 +288,7 @@ func (b DependencyBuilder) resolveROSTags(ctx context.Context, packageXMLPaths [
 	}
 
 	// Resolve through schema
+	b.SchemaResolver.SetTarget(b.Target)
 	resolved, unknown, err := b.SchemaResolver.ResolveAll(rosTags)
 	if err != nil {
 		return nil, err
@@ -268,6 +299,9 @@ func (b DependencyBuilder) resolveROSTags(ctx context.Context, packageXMLPaths [
 			Strs("keys", unknown).
 			Int("count", len(unknown)).
 			Msg("ROS tag keys not found in schema (skipped)")
+		if b.OnUnknownKeys != nil {
+			b.OnUnknownKeys(unknown)
+		}
 	}
 
 	log.Ctx(ctx).Debug().
@@ -296,6 +330,18 @@ func buildIgnoreSet(packageNames []string, prefix string) map[string]struct{} {
 	return ignore
 }
 
+// runtimeROSTags keeps the ROS tags needed at runtime, dropping
+// build- and test-only ones.
+func runtimeROSTags(tags []types.ROSTagDependency) []types.ROSTagDependency {
+	var runtime []types.ROSTagDependency
+	for _, tag := range tags {
+		if tag.Scope.Includes(types.ROSDepScopeExec) {
+			runtime = append(runtime, tag)
+		}
+	}
+	return runtime
+}
+
 // filterROSTags removes ROS tags whose keys match workspace-internal
 // package names.
 func filterROSTags(tags []types.ROSTagDependency, ignore map[string]struct{}) []types.ROSTagDependency {
@@ -346,6 +392,9 @@ func filterWorkspaceDeps(deps []string, workspaceNames []string, prefix string)
 	var filtered []string
 	for _, dep := range deps {
 		name := strings.TrimSpace(dep)
+		if constraint, err := ParseConstraint(dep, ""); err == nil {
+			name = constraint.Name
+		}
 		if _, ok := ignore[name]; ok {
 			continue
 		}
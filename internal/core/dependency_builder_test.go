@@ -87,6 +87,13 @@ func TestDependencyBuilderNormalizesPipNames(t *testing.T) {
 			wantType:     types.DependencyTypePip,
 			wantDepCount: 1,
 		},
+		{
+			name:         "separator runs collapse",
+			raw:          "Foo.__Bar==1.0.0",
+			wantName:     "foo-bar",
+			wantType:     types.DependencyTypePip,
+			wantDepCount: 1,
+		},
 	}
 
 	for _, tt := range tests {
@@ -113,3 +120,148 @@ func TestDependencyBuilderNormalizesPipNames(t *testing.T) {
 		})
 	}
 }
+
+func TestDependencyBuilderSchemaTargetOverride(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "package.xml"), []byte(`<?xml version="1.0"?>
+<package format="3">
+  <name>sample_pkg</name>
+  <version>0.1.0</version>
+  <description>Sample</description>
+  <maintainer email="dev@example.com">Dev</maintainer>
+  <license>MIT</license>
+  <depend>rclcpp</depend>
+</package>
+`), 0644))
+	inputs := types.Inputs{
+		PackageXML: types.PackageXMLInput{Enabled: true, Tags: []string{"debian_depend"}},
+	}
+	schema := types.SchemaFile{
+		SchemaVersion: "v1",
+		Mappings: map[string]types.SchemaMapping{
+			"rclcpp": {
+				Type:    types.DependencyTypeApt,
+				Package: "ros-rolling-rclcpp",
+				Targets: map[string]types.SchemaTargetMapping{
+					"ubuntu-22.04": {Package: "ros-humble-rclcpp"},
+					"ubuntu-24.04": {Package: "ros-jazzy-rclcpp"},
+				},
+			},
+		},
+	}
+
+	builder := NewDependencyBuilder(adapters.NewWorkspaceAdapter(), adapters.NewPackageXMLAdapter()).
+		WithSchemaResolver(adapters.NewSchemaResolverAdapter()).
+		WithTarget("24.04")
+	deps, err := builder.BuildWithSchema(t.Context(), inputs, []string{ws}, &schema)
+	require.NoError(t, err)
+
+	var names []string
+	for _, dep := range deps {
+		names = append(names, dep.Name)
+	}
+	if diff := cmp.Diff([]string{"ros-jazzy-rclcpp"}, names); diff != "" {
+		t.Fatalf("unexpected dependency names (-want +got):\n%s", diff)
+	}
+}
+
+func TestDependencyBuilderIncludesBuildDepsOnlyWhenEnabled(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "package.xml"), []byte(`<?xml version="1.0"?>
+<package format="3">
+  <name>sample_pkg</name>
+  <version>0.1.0</version>
+  <description>Sample</description>
+  <maintainer email="dev@example.com">Dev</maintainer>
+  <license>MIT</license>
+  <depend>rclcpp</depend>
+  <exec_depend>fmt</exec_depend>
+  <build_depend>ament_cmake</build_depend>
+  <test_depend>gtest</test_depend>
+</package>
+`), 0644))
+	inputs := types.Inputs{
+		PackageXML: types.PackageXMLInput{Enabled: true, Tags: []string{"debian_depend"}},
+	}
+	schema := types.SchemaFile{
+		SchemaVersion: "v1",
+		Mappings: map[string]types.SchemaMapping{
+			"rclcpp":      {Type: types.DependencyTypeApt, Package: "ros-jazzy-rclcpp"},
+			"fmt":         {Type: types.DependencyTypeApt, Package: "libfmt-dev"},
+			"ament_cmake": {Type: types.DependencyTypeApt, Package: "ros-jazzy-ament-cmake"},
+			"gtest":       {Type: types.DependencyTypeApt, Package: "libgtest-dev"},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		includeBuildDeps bool
+		want             []string
+	}{
+		{name: "runtime", want: []string{"libfmt-dev", "ros-jazzy-rclcpp"}},
+		{name: "dev", includeBuildDeps: true, want: []string{"libfmt-dev", "libgtest-dev", "ros-jazzy-ament-cmake", "ros-jazzy-rclcpp"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := NewDependencyBuilder(adapters.NewWorkspaceAdapter(), adapters.NewPackageXMLAdapter()).
+				WithSchemaResolver(adapters.NewSchemaResolverAdapter()).
+				WithBuildDeps(tt.includeBuildDeps)
+			deps, err := builder.BuildWithSchema(t.Context(), inputs, []string{ws}, &schema)
+			require.NoError(t, err)
+
+			var names []string
+			for _, dep := range deps {
+				names = append(names, dep.Name)
+			}
+			sort.Strings(names)
+			if diff := cmp.Diff(tt.want, names); diff != "" {
+				t.Fatalf("unexpected dependency names (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDependencyBuilderRuntimeDefaultKeepsFormat2DependAndBuildExport(t *testing.T) {
+	ws := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(ws, "package.xml"), []byte(`<?xml version="1.0"?>
+<package format="2">
+  <name>sample_pkg</name>
+  <version>0.1.0</version>
+  <description>Sample</description>
+  <maintainer email="dev@example.com">Dev</maintainer>
+  <license>MIT</license>
+  <depend>rclcpp</depend>
+  <build_export_depend>eigen</build_export_depend>
+  <build_depend>ament_cmake</build_depend>
+  <test_depend>gtest</test_depend>
+</package>
+`), 0644))
+	inputs := types.Inputs{
+		PackageXML: types.PackageXMLInput{Enabled: true, Tags: []string{"debian_depend"}},
+	}
+	schema := types.SchemaFile{
+		SchemaVersion: "v1",
+		Mappings: map[string]types.SchemaMapping{
+			"rclcpp":      {Type: types.DependencyTypeApt, Package: "ros-jazzy-rclcpp"},
+			"eigen":       {Type: types.DependencyTypeApt, Package: "libeigen3-dev"},
+			"ament_cmake": {Type: types.DependencyTypeApt, Package: "ros-jazzy-ament-cmake"},
+			"gtest":       {Type: types.DependencyTypeApt, Package: "libgtest-dev"},
+		},
+	}
+
+	// The default is runtime-only: <depend> and <build_export_depend>
+	// stay, build_depend and test_depend need IncludeBuildDeps.
+	builder := NewDependencyBuilder(adapters.NewWorkspaceAdapter(), adapters.NewPackageXMLAdapter()).
+		WithSchemaResolver(adapters.NewSchemaResolverAdapter())
+	deps, err := builder.BuildWithSchema(t.Context(), inputs, []string{ws}, &schema)
+	require.NoError(t, err)
+
+	var names []string
+	for _, dep := range deps {
+		names = append(names, dep.Name)
+	}
+	sort.Strings(names)
+	if diff := cmp.Diff([]string{"libeigen3-dev", "ros-jazzy-rclcpp"}, names); diff != "" {
+		t.Fatalf("unexpected dependency names (-want +got):\n%s", diff)
+	}
+}
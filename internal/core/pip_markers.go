@@ -0,0 +1,316 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	pep440 "github.com/aquasecurity/go-pep440-version"
+)
+
+// PipEnvironment is the target interpreter the PEP 508 environment
+// markers of Requires-Dist entries are evaluated against, on CPython
+// for Linux. Variables without a value (an empty PythonVersion, the
+// platform_machine, ...) are unknown, and a requirement whose marker
+// cannot be decided without them is kept.
+type PipEnvironment struct {
+	// PythonVersion is the target python3 minor version, e.g. 3.12.
+	PythonVersion string
+}
+
+// pipMarkerVariables lists the PEP 508 marker variables; a variable
+// without a value in the environment is unknown.
+var pipMarkerVariables = map[string]struct{}{
+	"os_name":                        {},
+	"sys_platform":                   {},
+	"platform_machine":               {},
+	"platform_python_implementation": {},
+	"platform_release":               {},
+	"platform_system":                {},
+	"platform_version":               {},
+	"python_version":                 {},
+	"python_full_version":            {},
+	"implementation_name":            {},
+	"implementation_version":         {},
+	"extra":                          {},
+}
+
+// markerValues returns the known marker variables of a CPython on
+// Linux target. No extras are requested, so "extra" is empty.
+func (e PipEnvironment) markerValues() map[string]string {
+	values := map[string]string{
+		"os_name":                        "posix",
+		"sys_platform":                   "linux",
+		"platform_system":                "Linux",
+		"platform_python_implementation": "CPython",
+		"implementation_name":            "cpython",
+		"extra":                          "",
+	}
+	if version := strings.TrimSpace(e.PythonVersion); version != "" {
+		values["python_version"] = version
+	}
+	return values
+}
+
+// markerResult is the three-valued outcome of a marker: a comparison
+// on an unknown variable is unknown, and and/or follow Kleene logic.
+type markerResult int
+
+const (
+	markerFalse markerResult = iota
+	markerTrue
+	markerUnknown
+)
+
+// evaluatePipMarker reports whether a PEP 508 marker such as
+// `python_version >= "3.8" and extra == "test"` holds in env. A marker
+// that stays undecided because of unknown variables holds.
+func evaluatePipMarker(marker string, env PipEnvironment) (bool, error) {
+	tokens, err := tokenizePipMarker(marker)
+	if err != nil {
+		return false, err
+	}
+	parser := markerParser{marker: marker, tokens: tokens, values: env.markerValues()}
+	result, err := parser.parseOr()
+	if err != nil {
+		return false, err
+	}
+	if parser.pos != len(parser.tokens) {
+		return false, parser.invalid()
+	}
+	return result != markerFalse, nil
+}
+
+type markerToken struct {
+	text   string
+	quoted bool
+}
+
+// markerComparisons lists the comparison operators, longest first so
+// that tokenizing never splits one.
+var markerComparisons = []string{"===", "==", "!=", "<=", ">=", "~=", "<", ">"}
+
+// markerSymbols are the operator tokens of a marker.
+var markerSymbols = append(append([]string(nil), markerComparisons...), "(", ")")
+
+func tokenizePipMarker(marker string) ([]markerToken, error) {
+	var tokens []markerToken
+	rest := marker
+	for {
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" {
+			return tokens, nil
+		}
+		if quote := rest[0]; quote == '"' || quote == '\'' {
+			end := strings.IndexByte(rest[1:], quote)
+			if end < 0 {
+				return nil, invalidPipMarker(marker)
+			}
+			tokens = append(tokens, markerToken{text: rest[1 : end+1], quoted: true})
+			rest = rest[end+2:]
+			continue
+		}
+		matched := false
+		for _, op := range markerSymbols {
+			if strings.HasPrefix(rest, op) {
+				tokens = append(tokens, markerToken{text: op})
+				rest = rest[len(op):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '.')
+		})
+		if end == 0 {
+			return nil, invalidPipMarker(marker)
+		}
+		if end < 0 {
+			end = len(rest)
+		}
+		tokens = append(tokens, markerToken{text: rest[:end]})
+		rest = rest[end:]
+	}
+}
+
+// markerParser evaluates a tokenized marker by recursive descent over
+// the PEP 508 grammar: or-expressions of and-expressions of
+// comparisons or parenthesized markers.
+type markerParser struct {
+	marker string
+	tokens []markerToken
+	pos    int
+	values map[string]string
+}
+
+func (p *markerParser) parseOr() (markerResult, error) {
+	result, err := p.parseAnd()
+	if err != nil {
+		return 0, err
+	}
+	for p.accept("or") {
+		next, err := p.parseAnd()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case result == markerTrue || next == markerTrue:
+			result = markerTrue
+		case result == markerUnknown || next == markerUnknown:
+			result = markerUnknown
+		}
+	}
+	return result, nil
+}
+
+func (p *markerParser) parseAnd() (markerResult, error) {
+	result, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	for p.accept("and") {
+		next, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case result == markerFalse || next == markerFalse:
+			result = markerFalse
+		case result == markerUnknown || next == markerUnknown:
+			result = markerUnknown
+		}
+	}
+	return result, nil
+}
+
+func (p *markerParser) parseExpr() (markerResult, error) {
+	if p.accept("(") {
+		result, err := p.parseOr()
+		if err != nil {
+			return 0, err
+		}
+		if !p.accept(")") {
+			return 0, p.invalid()
+		}
+		return result, nil
+	}
+	lhs, lhsKnown, err := p.parseValue()
+	if err != nil {
+		return 0, err
+	}
+	op, err := p.parseComparison()
+	if err != nil {
+		return 0, err
+	}
+	rhs, rhsKnown, err := p.parseValue()
+	if err != nil {
+		return 0, err
+	}
+	if !lhsKnown || !rhsKnown {
+		return markerUnknown, nil
+	}
+	ok, err := compareMarkerValues(lhs, op, rhs)
+	if err != nil {
+		return 0, p.invalid()
+	}
+	if ok {
+		return markerTrue, nil
+	}
+	return markerFalse, nil
+}
+
+// parseValue returns a quoted literal or the value of a marker
+// variable, and whether that value is known.
+func (p *markerParser) parseValue() (string, bool, error) {
+	if p.pos >= len(p.tokens) {
+		return "", false, p.invalid()
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	if token.quoted {
+		return token.text, true, nil
+	}
+	if _, ok := pipMarkerVariables[token.text]; !ok {
+		return "", false, p.invalid()
+	}
+	value, ok := p.values[token.text]
+	return value, ok, nil
+}
+
+func (p *markerParser) parseComparison() (string, error) {
+	if p.accept("in") {
+		return "in", nil
+	}
+	if p.accept("not") {
+		if !p.accept("in") {
+			return "", p.invalid()
+		}
+		return "not in", nil
+	}
+	for _, op := range markerComparisons {
+		if p.accept(op) {
+			return op, nil
+		}
+	}
+	return "", p.invalid()
+}
+
+// accept consumes the next token when it is the unquoted text.
+func (p *markerParser) accept(text string) bool {
+	if p.pos < len(p.tokens) && !p.tokens[p.pos].quoted && p.tokens[p.pos].text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *markerParser) invalid() error {
+	return invalidPipMarker(p.marker)
+}
+
+// compareMarkerValues applies a marker comparison. Version operators
+// compare PEP 440 versions when both sides parse as one and fall back
+// to string comparison otherwise, as PEP 508 specifies.
+func compareMarkerValues(lhs string, op string, rhs string) (bool, error) {
+	switch op {
+	case "in":
+		return strings.Contains(rhs, lhs), nil
+	case "not in":
+		return !strings.Contains(rhs, lhs), nil
+	case "===":
+		return lhs == rhs, nil
+	}
+	if version, err := pep440.Parse(lhs); err == nil {
+		if _, err := pep440.Parse(rhs); err == nil {
+			spec, err := pep440.NewSpecifiers(op + rhs)
+			if err != nil {
+				return false, err
+			}
+			return spec.Check(version), nil
+		}
+	}
+	switch op {
+	case "==":
+		return lhs == rhs, nil
+	case "!=":
+		return lhs != rhs, nil
+	case "<":
+		return lhs < rhs, nil
+	case "<=":
+		return lhs <= rhs, nil
+	case ">":
+		return lhs > rhs, nil
+	case ">=":
+		return lhs >= rhs, nil
+	}
+	return false, fmt.Errorf("operator %s needs versions on both sides", op)
+}
+
+func invalidPipMarker(marker string) error {
+	return errbuilder.New().
+		WithCode(errbuilder.CodeInvalidArgument).
+		WithMsg(fmt.Sprintf("invalid pip environment marker: %s", strings.TrimSpace(marker)))
+}
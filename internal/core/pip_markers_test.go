@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluatePipMarker(t *testing.T) {
+	py312 := PipEnvironment{PythonVersion: "3.12"}
+	tests := []struct {
+		name   string
+		marker string
+		env    PipEnvironment
+		want   bool
+	}{
+		{name: "python version holds", marker: `python_version >= "3.8"`, env: py312, want: true},
+		{name: "python version compares as PEP 440", marker: `python_version < "3.9"`, env: py312, want: false},
+		{name: "literal on the left", marker: `'3.10' <= python_version`, env: py312, want: true},
+		{name: "unknown python version keeps the requirement", marker: `python_version < "3.9"`, env: PipEnvironment{}, want: true},
+		{name: "platform", marker: `sys_platform == "win32"`, env: py312, want: false},
+		{name: "extra is never requested", marker: `extra == "test"`, env: py312, want: false},
+		{name: "or with an extra", marker: `python_version < "3.11" or extra == "toml"`, env: py312, want: false},
+		{name: "unknown and false is false", marker: `platform_machine == "aarch64" and python_version < "3.9"`, env: py312, want: false},
+		{name: "unknown or false is kept", marker: `platform_machine == "aarch64" or sys_platform == "darwin"`, env: py312, want: true},
+		{name: "parentheses", marker: `(os_name == "nt" or os_name == "posix") and implementation_name == "cpython"`, env: py312, want: true},
+		{name: "not in", marker: `platform_system not in "Windows Darwin"`, env: py312, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluatePipMarker(tt.marker, tt.env)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEvaluatePipMarkerRejectsInvalidMarkers(t *testing.T) {
+	for _, marker := range []string{`python_version >=`, `python_version >= "3.8`, `flavour == "x"`, `(os_name == "nt"`} {
+		_, err := evaluatePipMarker(marker, PipEnvironment{})
+		require.Error(t, err, marker)
+		assert.Equal(t, errbuilder.CodeInvalidArgument, errbuilder.CodeOf(err), marker)
+	}
+}
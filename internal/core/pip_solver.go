@@ -0,0 +1,368 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ZanzyTHEbar/errbuilder-go"
+	"github.com/crillab/gophersat/solver"
+
+	"avular-packages/internal/ports"
+	"avular-packages/internal/shared"
+	"avular-packages/internal/types"
+)
+
+// pipRequirement is a single parsed Requires-Dist entry, e.g.
+// "numpy (>=1.20,<2)" or "numpy>=1.20,<2".
+type pipRequirement struct {
+	Name        string
+	Constraints []types.Constraint
+}
+
+// pipSolverState holds the bookkeeping for one pip SAT solver
+// invocation, mirroring aptSolverState.
+type pipSolverState struct {
+	packageVars map[string][]int
+	varKey      map[int]aptVarKey
+	requires    map[int][]pipRequirement
+	cache       *versionCache
+	varID       int
+	costLits    []solver.Lit
+	costWeights []int
+	// allowPrerelease admits pre-releases for every constraint, as the
+	// repo index requested.
+	allowPrerelease bool
+}
+
+// resolvePipWithSolver uses a SAT solver to select a mutually compatible
+// set of pip releases for the given dependencies, following the
+// Requires-Dist edges recorded in the repo index whose environment
+// markers hold in env. Selections are keyed by normalized project name
+// and include transitive requirements.
+func resolvePipWithSolver(ctx context.Context, repo ports.RepoIndexPort, deps []types.Dependency, env PipEnvironment, unsatCoreMaxIterations int) (map[string]string, error) {
+	if len(deps) == 0 {
+		return map[string]string{}, nil
+	}
+	universe, err := collectPipUniverse(repo, deps, env)
+	if err != nil {
+		return nil, err
+	}
+	state, err := buildPipSolverState(universe, env)
+	if err != nil {
+		return nil, err
+	}
+	if state.allowPrerelease, err = repo.PipAllowPrerelease(); err != nil {
+		return nil, err
+	}
+	if state.varID == 0 {
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg("pip solver received no package versions to solve")
+	}
+	clauses, origins, err := buildPipSolverClauses(state, deps)
+	if err != nil {
+		return nil, err
+	}
+
+	problem := solver.ParseSliceNb(clauses, state.varID)
+	problem.SetCostFunc(state.costLits, state.costWeights)
+	sat := solver.New(problem)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	cost, err := minimizeSAT(ctx, sat, "pip", state.varID, len(clauses), 0)
+	if err != nil {
+		return nil, err
+	}
+	if cost < 0 {
+		msg := "pip solver found no satisfiable solution"
+		if core := explainUnsat(ctx, state.varID, clauses, origins, unsatCoreMaxIterations); len(core) > 0 {
+			msg += "; conflict likely involves: " + strings.Join(core, ", ")
+		}
+		return nil, errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(msg)
+	}
+	model := sat.Model()
+	selected := map[string]string{}
+	for id, key := range state.varKey {
+		if id-1 >= 0 && id-1 < len(model) && model[id-1] {
+			selected[key.Name] = key.Version
+		}
+	}
+	return selected, nil
+}
+
+// collectPipUniverse walks the Requires-Dist graph from the root
+// dependencies and returns the release metadata of every reachable
+// project. Projects without metadata fall back to the plain version list
+// of the index and are treated as having no requirements.
+func collectPipUniverse(repo ports.RepoIndexPort, deps []types.Dependency, env PipEnvironment) (map[string][]types.PipPackageVersion, error) {
+	metadata, err := repo.PipPackages()
+	if err != nil {
+		return nil, err
+	}
+	var queue []string
+	for _, dep := range deps {
+		queue = append(queue, shared.NormalizePipName(dep.Name))
+	}
+	universe := map[string][]types.PipPackageVersion{}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, seen := universe[name]; seen {
+			continue
+		}
+		entries, ok := metadata[name]
+		if !ok {
+			versions, err := repo.AvailableVersions(types.DependencyTypePip, name)
+			if err != nil {
+				return nil, err
+			}
+			for _, version := range versions {
+				entries = append(entries, types.PipPackageVersion{Version: version})
+			}
+		}
+		universe[name] = entries
+		for _, entry := range entries {
+			for _, raw := range entry.RequiresDist {
+				req, ok, err := parsePipRequirement(raw, env)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					queue = append(queue, req.Name)
+				}
+			}
+		}
+	}
+	return universe, nil
+}
+
+// buildPipSolverState enumerates every (project, version) pair as a SAT
+// variable. Weights follow the apt solver: the newest release costs
+// nothing, each older release one more, and every selection adds a unit
+// secondary cost so the smallest of equally new solutions wins.
+// Releases whose version is not valid PEP 440 are skipped.
+func buildPipSolverState(universe map[string][]types.PipPackageVersion, env PipEnvironment) (pipSolverState, error) {
+	s := pipSolverState{
+		packageVars: map[string][]int{},
+		varKey:      map[int]aptVarKey{},
+		requires:    map[int][]pipRequirement{},
+		cache:       newVersionCache(types.DependencyTypePip),
+	}
+	names := make([]string, 0, len(universe))
+	for name := range universe {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var ordered []types.PipPackageVersion
+		for _, entry := range universe[name] {
+			if _, err := s.cache.pepVersion(entry.Version); err != nil {
+				continue
+			}
+			ordered = append(ordered, entry)
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return s.cache.compare(ordered[i].Version, ordered[j].Version) < 0
+		})
+		for i, entry := range ordered {
+			s.varID++
+			id := s.varID
+			s.packageVars[name] = append(s.packageVars[name], id)
+			s.varKey[id] = aptVarKey{Name: name, Version: entry.Version}
+			for _, raw := range entry.RequiresDist {
+				req, ok, err := parsePipRequirement(raw, env)
+				if err != nil {
+					return pipSolverState{}, err
+				}
+				if ok {
+					s.requires[id] = append(s.requires[id], req)
+				}
+			}
+			s.costLits = append(s.costLits, solver.IntToLit(int32(id))) //nolint:gosec // id is bounded by the number of package versions, well within int32 range
+			s.costWeights = append(s.costWeights, len(ordered)-1-i)
+		}
+	}
+	scale := len(s.packageVars) + 1
+	for i, weight := range s.costWeights {
+		s.costWeights[i] = weight*scale + 1
+	}
+	return s, nil
+}
+
+// buildPipSolverClauses generates at-most-one clauses per project, one
+// clause per root demand, and an implication from each release to the
+// candidates of every requirement it declares. A requirement without
+// candidates rules the release out.
+func buildPipSolverClauses(s pipSolverState, deps []types.Dependency) ([][]int, []aptClauseOrigin, error) {
+	var clauses [][]int
+	var origins []aptClauseOrigin
+
+	names := make([]string, 0, len(s.packageVars))
+	for name := range s.packageVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ids := s.packageVars[name]
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				clauses = append(clauses, []int{-ids[i], -ids[j]})
+				origins = append(origins, aptClauseOrigin{Label: "pip:" + name})
+			}
+		}
+	}
+
+	for _, dep := range deps {
+		name := shared.NormalizePipName(dep.Name)
+		candidates, err := s.candidates(name, dep.Constraints)
+		if err != nil {
+			return nil, nil, err
+		}
+		label := "pip:" + formatPipDemand(name, dep.Constraints)
+		if len(candidates) == 0 {
+			return nil, nil, errbuilder.New().
+				WithCode(errbuilder.CodeFailedPrecondition).
+				WithMsg(fmt.Sprintf("pip solver found no candidates for %s", label))
+		}
+		clauses = append(clauses, candidates)
+		origins = append(origins, aptClauseOrigin{Root: true, Label: label, Demand: dep})
+	}
+
+	for id := 1; id <= s.varID; id++ {
+		key := s.varKey[id]
+		for _, req := range s.requires[id] {
+			candidates, err := s.candidates(req.Name, req.Constraints)
+			if err != nil {
+				return nil, nil, err
+			}
+			clauses = append(clauses, append([]int{-id}, candidates...))
+			origins = append(origins, aptClauseOrigin{
+				Label: fmt.Sprintf("pip:%s==%s requires %s", key.Name, key.Version, formatPipDemand(req.Name, req.Constraints)),
+			})
+		}
+	}
+	return clauses, origins, nil
+}
+
+// candidates returns the variable IDs of the releases of name that
+// satisfy every constraint, leaving out pre-releases the constraints do
+// not admit (see finalPipReleases) unless the index allows them.
+func (s pipSolverState) candidates(name string, constraints []types.Constraint) ([]int, error) {
+	prepared, err := prepareConstraints(types.DependencyTypePip, constraints, s.cache)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]int{}
+	var versions []string
+	for _, id := range s.packageVars[name] {
+		version := s.varKey[id].Version
+		ok, err := satisfiesAll(types.DependencyTypePip, version, prepared, s.cache)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ids[version] = id
+			versions = append(versions, version)
+		}
+	}
+	if !s.allowPrerelease {
+		versions = finalPipReleases(versions, constraints, s.cache)
+	}
+	var out []int
+	for _, version := range versions {
+		out = append(out, ids[version])
+	}
+	return out, nil
+}
+
+// parsePipRequirement parses a Requires-Dist value such as
+// "requests[socks] (>=2.0,<3); python_version >= '3.8'". Extras are
+// dropped, and a requirement whose environment marker does not hold in
+// env (including every "extra" requirement, since no extras are
+// requested) is reported as not applicable (false).
+func parsePipRequirement(raw string, env PipEnvironment) (pipRequirement, bool, error) {
+	value, marker, hasMarker := strings.Cut(raw, ";")
+	if hasMarker {
+		applies, err := evaluatePipMarker(marker, env)
+		if err != nil {
+			return pipRequirement{}, false, err
+		}
+		if !applies {
+			return pipRequirement{}, false, nil
+		}
+	}
+	value = strings.TrimSpace(value)
+	end := strings.IndexFunc(value, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	})
+	if end < 0 {
+		end = len(value)
+	}
+	name := value[:end]
+	if name == "" {
+		return pipRequirement{}, false, errbuilder.New().
+			WithCode(errbuilder.CodeInvalidArgument).
+			WithMsg(fmt.Sprintf("invalid pip requirement: %s", raw))
+	}
+	rest := strings.TrimSpace(value[end:])
+	if strings.HasPrefix(rest, "[") {
+		if close := strings.Index(rest, "]"); close >= 0 {
+			rest = strings.TrimSpace(rest[close+1:])
+		}
+	}
+	rest = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(rest, "("), ")"))
+
+	req := pipRequirement{Name: shared.NormalizePipName(name)}
+	if rest == "" {
+		return req, true, nil
+	}
+	for _, spec := range strings.Split(rest, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		matched := false
+		for _, op := range opTokens {
+			if !strings.HasPrefix(spec, string(op)) {
+				continue
+			}
+			version := strings.TrimSpace(strings.TrimPrefix(spec, string(op)))
+			if version == "" {
+				break
+			}
+			req.Constraints = append(req.Constraints, types.Constraint{
+				Name:    req.Name,
+				Op:      op,
+				Version: version,
+				Source:  "pip:requires_dist",
+			})
+			matched = true
+			break
+		}
+		if !matched {
+			return pipRequirement{}, false, errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("invalid pip requirement: %s", raw))
+		}
+	}
+	return req, true, nil
+}
+
+// formatPipDemand renders a project and its constraints in PEP 508
+// syntax, e.g. "numpy>=1.20,<2".
+func formatPipDemand(name string, constraints []types.Constraint) string {
+	var parts []string
+	for _, constraint := range constraints {
+		if constraint.Op == types.ConstraintOpNone || strings.TrimSpace(constraint.Version) == "" {
+			continue
+		}
+		parts = append(parts, toPep440Spec(constraint))
+	}
+	return name + strings.ReplaceAll(strings.Join(parts, ","), " ", "")
+}
@@ -0,0 +1,177 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/types"
+)
+
+// ---------------------------------------------------------------------------
+// parsePipRequirement
+// ---------------------------------------------------------------------------
+
+func TestParsePipRequirement(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		expect pipRequirement
+		skip   bool
+	}{
+		{
+			name:   "bare name is normalized",
+			input:  "Typing_Extensions",
+			expect: pipRequirement{Name: "typing-extensions"},
+		},
+		{
+			name:  "parenthesized specifiers",
+			input: "numpy (>=1.20,<2)",
+			expect: pipRequirement{
+				Name: "numpy",
+				Constraints: []types.Constraint{
+					{Name: "numpy", Op: types.ConstraintOpGte, Version: "1.20", Source: "pip:requires_dist"},
+					{Name: "numpy", Op: types.ConstraintOpLt, Version: "2", Source: "pip:requires_dist"},
+				},
+			},
+		},
+		{
+			name:  "extras and environment marker",
+			input: "requests[socks]~=2.31; python_version >= '3.8'",
+			expect: pipRequirement{
+				Name: "requests",
+				Constraints: []types.Constraint{
+					{Name: "requests", Op: types.ConstraintOpCompat, Version: "2.31", Source: "pip:requires_dist"},
+				},
+			},
+		},
+		{
+			name:  "extra-only requirement is skipped",
+			input: "pytest>=7; extra == 'test'",
+			skip:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parsePipRequirement(tt.input, PipEnvironment{})
+			require.NoError(t, err)
+			if tt.skip {
+				assert.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestParsePipRequirementEvaluatesMarkers(t *testing.T) {
+	env := PipEnvironment{PythonVersion: "3.12"}
+	_, ok, err := parsePipRequirement(`tomli>=1.1; python_version < "3.11"`, env)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	req, ok, err := parsePipRequirement(`typing-extensions; python_version >= "3.8" and sys_platform == "linux"`, env)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "typing-extensions", req.Name)
+}
+
+func TestParsePipRequirementRejectsUnknownSpecifier(t *testing.T) {
+	_, _, err := parsePipRequirement("numpy @1.0", PipEnvironment{})
+	require.Error(t, err)
+}
+
+// ---------------------------------------------------------------------------
+// resolvePipWithSolver
+// ---------------------------------------------------------------------------
+
+func TestResolvePipWithSolverFollowsRequiresDist(t *testing.T) {
+	repo := testRepoIndex{
+		pipPackages: map[string][]types.PipPackageVersion{
+			"opencv-python": {
+				{Version: "4.8.0", RequiresDist: []string{"numpy (<2)"}},
+				{Version: "4.10.0", RequiresDist: []string{"numpy>=2"}},
+			},
+			"scipy": {
+				{Version: "1.11.0", RequiresDist: []string{"numpy<2,>=1.21"}},
+			},
+			"numpy": {
+				{Version: "1.26.4"},
+				{Version: "2.0.0"},
+			},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "opencv_python", Type: types.DependencyTypePip},
+		{Name: "scipy", Type: types.DependencyTypePip},
+	}
+	selected, err := resolvePipWithSolver(context.Background(), repo, deps, PipEnvironment{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"opencv-python": "4.8.0",
+		"scipy":         "1.11.0",
+		"numpy":         "1.26.4",
+	}, selected)
+}
+
+func TestResolvePipWithSolverPrefersNewest(t *testing.T) {
+	repo := testRepoIndex{
+		pip: map[string][]string{
+			"requests": {"2.30.0", "2.31.0", "not-a-version"},
+		},
+	}
+	deps := []types.Dependency{{Name: "requests", Type: types.DependencyTypePip}}
+	selected, err := resolvePipWithSolver(context.Background(), repo, deps, PipEnvironment{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"requests": "2.31.0"}, selected)
+}
+
+func TestResolvePipWithSolverSkipsPrereleases(t *testing.T) {
+	repo := testRepoIndex{
+		pipPackages: map[string][]types.PipPackageVersion{
+			"app":   {{Version: "1.0.0", RequiresDist: []string{"numpy>=1.0"}}},
+			"numpy": {{Version: "1.26.4"}, {Version: "2.0.0rc1"}},
+			"tool":  {{Version: "0.9.0"}, {Version: "1.0.0b2"}},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypePip},
+		{Name: "tool", Type: types.DependencyTypePip, Constraints: []types.Constraint{
+			{Name: "tool", Op: types.ConstraintOpGte, Version: "1.0.0b1"},
+		}},
+	}
+	selected, err := resolvePipWithSolver(context.Background(), repo, deps, PipEnvironment{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"app":   "1.0.0",
+		"numpy": "1.26.4",
+		"tool":  "1.0.0b2",
+	}, selected)
+
+	repo.prerelease = true
+	selected, err = resolvePipWithSolver(context.Background(), repo, deps, PipEnvironment{}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "2.0.0rc1", selected["numpy"])
+}
+
+func TestResolvePipWithSolverReportsConflict(t *testing.T) {
+	repo := testRepoIndex{
+		pipPackages: map[string][]types.PipPackageVersion{
+			"a":     {{Version: "1.0", RequiresDist: []string{"numpy>=2"}}},
+			"b":     {{Version: "1.0", RequiresDist: []string{"numpy<2"}}},
+			"numpy": {{Version: "1.26.4"}, {Version: "2.0.0"}},
+		},
+	}
+	deps := []types.Dependency{
+		{Name: "a", Type: types.DependencyTypePip},
+		{Name: "b", Type: types.DependencyTypePip},
+	}
+	_, err := resolvePipWithSolver(context.Background(), repo, deps, PipEnvironment{}, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pip solver found no satisfiable solution")
+	assert.Contains(t, err.Error(), "pip:a")
+	assert.Contains(t, err.Error(), "pip:b")
+}
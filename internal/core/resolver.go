@@ -2,9 +2,11 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ZanzyTHEbar/errbuilder-go"
 	"github.com/rs/zerolog/log"
@@ -21,6 +23,45 @@ type ResolverCore struct {
 	RepoIndex    ports.RepoIndexPort
 	Policy       ports.PolicyPort
 	UseAptSolver bool
+	// UsePipSolver resolves pip dependencies with the SAT solver so
+	// Requires-Dist edges between them are honoured.
+	UsePipSolver bool
+	// PipEnvironment is the target the pip solver evaluates Requires-Dist
+	// environment markers against.
+	PipEnvironment PipEnvironment
+	// UnsatCoreMaxIterations bounds the SAT calls spent explaining an
+	// unsatisfiable apt problem (0 = default, negative = disabled).
+	UnsatCoreMaxIterations int
+	// PreferLock biases the apt solver towards previously locked
+	// versions (package name -> version) to keep re-resolves low-churn.
+	PreferLock map[string]string
+	// BasePackages are assumed present on the target system; the apt
+	// solver treats dependencies on them as already satisfied.
+	BasePackages []string
+	// AssumeEssential treats the Essential and Priority: required
+	// packages of the repo index as base packages.
+	AssumeEssential bool
+	// AllowUnresolved lets the apt solver drop unsatisfiable root demands
+	// and report them in ResolveResult.Unresolved instead of failing.
+	AllowUnresolved bool
+	// BestEffort records every dependency that cannot be resolved in
+	// ResolveResult.Unresolved and carries on, instead of failing on the
+	// first one. It implies AllowUnresolved for the apt solver.
+	BestEffort bool
+	// AptOnly drops pip dependencies before resolution, for targets that
+	// ship without python.
+	AptOnly bool
+	// Architectures lists the architectures solved together when more
+	// than one is given; ArchRepoIndexes holds the repo index of each.
+	// Packages get one apt.lock entry when every architecture selects the
+	// same version, and arch-qualified (name:arch) entries otherwise.
+	Architectures   []string
+	ArchRepoIndexes map[string]ports.RepoIndexPort
+	// SolverTimeout bounds each apt SAT solve (0 = no limit).
+	SolverTimeout time.Duration
+	// PackageXMLConstraints selects whether package.xml constraints are
+	// enforced (hard, the default) or only kept as minimum hints (soft).
+	PackageXMLConstraints types.PackageXMLConstraintPolicy
 }
 
 // ResolveResult holds the outputs of a successful resolution: APT lock
@@ -30,6 +71,12 @@ type ResolveResult struct {
 	BundleManifest []types.BundleManifestEntry
 	ResolvedDeps   []types.ResolvedDependency
 	Resolution     types.ResolutionReport
+	// Unresolved lists the dependencies dropped under AllowUnresolved or
+	// BestEffort, with the reason each one failed.
+	Unresolved []types.UnresolvedDependency
+	// UnusedDirectives lists resolution directives that were never
+	// applied to any dependency during this run.
+	UnusedDirectives []types.ResolutionDirective
 }
 
 // NewResolverCore creates a resolver with the given repo index and policy.
@@ -47,6 +94,12 @@ func (r ResolverCore) Resolve(ctx context.Context, deps []types.Dependency, dire
 			WithMsg("resolver requires repo index and policy ports")
 	}
 
+	if r.AptOnly {
+		deps = withoutPipDependencies(deps)
+	}
+	if r.PackageXMLConstraints == types.PackageXMLConstraintsSoft {
+		deps = softenPackageXMLConstraints(deps)
+	}
 	merged := mergeDependencies(deps)
 	directiveMap := mapDirectives(directives)
 
@@ -56,7 +109,27 @@ func (r ResolverCore) Resolve(ctx context.Context, deps []types.Dependency, dire
 
 	aptSolverDeps := map[string]types.Dependency{}
 	aptSolverGroups := map[string]types.PackagingGroup{}
+	pipSolverDeps := map[string]types.Dependency{}
+	pipSolverGroups := map[string]types.PackagingGroup{}
+	applied := map[string]struct{}{}
+	blocked := blockedAptPackages(directives)
+	if r.UseAptSolver {
+		// The solver keeps blocked packages out of the transitive closure
+		// too, so their directives apply whether or not they are roots.
+		for _, name := range blocked {
+			applied[normalizeDirectiveKey("apt:"+name)] = struct{}{}
+		}
+	}
 	for _, dep := range merged {
+		if directive, ok := directiveFor(dep, directiveMap); ok && policies.IsBlock(directive) {
+			_, record, err := policies.ApplyResolution(dep, directive)
+			if err != nil {
+				return ResolveResult{}, err
+			}
+			result.Resolution.Records = append(result.Resolution.Records, record)
+			applied[directiveKey(dep)] = struct{}{}
+			continue
+		}
 		group, err := r.Policy.ResolvePackagingMode(dep.Type, dep.Name)
 		if err != nil {
 			return ResolveResult{}, err
@@ -72,19 +145,39 @@ func (r ResolverCore) Resolve(ctx context.Context, deps []types.Dependency, dire
 			}
 			if record.Action != "" {
 				result.Resolution.Records = append(result.Resolution.Records, record)
+				applied[directiveKey(pinned)] = struct{}{}
 			}
 			key := normalizeDirectiveKey(fmt.Sprintf("%s:%s", updated.Type, updated.Name))
 			aptSolverDeps[key] = updated
 			aptSolverGroups[updated.Name] = group
 			continue
 		}
+		if r.UsePipSolver && dep.Type == types.DependencyTypePip {
+			updated, record, err := r.prepareDependency(pinned, directiveMap)
+			if err != nil {
+				return ResolveResult{}, err
+			}
+			if record.Action != "" {
+				result.Resolution.Records = append(result.Resolution.Records, record)
+				applied[directiveKey(pinned)] = struct{}{}
+			}
+			key := normalizeDirectiveKey(fmt.Sprintf("%s:%s", updated.Type, updated.Name))
+			pipSolverDeps[key] = updated
+			pipSolverGroups[updated.Name] = group
+			continue
+		}
 
 		version, record, err := r.resolveDependency(ctx, pinned, directiveMap)
 		if err != nil {
-			return ResolveResult{}, err
+			if !r.BestEffort || !isUnresolvable(err) {
+				return ResolveResult{}, err
+			}
+			recordUnresolved(&result, pinned, unresolvedReason(err))
+			continue
 		}
 		if record.Action != "" {
 			result.Resolution.Records = append(result.Resolution.Records, record)
+			applied[directiveKey(pinned)] = struct{}{}
 		}
 
 		lockName := aptLockPackageName(dep)
@@ -106,8 +199,13 @@ func (r ResolverCore) Resolve(ctx context.Context, deps []types.Dependency, dire
 		})
 	}
 
+	if r.UsePipSolver && len(pipSolverDeps) > 0 {
+		if err := r.mergePipSolverResults(ctx, &result, pipSolverDeps, pipSolverGroups); err != nil {
+			return ResolveResult{}, err
+		}
+	}
 	if r.UseAptSolver && len(aptSolverDeps) > 0 {
-		if err := r.mergeSATSolverResults(ctx, &result, aptSolverDeps, aptSolverGroups); err != nil {
+		if err := r.mergeSATSolverResults(ctx, &result, aptSolverDeps, aptSolverGroups, blocked); err != nil {
 			return ResolveResult{}, err
 		}
 	}
@@ -115,6 +213,10 @@ func (r ResolverCore) Resolve(ctx context.Context, deps []types.Dependency, dire
 	sort.Slice(result.AptLocks, func(i, j int) bool {
 		return result.AptLocks[i].Package < result.AptLocks[j].Package
 	})
+	sort.SliceStable(result.Unresolved, func(i, j int) bool {
+		return result.Unresolved[i].Name < result.Unresolved[j].Name
+	})
+	result.UnusedDirectives = unusedDirectives(directives, applied)
 
 	log.Ctx(ctx).Debug().Int("resolved", len(result.AptLocks)).Msg("resolver completed")
 	return result, nil
@@ -123,11 +225,34 @@ func (r ResolverCore) Resolve(ctx context.Context, deps []types.Dependency, dire
 // mergeSATSolverResults runs the APT SAT solver and merges the results
 // into the existing ResolveResult, updating locks, resolved deps, and
 // the bundle manifest.
-func (r ResolverCore) mergeSATSolverResults(ctx context.Context, result *ResolveResult, aptSolverDeps map[string]types.Dependency, aptSolverGroups map[string]types.PackagingGroup) error {
-	solved, err := resolveAptWithSolver(ctx, r.RepoIndex, mapValues(aptSolverDeps))
+func (r ResolverCore) mergeSATSolverResults(ctx context.Context, result *ResolveResult, aptSolverDeps map[string]types.Dependency, aptSolverGroups map[string]types.PackagingGroup, blocked []string) error {
+	if len(r.Architectures) > 1 {
+		return r.mergeMultiArchSolverResults(ctx, result, aptSolverDeps, aptSolverGroups, blocked)
+	}
+	outcome, err := solveApt(ctx, r.RepoIndex, mapValues(aptSolverDeps), aptSolverOptions{
+		UnsatCoreMaxIterations: r.UnsatCoreMaxIterations,
+		PreferLock:             r.PreferLock,
+		BasePackages:           r.BasePackages,
+		AssumeEssential:        r.AssumeEssential,
+		AllowUnresolved:        r.AllowUnresolved || r.BestEffort,
+		Blocked:                blocked,
+		Timeout:                r.SolverTimeout,
+	})
 	if err != nil {
 		return err
 	}
+	solved := outcome.Selected
+	for _, demand := range outcome.Unresolved {
+		recordUnresolved(result, demand, "no satisfiable candidate")
+	}
+	for _, choice := range outcome.Alternatives {
+		result.Resolution.Records = append(result.Resolution.Records, types.ResolutionRecord{
+			Dependency: fmt.Sprintf("%s:%s", types.DependencyTypeApt, choice.Selected),
+			Action:     "alternative",
+			Value:      choice.Version,
+			Reason:     fmt.Sprintf("selected %s to satisfy %s's alternative %s", choice.Selected, choice.Package, choice.Group),
+		})
+	}
 	lockSet := map[string]string{}
 	for _, entry := range result.AptLocks {
 		lockSet[entry.Package] = entry.Version
@@ -166,6 +291,160 @@ func (r ResolverCore) mergeSATSolverResults(ctx context.Context, result *Resolve
 	return nil
 }
 
+// mergeMultiArchSolverResults solves the apt dependencies for every
+// configured architecture at once and merges the per-arch selections.
+// Like the apt.lock entries, resolved deps and bundle rows of a package
+// selected in different versions are recorded per architecture, as
+// name:arch.
+func (r ResolverCore) mergeMultiArchSolverResults(ctx context.Context, result *ResolveResult, aptSolverDeps map[string]types.Dependency, aptSolverGroups map[string]types.PackagingGroup, blocked []string) error {
+	selected, err := solveAptMultiArch(ctx, r.ArchRepoIndexes, r.Architectures, mapValues(aptSolverDeps), aptSolverOptions{
+		UnsatCoreMaxIterations: r.UnsatCoreMaxIterations,
+		PreferLock:             r.PreferLock,
+		BasePackages:           r.BasePackages,
+		AssumeEssential:        r.AssumeEssential,
+		AllowUnresolved:        r.AllowUnresolved || r.BestEffort,
+		Blocked:                blocked,
+		Timeout:                r.SolverTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	lockSet := map[string]string{}
+	for _, entry := range result.AptLocks {
+		lockSet[entry.Package] = entry.Version
+	}
+	entries := multiArchLockEntries(r.Architectures, selected)
+	for name, version := range entries {
+		lockSet[name] = version
+	}
+	result.AptLocks = result.AptLocks[:0]
+	for name, version := range lockSet {
+		result.AptLocks = append(result.AptLocks, types.AptLockEntry{
+			Package: name,
+			Version: version,
+		})
+	}
+	for name, version := range entries {
+		result.ResolvedDeps = append(result.ResolvedDeps, types.ResolvedDependency{
+			Type:    types.DependencyTypeApt,
+			Package: name,
+			Version: version,
+		})
+	}
+	for _, dep := range aptSolverDeps {
+		group, ok := aptSolverGroups[dep.Name]
+		if !ok {
+			continue
+		}
+		packages := []string{dep.Name}
+		if _, ok := entries[dep.Name]; !ok {
+			packages = packages[:0]
+			for _, arch := range r.Architectures {
+				packages = append(packages, dep.Name+":"+arch)
+			}
+		}
+		for _, name := range packages {
+			version, ok := entries[name]
+			if !ok {
+				continue
+			}
+			result.BundleManifest = append(result.BundleManifest, types.BundleManifestEntry{
+				Group:   group.Name,
+				Mode:    group.Mode,
+				Package: name,
+				Version: version,
+			})
+		}
+	}
+	return nil
+}
+
+// mergePipSolverResults runs the pip SAT solver and records the chosen
+// version of every root pip dependency. Transitive releases only steer
+// the selection; like the per-dependency path, they are not locked.
+func (r ResolverCore) mergePipSolverResults(ctx context.Context, result *ResolveResult, pipSolverDeps map[string]types.Dependency, pipSolverGroups map[string]types.PackagingGroup) error {
+	deps := mapValues(pipSolverDeps)
+	solved, err := resolvePipWithSolver(ctx, r.RepoIndex, deps, r.PipEnvironment, r.UnsatCoreMaxIterations)
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		version, ok := solved[shared.NormalizePipName(dep.Name)]
+		if !ok {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInternal).
+				WithMsg(fmt.Sprintf("pip solver did not select a version for %s", dep.Name))
+		}
+		result.AptLocks = append(result.AptLocks, types.AptLockEntry{
+			Package: aptLockPackageName(dep),
+			Version: version,
+		})
+		result.ResolvedDeps = append(result.ResolvedDeps, types.ResolvedDependency{
+			Type:    dep.Type,
+			Package: dep.Name,
+			Version: version,
+		})
+		group := pipSolverGroups[dep.Name]
+		result.BundleManifest = append(result.BundleManifest, types.BundleManifestEntry{
+			Group:   group.Name,
+			Mode:    group.Mode,
+			Package: dep.Name,
+			Version: version,
+		})
+	}
+	return nil
+}
+
+// recordUnresolved lists dep as unresolved and adds an "unresolved"
+// record to the resolution report.
+func recordUnresolved(result *ResolveResult, dep types.Dependency, reason string) {
+	result.Unresolved = append(result.Unresolved, types.UnresolvedDependency{Dependency: dep, Reason: reason})
+	result.Resolution.Records = append(result.Resolution.Records, types.ResolutionRecord{
+		Dependency: fmt.Sprintf("%s:%s", dep.Type, dep.Name),
+		Action:     "unresolved",
+		Value:      aptConstraintSummary(dep.Constraints),
+		Reason:     reason,
+	})
+}
+
+// isUnresolvable reports whether err means no version of a dependency
+// could be selected, as opposed to a failure of the run itself.
+func isUnresolvable(err error) bool {
+	switch errbuilder.CodeOf(err) {
+	case errbuilder.CodeFailedPrecondition, errbuilder.CodeNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+// unresolvedReason returns the message of err, followed by that of its
+// cause when the cause explains it further.
+func unresolvedReason(err error) string {
+	var builder *errbuilder.ErrBuilder
+	if !errors.As(err, &builder) {
+		return err.Error()
+	}
+	var cause *errbuilder.ErrBuilder
+	if errors.As(builder.Cause, &cause) && cause.Msg != "" {
+		return fmt.Sprintf("%s (%s)", builder.Msg, cause.Msg)
+	}
+	return builder.Msg
+}
+
+// aptConstraintSummary renders constraints as space-separated relations
+// (e.g. ">= 1.0 << 2.0") so they fit a single report column.
+func aptConstraintSummary(constraints []types.Constraint) string {
+	var parts []string
+	for _, constraint := range constraints {
+		if constraint.Op == types.ConstraintOpNone || strings.TrimSpace(constraint.Version) == "" {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", constraint.Op, constraint.Version))
+	}
+	return strings.Join(parts, " ")
+}
+
 // prepareDependency applies a resolution directive (if one exists) to a
 // dependency before it enters the SAT solver. Returns the potentially
 // updated dependency and a resolution record.
@@ -185,11 +464,15 @@ func (r ResolverCore) prepareDependency(dep types.Dependency, directiveMap map[s
 // the repo index. If no compatible version is found and a resolution
 // directive exists, it retries with the updated constraints.
 func (r ResolverCore) resolveDependency(ctx context.Context, dep types.Dependency, directiveMap map[string]types.ResolutionDirective) (string, types.ResolutionRecord, error) {
+	allowPrerelease, err := r.RepoIndex.PipAllowPrerelease()
+	if err != nil {
+		return "", types.ResolutionRecord{}, err
+	}
 	available, err := r.RepoIndex.AvailableVersions(dep.Type, dep.Name)
 	if err != nil {
 		return "", types.ResolutionRecord{}, err
 	}
-	version, err := bestCompatibleVersion(dep, available)
+	version, err := bestCompatibleVersion(dep, available, allowPrerelease)
 	if err == nil {
 		return version, types.ResolutionRecord{}, nil
 	}
@@ -211,7 +494,7 @@ func (r ResolverCore) resolveDependency(ctx context.Context, dep types.Dependenc
 	if err != nil {
 		return "", types.ResolutionRecord{}, err
 	}
-	version, err = bestCompatibleVersion(updated, available)
+	version, err = bestCompatibleVersion(updated, available, allowPrerelease)
 	if err != nil {
 		return "", types.ResolutionRecord{}, err
 	}
@@ -219,6 +502,45 @@ func (r ResolverCore) resolveDependency(ctx context.Context, dep types.Dependenc
 	return version, record, nil
 }
 
+// withoutPipDependencies returns deps with every pip dependency removed.
+func withoutPipDependencies(deps []types.Dependency) []types.Dependency {
+	out := make([]types.Dependency, 0, len(deps))
+	for _, dep := range deps {
+		if dep.Type == types.DependencyTypePip {
+			continue
+		}
+		out = append(out, dep)
+	}
+	return out
+}
+
+// softenPackageXMLConstraints turns the package.xml-sourced constraints
+// of deps into minimum hints: exact, compatible-release and lower-bound
+// constraints become ">=" their version, while upper bounds and
+// exclusions are reduced to bare names. Constraints from other sources
+// are left untouched.
+func softenPackageXMLConstraints(deps []types.Dependency) []types.Dependency {
+	out := make([]types.Dependency, 0, len(deps))
+	for _, dep := range deps {
+		constraints := make([]types.Constraint, 0, len(dep.Constraints))
+		for _, constraint := range dep.Constraints {
+			if constraintPriority(constraint.Source) == packageXMLPriority {
+				switch constraint.Op {
+				case types.ConstraintOpEq, types.ConstraintOpEq2, types.ConstraintOpCompat:
+					constraint.Op = types.ConstraintOpGte
+				case types.ConstraintOpLt, types.ConstraintOpLte, types.ConstraintOpNe:
+					constraint.Op = types.ConstraintOpNone
+					constraint.Version = ""
+				}
+			}
+			constraints = append(constraints, constraint)
+		}
+		dep.Constraints = constraints
+		out = append(out, dep)
+	}
+	return out
+}
+
 // mergeDependencies combines duplicate (type, name) entries by merging
 // their constraints, then filters by priority so the highest-precedence
 // source wins.
@@ -259,6 +581,49 @@ func mapDirectives(directives []types.ResolutionDirective) map[string]types.Reso
 	return mapped
 }
 
+// blockedAptPackages returns the apt package names of the block
+// directives, in declaration order.
+func blockedAptPackages(directives []types.ResolutionDirective) []string {
+	var names []string
+	for _, directive := range directives {
+		if !policies.IsBlock(directive) {
+			continue
+		}
+		depType, name, ok := strings.Cut(strings.TrimSpace(directive.Dependency), ":")
+		if ok && strings.EqualFold(strings.TrimSpace(depType), string(types.DependencyTypeApt)) {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+	return names
+}
+
+// directiveKey returns the normalized "type:name" key of dep.
+func directiveKey(dep types.Dependency) string {
+	return normalizeDirectiveKey(fmt.Sprintf("%s:%s", dep.Type, dep.Name))
+}
+
+// unusedDirectives returns the directives, in declaration order, whose
+// key is not in applied.
+func unusedDirectives(directives []types.ResolutionDirective, applied map[string]struct{}) []types.ResolutionDirective {
+	var unused []types.ResolutionDirective
+	seen := map[string]struct{}{}
+	for _, directive := range directives {
+		if directive.Dependency == "" {
+			continue
+		}
+		key := normalizeDirectiveKey(directive.Dependency)
+		if _, ok := applied[key]; ok {
+			continue
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		unused = append(unused, directive)
+	}
+	return unused
+}
+
 // directiveFor looks up whether a resolution directive exists for the
 // given dependency, keyed by "type:name".
 func directiveFor(dep types.Dependency, directives map[string]types.ResolutionDirective) (types.ResolutionDirective, bool) {
@@ -290,7 +655,11 @@ func applyGroupPins(dep types.Dependency, group types.PackagingGroup) (types.Dep
 // filterConstraintsByPriority keeps only the constraints from the
 // highest-priority source (product > profile > package_xml). Within
 // that tier, hard constraints (those with an operator) take precedence
-// over bare name-only constraints.
+// over bare name-only constraints. Every hard constraint of the tier is
+// kept, so constraints contributed by several packages at the same
+// tier intersect rather than override each other. When the top tier
+// only names the dependency, the hard constraints of the next tier that
+// has any are used instead.
 func filterConstraintsByPriority(constraints []types.Constraint) []types.Constraint {
 	if len(constraints) == 0 {
 		return constraints
@@ -327,9 +696,16 @@ func filterConstraintsByPriority(constraints []types.Constraint) []types.Constra
 		}
 		return hard
 	}
+	fallbackPriority := -1
+	for _, constraint := range constraints {
+		priority := constraintPriority(constraint.Source)
+		if constraint.Op != types.ConstraintOpNone && priority > fallbackPriority {
+			fallbackPriority = priority
+		}
+	}
 	var fallback []types.Constraint
 	for _, constraint := range constraints {
-		if constraint.Op == types.ConstraintOpNone {
+		if constraint.Op == types.ConstraintOpNone || constraintPriority(constraint.Source) != fallbackPriority {
 			continue
 		}
 		fallback = append(fallback, constraint)
@@ -369,18 +745,28 @@ func normalizeDebPackageName(value string) string {
 	return normalized
 }
 
-// mapValues extracts the values from a dependency map into a slice.
+// mapValues returns the dependencies of a map sorted by key, so the
+// solver sees its root demands in a stable order.
 func mapValues(values map[string]types.Dependency) []types.Dependency {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 	out := make([]types.Dependency, 0, len(values))
-	for _, dep := range values {
-		out = append(out, dep)
+	for _, key := range keys {
+		out = append(out, values[key])
 	}
 	return out
 }
 
+// packageXMLPriority is the constraintPriority of package.xml sources.
+const packageXMLPriority = 1
+
 // constraintPriority assigns a numeric rank to constraint sources so that
 // product-level constraints override profile-level, which override
-// package_xml-level.
+// package_xml-level. Schema mapping versions come from resolving
+// package.xml ROS tags and rank with the package_xml export tags.
 func constraintPriority(source string) int {
 	normalized := strings.ToLower(strings.TrimSpace(source))
 	switch {
@@ -388,8 +774,8 @@ func constraintPriority(source string) int {
 		return 3
 	case strings.HasPrefix(normalized, "profile:"):
 		return 2
-	case strings.HasPrefix(normalized, "package_xml:"):
-		return 1
+	case strings.HasPrefix(normalized, "package_xml:"), normalized == "schema":
+		return packageXMLPriority
 	default:
 		return 0
 	}
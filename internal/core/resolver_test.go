@@ -1,12 +1,17 @@
 package core
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
 
+	"avular-packages/internal/adapters"
 	"avular-packages/internal/policies"
+	"avular-packages/internal/ports"
 	"avular-packages/internal/types"
 )
 
@@ -14,6 +19,8 @@ type testRepoIndex struct {
 	apt         map[string][]string
 	aptPackages map[string][]types.AptPackageVersion
 	pip         map[string][]string
+	pipPackages map[string][]types.PipPackageVersion
+	prerelease  bool
 }
 
 func (t testRepoIndex) AvailableVersions(depType types.DependencyType, name string) ([]string, error) {
@@ -34,6 +41,17 @@ func (t testRepoIndex) AptPackages() (map[string][]types.AptPackageVersion, erro
 	return t.aptPackages, nil
 }
 
+func (t testRepoIndex) PipPackages() (map[string][]types.PipPackageVersion, error) {
+	if t.pipPackages == nil {
+		return map[string][]types.PipPackageVersion{}, nil
+	}
+	return t.pipPackages, nil
+}
+
+func (t testRepoIndex) PipAllowPrerelease() (bool, error) {
+	return t.prerelease, nil
+}
+
 func TestResolverBestCompatible(t *testing.T) {
 	repo := testRepoIndex{
 		apt: map[string][]string{
@@ -288,6 +306,78 @@ func TestResolverFallsBackToLowerPriorityConstraints(t *testing.T) {
 	}
 }
 
+func TestResolverIntersectsSameTierConstraintsFromMultiplePackages(t *testing.T) {
+	repo := testRepoIndex{
+		apt: map[string][]string{
+			"libfoo": {"0.9.0", "1.5.0", "2.1.0"},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+
+	// Two workspace packages each declare libfoo with a different bound.
+	deps := []types.Dependency{
+		{
+			Name: "libfoo",
+			Type: types.DependencyTypeApt,
+			Constraints: []types.Constraint{
+				{Name: "libfoo", Op: types.ConstraintOpGte, Version: "1.0.0", Source: "package_xml:debian_depend"},
+			},
+		},
+		{
+			Name: "libfoo",
+			Type: types.DependencyTypeApt,
+			Constraints: []types.Constraint{
+				{Name: "libfoo", Op: types.ConstraintOpLt, Version: "2.0.0", Source: "package_xml:debian_depend"},
+			},
+		},
+	}
+	result, err := resolver.Resolve(t.Context(), deps, nil)
+	require.NoError(t, err)
+	if diff := cmp.Diff([]types.AptLockEntry{{Package: "libfoo", Version: "1.5.0"}}, result.AptLocks); diff != "" {
+		t.Fatalf("unexpected apt locks (-want +got):\n%s", diff)
+	}
+
+	merged := mergeDependencies(deps)
+	require.Len(t, merged, 1)
+	if diff := cmp.Diff(2, len(merged[0].Constraints)); diff != "" {
+		t.Fatalf("unexpected retained constraint count (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverReportsUnusedDirectives(t *testing.T) {
+	repo := testRepoIndex{
+		apt: map[string][]string{
+			"libfoo": {"1.0.0", "1.2.0"},
+			"libbar": {"2.0.0"},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+
+	deps := []types.Dependency{
+		{Name: "libfoo", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libfoo", Op: types.ConstraintOpGte, Version: "2.0.0"},
+		}},
+		{Name: "libbar", Type: types.DependencyTypeApt},
+	}
+	directives := []types.ResolutionDirective{
+		{Dependency: "apt:libfoo", Action: "force", Value: "1.2.0", Reason: "pinned", Owner: "team"},
+		{Dependency: "apt:libbar", Action: "force", Value: "2.0.0", Reason: "stale", Owner: "team"},
+		{Dependency: "apt:libgone", Action: "relax", Reason: "removed", Owner: "team"},
+	}
+	result, err := resolver.Resolve(t.Context(), deps, directives)
+	require.NoError(t, err)
+	want := []types.ResolutionDirective{directives[1], directives[2]}
+	if diff := cmp.Diff(want, result.UnusedDirectives); diff != "" {
+		t.Fatalf("unexpected unused directives (-want +got):\n%s", diff)
+	}
+}
+
 func TestResolverNormalizesPipDirectiveKey(t *testing.T) {
 	repo := testRepoIndex{
 		pip: map[string][]string{
@@ -419,3 +509,468 @@ func TestResolverAptSolverAddsTransitiveDeps(t *testing.T) {
 		t.Fatalf("missing alternative dependency lock")
 	}
 }
+
+func TestResolverMultiArchRecordsVersionsPerArch(t *testing.T) {
+	repos := map[string]ports.RepoIndexPort{
+		"amd64": testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{
+			"libcommon": {{Version: "1.5"}},
+			"libbar":    {{Version: "2.0"}},
+		}},
+		"arm64": testRepoIndex{aptPackages: map[string][]types.AptPackageVersion{
+			"libcommon": {{Version: "1.5"}},
+			"libbar":    {{Version: "1.0"}},
+		}},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repos["amd64"], policy)
+	resolver.UseAptSolver = true
+	resolver.Architectures = []string{"amd64", "arm64"}
+	resolver.ArchRepoIndexes = repos
+
+	deps := []types.Dependency{
+		{Name: "libcommon", Type: types.DependencyTypeApt},
+		{Name: "libbar", Type: types.DependencyTypeApt},
+	}
+	result, err := resolver.Resolve(t.Context(), deps, nil)
+	require.NoError(t, err)
+
+	want := map[string]string{
+		"libcommon":    "1.5",
+		"libbar:amd64": "2.0",
+		"libbar:arm64": "1.0",
+	}
+	resolved := map[string]string{}
+	for _, dep := range result.ResolvedDeps {
+		resolved[dep.Package] = dep.Version
+	}
+	if diff := cmp.Diff(want, resolved); diff != "" {
+		t.Fatalf("unexpected resolved deps (-want +got):\n%s", diff)
+	}
+	bundled := map[string]string{}
+	for _, entry := range result.BundleManifest {
+		bundled[entry.Package] = entry.Version
+	}
+	if diff := cmp.Diff(want, bundled); diff != "" {
+		t.Fatalf("unexpected bundle manifest (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverBlockDirectiveDropsLeaf(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app":     {{Version: "1.0.0"}},
+			"tool":    {{Version: "2.0.0", Depends: []string{"libtool"}}},
+			"libtool": {{Version: "1.0.0"}},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	directives := []types.ResolutionDirective{
+		{Dependency: "apt:tool", Action: policies.ActionBlock, Reason: "license review", Owner: "legal"},
+	}
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+		{Name: "tool", Type: types.DependencyTypeApt},
+	}
+
+	for _, useSolver := range []bool{false, true} {
+		resolver := NewResolverCore(repo, policy)
+		resolver.UseAptSolver = useSolver
+		if !useSolver {
+			resolver.RepoIndex = testRepoIndex{apt: map[string][]string{"app": {"1.0.0"}, "tool": {"2.0.0"}}}
+		}
+		result, err := resolver.Resolve(t.Context(), deps, directives)
+		require.NoError(t, err)
+		if diff := cmp.Diff([]types.AptLockEntry{{Package: "app", Version: "1.0.0"}}, result.AptLocks); diff != "" {
+			t.Fatalf("unexpected apt locks with solver=%v (-want +got):\n%s", useSolver, diff)
+		}
+		for _, entry := range result.BundleManifest {
+			require.NotEqual(t, "tool", entry.Package)
+		}
+		want := []types.ResolutionRecord{
+			{Dependency: "apt:tool", Action: "block", Value: "excluded", Reason: "license review", Owner: "legal"},
+		}
+		if diff := cmp.Diff(want, result.Resolution.Records); diff != "" {
+			t.Fatalf("unexpected resolution records with solver=%v (-want +got):\n%s", useSolver, diff)
+		}
+		require.Empty(t, result.UnusedDirectives)
+	}
+}
+
+func TestResolverBlockDirectiveWithDependents(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app":    {{Version: "1.0.0", Depends: []string{"libb | libc"}}},
+			"strict": {{Version: "1.0.0", Depends: []string{"libb"}}},
+			"libb":   {{Version: "2.0.0"}},
+			"libc":   {{Version: "1.5.0"}},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	directives := []types.ResolutionDirective{
+		{Dependency: "apt:libb", Action: policies.ActionBlock, Reason: "cve", Owner: "security"},
+	}
+	resolver := NewResolverCore(repo, policy)
+	resolver.UseAptSolver = true
+
+	result, err := resolver.Resolve(t.Context(), []types.Dependency{{Name: "app", Type: types.DependencyTypeApt}}, directives)
+	require.NoError(t, err)
+	want := []types.AptLockEntry{
+		{Package: "app", Version: "1.0.0"},
+		{Package: "libc", Version: "1.5.0"},
+	}
+	if diff := cmp.Diff(want, result.AptLocks); diff != "" {
+		t.Fatalf("unexpected apt locks (-want +got):\n%s", diff)
+	}
+	require.Empty(t, result.UnusedDirectives)
+
+	_, err = resolver.Resolve(t.Context(), []types.Dependency{{Name: "strict", Type: types.DependencyTypeApt}}, directives)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "conflict likely involves: strict")
+}
+
+func TestResolverAptSolverRecordsSelectedAlternative(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app":  {{Version: "1.0.0", Depends: []string{"liba | libb", "libc"}}},
+			"libb": {{Version: "2.0.0"}},
+			"libc": {{Version: "1.5.0", Depends: []string{"libb | libd"}}},
+			"libd": {{Version: "1.0.0"}},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+	resolver.UseAptSolver = true
+
+	result, err := resolver.Resolve(t.Context(), []types.Dependency{{Name: "app", Type: types.DependencyTypeApt}}, nil)
+	require.NoError(t, err)
+	want := []types.ResolutionRecord{
+		{Dependency: "apt:libb", Action: "alternative", Value: "2.0.0", Reason: "selected libb to satisfy app's alternative liba|libb"},
+		{Dependency: "apt:libb", Action: "alternative", Value: "2.0.0", Reason: "selected libb to satisfy libc's alternative libb|libd"},
+	}
+	if diff := cmp.Diff(want, result.Resolution.Records); diff != "" {
+		t.Fatalf("unexpected resolution records (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverAptSolverPreferLockKeepsLockedVersion(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app": {
+				{Version: "1.0.0", Depends: []string{"liba"}},
+			},
+			"liba": {
+				{Version: "1.0.0"},
+				{Version: "2.0.0"},
+			},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+	resolver.UseAptSolver = true
+	resolver.PreferLock = map[string]string{"app": "1.0.0", "liba": "1.0.0"}
+
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+	}
+
+	result, err := resolver.Resolve(t.Context(), deps, nil)
+	require.NoError(t, err)
+	lockNames := map[string]string{}
+	for _, entry := range result.AptLocks {
+		lockNames[entry.Package] = entry.Version
+	}
+	if diff := cmp.Diff("1.0.0", lockNames["liba"]); diff != "" {
+		t.Fatalf("unexpected liba version (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverAllowUnresolvedReportsDroppedDemands(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"libfoo": {{Version: "1.0.0"}},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+	resolver.UseAptSolver = true
+	resolver.AllowUnresolved = true
+
+	deps := []types.Dependency{
+		{Name: "libfoo", Type: types.DependencyTypeApt},
+		{
+			Name: "libfoo-ng",
+			Type: types.DependencyTypeApt,
+			Constraints: []types.Constraint{
+				{Name: "libfoo-ng", Op: types.ConstraintOpGte, Version: "2.0"},
+			},
+		},
+	}
+	result, err := resolver.Resolve(t.Context(), deps, nil)
+	require.NoError(t, err)
+	if diff := cmp.Diff([]types.AptLockEntry{{Package: "libfoo", Version: "1.0.0"}}, result.AptLocks); diff != "" {
+		t.Fatalf("unexpected apt locks (-want +got):\n%s", diff)
+	}
+	require.Len(t, result.Unresolved, 1)
+	if diff := cmp.Diff("libfoo-ng", result.Unresolved[0].Name); diff != "" {
+		t.Fatalf("unexpected unresolved demand (-want +got):\n%s", diff)
+	}
+	want := []types.ResolutionRecord{
+		{Dependency: "apt:libfoo-ng", Action: "unresolved", Value: ">= 2.0", Reason: "no satisfiable candidate"},
+	}
+	if diff := cmp.Diff(want, result.Resolution.Records); diff != "" {
+		t.Fatalf("unexpected resolution records (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverBestEffortCollectsUnresolved(t *testing.T) {
+	repo := testRepoIndex{
+		apt: map[string][]string{
+			"libfoo": {"1.0.0"},
+			"libbar": {"1.0.0"},
+		},
+		pip: map[string][]string{
+			"requests": {"2.31.0"},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+		{Name: "pip-group", Mode: types.PackagingModeIndividual, Matches: []string{"pip:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+	resolver.BestEffort = true
+
+	deps := []types.Dependency{
+		{
+			Name: "libbar",
+			Type: types.DependencyTypeApt,
+			Constraints: []types.Constraint{
+				{Name: "libbar", Op: types.ConstraintOpGte, Version: "2.0"},
+			},
+		},
+		{Name: "libfoo", Type: types.DependencyTypeApt},
+		{Name: "libmissing", Type: types.DependencyTypeApt},
+		{Name: "requests", Type: types.DependencyTypePip},
+	}
+	result, err := resolver.Resolve(t.Context(), deps, nil)
+	require.NoError(t, err)
+
+	want := []types.UnresolvedDependency{
+		{
+			Dependency: deps[0],
+			Reason:     "conflict without resolution directive: libbar (no compatible version for libbar)",
+		},
+		{
+			Dependency: deps[2],
+			Reason:     "conflict without resolution directive: libmissing (no available versions for libmissing)",
+		},
+	}
+	if diff := cmp.Diff(want, result.Unresolved); diff != "" {
+		t.Fatalf("unexpected unresolved dependencies (-want +got):\n%s", diff)
+	}
+	require.Len(t, result.AptLocks, 2)
+
+	resolver.BestEffort = false
+	_, err = resolver.Resolve(t.Context(), deps, nil)
+	require.Error(t, err)
+}
+
+func TestResolverAptSolverOutputIsDeterministic(t *testing.T) {
+	repo := testRepoIndex{
+		aptPackages: map[string][]types.AptPackageVersion{
+			"app":    {{Version: "1.0.0", Depends: []string{"libbar | libbaz", "mta"}}},
+			"libbar": {{Version: "1.0"}, {Version: "2.0"}},
+			"libbaz": {{Version: "1.0"}, {Version: "2.0"}},
+			"exim4":  {{Version: "4.95", Provides: []string{"mta"}}},
+			"sendmail": {
+				{Version: "8.17", Provides: []string{"mta"}},
+			},
+			"postfix": {{Version: "3.6.4", Provides: []string{"mta"}}},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+	resolver.UseAptSolver = true
+	deps := []types.Dependency{
+		{Name: "app", Type: types.DependencyTypeApt},
+	}
+
+	var first []byte
+	for i := 0; i < 20; i++ {
+		result, err := resolver.Resolve(t.Context(), deps, nil)
+		require.NoError(t, err)
+		outputDir := t.TempDir()
+		require.NoError(t, adapters.NewOutputFileAdapter(outputDir).WriteAptLock(result.AptLocks))
+		lock, err := os.ReadFile(filepath.Join(outputDir, "apt.lock"))
+		require.NoError(t, err)
+		if first == nil {
+			first = lock
+			require.Len(t, result.AptLocks, 3)
+			continue
+		}
+		if diff := cmp.Diff(string(first), string(lock)); diff != "" {
+			t.Fatalf("unexpected apt.lock on run %d (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestResolverAptOnlyExcludesPipDependencies(t *testing.T) {
+	repo := testRepoIndex{
+		apt: map[string][]string{
+			"libfoo": {"1.0.0"},
+		},
+		pip: map[string][]string{
+			"pandas": {"2.1.4"},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+		{Name: "pip-group", Mode: types.PackagingModeMetaBundle, Matches: []string{"pip:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+	resolver.AptOnly = true
+
+	deps := []types.Dependency{
+		{Name: "libfoo", Type: types.DependencyTypeApt},
+		{Name: "pandas", Type: types.DependencyTypePip},
+		{Name: "missing-on-index", Type: types.DependencyTypePip},
+	}
+	result, err := resolver.Resolve(t.Context(), deps, nil)
+	require.NoError(t, err)
+	if diff := cmp.Diff([]types.AptLockEntry{{Package: "libfoo", Version: "1.0.0"}}, result.AptLocks); diff != "" {
+		t.Fatalf("unexpected apt locks (-want +got):\n%s", diff)
+	}
+	wantManifest := []types.BundleManifestEntry{
+		{Group: "apt-group", Mode: types.PackagingModeIndividual, Package: "libfoo", Version: "1.0.0"},
+	}
+	if diff := cmp.Diff(wantManifest, result.BundleManifest); diff != "" {
+		t.Fatalf("unexpected bundle manifest (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverPipSolverLocksRootDependencies(t *testing.T) {
+	repo := testRepoIndex{
+		pipPackages: map[string][]types.PipPackageVersion{
+			"opencv-python": {
+				{Version: "4.8.0", RequiresDist: []string{"numpy<2"}},
+				{Version: "4.10.0", RequiresDist: []string{"numpy>=2"}},
+			},
+			"numpy": {{Version: "1.26.4"}, {Version: "2.0.0"}},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "pip-group", Mode: types.PackagingModeMetaBundle, Matches: []string{"pip:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	resolver := NewResolverCore(repo, policy)
+	resolver.UsePipSolver = true
+
+	deps := []types.Dependency{
+		{Name: "opencv-python", Type: types.DependencyTypePip},
+		{Name: "numpy", Type: types.DependencyTypePip, Constraints: []types.Constraint{
+			{Name: "numpy", Op: types.ConstraintOpLt, Version: "2", Source: "product"},
+		}},
+	}
+	result, err := resolver.Resolve(t.Context(), deps, nil)
+	require.NoError(t, err)
+	wantLocks := []types.AptLockEntry{
+		{Package: "python3-numpy", Version: "1.26.4"},
+		{Package: "python3-opencv-python", Version: "4.8.0"},
+	}
+	if diff := cmp.Diff(wantLocks, result.AptLocks); diff != "" {
+		t.Fatalf("unexpected apt locks (-want +got):\n%s", diff)
+	}
+}
+
+func TestMergeDependenciesCombinesSchemaAndExportTagDeps(t *testing.T) {
+	deps := []types.Dependency{
+		{Name: "libfmt-dev", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libfmt-dev", Op: types.ConstraintOpLt, Version: "9.0", Source: "package_xml:debian_depend"},
+		}},
+		{Name: "libfmt-dev", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libfmt-dev", Op: types.ConstraintOpGte, Version: "8.0", Source: "schema"},
+			{Name: "libfmt-dev", Op: types.ConstraintOpGte, Version: "8.1", Source: "package_xml:exec_depend"},
+		}},
+		{Name: "libyaml-dev", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libyaml-dev", Op: types.ConstraintOpNone, Source: "product:manual:apt"},
+			{Name: "libyaml-dev", Op: types.ConstraintOpGte, Version: "0.2", Source: "schema"},
+			{Name: "libyaml-dev", Op: types.ConstraintOpGte, Version: "0.1", Source: "profile:manual:apt"},
+		}},
+	}
+
+	merged := mergeDependencies(deps)
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Name < merged[j].Name })
+	want := []types.Dependency{
+		{Name: "libfmt-dev", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libfmt-dev", Op: types.ConstraintOpLt, Version: "9.0", Source: "package_xml:debian_depend"},
+			{Name: "libfmt-dev", Op: types.ConstraintOpGte, Version: "8.0", Source: "schema"},
+			{Name: "libfmt-dev", Op: types.ConstraintOpGte, Version: "8.1", Source: "package_xml:exec_depend"},
+		}},
+		{Name: "libyaml-dev", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libyaml-dev", Op: types.ConstraintOpGte, Version: "0.1", Source: "profile:manual:apt"},
+		}},
+	}
+	if diff := cmp.Diff(want, merged); diff != "" {
+		t.Fatalf("unexpected merged dependencies (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverPackageXMLConstraintPolicy(t *testing.T) {
+	repo := testRepoIndex{
+		apt: map[string][]string{
+			"libfoo": {"1.0.0", "1.5.0", "2.0.0"},
+			"libbar": {"1.0.0", "3.0.0"},
+		},
+	}
+	policy := policies.NewPackagingPolicy([]types.PackagingGroup{
+		{Name: "apt-group", Mode: types.PackagingModeIndividual, Matches: []string{"apt:*"}, Targets: []string{"ubuntu-22.04"}},
+	}, "ubuntu-22.04")
+	deps := []types.Dependency{
+		{Name: "libfoo", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libfoo", Op: types.ConstraintOpEq, Version: "1.5.0", Source: "package_xml:debian_depend"},
+		}},
+		{Name: "libbar", Type: types.DependencyTypeApt, Constraints: []types.Constraint{
+			{Name: "libbar", Op: types.ConstraintOpLt, Version: "2.0.0", Source: "package_xml:exec_depend"},
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		policy types.PackageXMLConstraintPolicy
+		want   []types.AptLockEntry
+	}{
+		{
+			name:   "hard",
+			policy: types.PackageXMLConstraintsHard,
+			want:   []types.AptLockEntry{{Package: "libbar", Version: "1.0.0"}, {Package: "libfoo", Version: "1.5.0"}},
+		},
+		{
+			name:   "soft",
+			policy: types.PackageXMLConstraintsSoft,
+			want:   []types.AptLockEntry{{Package: "libbar", Version: "3.0.0"}, {Package: "libfoo", Version: "2.0.0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := NewResolverCore(repo, policy)
+			resolver.PackageXMLConstraints = tt.policy
+			result, err := resolver.Resolve(t.Context(), deps, nil)
+			require.NoError(t, err)
+			if diff := cmp.Diff(tt.want, result.AptLocks); diff != "" {
+				t.Fatalf("unexpected apt locks (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"avular-packages/internal/shared"
+	"avular-packages/internal/types"
+)
+
+// BuildCycloneDXBOM describes the resolved snapshot as a CycloneDX
+// document. Bundle manifest entries that were locked as python3-<name>
+// are reported as PyPI components; every other apt.lock entry becomes a
+// Debian component. The serial number is derived from the snapshot ID so
+// regenerating the SBOM for a snapshot yields the same document.
+func BuildCycloneDXBOM(intent types.SnapshotIntent, locks []types.AptLockEntry, manifest []types.BundleManifestEntry) types.CycloneDXBOM {
+	lockVersions := make(map[string]string, len(locks))
+	for _, entry := range locks {
+		lockVersions[entry.Package] = entry.Version
+	}
+
+	claimed := map[string]struct{}{}
+	var components []types.CycloneDXComponent
+	for _, entry := range manifest {
+		properties := []types.CycloneDXProperty{
+			{Name: "avular:packaging-group", Value: entry.Group},
+			{Name: "avular:packaging-mode", Value: string(entry.Mode)},
+		}
+		if version, ok := lockVersions[entry.Package]; ok && version == entry.Version {
+			claimed[entry.Package] = struct{}{}
+			components = append(components, debComponent(entry.Package, entry.Version, properties))
+			continue
+		}
+		pipLock := aptLockPackageName(types.Dependency{Name: entry.Package, Type: types.DependencyTypePip})
+		if _, ok := lockVersions[pipLock]; ok {
+			claimed[pipLock] = struct{}{}
+			components = append(components, pypiComponent(entry.Package, entry.Version, properties))
+			continue
+		}
+		components = append(components, debComponent(entry.Package, entry.Version, properties))
+	}
+	for _, entry := range locks {
+		if _, ok := claimed[entry.Package]; ok {
+			continue
+		}
+		components = append(components, debComponent(entry.Package, entry.Version, nil))
+	}
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i].PURL < components[j].PURL
+	})
+
+	name := strings.TrimSpace(intent.Repository)
+	if name == "" {
+		name = "avular-packages snapshot"
+	}
+	return types.CycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: snapshotSerialNumber(intent.SnapshotID),
+		Version:      1,
+		Metadata: types.CycloneDXMetadata{
+			Timestamp: intent.CreatedAt,
+			Tools: types.CycloneDXTools{
+				Components: []types.CycloneDXComponent{{Type: "application", Name: "avular-packages"}},
+			},
+			Component: types.CycloneDXComponent{
+				Type:    "application",
+				BOMRef:  "snapshot:" + intent.SnapshotID,
+				Name:    name,
+				Version: intent.SnapshotID,
+			},
+			Properties: []types.CycloneDXProperty{
+				{Name: "avular:snapshot-id", Value: intent.SnapshotID},
+				{Name: "avular:channel", Value: intent.Channel},
+			},
+		},
+		Components: components,
+	}
+}
+
+func debComponent(name string, version string, properties []types.CycloneDXProperty) types.CycloneDXComponent {
+	purl := fmt.Sprintf("pkg:deb/ubuntu/%s@%s", name, url.QueryEscape(version))
+	return types.CycloneDXComponent{
+		Type:       "library",
+		BOMRef:     purl,
+		Name:       name,
+		Version:    version,
+		PURL:       purl,
+		Properties: properties,
+	}
+}
+
+func pypiComponent(name string, version string, properties []types.CycloneDXProperty) types.CycloneDXComponent {
+	purl := fmt.Sprintf("pkg:pypi/%s@%s", shared.NormalizePipName(name), url.QueryEscape(version))
+	return types.CycloneDXComponent{
+		Type:       "library",
+		BOMRef:     purl,
+		Name:       name,
+		Version:    version,
+		PURL:       purl,
+		Properties: properties,
+	}
+}
+
+// snapshotSerialNumber returns a name-based RFC 9562 (version 8) UUID URN
+// for the snapshot.
+func snapshotSerialNumber(snapshotID string) string {
+	sum := sha256.Sum256([]byte("avular-packages/snapshot/" + snapshotID))
+	b := sum[:16]
+	b[6] = (b[6] & 0x0f) | 0x80
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
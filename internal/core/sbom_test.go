@@ -0,0 +1,54 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/assert"
+
+	"avular-packages/internal/types"
+)
+
+func TestBuildCycloneDXBOM(t *testing.T) {
+	intent := types.SnapshotIntent{
+		Repository: "avular",
+		Channel:    "stable",
+		SnapshotID: "avular-abc123",
+		CreatedAt:  "2026-01-01T00:00:00Z",
+	}
+	locks := []types.AptLockEntry{
+		{Package: "libfoo", Version: "1:2.0-1"},
+		{Package: "libbar", Version: "1.0"},
+		{Package: "python3-opencv-python", Version: "4.8.0"},
+	}
+	manifest := []types.BundleManifestEntry{
+		{Group: "runtime", Mode: types.PackagingModeIndividual, Package: "libfoo", Version: "1:2.0-1"},
+		{Group: "python", Mode: types.PackagingModeMetaBundle, Package: "opencv_python", Version: "4.8.0"},
+	}
+
+	bom := BuildCycloneDXBOM(intent, locks, manifest)
+
+	assert.Equal(t, "CycloneDX", bom.BOMFormat)
+	assert.Equal(t, "1.5", bom.SpecVersion)
+	assert.Regexp(t, `^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-8[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, bom.SerialNumber)
+	assert.Equal(t, bom.SerialNumber, BuildCycloneDXBOM(intent, nil, nil).SerialNumber)
+	assert.Equal(t, "avular-abc123", bom.Metadata.Component.Version)
+	assert.Contains(t, bom.Metadata.Properties, types.CycloneDXProperty{Name: "avular:snapshot-id", Value: "avular-abc123"})
+
+	purls := make([]string, 0, len(bom.Components))
+	for _, component := range bom.Components {
+		purls = append(purls, component.PURL)
+	}
+	want := []string{
+		"pkg:deb/ubuntu/libbar@1.0",
+		"pkg:deb/ubuntu/libfoo@1%3A2.0-1",
+		"pkg:pypi/opencv-python@4.8.0",
+	}
+	if diff := cmp.Diff(want, purls); diff != "" {
+		t.Fatalf("unexpected component purls (-want +got):\n%s", diff)
+	}
+	assert.Equal(t, []types.CycloneDXProperty{
+		{Name: "avular:packaging-group", Value: "python"},
+		{Name: "avular:packaging-mode", Value: string(types.PackagingModeMetaBundle)},
+	}, bom.Components[2].Properties)
+}
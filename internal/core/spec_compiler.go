@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	assert "github.com/ZanzyTHEbar/assert-lib"
 	"github.com/ZanzyTHEbar/errbuilder-go"
@@ -152,6 +153,38 @@ func validatePublish(repo types.PublishRepository) error {
 	return nil
 }
 
+// ValidateAllowedScopes rejects packaging groups whose scope is not in
+// allowed, e.g. a dev or test group in a product built for runtime only.
+// An empty allowed set accepts every scope.
+func ValidateAllowedScopes(groups []types.PackagingGroup, allowed []string) error {
+	if len(allowed) == 0 {
+		return nil
+	}
+	allowedSet := map[string]struct{}{}
+	for _, scope := range allowed {
+		scope = strings.TrimSpace(scope)
+		if _, ok := validPackagingScopes[scope]; !ok {
+			return errbuilder.New().
+				WithCode(errbuilder.CodeInvalidArgument).
+				WithMsg(fmt.Sprintf("invalid allowed scope %s (expected runtime, dev, test or doc)", scope))
+		}
+		allowedSet[scope] = struct{}{}
+	}
+	var rejected []string
+	for _, group := range groups {
+		if _, ok := allowedSet[group.Scope]; !ok {
+			rejected = append(rejected, fmt.Sprintf("%s (%s)", group.Name, group.Scope))
+		}
+	}
+	if len(rejected) > 0 {
+		return errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("packaging groups with disallowed scope: %s (allowed: %s)",
+				strings.Join(rejected, ", "), strings.Join(allowed, ", ")))
+	}
+	return nil
+}
+
 func validateResolutions(resolutions []types.ResolutionDirective) error {
 	for _, directive := range resolutions {
 		if err := validateResolutionDirective(directive); err != nil {
@@ -200,9 +233,48 @@ func validateResolutionDirective(directive types.ResolutionDirective) error {
 			WithCode(errbuilder.CodeInvalidArgument).
 			WithMsg("resolution directive value must not be empty for force/replace actions")
 	}
+	if _, _, err := directiveExpiry(directive); err != nil {
+		return err
+	}
 	return nil
 }
 
+// directiveExpiry parses the optional expires_at of directive. Dates
+// (YYYY-MM-DD) expire at the end of that day in UTC; RFC 3339 timestamps
+// expire at the given instant. ok is false when no expiry is set.
+func directiveExpiry(directive types.ResolutionDirective) (time.Time, bool, error) {
+	value := strings.TrimSpace(directive.ExpiresAt)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date.AddDate(0, 0, 1), true, nil
+	}
+	if instant, err := time.Parse(time.RFC3339, value); err == nil {
+		return instant, true, nil
+	}
+	return time.Time{}, false, errbuilder.New().
+		WithCode(errbuilder.CodeInvalidArgument).
+		WithMsg(fmt.Sprintf("resolution directive %s has invalid expires_at %q (expected YYYY-MM-DD or RFC 3339)", directive.Dependency, directive.ExpiresAt))
+}
+
+// ExpiredDirectives returns the directives, in order, whose expires_at
+// lies at or before now. Directives with an invalid expiry are rejected
+// by spec validation and skipped here.
+func ExpiredDirectives(directives []types.ResolutionDirective, now time.Time) []types.ResolutionDirective {
+	var expired []types.ResolutionDirective
+	for _, directive := range directives {
+		expiry, ok, err := directiveExpiry(directive)
+		if err != nil || !ok {
+			continue
+		}
+		if !now.Before(expiry) {
+			expired = append(expired, directive)
+		}
+	}
+	return expired
+}
+
 func isTypedDependency(value string) bool {
 	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
 	if len(parts) != 2 {
@@ -2,7 +2,9 @@ package core
 
 import (
 	"testing"
+	"time"
 
+	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/require"
 
 	"avular-packages/internal/types"
@@ -60,6 +62,24 @@ func TestSpecCompilerValidateSpecCases(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "resolution directive with invalid expiry",
+			build: func() types.Spec {
+				spec := baseProfileSpec()
+				spec.Resolutions = []types.ResolutionDirective{
+					{
+						Dependency: "apt:libfoo",
+						Action:     "force",
+						Value:      "1.2.0",
+						Reason:     "test",
+						Owner:      "team",
+						ExpiresAt:  "next quarter",
+					},
+				}
+				return spec
+			},
+			wantErr: true,
+		},
 		{
 			name: "valid product spec",
 			build: func() types.Spec {
@@ -170,3 +190,59 @@ func baseProductSpec() types.Spec {
 		},
 	}
 }
+
+func TestExpiredDirectives(t *testing.T) {
+	directives := []types.ResolutionDirective{
+		{Dependency: "apt:libold", Action: "force", ExpiresAt: "2026-01-31"},
+		{Dependency: "apt:libtoday", Action: "force", ExpiresAt: "2026-02-01"},
+		{Dependency: "apt:libstamp", Action: "relax", ExpiresAt: "2026-02-01T08:00:00Z"},
+		{Dependency: "pip:forever", Action: "block"},
+	}
+	now := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	var got []string
+	for _, directive := range ExpiredDirectives(directives, now) {
+		got = append(got, directive.Dependency)
+	}
+	if diff := cmp.Diff([]string{"apt:libold", "apt:libstamp"}, got); diff != "" {
+		t.Fatalf("unexpected expired directives (-want +got):\n%s", diff)
+	}
+}
+
+func TestValidateAllowedScopes(t *testing.T) {
+	groups := []types.PackagingGroup{
+		{Name: "runtime-libs", Scope: "runtime"},
+		{Name: "dev-tools", Scope: "dev"},
+	}
+
+	tests := []struct {
+		name    string
+		allowed []string
+		wantErr string
+	}{
+		{name: "no restriction", allowed: nil},
+		{name: "all scopes allowed", allowed: []string{"runtime", "dev"}},
+		{
+			name:    "dev group rejected for runtime only",
+			allowed: []string{"runtime"},
+			wantErr: "packaging groups with disallowed scope: dev-tools (dev) (allowed: runtime)",
+		},
+		{
+			name:    "unknown allowed scope",
+			allowed: []string{"prod"},
+			wantErr: "invalid allowed scope prod",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAllowedScopes(groups, tt.allowed)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
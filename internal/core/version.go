@@ -14,11 +14,13 @@ import (
 
 // preparedConstraint is a pre-parsed version constraint ready for
 // repeated comparison. For APT it holds a parsed Debian version; for
-// Pip it holds a PEP 440 specifier set.
+// Pip it holds a PEP 440 specifier set. epochless records that an APT
+// constraint was written without an explicit epoch.
 type preparedConstraint struct {
-	op  types.ConstraintOp
-	deb debversion.Version
-	pep pep440.Specifiers
+	op        types.ConstraintOp
+	deb       debversion.Version
+	pep       pep440.Specifiers
+	epochless bool
 }
 
 // versionCache memoizes parsed version objects to avoid repeated parsing
@@ -108,10 +110,41 @@ func (c *versionCache) compare(a string, b string) int {
 	}
 }
 
+// VersionDowngrade is a package whose resolved version is lower than
+// the version a previous lock pinned.
+type VersionDowngrade struct {
+	Package  string
+	Previous string
+	Current  string
+}
+
+// AptDowngrades compares resolved apt locks with previously locked
+// versions and returns the packages that moved to a lower version,
+// sorted by name. Packages missing from either side are not downgrades.
+func AptDowngrades(previous map[string]string, current []types.AptLockEntry) []VersionDowngrade {
+	cache := newVersionCache(types.DependencyTypeApt)
+	var downgrades []VersionDowngrade
+	for _, entry := range current {
+		locked, ok := previous[entry.Package]
+		if !ok {
+			continue
+		}
+		if cmp, ok := cache.compareStrict(entry.Version, locked); ok && cmp < 0 {
+			downgrades = append(downgrades, VersionDowngrade{Package: entry.Package, Previous: locked, Current: entry.Version})
+		}
+	}
+	sort.Slice(downgrades, func(i, j int) bool {
+		return downgrades[i].Package < downgrades[j].Package
+	})
+	return downgrades
+}
+
 // bestCompatibleVersion selects the highest version from available that
-// satisfies all of the dependency's constraints. Returns an error if
-// no compatible version exists.
-func bestCompatibleVersion(dep types.Dependency, available []string) (string, error) {
+// satisfies all of the dependency's constraints. Pip pre-releases are
+// only selected as PEP 440 allows (see finalPipReleases) unless
+// allowPrerelease is set. Returns an error if no compatible version
+// exists.
+func bestCompatibleVersion(dep types.Dependency, available []string, allowPrerelease bool) (string, error) {
 	if len(available) == 0 {
 		return "", errbuilder.New().
 			WithCode(errbuilder.CodeNotFound).
@@ -122,6 +155,11 @@ func bestCompatibleVersion(dep types.Dependency, available []string) (string, er
 	if err != nil {
 		return "", err
 	}
+	if first, second, ok := findContradiction(dep.Constraints, cache); ok {
+		return "", errbuilder.New().
+			WithCode(errbuilder.CodeFailedPrecondition).
+			WithMsg(fmt.Sprintf("contradictory constraints for %s: %s and %s", dep.Name, describeConstraint(first), describeConstraint(second)))
+	}
 	var candidates []string
 	for _, version := range available {
 		ok, err := satisfiesAll(dep.Type, version, parsedConstraints, cache)
@@ -137,12 +175,134 @@ func bestCompatibleVersion(dep types.Dependency, available []string) (string, er
 			WithCode(errbuilder.CodeFailedPrecondition).
 			WithMsg(fmt.Sprintf("no compatible version for %s", dep.Name))
 	}
+	if dep.Type == types.DependencyTypePip && !allowPrerelease {
+		candidates = finalPipReleases(candidates, dep.Constraints, cache)
+	}
 	sort.Slice(candidates, func(i, j int) bool {
 		return cache.compare(candidates[i], candidates[j]) > 0
 	})
 	return candidates[0], nil
 }
 
+// finalPipReleases drops pre-releases and dev releases from candidates
+// that satisfy constraints, following PEP 440: they are kept when a
+// constraint names a pre-release, e.g. ">=2.0rc1", or when no final
+// release satisfies the constraints.
+func finalPipReleases(candidates []string, constraints []types.Constraint, cache *versionCache) []string {
+	for _, constraint := range constraints {
+		if isPipPrerelease(constraint.Version, cache) {
+			return candidates
+		}
+	}
+	var finals []string
+	for _, version := range candidates {
+		if !isPipPrerelease(version, cache) {
+			finals = append(finals, version)
+		}
+	}
+	if len(finals) == 0 {
+		return candidates
+	}
+	return finals
+}
+
+func isPipPrerelease(version string, cache *versionCache) bool {
+	parsed, err := cache.pepVersion(strings.TrimSpace(version))
+	return err == nil && parsed.IsPreRelease()
+}
+
+// versionBound is one end of the version range admitted by a constraint.
+type versionBound struct {
+	version   string
+	inclusive bool
+}
+
+// constraintBounds converts a range-style constraint into its lower and
+// upper bound. Operators that do not describe a contiguous range (!=, ~=)
+// yield no bounds.
+func constraintBounds(constraint types.Constraint) (lower *versionBound, upper *versionBound) {
+	bound := &versionBound{version: constraint.Version}
+	switch constraint.Op {
+	case types.ConstraintOpEq, types.ConstraintOpEq2:
+		bound.inclusive = true
+		return bound, bound
+	case types.ConstraintOpGte:
+		bound.inclusive = true
+		return bound, nil
+	case types.ConstraintOpGt:
+		return bound, nil
+	case types.ConstraintOpLte:
+		bound.inclusive = true
+		return nil, bound
+	case types.ConstraintOpLt:
+		return nil, bound
+	default:
+		return nil, nil
+	}
+}
+
+// findContradiction returns the first pair of constraints whose ranges
+// cannot overlap, such as ">= 2.0" and "< 1.0". Constraints with
+// unparseable versions are never reported.
+func findContradiction(constraints []types.Constraint, cache *versionCache) (types.Constraint, types.Constraint, bool) {
+	for i := 0; i < len(constraints); i++ {
+		for j := i + 1; j < len(constraints); j++ {
+			if boundsExclude(constraints[i], constraints[j], cache) || boundsExclude(constraints[j], constraints[i], cache) {
+				return constraints[i], constraints[j], true
+			}
+		}
+	}
+	return types.Constraint{}, types.Constraint{}, false
+}
+
+// boundsExclude reports whether the lower bound of a lies above the
+// upper bound of b.
+func boundsExclude(a types.Constraint, b types.Constraint, cache *versionCache) bool {
+	lower, _ := constraintBounds(a)
+	_, upper := constraintBounds(b)
+	if lower == nil || upper == nil {
+		return false
+	}
+	cmp, ok := cache.compareStrict(lower.version, upper.version)
+	if !ok {
+		return false
+	}
+	return cmp > 0 || (cmp == 0 && !(lower.inclusive && upper.inclusive))
+}
+
+// compareStrict is compare but reports whether both versions parsed.
+func (c *versionCache) compareStrict(a string, b string) (int, bool) {
+	switch c.depType {
+	case types.DependencyTypeApt:
+		if _, err := c.debVersion(a); err != nil {
+			return 0, false
+		}
+		if _, err := c.debVersion(b); err != nil {
+			return 0, false
+		}
+	case types.DependencyTypePip:
+		if _, err := c.pepVersion(a); err != nil {
+			return 0, false
+		}
+		if _, err := c.pepVersion(b); err != nil {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	return c.compare(a, b), true
+}
+
+// describeConstraint renders a constraint with its source for error
+// messages, e.g. ">= 2.0 (product:demo)".
+func describeConstraint(constraint types.Constraint) string {
+	text := fmt.Sprintf("%s %s", constraint.Op, constraint.Version)
+	if source := strings.TrimSpace(constraint.Source); source != "" {
+		text += fmt.Sprintf(" (%s)", source)
+	}
+	return text
+}
+
 // prepareConstraints parses each constraint's version string upfront so
 // it can be reused across multiple candidate comparisons.
 func prepareConstraints(depType types.DependencyType, constraints []types.Constraint, cache *versionCache) ([]preparedConstraint, error) {
@@ -157,7 +317,11 @@ func prepareConstraints(depType types.DependencyType, constraints []types.Constr
 			if err != nil {
 				return nil, err
 			}
-			out = append(out, preparedConstraint{op: constraint.Op, deb: parsed})
+			out = append(out, preparedConstraint{
+				op:        constraint.Op,
+				deb:       parsed,
+				epochless: !strings.Contains(constraint.Version, ":"),
+			})
 		case types.DependencyTypePip:
 			spec, err := cache.pepSpec(toPep440Spec(constraint))
 			if err != nil {
@@ -191,6 +355,10 @@ func satisfiesAll(depType types.DependencyType, version string, constraints []pr
 }
 
 // satisfiesDeb checks a Debian version against all prepared constraints.
+// Ordering follows Debian policy, where a missing epoch is epoch 0, so
+// ">= 2.0" accepts "1:2.0" but "<= 2.0" rejects it. Equality is the one
+// exception: a constraint without an epoch ignores the candidate's epoch,
+// so "= 1.2.3" matches "1:1.2.3". Writing "= 0:1.2.3" pins epoch 0.
 func satisfiesDeb(version string, constraints []preparedConstraint, cache *versionCache) (bool, error) {
 	v, err := cache.debVersion(version)
 	if err != nil {
@@ -200,7 +368,17 @@ func satisfiesDeb(version string, constraints []preparedConstraint, cache *versi
 		c := constraint.deb
 		switch constraint.op {
 		case types.ConstraintOpEq, types.ConstraintOpEq2:
-			if !v.Equal(c) {
+			if v.Equal(c) {
+				continue
+			}
+			if !constraint.epochless || v.Epoch() == 0 {
+				return false, nil
+			}
+			bare, err := cache.debVersion(withoutEpoch(version))
+			if err != nil {
+				return false, err
+			}
+			if !bare.Equal(c) {
 				return false, nil
 			}
 		case types.ConstraintOpGte:
@@ -228,6 +406,14 @@ func satisfiesDeb(version string, constraints []preparedConstraint, cache *versi
 	return true, nil
 }
 
+// withoutEpoch strips the "epoch:" prefix from a Debian version.
+func withoutEpoch(version string) string {
+	if _, rest, ok := strings.Cut(version, ":"); ok {
+		return rest
+	}
+	return version
+}
+
 // satisfiesPep440 checks a PEP 440 version against all prepared specifiers.
 func satisfiesPep440(version string, constraints []preparedConstraint, cache *versionCache) (bool, error) {
 	parsed, err := cache.pepVersion(version)
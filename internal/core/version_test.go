@@ -102,14 +102,14 @@ func TestVersionCacheCompareInvalidVersion(t *testing.T) {
 
 func TestBestCompatibleVersionNoAvailable(t *testing.T) {
 	dep := types.Dependency{Name: "libfoo", Type: types.DependencyTypeApt}
-	_, err := bestCompatibleVersion(dep, nil)
+	_, err := bestCompatibleVersion(dep, nil, false)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no available versions")
 }
 
 func TestBestCompatibleVersionNoConstraints(t *testing.T) {
 	dep := types.Dependency{Name: "libfoo", Type: types.DependencyTypeApt}
-	version, err := bestCompatibleVersion(dep, []string{"1.0.0", "2.0.0", "0.5.0"})
+	version, err := bestCompatibleVersion(dep, []string{"1.0.0", "2.0.0", "0.5.0"}, false)
 	require.NoError(t, err)
 	// Should pick the highest
 	assert.Equal(t, "2.0.0", version)
@@ -123,7 +123,7 @@ func TestBestCompatibleVersionWithConstraint(t *testing.T) {
 			{Name: "libfoo", Op: types.ConstraintOpLte, Version: "1.5.0"},
 		},
 	}
-	version, err := bestCompatibleVersion(dep, []string{"1.0.0", "1.5.0", "2.0.0"})
+	version, err := bestCompatibleVersion(dep, []string{"1.0.0", "1.5.0", "2.0.0"}, false)
 	require.NoError(t, err)
 	assert.Equal(t, "1.5.0", version)
 }
@@ -136,11 +136,61 @@ func TestBestCompatibleVersionPinExact(t *testing.T) {
 			{Name: "libfoo", Op: types.ConstraintOpEq, Version: "1.0.0"},
 		},
 	}
-	version, err := bestCompatibleVersion(dep, []string{"1.0.0", "2.0.0"})
+	version, err := bestCompatibleVersion(dep, []string{"1.0.0", "2.0.0"}, false)
 	require.NoError(t, err)
 	assert.Equal(t, "1.0.0", version)
 }
 
+func TestBestCompatibleVersionSkipsPipPrereleases(t *testing.T) {
+	available := []string{"1.0.0", "2.0.0rc1", "2.0.0.dev1"}
+	tests := []struct {
+		name            string
+		constraints     []types.Constraint
+		available       []string
+		allowPrerelease bool
+		want            string
+	}{
+		{
+			name:        "open constraint",
+			constraints: []types.Constraint{{Name: "demo", Op: types.ConstraintOpGte, Version: "1.0"}},
+			available:   available,
+			want:        "1.0.0",
+		},
+		{
+			name:        "constraint names a pre-release",
+			constraints: []types.Constraint{{Name: "demo", Op: types.ConstraintOpGte, Version: "2.0.0.dev1"}},
+			available:   available,
+			want:        "2.0.0rc1",
+		},
+		{
+			name:        "pinned pre-release",
+			constraints: []types.Constraint{{Name: "demo", Op: types.ConstraintOpEq2, Version: "2.0.0.dev1"}},
+			available:   available,
+			want:        "2.0.0.dev1",
+		},
+		{
+			name:      "only pre-releases",
+			available: []string{"2.0.0rc1", "2.0.0.dev1"},
+			want:      "2.0.0rc1",
+		},
+		{
+			name:            "open constraint with pre-releases allowed",
+			constraints:     []types.Constraint{{Name: "demo", Op: types.ConstraintOpGte, Version: "1.0"}},
+			available:       available,
+			allowPrerelease: true,
+			want:            "2.0.0rc1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dep := types.Dependency{Name: "demo", Type: types.DependencyTypePip, Constraints: tt.constraints}
+			version, err := bestCompatibleVersion(dep, tt.available, tt.allowPrerelease)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, version)
+		})
+	}
+}
+
 func TestBestCompatibleVersionNoMatch(t *testing.T) {
 	dep := types.Dependency{
 		Name: "libfoo",
@@ -149,11 +199,89 @@ func TestBestCompatibleVersionNoMatch(t *testing.T) {
 			{Name: "libfoo", Op: types.ConstraintOpGte, Version: "5.0.0"},
 		},
 	}
-	_, err := bestCompatibleVersion(dep, []string{"1.0.0", "2.0.0"})
+	_, err := bestCompatibleVersion(dep, []string{"1.0.0", "2.0.0"}, false)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "no compatible version")
 }
 
+func TestBestCompatibleVersionReportsContradiction(t *testing.T) {
+	dep := types.Dependency{
+		Name: "libfoo",
+		Type: types.DependencyTypeApt,
+		Constraints: []types.Constraint{
+			{Name: "libfoo", Op: types.ConstraintOpGte, Version: "2.0", Source: "package_xml:debian_depend"},
+			{Name: "libfoo", Op: types.ConstraintOpLt, Version: "1.0", Source: "package_xml:depend"},
+		},
+	}
+	_, err := bestCompatibleVersion(dep, []string{"0.9", "1.5", "2.1"}, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "contradictory constraints for libfoo")
+	assert.Contains(t, err.Error(), ">= 2.0 (package_xml:debian_depend)")
+	assert.Contains(t, err.Error(), "< 1.0 (package_xml:depend)")
+}
+
+func TestFindContradiction(t *testing.T) {
+	tests := []struct {
+		name        string
+		depType     types.DependencyType
+		constraints []types.Constraint
+		want        bool
+	}{
+		{
+			name:    "disjoint range",
+			depType: types.DependencyTypeApt,
+			constraints: []types.Constraint{
+				{Op: types.ConstraintOpGte, Version: "2.0"},
+				{Op: types.ConstraintOpLt, Version: "1.0"},
+			},
+			want: true,
+		},
+		{
+			name:    "touching exclusive bound",
+			depType: types.DependencyTypeApt,
+			constraints: []types.Constraint{
+				{Op: types.ConstraintOpGte, Version: "2.0"},
+				{Op: types.ConstraintOpLt, Version: "2.0"},
+			},
+			want: true,
+		},
+		{
+			name:    "touching inclusive bounds",
+			depType: types.DependencyTypeApt,
+			constraints: []types.Constraint{
+				{Op: types.ConstraintOpGte, Version: "2.0"},
+				{Op: types.ConstraintOpLte, Version: "2.0"},
+			},
+			want: false,
+		},
+		{
+			name:    "different exact pins",
+			depType: types.DependencyTypePip,
+			constraints: []types.Constraint{
+				{Op: types.ConstraintOpEq2, Version: "1.26.4"},
+				{Op: types.ConstraintOpEq2, Version: "2.0.0"},
+			},
+			want: true,
+		},
+		{
+			name:    "overlapping range",
+			depType: types.DependencyTypePip,
+			constraints: []types.Constraint{
+				{Op: types.ConstraintOpGte, Version: "1.0"},
+				{Op: types.ConstraintOpLt, Version: "2.0"},
+				{Op: types.ConstraintOpNe, Version: "1.5"},
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, got := findContradiction(tt.constraints, newVersionCache(tt.depType))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 func TestBestCompatibleVersionPip(t *testing.T) {
 	dep := types.Dependency{
 		Name: "numpy",
@@ -162,7 +290,7 @@ func TestBestCompatibleVersionPip(t *testing.T) {
 			{Name: "numpy", Op: types.ConstraintOpGte, Version: "1.20.0"},
 		},
 	}
-	version, err := bestCompatibleVersion(dep, []string{"1.19.0", "1.20.0", "1.26.0"})
+	version, err := bestCompatibleVersion(dep, []string{"1.19.0", "1.20.0", "1.26.0"}, false)
 	require.NoError(t, err)
 	assert.Equal(t, "1.26.0", version)
 }
@@ -175,7 +303,7 @@ func TestBestCompatibleVersionPipExact(t *testing.T) {
 			{Name: "flask", Op: types.ConstraintOpEq2, Version: "2.3.0"},
 		},
 	}
-	version, err := bestCompatibleVersion(dep, []string{"2.2.0", "2.3.0", "2.4.0"})
+	version, err := bestCompatibleVersion(dep, []string{"2.2.0", "2.3.0", "2.4.0"}, false)
 	require.NoError(t, err)
 	assert.Equal(t, "2.3.0", version)
 }
@@ -241,6 +369,46 @@ func TestSatisfiesDebRange(t *testing.T) {
 	assert.False(t, ok)
 }
 
+func TestVersionCacheCompareAptEpoch(t *testing.T) {
+	cache := newVersionCache(types.DependencyTypeApt)
+	assert.Equal(t, 1, cache.compare("1:2.0", "2.0"))
+	assert.Equal(t, 1, cache.compare("1:1.0", "3.0"))
+	assert.Equal(t, 0, cache.compare("0:2.0", "2.0"))
+}
+
+func TestSatisfiesDebEpoch(t *testing.T) {
+	cases := []struct {
+		name       string
+		op         types.ConstraintOp
+		constraint string
+		version    string
+		want       bool
+	}{
+		{name: "eq without epoch matches epoched", op: types.ConstraintOpEq, constraint: "1.2.3", version: "1:1.2.3", want: true},
+		{name: "eq2 without epoch matches epoched", op: types.ConstraintOpEq2, constraint: "1.2.3", version: "1:1.2.3", want: true},
+		{name: "eq without epoch rejects other version", op: types.ConstraintOpEq, constraint: "1.2.3", version: "1:1.2.4", want: false},
+		{name: "eq with epoch matches same epoch", op: types.ConstraintOpEq, constraint: "1:1.2.3", version: "1:1.2.3", want: true},
+		{name: "eq with epoch rejects missing epoch", op: types.ConstraintOpEq, constraint: "1:1.2.3", version: "1.2.3", want: false},
+		{name: "eq with epoch 0 rejects epoched", op: types.ConstraintOpEq, constraint: "0:1.2.3", version: "1:1.2.3", want: false},
+		{name: "gte without epoch matches epoched", op: types.ConstraintOpGte, constraint: "2.0", version: "1:2.0", want: true},
+		{name: "gte with epoch rejects missing epoch", op: types.ConstraintOpGte, constraint: "1:2.0", version: "3.0", want: false},
+		{name: "lte without epoch rejects epoched", op: types.ConstraintOpLte, constraint: "2.0", version: "1:1.0", want: false},
+		{name: "lt with epoch matches missing epoch", op: types.ConstraintOpLt, constraint: "1:2.0", version: "2.0", want: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := newVersionCache(types.DependencyTypeApt)
+			constraints, err := prepareConstraints(types.DependencyTypeApt, []types.Constraint{
+				{Name: "libfoo", Op: tc.op, Version: tc.constraint},
+			}, cache)
+			require.NoError(t, err)
+			ok, err := satisfiesDeb(tc.version, constraints, cache)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ok)
+		})
+	}
+}
+
 func TestSatisfiesDebNoConstraints(t *testing.T) {
 	cache := newVersionCache(types.DependencyTypeApt)
 	ok, err := satisfiesDeb("1.0.0", nil, cache)
@@ -318,3 +486,24 @@ func TestPrepareConstraintsUnsupportedType(t *testing.T) {
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "unsupported dependency type")
 }
+
+func TestAptDowngrades(t *testing.T) {
+	previous := map[string]string{
+		"libfoo": "2.0.0",
+		"libbar": "1:1.0",
+		"libbaz": "1.0.0",
+		"libqux": "1.0.0",
+	}
+	current := []types.AptLockEntry{
+		{Package: "libqux", Version: "1.0.0"},
+		{Package: "libfoo", Version: "1.9.9"},
+		{Package: "libbaz", Version: "1.1.0"},
+		{Package: "libbar", Version: "2.0"},
+		{Package: "libnew", Version: "0.1"},
+	}
+	want := []VersionDowngrade{
+		{Package: "libbar", Previous: "1:1.0", Current: "2.0"},
+		{Package: "libfoo", Previous: "2.0.0", Current: "1.9.9"},
+	}
+	assert.Equal(t, want, AptDowngrades(previous, current))
+}
@@ -16,6 +16,15 @@ const (
 	ActionBlock   = "block"
 )
 
+// IsBlock reports whether directive removes its dependency from the
+// resolution set.
+func IsBlock(directive types.ResolutionDirective) bool {
+	return strings.EqualFold(strings.TrimSpace(directive.Action), ActionBlock)
+}
+
+// ApplyResolution rewrites dep according to directive. A block directive
+// returns a zero Dependency and a record whose value is "excluded"; the
+// caller drops the dependency instead of resolving it.
 func ApplyResolution(dep types.Dependency, directive types.ResolutionDirective) (types.Dependency, types.ResolutionRecord, error) {
 	record := types.ResolutionRecord(directive)
 
@@ -46,9 +55,10 @@ func ApplyResolution(dep types.Dependency, directive types.ResolutionDirective)
 		dep.Constraints = []types.Constraint{}
 		return dep, record, nil
 	case ActionBlock:
-		return types.Dependency{}, record, errbuilder.New().
-			WithCode(errbuilder.CodePermissionDenied).
-			WithMsg(fmt.Sprintf("dependency blocked by directive: %s", dep.Name))
+		if record.Value == "" {
+			record.Value = "excluded"
+		}
+		return types.Dependency{}, record, nil
 	default:
 		return types.Dependency{}, record, errbuilder.New().
 			WithCode(errbuilder.CodeInvalidArgument).
@@ -0,0 +1,16 @@
+package ports
+
+import "context"
+
+// EnvironmentProbePort inspects the host toolchain, directories and
+// network reachability for the doctor command.
+type EnvironmentProbePort interface {
+	// ToolVersion runs name with args and returns the first line of its
+	// output, failing when name is not found in PATH.
+	ToolVersion(ctx context.Context, name string, args ...string) (string, error)
+	// CheckWritable creates dir when missing and verifies a file can be
+	// written to it.
+	CheckWritable(dir string) error
+	// CheckEndpoint verifies that endpoint answers an HTTP request.
+	CheckEndpoint(ctx context.Context, endpoint string) error
+}
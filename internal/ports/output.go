@@ -10,4 +10,7 @@ type OutputPort interface {
 	WriteSnapshotIntent(intent types.SnapshotIntent) error
 	WriteSnapshotSources(intent types.SnapshotIntent, baseURL string, component string, archs []string) error
 	WriteResolutionReport(report types.ResolutionReport) error
+	WriteCycloneDXSBOM(bom types.CycloneDXBOM) error
+	WriteResolveSummary(summary types.ResolveSummary) error
+	WriteEffectiveConfig(config types.EffectiveConfig) error
 }
@@ -9,6 +9,8 @@ import (
 type RepoIndexPort interface {
 	AvailableVersions(depType types.DependencyType, name string) ([]string, error)
 	AptPackages() (map[string][]types.AptPackageVersion, error)
+	PipPackages() (map[string][]types.PipPackageVersion, error)
+	PipAllowPrerelease() (bool, error)
 }
 
 type RepoSnapshotPort interface {
@@ -7,25 +7,50 @@ import (
 )
 
 type RepoIndexBuildRequest struct {
-	AptSources       []string
-	AptEndpoint      string
-	AptDistribution  string
-	AptComponent     string
-	AptArch          string
-	AptUser          string
-	AptAPIKey        string
-	AptWorkers       int
-	PipIndex         string
-	PipUser          string
-	PipAPIKey        string
-	PipPackages      []string
-	PipMax           int
-	PipWorkers       int
-	HTTPTimeoutSec   int
-	HTTPRetries      int
-	HTTPRetryDelayMs int
-	CacheDir         string
-	CacheTTLMinutes  int
+	AptSources              []string
+	AptDebDirs              []string
+	AptEndpoint             string
+	AptDistribution         string
+	AptComponent            string
+	AptArch                 string
+	AptUser                 string
+	AptAPIKey               string
+	AptAuthMode             string
+	AptWorkers              int
+	PipIndex                string
+	PipUser                 string
+	PipAPIKey               string
+	PipAuthMode             string
+	PipPackages             []string
+	PipMax                  int
+	PipWorkers              int
+	PipAllowPrerelease      bool
+	PipMetadata             bool
+	HTTPTimeoutSec          int
+	HTTPRetries             int
+	HTTPRetryDelayMs        int
+	HTTPMaxIdleConnsPerHost int
+	HTTPIdleConnTimeoutSec  int
+	HTTPDisableHTTP2        bool
+	HTTPAuthRedirectHosts   []string
+	HTTPProxy               string
+	HTTPCABundle            string
+	RateLimitBytesPerSec    int
+	CacheDir                string
+	CacheTTLMinutes         int
+	UserAgent               string
+	// HostCredentials maps a host name to the credentials sent to it,
+	// overriding the apt and pip credentials for that host.
+	HostCredentials map[string]HostCredential
+	// Offline serves every fetch from CacheDir, regardless of its TTL,
+	// and fails on a cache miss instead of making a network request.
+	Offline bool
+}
+
+// HostCredential is the user and API key (or token) of one host.
+type HostCredential struct {
+	User   string
+	APIKey string
 }
 
 type RepoIndexBuilderPort interface {
@@ -20,10 +20,16 @@ type SchemaResolverPort interface {
 	// later loads win per key.
 	LoadSchemaInline(schema types.SchemaFile) error
 
-	// Resolve maps a single abstract key to a concrete typed Dependency.
-	// Returns (dep, true, nil) on hit, (zero, false, nil) on miss, or
-	// (zero, false, err) on failure.
-	Resolve(key string) (types.Dependency, bool, error)
+	// SetTarget selects the target Ubuntu release whose per-target
+	// mapping overrides Resolve and ResolveAll apply. An empty target
+	// uses the top-level packages only.
+	SetTarget(target string)
+
+	// Resolve maps a single abstract key to its concrete typed
+	// Dependencies, one per package the mapping lists. Returns
+	// (deps, true, nil) on hit, (nil, false, nil) on miss, or
+	// (nil, false, err) on failure.
+	Resolve(key string) ([]types.Dependency, bool, error)
 
 	// ResolveAll maps a batch of ROSTagDependency keys.  Unknown keys are
 	// collected and returned as the second value so callers can decide
@@ -4,15 +4,34 @@ package shared
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
-// NormalizePipName lowercases a Python package name and replaces
-// underscores and dots with hyphens, following PEP 503 normalization.
+// UserAgentProduct is the product token sent in the User-Agent header of
+// every outbound HTTP request.
+const UserAgentProduct = "avular-packages"
+
+// UserAgent returns the User-Agent header value for the given tool
+// version, e.g. "avular-packages/1.2.3".
+func UserAgent(version string) string {
+	trimmed := strings.TrimSpace(version)
+	if trimmed == "" {
+		return UserAgentProduct
+	}
+	return UserAgentProduct + "/" + trimmed
+}
+
+// pipNameSeparators matches runs of the characters PEP 503 treats as
+// equivalent separators.
+var pipNameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizePipName lowercases a Python package name and replaces each run
+// of "-", "_" and "." with a single hyphen, following PEP 503
+// normalization.
 func NormalizePipName(value string) string {
 	lower := strings.ToLower(strings.TrimSpace(value))
-	replacer := strings.NewReplacer("_", "-", ".", "-")
-	return replacer.Replace(lower)
+	return pipNameSeparators.ReplaceAllString(lower, "-")
 }
 
 // HTTPStatusError creates a formatted error for non-2xx HTTP responses.
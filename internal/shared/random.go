@@ -0,0 +1,36 @@
+package shared
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// randomSource backs every randomized decision of the tool, such as
+// retry jitter. It is seeded from the clock unless SetRandomSeed pins it.
+var randomSource = struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}{rng: newRandom(uint64(time.Now().UnixNano()))} //nolint:gosec // the clock only seeds non-cryptographic jitter
+
+func newRandom(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed)) //nolint:gosec // jitter does not need a cryptographic source
+}
+
+// SetRandomSeed reseeds the shared random source so that runs with the
+// same seed make the same random choices.
+func SetRandomSeed(seed int64) {
+	randomSource.mu.Lock()
+	defer randomSource.mu.Unlock()
+	randomSource.rng = newRandom(uint64(seed)) //nolint:gosec // negative seeds are as good as positive ones
+}
+
+// Jitter returns a random duration in [0, limit].
+func Jitter(limit time.Duration) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	randomSource.mu.Lock()
+	defer randomSource.mu.Unlock()
+	return time.Duration(randomSource.rng.Int64N(int64(limit) + 1))
+}
@@ -12,3 +12,10 @@ type Dependency struct {
 	Type        DependencyType
 	Constraints []Constraint
 }
+
+// UnresolvedDependency is a dependency a best-effort or allow-unresolved
+// run left out, together with the reason no version was selected.
+type UnresolvedDependency struct {
+	Dependency
+	Reason string
+}
@@ -35,3 +35,17 @@ const (
 	ConstraintOpGt     ConstraintOp = ">"
 	ConstraintOpLt     ConstraintOp = "<"
 )
+
+// PackageXMLConstraintPolicy controls how version constraints declared
+// in package.xml files are enforced during resolution.
+type PackageXMLConstraintPolicy string
+
+const (
+	// PackageXMLConstraintsHard enforces package.xml constraints as
+	// written.
+	PackageXMLConstraintsHard PackageXMLConstraintPolicy = "hard"
+	// PackageXMLConstraintsSoft treats package.xml constraints as minimum
+	// hints: pins become lower bounds and upper bounds are dropped, so the
+	// resolver may upgrade.
+	PackageXMLConstraintsSoft PackageXMLConstraintPolicy = "soft"
+)
@@ -39,3 +39,148 @@ type ResolutionRecord struct {
 type ResolutionReport struct {
 	Records []ResolutionRecord
 }
+
+// ResolveSummarySchemaVersion is bumped whenever a field of
+// ResolveSummary is renamed or removed; adding fields keeps the version.
+const ResolveSummarySchemaVersion = 1
+
+// ResolveSummary is the resolve.json document: one machine-readable view
+// of apt.lock, the resolved dependencies and resolution.report.
+type ResolveSummary struct {
+	SchemaVersion int                        `json:"schema_version"`
+	SnapshotID    string                     `json:"snapshot_id"`
+	AptLocks      []ResolveSummaryLock       `json:"apt_locks"`
+	Dependencies  []ResolveSummaryDependency `json:"dependencies"`
+	Resolutions   []ResolveSummaryRecord     `json:"resolutions"`
+}
+
+// ResolveSummaryLock is one apt.lock entry.
+type ResolveSummaryLock struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+}
+
+// ResolveSummaryDependency is a resolved dependency with its type
+// ("apt" or "pip") and the name it was demanded under.
+type ResolveSummaryDependency struct {
+	Type    string `json:"type"`
+	Package string `json:"package"`
+	Version string `json:"version"`
+}
+
+// ResolveSummaryRecord is an applied resolution directive.
+type ResolveSummaryRecord struct {
+	Dependency string `json:"dependency"`
+	Action     string `json:"action"`
+	Value      string `json:"value,omitempty"`
+	Reason     string `json:"reason"`
+	Owner      string `json:"owner"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+}
+
+// DependencyDump is the collected dependency set printed by
+// `resolve --dump-deps`, before any version is resolved.
+type DependencyDump struct {
+	Dependencies []DependencyDumpEntry `json:"dependencies" yaml:"dependencies"`
+}
+
+// DependencyDumpEntry is one collected dependency with its constraints
+// and the inputs (e.g. manual, package.xml) that demanded it.
+type DependencyDumpEntry struct {
+	Name        string                     `json:"name" yaml:"name"`
+	Type        string                     `json:"type" yaml:"type"`
+	Constraints []DependencyDumpConstraint `json:"constraints" yaml:"constraints"`
+	Sources     []string                   `json:"sources" yaml:"sources"`
+}
+
+// DependencyDumpConstraint is a version constraint on a collected
+// dependency; Op and Version are empty for an unversioned demand.
+type DependencyDumpConstraint struct {
+	Op      string `json:"op,omitempty" yaml:"op,omitempty"`
+	Version string `json:"version,omitempty" yaml:"version,omitempty"`
+	Source  string `json:"source" yaml:"source"`
+}
+
+// BuildFailureSummary is the build-failures.json document written when
+// a deb build fails, listing every package that failed.
+type BuildFailureSummary struct {
+	Failures []BuildFailure `json:"failures"`
+}
+
+// BuildFailure is one failed package build: the deb or packaging group
+// that failed, the stage (resolve, install or dpkg-deb) and the error
+// with any captured tool output.
+type BuildFailure struct {
+	Package string `json:"package"`
+	Stage   string `json:"stage"`
+	Error   string `json:"error"`
+	Output  string `json:"output,omitempty"`
+}
+
+// EffectiveConfig is the effective-config.yaml written by resolve: every
+// input that determined the outputs, after spec defaults were applied,
+// so a run can be audited and reproduced.
+type EffectiveConfig struct {
+	ToolVersion string                   `yaml:"tool_version"`
+	ProductPath string                   `yaml:"product_path"`
+	SnapshotID  string                   `yaml:"snapshot_id"`
+	RepoIndex   EffectiveRepoIndex       `yaml:"repo_index"`
+	Settings    EffectiveResolveSettings `yaml:"settings"`
+	Spec        Spec                     `yaml:"spec"`
+}
+
+// EffectiveRepoIndex identifies the repo index file a run resolved
+// against by path and content hash.
+type EffectiveRepoIndex struct {
+	Path   string `yaml:"path"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// EffectiveResolveSettings are the resolve options in effect for a run.
+type EffectiveResolveSettings struct {
+	Profiles        []string `yaml:"profiles,omitempty"`
+	Workspace       []string `yaml:"workspace,omitempty"`
+	TargetUbuntu    string   `yaml:"target_ubuntu"`
+	OutputDir       string   `yaml:"output"`
+	SchemaFiles     []string `yaml:"schema_files,omitempty"`
+	AptSatSolver    bool     `yaml:"apt_sat_solver"`
+	PipSatSolver    bool     `yaml:"pip_sat_solver"`
+	PreferLock      string   `yaml:"prefer_lock,omitempty"`
+	FailOnDowngrade bool     `yaml:"fail_on_downgrade,omitempty"`
+	BasePackages    []string `yaml:"base_packages,omitempty"`
+	AssumeEssential bool     `yaml:"assume_essential,omitempty"`
+	AllowUnresolved bool     `yaml:"allow_unresolved"`
+	BestEffort      bool     `yaml:"best_effort"`
+	NoPip           bool     `yaml:"no_pip"`
+	AllowedScopes   []string `yaml:"allowed_scopes,omitempty"`
+}
+
+// DependencyGraph is the apt dependency graph reachable from a set of
+// root demands, with the versions chosen by the solver marked. When the
+// demands are unsatisfiable nothing is marked, Unsatisfiable is set and
+// Conflict lists the clauses of the unsat core.
+type DependencyGraph struct {
+	Roots         []string
+	Nodes         []DependencyGraphNode
+	Edges         []DependencyGraphEdge
+	Unsatisfiable bool
+	Conflict      []string
+}
+
+type DependencyGraphNode struct {
+	Package  string
+	Version  string
+	Root     bool
+	Selected bool
+}
+
+// DependencyGraphEdge links a package version to one candidate of a
+// Depends or Pre-Depends group; Relation is the group as written.
+type DependencyGraphEdge struct {
+	FromPackage string
+	FromVersion string
+	ToPackage   string
+	ToVersion   string
+	Relation    string
+	PreDepends  bool
+}
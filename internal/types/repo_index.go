@@ -4,6 +4,11 @@ type RepoIndexFile struct {
 	Apt         map[string][]string            `yaml:"apt"`
 	AptPackages map[string][]AptPackageVersion `yaml:"apt_packages,omitempty"`
 	Pip         map[string][]string            `yaml:"pip"`
+	PipPackages map[string][]PipPackageVersion `yaml:"pip_packages,omitempty"`
+	// PipAllowPrerelease lets open pip constraints such as ">=1.0" select
+	// pre-release and dev versions; otherwise they are only selected when
+	// a constraint names one.
+	PipAllowPrerelease bool `yaml:"pip_allow_prerelease,omitempty"`
 }
 
 type AptPackageVersion struct {
@@ -11,4 +16,17 @@ type AptPackageVersion struct {
 	Depends    []string `yaml:"depends,omitempty"`
 	PreDepends []string `yaml:"pre_depends,omitempty"`
 	Provides   []string `yaml:"provides,omitempty"`
+	Breaks     []string `yaml:"breaks,omitempty"`
+	Conflicts  []string `yaml:"conflicts,omitempty"`
+	// Essential and Priority mirror the Packages fields; minimal base
+	// images ship every Essential or Priority: required package.
+	Essential bool   `yaml:"essential,omitempty"`
+	Priority  string `yaml:"priority,omitempty"`
+}
+
+// PipPackageVersion carries the Requires-Dist metadata of one pip release
+// so the pip solver can follow transitive requirements.
+type PipPackageVersion struct {
+	Version      string   `yaml:"version"`
+	RequiresDist []string `yaml:"requires_dist,omitempty"`
 }
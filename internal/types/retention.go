@@ -10,12 +10,17 @@ type SnapshotInfo struct {
 	CreatedAt  time.Time
 }
 
+// SnapshotRetentionPolicy selects the snapshots a prune keeps. A
+// snapshot is kept when any rule matches; snapshots a channel points at
+// are always kept unless Force is set.
 type SnapshotRetentionPolicy struct {
 	KeepLast        int
 	KeepDays        int
+	KeepWithin      time.Duration
 	ProtectChannels []string
 	ProtectPrefixes []string
 	DryRun          bool
+	Force           bool
 }
 
 type SnapshotPrunePlan struct {
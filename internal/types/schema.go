@@ -6,7 +6,7 @@ package types
 //
 // Keys are plain names such as "fmt", "rclcpp", or "numpy".  The schema
 // resolver looks up each key in the mapping table and emits a typed
-// Dependency (apt or pip) with the resolved package name and optional
+// Dependency (apt or pip) per resolved package name, with the optional
 // version constraint.
 type SchemaMapping struct {
 	// Type is the target dependency type: "apt" or "pip".
@@ -15,12 +15,30 @@ type SchemaMapping struct {
 	// Package is the concrete package name in the target ecosystem.
 	// For apt: e.g. "libfmt-dev", "ros-humble-rclcpp".
 	// For pip: e.g. "numpy", "flask".
-	Package string `yaml:"package"`
+	Package string `yaml:"package,omitempty"`
+
+	// Packages lists several concrete packages for keys that need more
+	// than one, e.g. "boost" -> libboost-dev, libboost-system-dev. Each
+	// gets the same Version constraint. Set either Package or Packages.
+	Packages []string `yaml:"packages,omitempty"`
 
 	// Version is an optional version constraint string.
 	// Examples: ">=9.1.0", "==1.26.4", ">=1.0,<2.0".
 	// If empty, no version constraint is applied.
 	Version string `yaml:"version,omitempty"`
+
+	// Targets optionally overrides the package(s) per target Ubuntu
+	// release, keyed like "ubuntu-22.04" or "24.04". The override for
+	// the resolve target replaces Package/Packages, and Version when it
+	// sets one; without a matching override the top-level fields apply.
+	Targets map[string]SchemaTargetMapping `yaml:"targets,omitempty"`
+}
+
+// SchemaTargetMapping is the target-specific part of a SchemaMapping.
+type SchemaTargetMapping struct {
+	Package  string   `yaml:"package,omitempty"`
+	Packages []string `yaml:"packages,omitempty"`
+	Version  string   `yaml:"version,omitempty"`
 }
 
 // SchemaFile is the top-level structure of a schema.yaml file.
@@ -52,6 +70,23 @@ const (
 	ROSDepScopeAll       ROSDepScope = "all"
 )
 
+// Includes reports whether a dependency of scope s is needed in phase
+// (build, exec or test). A plain <depend> (all) and a
+// build_export_depend (build_exec) are needed for both build and exec;
+// neither covers test.
+func (s ROSDepScope) Includes(phase ROSDepScope) bool {
+	if s == phase {
+		return true
+	}
+	switch s {
+	case ROSDepScopeAll:
+		return phase == ROSDepScopeBuild || phase == ROSDepScopeExec || phase == ROSDepScopeBuildExec
+	case ROSDepScopeBuildExec:
+		return phase == ROSDepScopeBuild || phase == ROSDepScopeExec
+	}
+	return false
+}
+
 // ROSTagDependency is a raw dependency key extracted from a standard ROS
 // package.xml tag.  It carries the abstract name plus the scope derived
 // from the XML element name.
@@ -61,4 +96,8 @@ type ROSTagDependency struct {
 
 	// Scope indicates which lifecycle phase needs this dependency.
 	Scope ROSDepScope
+
+	// Constraints holds the bounds declared through the version_lt,
+	// version_lte, version_eq, version_gte and version_gt attributes.
+	Constraints []Constraint
 }
@@ -31,6 +31,10 @@ type ComposeRef struct {
 	Source  string `yaml:"source"`
 	Path    string `yaml:"path"`
 
+	// URL locates the profile YAML for "http" sources and the repository
+	// for "git" sources, where it takes precedence over Name.
+	URL string `yaml:"url,omitempty"`
+
 	// Profile holds an inline profile definition when Source is "inline".
 	// This allows simple products to embed their packaging policy
 	// directly without a separate profile file.
@@ -78,6 +82,12 @@ type PackagingGroup struct {
 	Matches []string      `yaml:"matches"`
 	Targets []string      `yaml:"targets"`
 	Pins    []string      `yaml:"pins,omitempty"`
+
+	// MaintainerScripts points at a directory holding optional preinst,
+	// postinst, prerm and postrm scripts for the group's debs. A
+	// <package>/ subdirectory overrides the group-level scripts for a
+	// single pip package.
+	MaintainerScripts string `yaml:"maintainer_scripts,omitempty"`
 }
 
 type Packaging struct {
@@ -100,8 +110,19 @@ type PublishRepository struct {
 	SigningKey     string `yaml:"signing_key"`
 }
 
+// DebControl holds optional control fields applied to every generated
+// deb. Empty fields are omitted from the control file, except Maintainer
+// which falls back to "avular".
+type DebControl struct {
+	Maintainer string `yaml:"maintainer,omitempty"`
+	Section    string `yaml:"section,omitempty"`
+	Priority   string `yaml:"priority,omitempty"`
+	Homepage   string `yaml:"homepage,omitempty"`
+}
+
 type Publish struct {
 	Repository PublishRepository `yaml:"repository"`
+	Control    DebControl        `yaml:"control,omitempty"`
 }
 
 type Spec struct {
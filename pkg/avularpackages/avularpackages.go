@@ -0,0 +1,181 @@
+// Package avularpackages is the library API of avular-packages. It runs
+// the validate, resolve, build and publish flows of the CLI in-process,
+// with every adapter replaceable through options and without any flag or
+// config file handling.
+//
+// A Client built without options behaves like the CLI: specs, workspaces
+// and repo indexes are read from disk. Requests are plain structs; zero
+// fields take the same defaults as the corresponding CLI flags, and spec
+// defaults apply exactly as they do on the command line.
+package avularpackages
+
+import (
+	"context"
+	"time"
+
+	"avular-packages/internal/adapters"
+	"avular-packages/internal/app"
+	"avular-packages/internal/ports"
+	"avular-packages/internal/types"
+)
+
+// Requests and results of the Client methods. They are the structs the
+// CLI fills from its flags, so every field documented there is available.
+type (
+	ValidateRequest = app.ValidateRequest
+	ValidateResult  = app.ValidateResult
+	ResolveRequest  = app.ResolveRequest
+	ResolveResult   = app.ResolveResult
+	BuildRequest    = app.BuildRequest
+	BuildResult     = app.BuildResult
+	PublishRequest  = app.PublishRequest
+	PublishResult   = app.PublishResult
+	// Warning is a non-fatal finding; results carry them in Warnings
+	// instead of printing them.
+	Warning = app.Warning
+)
+
+// Spec and dependency types needed to implement the adapter interfaces.
+type (
+	Spec                 = types.Spec
+	Dependency           = types.Dependency
+	UnresolvedDependency = types.UnresolvedDependency
+	RepoIndexFile        = types.RepoIndexFile
+	AptPackageVersion    = types.AptPackageVersion
+	PipPackageVersion    = types.PipPackageVersion
+	SnapshotIntent       = types.SnapshotIntent
+)
+
+// Adapter interfaces a Client can be configured with.
+type (
+	ProductSpecLoader = ports.ProductSpecPort
+	ProfileSource     = ports.ProfileSourcePort
+	Workspace         = ports.WorkspacePort
+	PackageXML        = ports.PackageXMLPort
+	SchemaResolver    = ports.SchemaResolverPort
+	OutputReader      = ports.OutputReaderPort
+	SBOMWriter        = ports.SBOMPort
+	InternalDebs      = ports.InternalDebsPort
+	RepoIndex         = ports.RepoIndexPort
+	RepoSnapshot      = ports.RepoSnapshotPort
+)
+
+// RepoBackendFactory builds the RepoSnapshot a publish request is pushed
+// to; see WithRepoBackend.
+type RepoBackendFactory = app.RepoBackendFactory
+
+// Client runs the avular-packages flows with a fixed set of adapters. It
+// holds no per-call state and is safe to reuse.
+type Client struct {
+	service app.Service
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// New returns a Client using the file-based adapters of the CLI, with
+// opts applied on top.
+func New(opts ...Option) *Client {
+	client := &Client{service: app.NewService()}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+// WithSpecLoader replaces how product specs are loaded.
+func WithSpecLoader(loader ProductSpecLoader) Option {
+	return func(c *Client) { c.service.SpecLoader = loader }
+}
+
+// WithProfileSource replaces how the profiles of a product are loaded.
+func WithProfileSource(source ProfileSource) Option {
+	return func(c *Client) { c.service.ProfileSource = source }
+}
+
+// WithWorkspace replaces package.xml discovery in workspace roots.
+func WithWorkspace(workspace Workspace) Option {
+	return func(c *Client) { c.service.Workspace = workspace }
+}
+
+// WithPackageXML replaces package.xml parsing.
+func WithPackageXML(parser PackageXML) Option {
+	return func(c *Client) { c.service.PackageXML = parser }
+}
+
+// WithSchemaResolver replaces the ROS tag schema resolver. A nil
+// resolver disables schema mapping.
+func WithSchemaResolver(resolver SchemaResolver) Option {
+	return func(c *Client) { c.service.SchemaResolver = resolver }
+}
+
+// WithOutputReader replaces how resolve outputs are read back by build
+// and publish.
+func WithOutputReader(reader OutputReader) Option {
+	return func(c *Client) { c.service.OutputReader = reader }
+}
+
+// WithSBOMWriter replaces the SPDX SBOM writer used by publish.
+func WithSBOMWriter(writer SBOMWriter) Option {
+	return func(c *Client) { c.service.SBOMWriter = writer }
+}
+
+// WithInternalDebs replaces how internal debs are copied and built.
+func WithInternalDebs(debs InternalDebs) Option {
+	return func(c *Client) { c.service.InternalDebs = debs }
+}
+
+// WithRepoIndex replaces how the repo index named in a request is
+// opened. load receives the request's repo index path.
+func WithRepoIndex(load func(path string) RepoIndex) Option {
+	return func(c *Client) { c.service.RepoIndexLoad = load }
+}
+
+// NewMemoryRepoIndex returns a RepoIndex serving index from memory, for
+// use with WithRepoIndex. With AptPackages filled in, the apt SAT solver
+// follows Depends and Provides exactly as for a repo index file.
+func NewMemoryRepoIndex(index RepoIndexFile) RepoIndex {
+	return adapters.NewRepoIndexMemoryAdapter(index)
+}
+
+// WithRepoBackend registers factory under name, so publish requests
+// with RepoBackend set to name go through the RepoSnapshot it returns.
+// The built-in file, aptly and proget backends can be replaced this way.
+func WithRepoBackend(name string, factory RepoBackendFactory) Option {
+	return func(c *Client) {
+		if c.service.RepoBackends == nil {
+			c.service.RepoBackends = app.DefaultRepoBackends()
+		}
+		c.service.RepoBackends.Register(name, factory)
+	}
+}
+
+// WithClock replaces the clock used for snapshot timestamps and
+// directive expiry.
+func WithClock(now func() time.Time) Option {
+	return func(c *Client) { c.service.Clock = now }
+}
+
+// Validate loads and composes a product spec and checks it, without
+// resolving.
+func (c *Client) Validate(ctx context.Context, req ValidateRequest) (ValidateResult, error) {
+	return c.service.Validate(ctx, req)
+}
+
+// Resolve resolves a product's dependencies against a repo index and
+// writes the lock outputs to req.OutputDir.
+func (c *Client) Resolve(ctx context.Context, req ResolveRequest) (ResolveResult, error) {
+	return c.service.Resolve(ctx, req)
+}
+
+// Build resolves (when a product is given) and builds debs into
+// req.DebsDir or <output>/debs.
+func (c *Client) Build(ctx context.Context, req BuildRequest) (BuildResult, error) {
+	return c.service.Build(ctx, req)
+}
+
+// Publish publishes the snapshot described by req.OutputDir to the
+// configured repo backend.
+func (c *Client) Publish(ctx context.Context, req PublishRequest) (PublishResult, error) {
+	return c.service.Publish(ctx, req)
+}
@@ -0,0 +1,169 @@
+package avularpackages
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+
+	"avular-packages/internal/types"
+)
+
+type fakeSpecLoader struct {
+	product types.Spec
+}
+
+func (f fakeSpecLoader) LoadProduct(string) (types.Spec, error) {
+	return f.product, nil
+}
+
+type fakeProfileSource struct {
+	profiles []types.Spec
+}
+
+func (f fakeProfileSource) LoadProfiles(types.Spec, []string) ([]types.Spec, error) {
+	return f.profiles, nil
+}
+
+type fakeRepoIndex struct {
+	apt map[string][]string
+}
+
+func (f fakeRepoIndex) AvailableVersions(depType types.DependencyType, name string) ([]string, error) {
+	if depType != types.DependencyTypeApt {
+		return nil, nil
+	}
+	return f.apt[name], nil
+}
+
+func (f fakeRepoIndex) AptPackages() (map[string][]types.AptPackageVersion, error) {
+	return map[string][]types.AptPackageVersion{}, nil
+}
+
+func (f fakeRepoIndex) PipPackages() (map[string][]types.PipPackageVersion, error) {
+	return map[string][]types.PipPackageVersion{}, nil
+}
+
+func (f fakeRepoIndex) PipAllowPrerelease() (bool, error) {
+	return false, nil
+}
+
+func TestClientResolveWithFakeAdapters(t *testing.T) {
+	product := types.Spec{
+		APIVersion: "v1",
+		Kind:       types.SpecKindProduct,
+		Metadata:   types.Metadata{Name: "embedded", Version: "1.0.0", Owners: []string{"platform"}},
+		Compose:    []types.ComposeRef{{Name: "base", Version: "1.0.0", Source: "local", Path: "profile.yaml"}},
+		Inputs: types.Inputs{Manual: types.ManualInputs{
+			Apt: []string{"libfoo>=1.0", "libbar"},
+		}},
+		Publish: types.Publish{Repository: types.PublishRepository{
+			Name:           "avular",
+			Channel:        "dev",
+			SnapshotPrefix: "embedded",
+			SigningKey:     "avular-release",
+		}},
+	}
+	profile := types.Spec{
+		APIVersion: "v1",
+		Kind:       types.SpecKindProfile,
+		Metadata:   types.Metadata{Name: "base", Version: "1.0.0", Owners: []string{"platform"}},
+		Packaging: types.Packaging{Groups: []types.PackagingGroup{
+			{Name: "apt-individual", Mode: types.PackagingModeIndividual, Scope: "runtime", Matches: []string{"apt:*"}, Targets: []string{"24.04"}},
+		}},
+	}
+	repo := fakeRepoIndex{apt: map[string][]string{
+		"libfoo": {"0.9", "1.2"},
+		"libbar": {"2.0"},
+	}}
+	var openedIndex string
+	client := New(
+		WithSpecLoader(fakeSpecLoader{product: product}),
+		WithProfileSource(fakeProfileSource{profiles: []types.Spec{profile}}),
+		WithRepoIndex(func(path string) RepoIndex {
+			openedIndex = path
+			return repo
+		}),
+		WithClock(func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }),
+	)
+
+	outDir := t.TempDir()
+	result, err := client.Resolve(t.Context(), ResolveRequest{
+		ProductPath:  "product.yaml",
+		RepoIndex:    "memory",
+		OutputDir:    outDir,
+		TargetUbuntu: "24.04",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "memory", openedIndex)
+	require.Equal(t, "embedded", result.ProductName)
+	require.NotEmpty(t, result.SnapshotID)
+	require.Empty(t, result.Warnings)
+
+	data, err := os.ReadFile(filepath.Join(outDir, "apt.lock"))
+	require.NoError(t, err)
+	if diff := cmp.Diff("libbar=2.0\nlibfoo=1.2", string(data)); diff != "" {
+		t.Fatalf("unexpected apt.lock (-want +got):\n%s", diff)
+	}
+
+	validated, err := client.Validate(t.Context(), ValidateRequest{ProductPath: "product.yaml"})
+	require.NoError(t, err)
+	require.Equal(t, "embedded", validated.ProductName)
+}
+
+func TestClientResolveWithMemoryRepoIndex(t *testing.T) {
+	product := types.Spec{
+		APIVersion: "v1",
+		Kind:       types.SpecKindProduct,
+		Metadata:   types.Metadata{Name: "embedded", Version: "1.0.0", Owners: []string{"platform"}},
+		Compose:    []types.ComposeRef{{Name: "base", Version: "1.0.0", Source: "local", Path: "profile.yaml"}},
+		Inputs: types.Inputs{Manual: types.ManualInputs{
+			Apt: []string{"app"},
+		}},
+		Publish: types.Publish{Repository: types.PublishRepository{
+			Name:           "avular",
+			Channel:        "dev",
+			SnapshotPrefix: "embedded",
+			SigningKey:     "avular-release",
+		}},
+	}
+	profile := types.Spec{
+		APIVersion: "v1",
+		Kind:       types.SpecKindProfile,
+		Metadata:   types.Metadata{Name: "base", Version: "1.0.0", Owners: []string{"platform"}},
+		Packaging: types.Packaging{Groups: []types.PackagingGroup{
+			{Name: "apt-individual", Mode: types.PackagingModeIndividual, Scope: "runtime", Matches: []string{"apt:*"}, Targets: []string{"24.04"}},
+		}},
+	}
+	index := NewMemoryRepoIndex(RepoIndexFile{
+		AptPackages: map[string][]AptPackageVersion{
+			"app":    {{Version: "1.0", Depends: []string{"libfoo (>= 2.0)"}}},
+			"libfoo": {{Version: "1.5"}, {Version: "2.1", Depends: []string{"libbar"}}},
+			"libbar": {{Version: "3.0"}},
+		},
+	})
+	client := New(
+		WithSpecLoader(fakeSpecLoader{product: product}),
+		WithProfileSource(fakeProfileSource{profiles: []types.Spec{profile}}),
+		WithRepoIndex(func(string) RepoIndex { return index }),
+	)
+
+	outDir := t.TempDir()
+	_, err := client.Resolve(t.Context(), ResolveRequest{
+		ProductPath:  "product.yaml",
+		RepoIndex:    "memory",
+		OutputDir:    outDir,
+		TargetUbuntu: "24.04",
+		AptSatSolver: true,
+	})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(outDir, "apt.lock"))
+	require.NoError(t, err)
+	if diff := cmp.Diff("app=1.0\nlibbar=3.0\nlibfoo=2.1", string(data)); diff != "" {
+		t.Fatalf("unexpected apt.lock (-want +got):\n%s", diff)
+	}
+}
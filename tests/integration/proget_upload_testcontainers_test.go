@@ -24,6 +24,7 @@ import (
 	"avular-packages/internal/adapters"
 	"avular-packages/internal/app"
 	"avular-packages/internal/core"
+	"avular-packages/internal/shared"
 	"avular-packages/internal/types"
 	"avular-packages/tests/testutil"
 )
@@ -707,14 +708,14 @@ func buildReplaceMap(directives []types.ResolutionDirective) map[string]string {
 		depType := strings.ToLower(strings.TrimSpace(parts[0]))
 		name := strings.TrimSpace(parts[1])
 		if depType == "pip" {
-			name = normalizePipName(name)
+			name = shared.NormalizePipName(name)
 		}
 		replacement := strings.TrimSpace(directive.Value)
 		if replacement == "" {
 			continue
 		}
 		if depType == "pip" {
-			replacement = normalizePipName(replacement)
+			replacement = shared.NormalizePipName(replacement)
 		}
 		out[depType+":"+name] = replacement
 	}
@@ -733,7 +734,7 @@ func buildVersionIndex(entries []string, depType types.DependencyType, replaceMa
 			continue
 		}
 		if depType == types.DependencyTypePip {
-			name = normalizePipName(name)
+			name = shared.NormalizePipName(name)
 		}
 		key := depTypeKey(depType, name)
 		if replacement, ok := replaceMap[key]; ok {
@@ -965,17 +966,11 @@ func listDirFiles(dir string) (map[string]struct{}, error) {
 }
 
 func moduleNameFromPackageName(value string) string {
-	normalized := normalizePipName(value)
+	normalized := shared.NormalizePipName(value)
 	replacer := strings.NewReplacer("-", "_", ".", "_")
 	return replacer.Replace(normalized)
 }
 
-func normalizePipName(value string) string {
-	lower := strings.ToLower(strings.TrimSpace(value))
-	replacer := strings.NewReplacer("_", "-", ".", "-")
-	return replacer.Replace(lower)
-}
-
 func mapKeys(values map[string][]string) []string {
 	var keys []string
 	for key := range values {